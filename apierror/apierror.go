@@ -0,0 +1,169 @@
+// Package apierror defines the catalog of machine readable error codes
+// returned by the API, and the error type handlers use to report them.
+//
+// Codes are part of the API contract: clients match on them instead of
+// messages, so existing codes must never be renamed or change meaning.
+package apierror
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+type Code string
+
+const (
+	CodeInvalidRequest       Code = "invalid_request"
+	CodeValidationFailed     Code = "validation_failed"
+	CodeMissingToken         Code = "missing_token"
+	CodeInvalidToken         Code = "invalid_token"
+	CodeForbidden            Code = "forbidden"
+	CodeInvalidCredentials   Code = "invalid_credentials"
+	CodeNotFound             Code = "not_found"
+	CodeRouteNotFound        Code = "route_not_found"
+	CodeMethodNotAllowed     Code = "method_not_allowed"
+	CodePhoneNumberTaken     Code = "phone_number_taken"
+	CodeConflict             Code = "conflict"
+	CodeVersionConflict      Code = "version_conflict"
+	CodeIdempotencyKeyReused Code = "idempotency_key_reused"
+	CodeIdempotencyKeyInUse  Code = "idempotency_key_in_use"
+	CodeUnsupportedMediaType Code = "unsupported_media_type"
+	CodeServiceUnavailable   Code = "service_unavailable"
+	CodeInternal             Code = "internal_error"
+)
+
+// Definition is the catalog entry of a code: the HTTP status it is served
+// with and a short, human readable summary that does not change between
+// occurrences. The title is translated by the i18n catalog, see Code.Title.
+type Definition struct {
+	Status int
+	Title  string
+}
+
+var catalog = map[Code]int{
+	CodeInvalidRequest:       http.StatusBadRequest,
+	CodeValidationFailed:     http.StatusBadRequest,
+	CodeMissingToken:         http.StatusForbidden,
+	CodeInvalidToken:         http.StatusForbidden,
+	CodeForbidden:            http.StatusForbidden,
+	CodeInvalidCredentials:   http.StatusBadRequest,
+	CodeNotFound:             http.StatusNotFound,
+	CodeRouteNotFound:        http.StatusNotFound,
+	CodeMethodNotAllowed:     http.StatusMethodNotAllowed,
+	CodePhoneNumberTaken:     http.StatusConflict,
+	CodeConflict:             http.StatusConflict,
+	CodeVersionConflict:      http.StatusPreconditionFailed,
+	CodeIdempotencyKeyReused: http.StatusUnprocessableEntity,
+	CodeIdempotencyKeyInUse:  http.StatusConflict,
+	CodeUnsupportedMediaType: http.StatusUnsupportedMediaType,
+	CodeServiceUnavailable:   http.StatusServiceUnavailable,
+	CodeInternal:             http.StatusInternalServerError,
+}
+
+// Lookup returns the catalog entry of the code, with the English title.
+// Unknown codes are reported as internal errors.
+func Lookup(code Code) Definition {
+	status, ok := catalog[code]
+	if !ok {
+		code, status = CodeInternal, catalog[CodeInternal]
+	}
+	return Definition{Status: status, Title: code.Title(i18n.English)}
+}
+
+// Codes returns every code of the catalog, sorted.
+func Codes() []Code {
+	codes := make([]Code, 0, len(catalog))
+	for code := range catalog {
+		codes = append(codes, code)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i] < codes[j] })
+	return codes
+}
+
+// Type returns the problem type URI of the code, relative to the API root.
+func (c Code) Type() string {
+	return "/errors/" + string(c)
+}
+
+// TitleKey returns the i18n catalog key of the title of the code.
+func (c Code) TitleKey() i18n.Key {
+	return i18n.Key("error." + string(c))
+}
+
+// Title returns the title of the code in the language.
+func (c Code) Title(language i18n.Language) string {
+	if _, ok := catalog[c]; !ok {
+		c = CodeInternal
+	}
+	return i18n.Translate(language, c.TitleKey(), nil)
+}
+
+// Error is an error reported to the client. Detail is specific to the
+// occurrence and safe to show; Err is the underlying cause, which is logged
+// but never sent to the client. When Key is set, Detail is its English
+// message and the client is served the message in its own language.
+type Error struct {
+	Code   Code
+	Detail string
+	Key    i18n.Key
+	Params map[string]interface{}
+	Fields model.ValidationErrors
+	Err    error
+}
+
+// New reports code to the client with the catalog message of key as detail.
+// An empty key falls back to the title of the code.
+func New(code Code, key i18n.Key) *Error {
+	return &Error{Code: code, Detail: i18n.Translate(i18n.English, key, nil), Key: key}
+}
+
+// Wrap reports err to the client as code with the catalog message of key as
+// detail.
+func Wrap(code Code, key i18n.Key, err error) *Error {
+	e := New(code, key)
+	e.Err = err
+	return e
+}
+
+// Validation reports invalid request fields.
+func Validation(key i18n.Key, fields ...model.FieldError) *Error {
+	e := New(CodeValidationFailed, key)
+	e.Fields = fields
+	return e
+}
+
+// WithParams sets the values of the placeholders of the detail message.
+func (e *Error) WithParams(params map[string]interface{}) *Error {
+	e.Params = params
+	e.Detail = i18n.Translate(i18n.English, e.Key, params)
+	return e
+}
+
+// LocalizedDetail returns the detail in the language. Details that are not
+// from the catalog are returned as is.
+func (e *Error) LocalizedDetail(language i18n.Language) string {
+	if e.Key == "" {
+		return e.Detail
+	}
+	return i18n.Translate(language, e.Key, e.Params)
+}
+
+func (e *Error) Error() string {
+	if e.Err != nil {
+		return fmt.Sprintf("%s: %s: %v", e.Code, e.Detail, e.Err)
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Detail)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Status returns the HTTP status of the error.
+func (e *Error) Status() int {
+	return Lookup(e.Code).Status
+}
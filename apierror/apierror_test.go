@@ -0,0 +1,69 @@
+package apierror
+
+import (
+	"errors"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCatalog(t *testing.T) {
+	codes := Codes()
+	assert.NotEmpty(t, codes)
+
+	for _, code := range codes {
+		definition := Lookup(code)
+		assert.NotEmpty(t, definition.Title, code)
+		assert.GreaterOrEqual(t, definition.Status, http.StatusBadRequest, code)
+		assert.NotEmpty(t, http.StatusText(definition.Status), code)
+		assert.Regexp(t, `^[a-z]+(_[a-z]+)*$`, string(code))
+		assert.Equal(t, "/errors/"+string(code), code.Type())
+
+		// Every title must be translated in every language
+		for _, language := range i18n.Supported() {
+			assert.True(t, i18n.Has(language, code.TitleKey()), "code %s has no %s title", code, language)
+		}
+	}
+
+	// unknown codes are reported as internal errors
+	assert.Equal(t, Lookup(CodeInternal), Lookup(Code("unknown")))
+}
+
+func TestCatalogDocumented(t *testing.T) {
+	spec, err := os.ReadFile("../api.yml")
+	assert.NoError(t, err)
+
+	// Every code must be listed in the enum of the Problem schema
+	for _, code := range Codes() {
+		assert.True(t, strings.Contains(string(spec), "            - "+string(code)+"\n"), "code %s is missing from api.yml", code)
+	}
+}
+
+func TestError(t *testing.T) {
+	cause := errors.New("cause")
+
+	err := Wrap(CodeNotFound, i18n.DetailUserNotFound, cause)
+	assert.ErrorIs(t, err, cause)
+	assert.Equal(t, "not_found: User not found.: cause", err.Error())
+	assert.Equal(t, http.StatusNotFound, err.Status())
+	assert.Equal(t, "Pengguna tidak ditemukan.", err.LocalizedDetail(i18n.Indonesian))
+
+	err = Validation(i18n.DetailInvalidMergePatch, model.Required("full_name"))
+	assert.Equal(t, CodeValidationFailed, err.Code)
+	assert.Equal(t, "validation_failed: Invalid merge patch.", err.Error())
+	assert.Len(t, err.Fields, 1)
+
+	err = New(CodeUnsupportedMediaType, i18n.DetailUnsupportedContentType).
+		WithParams(map[string]interface{}{"content_type": "application/json"})
+	assert.Equal(t, "Unsupported content type. Please use application/json.", err.Detail)
+	assert.Equal(t, "Tipe konten tidak didukung. Gunakan application/json.", err.LocalizedDetail(i18n.Indonesian))
+
+	// details that are not from the catalog are served as is
+	err = &Error{Code: CodeInvalidRequest, Detail: "Syntax error"}
+	assert.Equal(t, "Syntax error", err.LocalizedDetail(i18n.Indonesian))
+}
@@ -0,0 +1,143 @@
+package blocklist
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFilter(t *testing.T) {
+	f := NewFilter(1000, 0.01)
+	for i := 0; i < 1000; i++ {
+		f.Add(DigestOf(fmt.Sprintf("listed-%d", i)))
+	}
+
+	// test 1 listed passwords are never missed
+	for i := 0; i < 1000; i++ {
+		assert.True(t, f.Contains(fmt.Sprintf("listed-%d", i)))
+	}
+	assert.Equal(t, 1000, f.Len())
+
+	// test 2 false positives stay near the requested rate
+	falsePositives := 0
+	for i := 0; i < 10000; i++ {
+		if f.Contains(fmt.Sprintf("unlisted-%d", i)) {
+			falsePositives++
+		}
+	}
+	assert.Less(t, falsePositives, 300)
+
+	// test 3 round trip
+	var buf bytes.Buffer
+	n, err := f.WriteTo(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(buf.Len()), n)
+
+	read, err := ReadFilter(&buf)
+	assert.NoError(t, err)
+	assert.Equal(t, f, read)
+
+	// test 4 invalid files
+	_, err = ReadFilter(strings.NewReader("not a filter"))
+	assert.Error(t, err)
+
+	buf.Reset()
+	f.WriteTo(&buf)
+	_, err = ReadFilter(bytes.NewReader(buf.Bytes()[:buf.Len()-1]))
+	assert.Error(t, err)
+}
+
+func TestReadPasswords(t *testing.T) {
+	var digests []Digest
+	err := ReadPasswords(strings.NewReader("# comment\npassword\r\n\nP@ssw0rd1\n"), func(d Digest) {
+		digests = append(digests, d)
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, []Digest{DigestOf("password"), DigestOf("P@ssw0rd1")}, digests)
+}
+
+func TestReadRange(t *testing.T) {
+	// SHA-1 of "password" is 5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8
+	collect := func(prefix, body string, minCount int) (digests []Digest, err error) {
+		err = ReadRange(prefix, strings.NewReader(body), minCount, func(d Digest) {
+			digests = append(digests, d)
+		})
+		return digests, err
+	}
+
+	// test 1 range file
+	digests, err := collect("5BAA6", "1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\r\n0018A45C4D1DEF81644B54AB7F969B88D65:1\n", 0)
+	assert.NoError(t, err)
+	assert.Len(t, digests, 2)
+	assert.Equal(t, DigestOf("password"), digests[0])
+
+	// test 2 minimum count
+	digests, err = collect("5baa6", "1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n0018A45C4D1DEF81644B54AB7F969B88D65:1\n", 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []Digest{DigestOf("password")}, digests)
+
+	// test 3 whole digests
+	digests, err = collect("", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8\n", 0)
+	assert.NoError(t, err)
+	assert.Equal(t, []Digest{DigestOf("password")}, digests)
+
+	// test 4 malformed lines
+	_, err = collect("5BAA6", "1E4C9B93F3F0682250B6CF8331B7EE68FD8:many\n", 10)
+	assert.Error(t, err)
+	_, err = collect("5BAA6", "1E4C9B93:1\n", 0)
+	assert.Error(t, err)
+	_, err = collect("XYZ", "", 0)
+	assert.Error(t, err)
+
+	// test 5 over-long hashes, e.g. whole digests under a prefix
+	_, err = collect("5BAA6", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8:1\n", 0)
+	assert.ErrorContains(t, err, "line 1: invalid hash")
+	_, err = collect("", "5BAA61E4C9B93F3F0682250B6CF8331B7EE68FD8AB\n", 0)
+	assert.ErrorContains(t, err, "line 1: invalid hash")
+}
+
+func TestLoad(t *testing.T) {
+	dir := t.TempDir()
+	rangeDir := filepath.Join(dir, "ranges")
+	assert.NoError(t, os.Mkdir(rangeDir, 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(rangeDir, "5BAA6.txt"), []byte("1E4C9B93F3F0682250B6CF8331B7EE68FD8:9545824\n"), 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(rangeDir, "README"), []byte("skipped"), 0o644))
+	listFile := filepath.Join(dir, "list.txt")
+	assert.NoError(t, os.WriteFile(listFile, []byte("Kebun#Sawit9\n"), 0o644))
+
+	// test 1 bundled list
+	f, err := Load(Options{})
+	assert.NoError(t, err)
+	assert.True(t, f.Contains("P@ssw0rd1"))
+	assert.True(t, f.Contains("Password123!"))
+	assert.False(t, f.Contains("Kebun#Sawit9"))
+
+	// test 2 local corpus without the bundled list
+	f, err = Load(Options{PasswordFiles: []string{listFile}, RangePaths: []string{rangeDir}, DisableBundled: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, f.Len())
+	assert.True(t, f.Contains("password"))
+	assert.True(t, f.Contains("Kebun#Sawit9"))
+	assert.False(t, f.Contains("P@ssw0rd1"))
+
+	// test 3 filter file
+	filterFile := filepath.Join(dir, "blocklist.bin")
+	out, err := os.Create(filterFile)
+	assert.NoError(t, err)
+	_, err = f.WriteTo(out)
+	assert.NoError(t, err)
+	assert.NoError(t, out.Close())
+
+	read, err := Load(Options{FilterFile: filterFile})
+	assert.NoError(t, err)
+	assert.Equal(t, f, read)
+
+	// test 4 missing file
+	_, err = Load(Options{PasswordFiles: []string{filepath.Join(dir, "missing.txt")}})
+	assert.Error(t, err)
+}
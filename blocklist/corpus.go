@@ -0,0 +1,77 @@
+package blocklist
+
+import (
+	"bufio"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// ReadPasswords calls add with the digest of every password of a plain text
+// list, one password per line. Blank lines and lines starting with # are
+// skipped.
+func ReadPasswords(r io.Reader, add func(Digest)) error {
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		add(DigestOf(line))
+	}
+	return scanner.Err()
+}
+
+// ReadRange calls add with the digest of every hash of a file in the Have I
+// Been Pwned range format: one "SUFFIX:COUNT" line per hash, the suffix being
+// the SHA-1 digest in hex without its first five characters, which are the
+// prefix the file was downloaded for. When prefix is empty, the lines must hold
+// the whole digest instead. Hashes seen fewer than minCount times are skipped.
+func ReadRange(prefix string, r io.Reader, minCount int, add func(Digest)) error {
+	if prefix != "" && !isRangePrefix(prefix) {
+		return fmt.Errorf("blocklist: invalid range prefix %q", prefix)
+	}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+
+		suffix, count, found := strings.Cut(text, ":")
+		if found && minCount > 1 {
+			n, err := strconv.Atoi(count)
+			if err != nil {
+				return fmt.Errorf("blocklist: line %d: invalid count %q", line, count)
+			}
+			if n < minCount {
+				continue
+			}
+		}
+
+		// hex.Decode writes past the digest of a longer hash, the length is
+		// checked first
+		var digest Digest
+		if len(prefix)+len(suffix) != hex.EncodedLen(len(digest)) {
+			return fmt.Errorf("blocklist: line %d: invalid hash %q", line, suffix)
+		}
+		if _, err := hex.Decode(digest[:], []byte(prefix+suffix)); err != nil {
+			return fmt.Errorf("blocklist: line %d: invalid hash %q", line, suffix)
+		}
+		add(digest)
+	}
+	return scanner.Err()
+}
+
+// isRangePrefix reports whether s is a five character hex prefix, the name
+// the range files are saved under.
+func isRangePrefix(s string) bool {
+	if len(s) != 5 {
+		return false
+	}
+	_, err := hex.DecodeString(s + "0")
+	return err == nil
+}
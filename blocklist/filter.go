@@ -0,0 +1,161 @@
+// Package blocklist screens passwords against a local corpus of common and
+// breached passwords, so the check works offline and never sends password
+// hashes to a third party.
+//
+// The corpus is stored in a bloom filter keyed by the SHA-1 digest of the
+// password, the digest the Have I Been Pwned range files are keyed by. A bloom
+// filter never misses a listed password but may, rarely, report an unlisted
+// one; the rate is chosen when the filter is built.
+package blocklist
+
+import (
+	"crypto/sha1"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// Digest is the SHA-1 digest of a password.
+type Digest [sha1.Size]byte
+
+// DigestOf returns the digest of the password.
+func DigestOf(password string) Digest {
+	return sha1.Sum([]byte(password))
+}
+
+// Filter is a bloom filter of password digests. It is safe for concurrent
+// reads once built.
+type Filter struct {
+	bits   []uint64
+	m      uint64
+	k      uint32
+	length uint64
+}
+
+// NewFilter returns an empty filter sized for the expected number of
+// digests and the false positive rate, e.g. 0.001.
+func NewFilter(expected int, falsePositiveRate float64) *Filter {
+	if expected < 1 {
+		expected = 1
+	}
+	if falsePositiveRate <= 0 || falsePositiveRate >= 1 {
+		falsePositiveRate = DefaultFalsePositiveRate
+	}
+
+	m := math.Ceil(-float64(expected) * math.Log(falsePositiveRate) / (math.Ln2 * math.Ln2))
+	k := math.Round(m / float64(expected) * math.Ln2)
+
+	return newFilter(uint64(m), uint32(math.Max(k, 1)))
+}
+
+func newFilter(m uint64, k uint32) *Filter {
+	words := (m + 63) / 64
+	return &Filter{bits: make([]uint64, words), m: words * 64, k: k}
+}
+
+// DefaultFalsePositiveRate is one unlisted password in a thousand.
+const DefaultFalsePositiveRate = 0.001
+
+// Add adds the digest to the filter.
+func (f *Filter) Add(digest Digest) {
+	h1, h2 := f.hashes(digest)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+	f.length++
+}
+
+// ContainsDigest reports whether the digest may have been added.
+func (f *Filter) ContainsDigest(digest Digest) bool {
+	h1, h2 := f.hashes(digest)
+	for i := uint32(0); i < f.k; i++ {
+		bit := (h1 + uint64(i)*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Contains reports whether the password is, most likely, listed.
+func (f *Filter) Contains(password string) bool {
+	return f.ContainsDigest(DigestOf(password))
+}
+
+// Len returns the number of digests added.
+func (f *Filter) Len() int {
+	return int(f.length)
+}
+
+// hashes derives the two hashes of the double hashing scheme from the digest,
+// which is already uniformly distributed.
+func (f *Filter) hashes(digest Digest) (h1, h2 uint64) {
+	h1 = binary.LittleEndian.Uint64(digest[0:8])
+	h2 = binary.LittleEndian.Uint64(digest[8:16]) | 1
+	return h1, h2
+}
+
+// The file format is the magic, k, m and the number of digests, followed by
+// the bits, all little endian.
+var fileMagic = [4]byte{'P', 'W', 'B', '1'}
+
+// WriteTo writes the filter in the format read by ReadFilter.
+func (f *Filter) WriteTo(w io.Writer) (n int64, err error) {
+	header := make([]byte, 0, 24)
+	header = append(header, fileMagic[:]...)
+	header = binary.LittleEndian.AppendUint32(header, f.k)
+	header = binary.LittleEndian.AppendUint64(header, f.m)
+	header = binary.LittleEndian.AppendUint64(header, f.length)
+
+	written, err := w.Write(header)
+	n += int64(written)
+	if err != nil {
+		return n, err
+	}
+
+	body := make([]byte, 0, len(f.bits)*8)
+	for _, word := range f.bits {
+		body = binary.LittleEndian.AppendUint64(body, word)
+	}
+	written, err = w.Write(body)
+	n += int64(written)
+	return n, err
+}
+
+// maxFilterBits bounds the size of the filters read, 1 GiB.
+const maxFilterBits = 8 << 30
+
+var errInvalidFilter = errors.New("blocklist: invalid filter file")
+
+// ReadFilter reads a filter written by Filter.WriteTo.
+func ReadFilter(r io.Reader) (*Filter, error) {
+	header := make([]byte, 24)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidFilter, err)
+	}
+	if [4]byte(header[0:4]) != fileMagic {
+		return nil, errInvalidFilter
+	}
+
+	k := binary.LittleEndian.Uint32(header[4:8])
+	m := binary.LittleEndian.Uint64(header[8:16])
+	if k == 0 || m == 0 || m%64 != 0 || m > maxFilterBits {
+		return nil, errInvalidFilter
+	}
+
+	f := newFilter(m, k)
+	f.length = binary.LittleEndian.Uint64(header[16:24])
+
+	body := make([]byte, m/8)
+	if _, err := io.ReadFull(r, body); err != nil {
+		return nil, fmt.Errorf("%w: %v", errInvalidFilter, err)
+	}
+	for i := range f.bits {
+		f.bits[i] = binary.LittleEndian.Uint64(body[i*8:])
+	}
+
+	return f, nil
+}
@@ -0,0 +1,154 @@
+package blocklist
+
+import (
+	_ "embed"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// commonPasswords is the bundled list of common passwords, used unless
+// disabled. It includes the variants that satisfy the default password
+// policy, e.g. P@ssw0rd1.
+//
+//go:embed common_passwords.txt
+var commonPasswords string
+
+// Options select the corpus Load builds the filter from.
+type Options struct {
+	// FilterFile is a filter written by Filter.WriteTo, e.g. by the
+	// blocklist command. When set, the other sources are ignored.
+	FilterFile string
+	// PasswordFiles are plain text lists of passwords, one per line.
+	PasswordFiles []string
+	// RangePaths are files in the Have I Been Pwned range format, or
+	// directories of them, each named after the prefix it was downloaded for,
+	// e.g. 5BAA6.txt. Files named otherwise must hold whole digests.
+	RangePaths []string
+	// MinCount skips the hashes of the range files seen fewer times.
+	MinCount int
+	// FalsePositiveRate defaults to DefaultFalsePositiveRate.
+	FalsePositiveRate float64
+	// DisableBundled leaves the bundled common passwords out.
+	DisableBundled bool
+}
+
+// Load builds the filter from the sources of the options.
+func Load(opts Options) (*Filter, error) {
+	if opts.FilterFile != "" {
+		file, err := os.Open(opts.FilterFile)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+
+		return ReadFilter(file)
+	}
+
+	var b Builder
+	if !opts.DisableBundled {
+		if err := ReadPasswords(strings.NewReader(commonPasswords), b.Add); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range opts.PasswordFiles {
+		err := readFile(path, func(r io.Reader) error {
+			return ReadPasswords(r, b.Add)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	for _, path := range opts.RangePaths {
+		if err := b.addRangePath(path, opts.MinCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return b.Build(opts.FalsePositiveRate), nil
+}
+
+// Builder collects the digests of a filter, which must be sized before
+// they are added.
+type Builder struct {
+	digests map[Digest]struct{}
+}
+
+// Add adds the digest, ignoring duplicates.
+func (b *Builder) Add(digest Digest) {
+	if b.digests == nil {
+		b.digests = make(map[Digest]struct{})
+	}
+	b.digests[digest] = struct{}{}
+}
+
+// Len returns the number of distinct digests added.
+func (b *Builder) Len() int {
+	return len(b.digests)
+}
+
+// Build returns a filter of the digests added.
+func (b *Builder) Build(falsePositiveRate float64) *Filter {
+	f := NewFilter(len(b.digests), falsePositiveRate)
+	for digest := range b.digests {
+		f.Add(digest)
+	}
+	return f
+}
+
+func (b *Builder) addRangePath(path string, minCount int) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if !info.IsDir() {
+		return b.addRangeFile(path, minCount)
+	}
+
+	entries, err := os.ReadDir(path)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		if entry.IsDir() || !isRangePrefix(rangePrefix(entry.Name())) {
+			continue
+		}
+		if err := b.addRangeFile(filepath.Join(path, entry.Name()), minCount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b *Builder) addRangeFile(path string, minCount int) error {
+	prefix := rangePrefix(filepath.Base(path))
+	if !isRangePrefix(prefix) {
+		prefix = ""
+	}
+
+	return readFile(path, func(r io.Reader) error {
+		return ReadRange(prefix, r, minCount, b.Add)
+	})
+}
+
+// rangePrefix returns the upper case file name without its extension.
+func rangePrefix(name string) string {
+	return strings.ToUpper(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+func readFile(path string, read func(io.Reader) error) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := read(file); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	return nil
+}
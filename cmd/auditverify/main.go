@@ -0,0 +1,84 @@
+// Command auditverify re-computes the hash chain of the audit_events table and
+// reports the first event that has been modified, removed or inserted out of order.
+//
+// Removing the most recent events cannot be detected from the table alone, pass
+// the id and hash printed by a previous run with -head-id and -head-hash to check
+// the chain still contains them.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+func main() {
+	var (
+		batchSize = flag.Int("batch", 1000, "number of events read per query")
+		headID    = flag.Int64("head-id", 0, "id of a previously verified event that must still exist")
+		headHash  = flag.String("head-hash", "", "hash of the event given by -head-id")
+	)
+	flag.Parse()
+
+	repoOpts, err := config.ParseDatabaseOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	repo, err := repository.NewRepository(repoOpts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var (
+		ctx       = context.Background()
+		prevID    int64
+		prevHash  string
+		headFound bool
+	)
+
+	for {
+		events, err := repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{
+			AfterID: prevID,
+			Limit:   *batchSize,
+		})
+		if err != nil {
+			log.Fatalln(err)
+		}
+
+		if len(events) == 0 {
+			break
+		}
+
+		if err := model.VerifyAuditChain(events, prevID, prevHash); err != nil {
+			fmt.Println(err)
+			os.Exit(1)
+		}
+
+		for _, event := range events {
+			if event.ID == *headID {
+				if event.Hash != *headHash {
+					fmt.Printf("audit chain broken at event %d: head hash mismatch\n", event.ID)
+					os.Exit(1)
+				}
+				headFound = true
+			}
+		}
+
+		last := events[len(events)-1]
+		prevID, prevHash = last.ID, last.Hash
+	}
+
+	if *headID != 0 && !headFound {
+		fmt.Printf("audit chain broken: event %d is missing\n", *headID)
+		os.Exit(1)
+	}
+
+	fmt.Printf("audit chain ok: %d events, head id %d hash %s\n", prevID, prevID, prevHash)
+}
@@ -0,0 +1,82 @@
+// Command blocklist builds the password blocklist filter loaded by the service
+// from PASSWORD_BLOCKLIST_FILTER, so large corpora are read once instead of on
+// every start.
+//
+// The filter holds the bundled common passwords, unless -bundled=false, the
+// plain text lists given with -passwords and the Have I Been Pwned range files
+// given with -ranges, e.g.
+//
+//	blocklist -ranges ./pwned -min-count 10 -o blocklist.bin
+//
+// Pass -check to report whether the filter screens a password instead.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/blocklist"
+)
+
+func main() {
+	var (
+		output    = flag.String("o", "blocklist.bin", "file the filter is written to")
+		passwords = flag.String("passwords", "", "comma separated plain text password lists")
+		ranges    = flag.String("ranges", "", "comma separated range files or directories of them")
+		minCount  = flag.Int("min-count", 0, "skip the range hashes seen fewer times")
+		fpRate    = flag.Float64("fp-rate", blocklist.DefaultFalsePositiveRate, "false positive rate of the filter")
+		bundled   = flag.Bool("bundled", true, "include the bundled common passwords")
+		check     = flag.String("check", "", "report whether the filter given by -o screens this password")
+	)
+	flag.Parse()
+
+	if *check != "" {
+		filter, err := blocklist.Load(blocklist.Options{FilterFile: *output})
+		if err != nil {
+			log.Fatalln(err)
+		}
+		if filter.Contains(*check) {
+			fmt.Println("listed")
+			os.Exit(1)
+		}
+		fmt.Println("not listed")
+		return
+	}
+
+	filter, err := blocklist.Load(blocklist.Options{
+		PasswordFiles:     splitList(*passwords),
+		RangePaths:        splitList(*ranges),
+		MinCount:          *minCount,
+		FalsePositiveRate: *fpRate,
+		DisableBundled:    !*bundled,
+	})
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	file, err := os.Create(*output)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	size, err := filter.WriteTo(file)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if err := file.Close(); err != nil {
+		log.Fatalln(err)
+	}
+
+	fmt.Printf("wrote %s: %d passwords, %d bytes\n", *output, filter.Len(), size)
+}
+
+func splitList(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
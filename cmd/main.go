@@ -1,40 +1,207 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"expvar"
 	"log"
+	"log/slog"
+	"net"
 	"os"
+	"strconv"
+	"time"
 
+	"github.com/SawitProRecruitment/UserService/blocklist"
 	"github.com/SawitProRecruitment/UserService/config"
 	"github.com/SawitProRecruitment/UserService/generated"
 	"github.com/SawitProRecruitment/UserService/handler"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/SawitProRecruitment/UserService/outbox"
 	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/webhook"
 
 	"github.com/labstack/echo/v4"
 )
 
+const (
+	purgeInterval = time.Hour
+	purgeBatch    = 1000
+
+	defaultEventRetention = 7 * 24 * time.Hour
+
+	defaultGRPCAddr = ":50051"
+)
+
 func main() {
 	e := echo.New()
 
-	var server generated.ServerInterface = newServer()
+	cfg := initConfig()
+
+	appLogger := logger.NewLogger(logger.NewLoggerOptions{
+		Output: os.Stdout,
+		Level:  cfg.LogLevel,
+	})
+	slog.SetDefault(appLogger)
+
+	repoOpts, err := config.ParseDatabaseOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	repoOpts.TxIsolation = cfg.TxIsolation
+	repoOpts.TxMaxRetries = cfg.TxMaxRetries
+
+	piiCipher, err := config.ParsePIICipher(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if piiCipher != nil {
+		repoOpts.Cipher = piiCipher
+	} else {
+		appLogger.Warn("PII_MASTER_KEY_FILE is not set, personal data is stored in plaintext")
+	}
+
+	repo, err := repository.NewRepository(repoOpts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	// Reads are retried through short failures, then the breaker fails the
+	// requests fast until the database answers again
+	retryOpts, breakerOpts, err := config.ParseResilienceOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	breaker := repository.NewCircuitBreaker(breakerOpts)
+	repo = repository.NewCircuitBreakerRepository(repository.NewRetryingRepository(repo, retryOpts), breaker)
+
+	cacheOpts, cacheProfiles, err := config.ParseCacheOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if cacheProfiles {
+		cachedRepo := repository.NewCachedRepository(repo, cacheOpts)
+		expvar.Publish("user_cache", expvar.Func(func() any { return cachedRepo.Stats() }))
+		repo = cachedRepo
+	}
+
+	server := newServer(cfg, repo, breaker, appLogger)
+
+	dispatcher := webhook.NewDispatcher(webhook.NewDispatcherOptions{
+		Repository: repo,
+		Logger:     appLogger,
+	})
+	go dispatcher.Run(context.Background())
+
+	// Webhook deliveries are scheduled in-process next to the configured publisher.
+	webhooks := outbox.NewInProcessPublisher()
+	webhooks.Subscribe(dispatcher.Enqueue)
+
+	relay := outbox.NewRelay(outbox.NewRelayOptions{
+		Repository: repo,
+		Publisher:  outbox.MultiPublisher{newOutboxPublisher(cfg), webhooks},
+		Logger:     appLogger,
+	})
+	go relay.Run(context.Background())
+
+	go purge(context.Background(), "idempotency keys", appLogger, func(ctx context.Context) (int64, error) {
+		return repo.DeleteExpiredIdempotencyKeys(ctx, repository.DeleteExpiredIdempotencyKeysInput{Limit: purgeBatch})
+	})
+	go purge(context.Background(), "outbox events", appLogger, func(ctx context.Context) (int64, error) {
+		return repo.DeletePublishedOutboxEvents(ctx, repository.DeletePublishedOutboxEventsInput{
+			Before: time.Now().Add(-cfg.EventRetention),
+			Limit:  purgeBatch,
+		})
+	})
+	go purge(context.Background(), "webhook deliveries", appLogger, func(ctx context.Context) (int64, error) {
+		return repo.DeleteDeliveredWebhookDeliveries(ctx, repository.DeleteDeliveredWebhookDeliveriesInput{
+			Before: time.Now().Add(-cfg.EventRetention),
+			Limit:  purgeBatch,
+		})
+	})
+
+	e.HTTPErrorHandler = server.HTTPErrorHandler
+	e.Use(handler.RequestID())
+	e.Use(server.RequestLogger())
+	e.Use(handler.Deprecation(handler.DeprecationOptions{
+		Routes:       handler.LegacyRoutes,
+		DeprecatedAt: handler.LegacyDeprecatedAt,
+		Sunset:       cfg.LegacySunset,
+	}))
+	e.Use(server.Idempotency())
+
+	// The gRPC API is served next to the REST one, for the internal services
+	listener, err := net.Listen("tcp", cfg.GRPCAddr)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	grpcServer := server.GRPCServer()
+	go func() {
+		if err := grpcServer.Serve(listener); err != nil {
+			log.Fatalln(err)
+		}
+	}()
 
 	generated.RegisterHandlers(e, server)
+	e.GET("/debug/vars", server.DebugVars)
+	e.GET("/readyz", server.Readiness)
 	e.Logger.Fatal(e.Start(":1323"))
 }
 
-func newServer() *handler.Server {
-	cfg := initConfig()
-
-	dbDsn := os.Getenv("DATABASE_URL")
-	var repo repository.RepositoryInterface = repository.NewRepository(repository.NewRepositoryOptions{
-		Dsn: dbDsn,
-	})
+func newServer(cfg *config.Config, repo repository.RepositoryInterface, breaker *repository.CircuitBreaker, appLogger *slog.Logger) *handler.Server {
 	opts := handler.NewServerOptions{
 		Repository: repo,
 		Config:     cfg,
+		Logger:     appLogger,
+		Breaker:    breaker,
 	}
 	return handler.NewServer(opts)
 }
 
+// purge deletes the rows of a table no longer needed, e.g. the expired
+// responses to the requests sent with an Idempotency-Key, every
+// purgeInterval. deleteBatch deletes up to purgeBatch rows and is called
+// until it deletes less.
+func purge(ctx context.Context, name string, appLogger *slog.Logger, deleteBatch func(ctx context.Context) (int64, error)) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		for {
+			deleted, err := deleteBatch(ctx)
+			if err != nil {
+				appLogger.WarnContext(ctx, "purge "+name, "error", err)
+				break
+			}
+			if deleted < purgeBatch {
+				break
+			}
+		}
+	}
+}
+
+func newOutboxPublisher(cfg *config.Config) outbox.Publisher {
+	switch cfg.OutboxPublisher {
+	case "file":
+		file, err := os.OpenFile(cfg.OutboxFile, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		return outbox.NewWriterPublisher(file)
+	case "none":
+		return outbox.MultiPublisher{}
+	default:
+		return outbox.NewWriterPublisher(os.Stdout)
+	}
+}
+
 func initConfig() *config.Config {
 	prvKey, err := os.ReadFile("cert/id_rsa")
 	if err != nil {
@@ -48,7 +215,126 @@ func initConfig() *config.Config {
 
 	jwtToken := config.NewJWT(prvKey, pubKey)
 
+	adminUserIDs, err := config.ParseUserIDs(os.Getenv("ADMIN_USER_IDS"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	txIsolation, err := repository.ParseIsolationLevel(os.Getenv("DB_TX_ISOLATION"))
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var txMaxRetries int
+	if value := os.Getenv("DB_TX_MAX_RETRIES"); value != "" {
+		txMaxRetries, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	legacySunset := handler.DefaultLegacySunset
+	if value := os.Getenv("LEGACY_API_SUNSET"); value != "" {
+		legacySunset, err = time.Parse("2006-01-02", value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	passwordPolicy, err := config.ParsePasswordPolicy(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	blocklistOptions, screenPasswords, err := config.ParseBlocklistOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if screenPasswords {
+		passwordBlocklist, err := blocklist.Load(blocklistOptions)
+		if err != nil {
+			log.Fatalln(err)
+		}
+		passwordPolicy.Blocklist = passwordBlocklist
+	}
+
+	passwordHasher, err := config.ParsePasswordHasher(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var passwordHashConcurrency int
+	if value := os.Getenv("PASSWORD_HASH_CONCURRENCY"); value != "" {
+		passwordHashConcurrency, err = strconv.Atoi(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	phoneNumberPolicy, err := config.ParsePhoneNumberPolicy(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	var idempotencyKeyTTL time.Duration
+	if value := os.Getenv("IDEMPOTENCY_KEY_TTL"); value != "" {
+		idempotencyKeyTTL, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	// Without a shared key, a retry only matches its first request on the
+	// same instance until it restarts
+	idempotencyFingerprintKey := make([]byte, 32)
+	if value := os.Getenv("IDEMPOTENCY_FINGERPRINT_KEY"); value != "" {
+		idempotencyFingerprintKey, err = base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	} else if _, err := rand.Read(idempotencyFingerprintKey); err != nil {
+		log.Fatalln(err)
+	}
+
+	eventRetention := defaultEventRetention
+	if value := os.Getenv("EVENT_RETENTION"); value != "" {
+		eventRetention, err = time.ParseDuration(value)
+		if err != nil {
+			log.Fatalln(err)
+		}
+	}
+
+	grpcAddr := os.Getenv("GRPC_ADDR")
+	if grpcAddr == "" {
+		grpcAddr = defaultGRPCAddr
+	}
+
+	defaultLanguage := i18n.DefaultLanguage
+	if value := os.Getenv("DEFAULT_LANGUAGE"); value != "" {
+		language, ok := i18n.ParseLanguage(value)
+		if !ok {
+			log.Fatalf("unsupported DEFAULT_LANGUAGE %q", value)
+		}
+		defaultLanguage = language
+	}
+
 	return &config.Config{
-		JWT: jwtToken,
+		JWT:                       jwtToken,
+		LogLevel:                  os.Getenv("LOG_LEVEL"),
+		AdminUserIDs:              adminUserIDs,
+		OutboxPublisher:           os.Getenv("OUTBOX_PUBLISHER"),
+		OutboxFile:                os.Getenv("OUTBOX_FILE"),
+		TxIsolation:               txIsolation,
+		TxMaxRetries:              txMaxRetries,
+		LegacySunset:              legacySunset,
+		DefaultLanguage:           defaultLanguage,
+		PasswordPolicy:            passwordPolicy,
+		PasswordHasher:            passwordHasher,
+		PasswordHashConcurrency:   passwordHashConcurrency,
+		PhoneNumberPolicy:         phoneNumberPolicy,
+		IdempotencyKeyTTL:         idempotencyKeyTTL,
+		IdempotencyFingerprintKey: idempotencyFingerprintKey,
+		EventRetention:            eventRetention,
+		GRPCAddr:                  grpcAddr,
 	}
 }
@@ -0,0 +1,86 @@
+// Command rotatekeys encrypts again, in batches, the personal data of the
+// users that is not encrypted under the current master key, i.e. the first
+// key of PII_MASTER_KEY_FILE, and recomputes its blind index.
+//
+// To rotate the master key, add the new key at the top of the key file,
+// restart the service so it encrypts new data under it, run this command and
+// only then remove the old key. New keys are generated with e.g.
+//
+//	openssl rand -base64 32
+//
+// It also encrypts again the values of version 1 of the format, which are not
+// bound to their row and column, and the data stored before encryption was
+// enabled, whose rows cannot be logged in to until their blind index is
+// computed. With the service stopped, add the phone_number_index column
+// without its constraints, copy phone_number into it, widen phone_number and
+// full_name to TEXT, run this command and then add the NOT NULL and UNIQUE
+// constraints.
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+func main() {
+	var (
+		batchSize = flag.Int("batch", 500, "number of users re-encrypted per transaction")
+		afterID   = flag.Int("after-id", 0, "resume after the user with this id")
+	)
+	flag.Parse()
+
+	piiCipher, err := config.ParsePIICipher(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	if piiCipher == nil {
+		log.Fatalln("PII_MASTER_KEY_FILE is not set")
+	}
+
+	repoOpts, err := config.ParseDatabaseOptions(os.Getenv)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	repoOpts.Cipher = piiCipher
+
+	db, err := repository.NewRepository(repoOpts)
+	if err != nil {
+		log.Fatalln(err)
+	}
+	repo, ok := db.(repository.UserReencrypter)
+	if !ok {
+		log.Fatalln("the database of DATABASE_URL does not support re-encryption")
+	}
+
+	var (
+		ctx         = context.Background()
+		lastID      = int32(*afterID)
+		reencrypted int
+	)
+
+	for {
+		out, err := repo.ReencryptUsers(ctx, repository.ReencryptUsersInput{
+			AfterID: lastID,
+			Limit:   *batchSize,
+		})
+		if err != nil {
+			log.Fatalf("re-encrypt users after id %d: %v", lastID, err)
+		}
+
+		if out.LastID == 0 {
+			break
+		}
+
+		lastID = out.LastID
+		reencrypted += out.Reencrypted
+		fmt.Printf("re-encrypted %d users up to id %d\n", out.Reencrypted, lastID)
+	}
+
+	fmt.Printf("done: %d users re-encrypted\n", reencrypted)
+}
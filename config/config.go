@@ -1,19 +1,396 @@
 package config
 
 import (
+	"database/sql"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"log"
 	"strconv"
+	"strings"
 	"time"
 
+	"github.com/SawitProRecruitment/UserService/blocklist"
+	"github.com/SawitProRecruitment/UserService/encryption"
+	"github.com/SawitProRecruitment/UserService/i18n"
 	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/passwordhash"
+	"github.com/SawitProRecruitment/UserService/repository"
 	"github.com/dgrijalva/jwt-go"
 )
 
 type Config struct {
 	JWT JWT
+	// LogLevel is one of debug, info, warn or error. Defaults to info.
+	LogLevel string
+	// AdminUserIDs are the users allowed to call the admin endpoints.
+	AdminUserIDs []int32
+	// OutboxPublisher selects where domain events are published besides the
+	// webhooks: stdout (default), file (appended to OutboxFile) or none.
+	OutboxPublisher string
+	OutboxFile      string
+	// TxIsolation and TxMaxRetries configure the repository transactions.
+	TxIsolation  sql.IsolationLevel
+	TxMaxRetries int
+	// LegacySunset is announced in the Sunset header of the deprecated
+	// RPC-style routes.
+	LegacySunset time.Time
+	// DefaultLanguage is served when neither the user nor the request asks
+	// for a supported language. Defaults to i18n.DefaultLanguage.
+	DefaultLanguage i18n.Language
+	// PasswordPolicy is enforced on new passwords and published at
+	// GET /password-policy. See ParsePasswordPolicy.
+	PasswordPolicy model.PasswordPolicy
+	// PasswordHasher hashes new passwords. Hashes created by another
+	// algorithm or weaker parameters are replaced on login. See
+	// ParsePasswordHasher.
+	PasswordHasher model.PasswordHasher
+	// PasswordHashConcurrency is how many password hashes and verifications
+	// may run at once, requests needing one more are refused with a 503.
+	// Defaults to the number of CPUs.
+	PasswordHashConcurrency int
+	// PhoneNumberPolicy are the countries phone numbers are accepted from.
+	// See ParsePhoneNumberPolicy.
+	PhoneNumberPolicy model.PhoneNumberPolicy
+	// IdempotencyKeyTTL is how long the responses to the requests sent with
+	// an Idempotency-Key are replayed. Defaults to 24h.
+	IdempotencyKeyTTL time.Duration
+	// IdempotencyFingerprintKey keys the HMAC fingerprinting the requests
+	// sent with an Idempotency-Key, so the stored fingerprints cannot be
+	// used to guess the passwords in the bodies.
+	IdempotencyFingerprintKey []byte
+	// EventRetention is how long the published outbox events and the
+	// delivered webhooks are kept before being purged.
+	EventRetention time.Duration
+	// GRPCAddr is the address the gRPC API listens on. Defaults to :50051.
+	GRPCAddr string
 }
 
+func (c *Config) IsAdmin(userID int32) bool {
+	for _, adminID := range c.AdminUserIDs {
+		if adminID == userID {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseUserIDs parses a comma separated list of user IDs, e.g. "1,2,3".
+func ParseUserIDs(value string) ([]int32, error) {
+	var userIDs []int32
+
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		userID, err := strconv.ParseInt(part, 10, 32)
+		if err != nil {
+			return nil, fmt.Errorf("parse user id %q: %w", part, err)
+		}
+		userIDs = append(userIDs, int32(userID))
+	}
+
+	return userIDs, nil
+}
+
+// ParsePasswordPolicy reads the password policy from the PASSWORD_*
+// environment variables returned by getenv, on top of the default policy.
+func ParsePasswordPolicy(getenv func(string) string) (policy model.PasswordPolicy, err error) {
+	policy = model.DefaultPasswordPolicy()
+
+	ints := map[string]*int{
+		"PASSWORD_MIN_LENGTH":              &policy.MinLength,
+		"PASSWORD_MAX_LENGTH":              &policy.MaxLength,
+		"PASSWORD_MAX_REPEATED_CHARACTERS": &policy.MaxRepeatedCharacters,
+		"PASSWORD_HISTORY_DEPTH":           &policy.HistoryDepth,
+	}
+	for name, target := range ints {
+		if value := getenv(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return policy, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	bools := map[string]*bool{
+		"PASSWORD_REQUIRE_UPPERCASE":      &policy.RequireUppercase,
+		"PASSWORD_REQUIRE_LOWERCASE":      &policy.RequireLowercase,
+		"PASSWORD_REQUIRE_DIGIT":          &policy.RequireDigit,
+		"PASSWORD_REQUIRE_SPECIAL":        &policy.RequireSpecial,
+		"PASSWORD_DISALLOW_PERSONAL_INFO": &policy.DisallowPersonalInfo,
+	}
+	for name, target := range bools {
+		if value := getenv(name); value != "" {
+			if *target, err = strconv.ParseBool(value); err != nil {
+				return policy, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	if value := getenv("PASSWORD_SPECIAL_CHARACTERS"); value != "" {
+		policy.SpecialCharacters = value
+	}
+
+	return policy, policy.Validate()
+}
+
+// ParsePasswordHasher reads the password hasher from the PASSWORD_HASH_*
+// environment variables returned by getenv. The algorithm defaults to
+// argon2id with the default parameters of passwordhash.
+func ParsePasswordHasher(getenv func(string) string) (model.PasswordHasher, error) {
+	algorithm := passwordhash.AlgorithmArgon2id
+	if value := getenv("PASSWORD_HASH_ALGORITHM"); value != "" {
+		algorithm = value
+	}
+
+	params := passwordhash.DefaultArgon2idParams()
+	uint32s := map[string]*uint32{
+		"PASSWORD_HASH_ARGON2_MEMORY":     &params.Memory,
+		"PASSWORD_HASH_ARGON2_ITERATIONS": &params.Iterations,
+	}
+	for name, target := range uint32s {
+		if value := getenv(name); value != "" {
+			parsed, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("parse %s: %w", name, err)
+			}
+			*target = uint32(parsed)
+		}
+	}
+	if value := getenv("PASSWORD_HASH_ARGON2_PARALLELISM"); value != "" {
+		parsed, err := strconv.ParseUint(value, 10, 8)
+		if err != nil {
+			return nil, fmt.Errorf("parse PASSWORD_HASH_ARGON2_PARALLELISM: %w", err)
+		}
+		params.Parallelism = uint8(parsed)
+	}
+
+	var bcryptCost int
+	if value := getenv("PASSWORD_HASH_BCRYPT_COST"); value != "" {
+		var err error
+		if bcryptCost, err = strconv.Atoi(value); err != nil {
+			return nil, fmt.Errorf("parse PASSWORD_HASH_BCRYPT_COST: %w", err)
+		}
+	}
+
+	return passwordhash.New(algorithm, params, bcryptCost)
+}
+
+// ParsePhoneNumberPolicy reads the allowed countries from PHONE_COUNTRIES
+// returned by getenv, a comma separated list of ISO 3166-1 alpha-2 codes, e.g.
+// "ID,MY". National numbers are read as numbers of the first country. Defaults
+// to Indonesia only.
+func ParsePhoneNumberPolicy(getenv func(string) string) (policy model.PhoneNumberPolicy, err error) {
+	codes := splitList(getenv("PHONE_COUNTRIES"))
+	if len(codes) == 0 {
+		return model.DefaultPhoneNumberPolicy(), nil
+	}
+
+	for _, code := range codes {
+		country, ok := model.LookupPhoneCountry(code)
+		if !ok {
+			return policy, fmt.Errorf("parse PHONE_COUNTRIES: unsupported country %q", code)
+		}
+		policy.Countries = append(policy.Countries, country)
+	}
+
+	return policy, policy.Validate()
+}
+
+// ParsePIICipher reads the cipher of the personal data of the users from the
+// PII_* environment variables returned by getenv: PII_MASTER_KEY_FILE, a key
+// file read by encryption.LoadKeyFile, and PII_INDEX_KEY, the base64 encoded
+// key of the blind index. It returns nil when no key file is configured, the
+// data is then stored in plaintext.
+func ParsePIICipher(getenv func(string) string) (*encryption.Cipher, error) {
+	keyFile := getenv("PII_MASTER_KEY_FILE")
+	if keyFile == "" {
+		return nil, nil
+	}
+
+	provider, err := encryption.LoadKeyFile(keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load PII_MASTER_KEY_FILE: %w", err)
+	}
+
+	encoded := getenv("PII_INDEX_KEY")
+	if encoded == "" {
+		return nil, errors.New("PII_INDEX_KEY is required with PII_MASTER_KEY_FILE")
+	}
+	indexKey, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, fmt.Errorf("parse PII_INDEX_KEY: %w", err)
+	}
+
+	return encryption.NewCipher(provider, indexKey)
+}
+
+// ParseBlocklistOptions reads the sources of the password blocklist from the
+// PASSWORD_BLOCKLIST_* environment variables returned by getenv. Screening is
+// enabled with the bundled common passwords unless PASSWORD_BLOCKLIST is
+// false.
+func ParseBlocklistOptions(getenv func(string) string) (opts blocklist.Options, enabled bool, err error) {
+	enabled = true
+	if value := getenv("PASSWORD_BLOCKLIST"); value != "" {
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return opts, false, fmt.Errorf("parse PASSWORD_BLOCKLIST: %w", err)
+		}
+	}
+
+	opts.FilterFile = getenv("PASSWORD_BLOCKLIST_FILTER")
+	opts.PasswordFiles = splitList(getenv("PASSWORD_BLOCKLIST_FILES"))
+	opts.RangePaths = splitList(getenv("PASSWORD_BLOCKLIST_RANGES"))
+
+	if value := getenv("PASSWORD_BLOCKLIST_MIN_COUNT"); value != "" {
+		if opts.MinCount, err = strconv.Atoi(value); err != nil {
+			return opts, false, fmt.Errorf("parse PASSWORD_BLOCKLIST_MIN_COUNT: %w", err)
+		}
+	}
+	if value := getenv("PASSWORD_BLOCKLIST_BUNDLED"); value != "" {
+		bundled, err := strconv.ParseBool(value)
+		if err != nil {
+			return opts, false, fmt.Errorf("parse PASSWORD_BLOCKLIST_BUNDLED: %w", err)
+		}
+		opts.DisableBundled = !bundled
+	}
+
+	return opts, enabled, nil
+}
+
+// DefaultDatabaseConnectTimeout is how long the database is waited for at
+// startup when DB_CONNECT_TIMEOUT is not set.
+const DefaultDatabaseConnectTimeout = 30 * time.Second
+
+// ParseDatabaseOptions reads the database to connect to from DATABASE_URL, its
+// read replicas from the comma separated DB_REPLICA_URLS, and the tuning of
+// its connections from the DB_* environment variables returned by getenv:
+// DB_MAX_OPEN_CONNS, DB_MAX_IDLE_CONNS, DB_STATEMENT_CACHE_CAPACITY and the
+// durations DB_CONN_MAX_LIFETIME, DB_CONN_MAX_IDLE_TIME, DB_QUERY_TIMEOUT,
+// DB_CONNECT_TIMEOUT and DB_READ_YOUR_WRITES_WINDOW, e.g. "30s".
+func ParseDatabaseOptions(getenv func(string) string) (opts repository.NewRepositoryOptions, err error) {
+	opts.Dsn = getenv("DATABASE_URL")
+	opts.ReplicaDsns = splitList(getenv("DB_REPLICA_URLS"))
+	opts.ConnectTimeout = DefaultDatabaseConnectTimeout
+
+	ints := map[string]*int{
+		"DB_MAX_OPEN_CONNS":           &opts.Pool.MaxOpenConns,
+		"DB_MAX_IDLE_CONNS":           &opts.Pool.MaxIdleConns,
+		"DB_STATEMENT_CACHE_CAPACITY": &opts.Pool.StatementCacheCapacity,
+	}
+	for name, target := range ints {
+		if value := getenv(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return opts, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"DB_CONN_MAX_LIFETIME":       &opts.Pool.ConnMaxLifetime,
+		"DB_CONN_MAX_IDLE_TIME":      &opts.Pool.ConnMaxIdleTime,
+		"DB_QUERY_TIMEOUT":           &opts.QueryTimeout,
+		"DB_CONNECT_TIMEOUT":         &opts.ConnectTimeout,
+		"DB_READ_YOUR_WRITES_WINDOW": &opts.ReadYourWritesWindow,
+	}
+	for name, target := range durations {
+		if value := getenv(name); value != "" {
+			if *target, err = time.ParseDuration(value); err != nil {
+				return opts, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	return opts, nil
+}
+
+// ParseResilienceOptions reads the retries of the database reads and the
+// circuit breaker around the database from the environment variables returned
+// by getenv: DB_READ_MAX_RETRIES, DB_BREAKER_FAILURE_THRESHOLD and the
+// durations DB_READ_RETRY_DELAY, DB_READ_RETRY_MAX_DELAY and
+// DB_BREAKER_COOLDOWN, e.g. "10s".
+func ParseResilienceOptions(getenv func(string) string) (retry repository.RetryOptions, breaker repository.CircuitBreakerOptions, err error) {
+	ints := map[string]*int{
+		"DB_READ_MAX_RETRIES":          &retry.MaxRetries,
+		"DB_BREAKER_FAILURE_THRESHOLD": &breaker.FailureThreshold,
+	}
+	for name, target := range ints {
+		if value := getenv(name); value != "" {
+			if *target, err = strconv.Atoi(value); err != nil {
+				return retry, breaker, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	durations := map[string]*time.Duration{
+		"DB_READ_RETRY_DELAY":     &retry.BaseDelay,
+		"DB_READ_RETRY_MAX_DELAY": &retry.MaxDelay,
+		"DB_BREAKER_COOLDOWN":     &breaker.Cooldown,
+	}
+	for name, target := range durations {
+		if value := getenv(name); value != "" {
+			if *target, err = time.ParseDuration(value); err != nil {
+				return retry, breaker, fmt.Errorf("parse %s: %w", name, err)
+			}
+		}
+	}
+
+	return retry, breaker, nil
+}
+
+// ParseCacheOptions reads the cache of the user profiles from the USER_CACHE_*
+// environment variables returned by getenv. The cache is enabled by
+// USER_CACHE=true, holds USER_CACHE_SIZE profiles in process for
+// USER_CACHE_TTL, e.g. "30s", or is shared on the Redis server named by
+// USER_CACHE_REDIS_URL when set.
+func ParseCacheOptions(getenv func(string) string) (opts repository.CacheOptions, enabled bool, err error) {
+	if value := getenv("USER_CACHE"); value != "" {
+		if enabled, err = strconv.ParseBool(value); err != nil {
+			return opts, false, fmt.Errorf("parse USER_CACHE: %w", err)
+		}
+	}
+	if !enabled {
+		return opts, false, nil
+	}
+
+	if value := getenv("USER_CACHE_TTL"); value != "" {
+		if opts.TTL, err = time.ParseDuration(value); err != nil {
+			return opts, false, fmt.Errorf("parse USER_CACHE_TTL: %w", err)
+		}
+	}
+	if value := getenv("USER_CACHE_SIZE"); value != "" {
+		if opts.Size, err = strconv.Atoi(value); err != nil {
+			return opts, false, fmt.Errorf("parse USER_CACHE_SIZE: %w", err)
+		}
+	}
+	if url := getenv("USER_CACHE_REDIS_URL"); url != "" {
+		if opts.Backend, err = repository.NewRedisCache(url); err != nil {
+			return opts, false, fmt.Errorf("parse USER_CACHE_REDIS_URL: %w", err)
+		}
+	}
+
+	return opts, true, nil
+}
+
+// splitList splits a comma separated list, dropping the empty items.
+func splitList(value string) (items []string) {
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+const (
+	// MaxTokenTTL bounds the lifetime of the tokens, Validate rejects the ones
+	// expiring later, e.g. those issued before their expiry was enforced.
+	MaxTokenTTL = time.Hour
+	// tokenClockSkew is the difference tolerated between the clocks of the
+	// instance that issued a token and the one validating it.
+	tokenClockSkew = time.Minute
+)
+
 type JWT struct {
 	privateKey []byte
 	publicKey  []byte
@@ -26,27 +403,31 @@ func NewJWT(privateKey []byte, publicKey []byte) JWT {
 	}
 }
 
+// Create issues a token for user which expires after ttl, at most MaxTokenTTL.
 func (j JWT) Create(ttl time.Duration, user model.User) (string, error) {
+	if ttl > MaxTokenTTL {
+		return "", fmt.Errorf("create: ttl %s exceeds %s", ttl, MaxTokenTTL)
+	}
+
 	// Create a new JWT token with RS256 signing method.
 	token := jwt.New(jwt.SigningMethodRS256)
 
 	// Set claims (payload) for the token.
 	claims := token.Claims.(jwt.MapClaims)
 	claims["user_id"] = fmt.Sprint(user.UserID)
-	claims["exp"] = ttl
+	claims["exp"] = time.Now().Add(ttl).Unix()
+	claims["tv"] = user.TokenVersion
 
 	// Load your RSA private key for signing the token.
 	privateKey, err := jwt.ParseRSAPrivateKeyFromPEM(j.privateKey)
 	if err != nil {
-		log.Println("Error loading private key:", err)
-		return "", err
+		return "", fmt.Errorf("create: parse key: %w", err)
 	}
 
 	// Sign the token with the private key.
 	tokenString, err := token.SignedString(privateKey)
 	if err != nil {
-		log.Println("Error signing token:", err)
-		return "", err
+		return "", fmt.Errorf("create: sign: %w", err)
 	}
 
 	return tokenString, nil
@@ -74,14 +455,26 @@ func (j JWT) Validate(token string) (model.User, error) {
 		return model.User{}, fmt.Errorf("validate: invalid")
 	}
 
-	fmt.Println("claims ==== ", claims["user_id"].(string))
-
 	userID, err := strconv.Atoi(claims["user_id"].(string))
 	if err != nil {
 		return model.User{}, err
 	}
 
+	// jwt-go only checks the expiry when there is one. Tokens without one or
+	// living longer than MaxTokenTTL were issued before the lifetime was
+	// enforced, as were the tokens without a token version.
+	exp, ok := claims["exp"].(float64)
+	if !ok || int64(exp) > time.Now().Add(MaxTokenTTL+tokenClockSkew).Unix() {
+		return model.User{}, fmt.Errorf("validate: invalid expiry")
+	}
+
+	tokenVersion, ok := claims["tv"].(float64)
+	if !ok {
+		return model.User{}, fmt.Errorf("validate: missing token version")
+	}
+
 	return model.User{
-		UserID: int32(userID),
+		UserID:       int32(userID),
+		TokenVersion: int32(tokenVersion),
 	}, nil
 }
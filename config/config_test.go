@@ -0,0 +1,357 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/blocklist"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/passwordhash"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/dgrijalva/jwt-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePasswordPolicy(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults
+	policy, err := ParsePasswordPolicy(env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, model.DefaultPasswordPolicy(), policy)
+
+	// test 2 overrides
+	policy, err = ParsePasswordPolicy(env(map[string]string{
+		"PASSWORD_MIN_LENGTH":              "12",
+		"PASSWORD_MAX_LENGTH":              "72",
+		"PASSWORD_REQUIRE_LOWERCASE":       "true",
+		"PASSWORD_REQUIRE_SPECIAL":         "false",
+		"PASSWORD_SPECIAL_CHARACTERS":      "!?",
+		"PASSWORD_MAX_REPEATED_CHARACTERS": "3",
+		"PASSWORD_DISALLOW_PERSONAL_INFO":  "1",
+		"PASSWORD_HISTORY_DEPTH":           "5",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, model.PasswordPolicy{
+		MinLength:             12,
+		MaxLength:             72,
+		RequireUppercase:      true,
+		RequireLowercase:      true,
+		RequireDigit:          true,
+		RequireSpecial:        false,
+		SpecialCharacters:     "!?",
+		MaxRepeatedCharacters: 3,
+		DisallowPersonalInfo:  true,
+		HistoryDepth:          5,
+	}, policy)
+
+	// test 3 malformed value
+	_, err = ParsePasswordPolicy(env(map[string]string{"PASSWORD_MIN_LENGTH": "twelve"}))
+	assert.Error(t, err)
+
+	// test 4 policy nobody can satisfy
+	_, err = ParsePasswordPolicy(env(map[string]string{"PASSWORD_MIN_LENGTH": "80"}))
+	assert.Error(t, err)
+}
+
+func TestParseBlocklistOptions(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults to the bundled list
+	opts, enabled, err := ParseBlocklistOptions(env(nil))
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, blocklist.Options{}, opts)
+
+	// test 2 overrides
+	opts, enabled, err = ParseBlocklistOptions(env(map[string]string{
+		"PASSWORD_BLOCKLIST_FILES":     "top1000.txt, leaked.txt",
+		"PASSWORD_BLOCKLIST_RANGES":    "/var/lib/pwned",
+		"PASSWORD_BLOCKLIST_MIN_COUNT": "10",
+		"PASSWORD_BLOCKLIST_BUNDLED":   "false",
+	}))
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, blocklist.Options{
+		PasswordFiles:  []string{"top1000.txt", "leaked.txt"},
+		RangePaths:     []string{"/var/lib/pwned"},
+		MinCount:       10,
+		DisableBundled: true,
+	}, opts)
+
+	// test 3 disabled
+	_, enabled, err = ParseBlocklistOptions(env(map[string]string{"PASSWORD_BLOCKLIST": "false"}))
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	// test 4 malformed value
+	_, _, err = ParseBlocklistOptions(env(map[string]string{"PASSWORD_BLOCKLIST_MIN_COUNT": "ten"}))
+	assert.Error(t, err)
+}
+
+func TestParsePasswordHasher(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults to argon2id
+	hasher, err := ParsePasswordHasher(env(nil))
+	assert.NoError(t, err)
+	assert.IsType(t, &passwordhash.Argon2id{}, hasher)
+
+	// test 2 argon2id parameters
+	hasher, err = ParsePasswordHasher(env(map[string]string{
+		"PASSWORD_HASH_ARGON2_MEMORY":      "19456",
+		"PASSWORD_HASH_ARGON2_ITERATIONS":  "2",
+		"PASSWORD_HASH_ARGON2_PARALLELISM": "1",
+	}))
+	assert.NoError(t, err)
+	hash, err := hasher.Hash("Leo9999#")
+	assert.NoError(t, err)
+	assert.Contains(t, hash, "$m=19456,t=2,p=1$")
+
+	// test 3 bcrypt
+	hasher, err = ParsePasswordHasher(env(map[string]string{
+		"PASSWORD_HASH_ALGORITHM":   "bcrypt",
+		"PASSWORD_HASH_BCRYPT_COST": "11",
+	}))
+	assert.NoError(t, err)
+	assert.IsType(t, &passwordhash.Bcrypt{}, hasher)
+
+	// test 4 malformed and weak values
+	for _, values := range []map[string]string{
+		{"PASSWORD_HASH_ALGORITHM": "md5"},
+		{"PASSWORD_HASH_ARGON2_MEMORY": "lots"},
+		{"PASSWORD_HASH_ARGON2_PARALLELISM": "300"},
+		{"PASSWORD_HASH_ARGON2_ITERATIONS": "0"},
+		{"PASSWORD_HASH_ALGORITHM": "bcrypt", "PASSWORD_HASH_BCRYPT_COST": "4"},
+	} {
+		_, err = ParsePasswordHasher(env(values))
+		assert.Error(t, err, values)
+	}
+}
+
+func TestParsePhoneNumberPolicy(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults to Indonesia
+	policy, err := ParsePhoneNumberPolicy(env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, model.DefaultPhoneNumberPolicy(), policy)
+
+	// test 2 countries in order
+	policy, err = ParsePhoneNumberPolicy(env(map[string]string{"PHONE_COUNTRIES": "my, ID"}))
+	assert.NoError(t, err)
+	if assert.Len(t, policy.Countries, 2) {
+		assert.Equal(t, "MY", policy.Countries[0].Code)
+		assert.Equal(t, "ID", policy.Countries[1].Code)
+	}
+
+	// test 3 unsupported country
+	_, err = ParsePhoneNumberPolicy(env(map[string]string{"PHONE_COUNTRIES": "ID,SG"}))
+	assert.Error(t, err)
+}
+
+func TestParsePIICipher(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	keyFile := filepath.Join(t.TempDir(), "master.keys")
+	assert.NoError(t, os.WriteFile(keyFile, []byte("2024-06 ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\n"), 0o600))
+	indexKey := "MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY="
+
+	// test 1 plaintext without key file
+	piiCipher, err := ParsePIICipher(env(nil))
+	assert.NoError(t, err)
+	assert.Nil(t, piiCipher)
+
+	// test 2 configured
+	piiCipher, err = ParsePIICipher(env(map[string]string{"PII_MASTER_KEY_FILE": keyFile, "PII_INDEX_KEY": indexKey}))
+	assert.NoError(t, err)
+	assert.NotNil(t, piiCipher)
+
+	// test 3 missing or invalid keys
+	for _, values := range []map[string]string{
+		{"PII_MASTER_KEY_FILE": keyFile},
+		{"PII_MASTER_KEY_FILE": keyFile, "PII_INDEX_KEY": "not base64"},
+		{"PII_MASTER_KEY_FILE": keyFile, "PII_INDEX_KEY": "c2hvcnQ="},
+		{"PII_MASTER_KEY_FILE": filepath.Join(t.TempDir(), "missing"), "PII_INDEX_KEY": indexKey},
+	} {
+		_, err = ParsePIICipher(env(values))
+		assert.Error(t, err, values)
+	}
+}
+
+func TestParseDatabaseOptions(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults
+	opts, err := ParseDatabaseOptions(env(map[string]string{"DATABASE_URL": "postgres://localhost/users"}))
+	assert.NoError(t, err)
+	assert.Equal(t, repository.NewRepositoryOptions{
+		Dsn:            "postgres://localhost/users",
+		ConnectTimeout: DefaultDatabaseConnectTimeout,
+	}, opts)
+
+	// test 2 overrides
+	opts, err = ParseDatabaseOptions(env(map[string]string{
+		"DB_MAX_OPEN_CONNS":           "20",
+		"DB_MAX_IDLE_CONNS":           "5",
+		"DB_CONN_MAX_LIFETIME":        "30m",
+		"DB_CONN_MAX_IDLE_TIME":       "5m",
+		"DB_STATEMENT_CACHE_CAPACITY": "-1",
+		"DB_QUERY_TIMEOUT":            "3s",
+		"DB_CONNECT_TIMEOUT":          "0s",
+		"DB_REPLICA_URLS":             "postgres://replica-1/users, postgres://replica-2/users",
+		"DB_READ_YOUR_WRITES_WINDOW":  "2s",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, repository.PoolOptions{
+		MaxOpenConns:           20,
+		MaxIdleConns:           5,
+		ConnMaxLifetime:        30 * time.Minute,
+		ConnMaxIdleTime:        5 * time.Minute,
+		StatementCacheCapacity: -1,
+	}, opts.Pool)
+	assert.Equal(t, 3*time.Second, opts.QueryTimeout)
+	assert.Equal(t, time.Duration(0), opts.ConnectTimeout)
+	assert.Equal(t, []string{"postgres://replica-1/users", "postgres://replica-2/users"}, opts.ReplicaDsns)
+	assert.Equal(t, 2*time.Second, opts.ReadYourWritesWindow)
+
+	// test 3 invalid values
+	_, err = ParseDatabaseOptions(env(map[string]string{"DB_MAX_OPEN_CONNS": "many"}))
+	assert.ErrorContains(t, err, "DB_MAX_OPEN_CONNS")
+
+	_, err = ParseDatabaseOptions(env(map[string]string{"DB_QUERY_TIMEOUT": "3"}))
+	assert.ErrorContains(t, err, "DB_QUERY_TIMEOUT")
+}
+
+func TestParseResilienceOptions(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 defaults are left to the repository
+	retry, breaker, err := ParseResilienceOptions(env(nil))
+	assert.NoError(t, err)
+	assert.Equal(t, repository.RetryOptions{}, retry)
+	assert.Equal(t, repository.CircuitBreakerOptions{}, breaker)
+
+	// test 2 overrides
+	retry, breaker, err = ParseResilienceOptions(env(map[string]string{
+		"DB_READ_MAX_RETRIES":          "5",
+		"DB_READ_RETRY_DELAY":          "20ms",
+		"DB_READ_RETRY_MAX_DELAY":      "500ms",
+		"DB_BREAKER_FAILURE_THRESHOLD": "10",
+		"DB_BREAKER_COOLDOWN":          "30s",
+	}))
+	assert.NoError(t, err)
+	assert.Equal(t, repository.RetryOptions{MaxRetries: 5, BaseDelay: 20 * time.Millisecond, MaxDelay: 500 * time.Millisecond}, retry)
+	assert.Equal(t, repository.CircuitBreakerOptions{FailureThreshold: 10, Cooldown: 30 * time.Second}, breaker)
+
+	// test 3 invalid values
+	_, _, err = ParseResilienceOptions(env(map[string]string{"DB_BREAKER_COOLDOWN": "30"}))
+	assert.ErrorContains(t, err, "DB_BREAKER_COOLDOWN")
+}
+
+func TestParseCacheOptions(t *testing.T) {
+	env := func(values map[string]string) func(string) string {
+		return func(name string) string { return values[name] }
+	}
+
+	// test 1 disabled by default
+	_, enabled, err := ParseCacheOptions(env(nil))
+	assert.NoError(t, err)
+	assert.False(t, enabled)
+
+	// test 2 in-process cache
+	opts, enabled, err := ParseCacheOptions(env(map[string]string{
+		"USER_CACHE":      "true",
+		"USER_CACHE_TTL":  "30s",
+		"USER_CACHE_SIZE": "500",
+	}))
+	assert.NoError(t, err)
+	assert.True(t, enabled)
+	assert.Equal(t, repository.CacheOptions{TTL: 30 * time.Second, Size: 500}, opts)
+
+	// test 3 shared on redis
+	opts, _, err = ParseCacheOptions(env(map[string]string{
+		"USER_CACHE":           "true",
+		"USER_CACHE_REDIS_URL": "redis://localhost:6379/1",
+	}))
+	assert.NoError(t, err)
+	assert.IsType(t, &repository.RedisCache{}, opts.Backend)
+
+	// test 4 invalid values
+	_, _, err = ParseCacheOptions(env(map[string]string{"USER_CACHE": "true", "USER_CACHE_TTL": "30"}))
+	assert.ErrorContains(t, err, "USER_CACHE_TTL")
+
+	_, _, err = ParseCacheOptions(env(map[string]string{"USER_CACHE": "true", "USER_CACHE_REDIS_URL": "localhost:6379"}))
+	assert.ErrorContains(t, err, "USER_CACHE_REDIS_URL")
+}
+
+func TestJWT(t *testing.T) {
+	privateKey, err := os.ReadFile("../cert/id_rsa")
+	assert.NoError(t, err)
+	publicKey, err := os.ReadFile("../cert/id_rsa.pub")
+	assert.NoError(t, err)
+	tokens := NewJWT(privateKey, publicKey)
+
+	// sign signs claims the way Create does
+	sign := func(claims jwt.MapClaims) string {
+		key, err := jwt.ParseRSAPrivateKeyFromPEM(privateKey)
+		require.NoError(t, err)
+		token, err := jwt.NewWithClaims(jwt.SigningMethodRS256, claims).SignedString(key)
+		require.NoError(t, err)
+		return token
+	}
+
+	// test 1 valid token
+	token, err := tokens.Create(time.Hour, model.User{UserID: 7, TokenVersion: 2})
+	assert.NoError(t, err)
+
+	user, err := tokens.Validate(token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(7), user.UserID)
+	assert.Equal(t, int32(2), user.TokenVersion)
+
+	// test 2 expired token
+	token, err = tokens.Create(-time.Second, model.User{UserID: 7})
+	assert.NoError(t, err)
+
+	_, err = tokens.Validate(token)
+	assert.ErrorContains(t, err, "expired")
+
+	// test 3 invalid token
+	_, err = tokens.Validate("invalid")
+	assert.Error(t, err)
+
+	// test 4 tokens living longer than MaxTokenTTL are not issued
+	_, err = tokens.Create(MaxTokenTTL+time.Second, model.User{UserID: 7})
+	assert.Error(t, err)
+
+	// test 5 legacy token, expiring at the ttl in nanoseconds and without a
+	// token version
+	_, err = tokens.Validate(sign(jwt.MapClaims{"user_id": "7", "exp": time.Hour.Nanoseconds()}))
+	assert.ErrorContains(t, err, "invalid expiry")
+
+	// test 6 token without expiry
+	_, err = tokens.Validate(sign(jwt.MapClaims{"user_id": "7", "tv": 0}))
+	assert.ErrorContains(t, err, "invalid expiry")
+
+	// test 7 token without token version
+	_, err = tokens.Validate(sign(jwt.MapClaims{"user_id": "7", "exp": time.Now().Add(time.Minute).Unix()}))
+	assert.ErrorContains(t, err, "missing token version")
+}
@@ -9,8 +9,129 @@
 
 CREATE TABLE users (
   id serial PRIMARY KEY,
-  phone_number VARCHAR (13) UNIQUE NOT NULL,
-  full_name VARCHAR ( 60 ) NOT NULL,
+  -- The phone number and full name are encrypted when a cipher is
+  -- configured, bound to the column and the id of the user. The phone number
+  -- is looked up by its blind index, an HMAC of its E.164 form, or the number
+  -- itself without encryption.
+  phone_number TEXT NOT NULL,
+  phone_number_index VARCHAR (64) UNIQUE NOT NULL,
+  full_name TEXT NOT NULL,
   password VARCHAR (255),
-  successful_login numeric DEFAULT 0
-);
\ No newline at end of file
+  successful_login numeric DEFAULT 0,
+  version INTEGER NOT NULL DEFAULT 1,
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  -- ISO 639-1 code of the language messages are served in, empty when the
+  -- user did not choose one.
+  preferred_language VARCHAR (8) NOT NULL DEFAULT '',
+  -- Incremented when the password changes, revoking the access tokens issued
+  -- for the previous versions.
+  token_version INTEGER NOT NULL DEFAULT 0
+);
+-- Hashes of the passwords a user replaced, so the password policy can reject
+-- reusing them. Only the newest entries allowed by the policy are kept.
+CREATE TABLE password_history (
+  id BIGSERIAL PRIMARY KEY,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  password VARCHAR (255) NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history (user_id, id);
+-- Append-only, hash chained log of security relevant events.
+-- Every row stores the hash of the previous row so tampering or removing
+-- rows can be detected by re-computing the chain.
+CREATE TABLE audit_events (
+  id BIGINT PRIMARY KEY,
+  event_type VARCHAR (64) NOT NULL,
+  user_id INTEGER NOT NULL DEFAULT 0,
+  actor_id INTEGER NOT NULL DEFAULT 0,
+  metadata JSONB NOT NULL DEFAULT '{}',
+  ip_address VARCHAR (45) NOT NULL DEFAULT '',
+  request_id VARCHAR (128) NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL,
+  prev_hash VARCHAR (64) NOT NULL DEFAULT '',
+  hash VARCHAR (64) NOT NULL
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id);
+CREATE INDEX audit_events_event_type_idx ON audit_events (event_type);
+
+CREATE FUNCTION audit_events_append_only() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'audit_events is append-only';
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE TRIGGER audit_events_append_only
+  BEFORE UPDATE OR DELETE ON audit_events
+  FOR EACH ROW EXECUTE FUNCTION audit_events_append_only();
+
+-- Transactional outbox. Domain events are written in the same transaction as
+-- the change they describe and published to other services by the relay.
+-- Failed events are retried with a back-off from next_attempt_at until they
+-- are dead. The payloads only carry the id of the user, published events are
+-- purged after EVENT_RETENTION.
+CREATE TABLE outbox_events (
+  id BIGSERIAL PRIMARY KEY,
+  event_type VARCHAR (64) NOT NULL,
+  aggregate_id INTEGER NOT NULL,
+  payload JSONB NOT NULL,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  claimed_until TIMESTAMPTZ,
+  published_at TIMESTAMPTZ,
+  dead_at TIMESTAMPTZ,
+  last_error TEXT
+);
+
+CREATE INDEX outbox_events_unpublished_idx ON outbox_events (id) WHERE published_at IS NULL AND dead_at IS NULL;
+
+-- Outgoing webhooks managed by admins. Every matching domain event creates
+-- one delivery per subscription which is retried until delivered or dead.
+-- Delivered ones are purged after EVENT_RETENTION.
+CREATE TABLE webhook_subscriptions (
+  id serial PRIMARY KEY,
+  url VARCHAR (2048) NOT NULL,
+  event_types JSONB NOT NULL DEFAULT '[]',
+  secret VARCHAR (128) NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE TABLE webhook_deliveries (
+  id BIGSERIAL PRIMARY KEY,
+  subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id),
+  event_id BIGINT NOT NULL,
+  event_type VARCHAR (64) NOT NULL,
+  payload JSONB NOT NULL,
+  status VARCHAR (16) NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  last_status_code INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
+
+-- First responses to the requests sent with an Idempotency-Key, replayed to
+-- the retries of the same caller until they expire. A record without a status
+-- code is held by the request being handled until locked_until. The body is
+-- encrypted like the personal data of the users, bound to its key.
+CREATE TABLE idempotency_keys (
+  caller VARCHAR (64) NOT NULL,
+  idempotency_key VARCHAR (255) NOT NULL,
+  fingerprint VARCHAR (64) NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  header JSONB NOT NULL DEFAULT '{}',
+  body BYTEA,
+  created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+  locked_until TIMESTAMPTZ NOT NULL,
+  expires_at TIMESTAMPTZ NOT NULL,
+  PRIMARY KEY (caller, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
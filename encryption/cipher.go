@@ -0,0 +1,291 @@
+package encryption
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// ErrMalformedValue is returned when decrypting a value that has been
+// tampered with or was encrypted under another key.
+var ErrMalformedValue = errors.New("encryption: malformed value")
+
+const (
+	// valuePrefix marks the encrypted values, version 2 of the format. Values
+	// without a prefix were stored before encryption was enabled.
+	valuePrefix = "enc:v2:"
+	// legacyValuePrefix marks the values of version 1, encrypted without
+	// associated data. They are decrypted but need to be encrypted again.
+	legacyValuePrefix = "enc:v1:"
+)
+
+const (
+	// maxKeyIDLength bounds the master key ids, stored in one byte.
+	maxKeyIDLength = 255
+	// maxDataKeyUses is the number of values encrypted under one data key
+	// before a new one is generated, far below the limit of AES-GCM with
+	// random nonces.
+	maxDataKeyUses = 1 << 24
+	// minIndexKeyLength is the length of a SHA-256 HMAC key.
+	minIndexKeyLength = 32
+)
+
+// Cipher encrypts values under data keys wrapped by the master keys of a
+// KeyProvider, and computes their blind index. It is safe for concurrent use.
+type Cipher struct {
+	provider KeyProvider
+	indexKey []byte
+
+	mu      sync.Mutex
+	current *dataKey
+	// generating shares the generation of a new data key between the
+	// callers needing one at the same time, by master key id
+	generating singleflight.Group
+	// unwrapped caches the data keys of the values decrypted, by master key
+	// id and wrapped key. It stays small since every process only generates
+	// a new data key on start and after maxDataKeyUses encryptions.
+	unwrapped map[string]cipher.AEAD
+}
+
+type dataKey struct {
+	keyID   string
+	wrapped []byte
+	aead    cipher.AEAD
+	uses    int
+}
+
+// NewCipher returns a cipher wrapping its data keys with the provider. The
+// index key, at least 32 bytes, keys the blind index; unlike the master keys
+// it cannot be rotated without recomputing every index.
+func NewCipher(provider KeyProvider, indexKey []byte) (*Cipher, error) {
+	if len(indexKey) < minIndexKeyLength {
+		return nil, errors.New("encryption: index key must be at least 32 bytes")
+	}
+	if len(provider.CurrentKeyID()) > maxKeyIDLength {
+		return nil, errors.New("encryption: master key id is too long")
+	}
+
+	return &Cipher{
+		provider:  provider,
+		indexKey:  indexKey,
+		unwrapped: make(map[string]cipher.AEAD),
+	}, nil
+}
+
+// Encrypt returns the encrypted value, prefixed by the wrapped data key. The
+// value is bound to the associated data, e.g. the column and the row it is
+// stored in, which must be given again to decrypt it.
+func (c *Cipher) Encrypt(ctx context.Context, plaintext, associatedData string) (string, error) {
+	key, err := c.dataKey(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	sealed, err := seal(key.aead, []byte(plaintext), []byte(associatedData))
+	if err != nil {
+		return "", err
+	}
+
+	value := make([]byte, 0, 1+len(key.keyID)+2+len(key.wrapped)+len(sealed))
+	value = append(value, byte(len(key.keyID)))
+	value = append(value, key.keyID...)
+	value = binary.BigEndian.AppendUint16(value, uint16(len(key.wrapped)))
+	value = append(value, key.wrapped...)
+	value = append(value, sealed...)
+
+	return valuePrefix + base64.RawURLEncoding.EncodeToString(value), nil
+}
+
+// Decrypt returns the plaintext of a value returned by Encrypt with the same
+// associated data. Values stored before encryption was enabled are returned
+// as is.
+func (c *Cipher) Decrypt(ctx context.Context, value, associatedData string) (string, error) {
+	additionalData := []byte(associatedData)
+	encoded, ok := strings.CutPrefix(value, valuePrefix)
+	if !ok {
+		if encoded, ok = strings.CutPrefix(value, legacyValuePrefix); !ok {
+			return value, nil
+		}
+		additionalData = nil
+	}
+
+	keyID, wrapped, sealed, err := parseValue(encoded)
+	if err != nil {
+		return "", err
+	}
+
+	aead, err := c.unwrap(ctx, keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+
+	plaintext, err := open(aead, sealed, additionalData)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// NeedsReencrypt reports whether the value is not encrypted, encrypted by a
+// previous version of the format or not under the current master key.
+func (c *Cipher) NeedsReencrypt(value string) bool {
+	encoded, ok := strings.CutPrefix(value, valuePrefix)
+	if !ok {
+		return true
+	}
+
+	keyID, _, _, err := parseValue(encoded)
+	return err != nil || keyID != c.provider.CurrentKeyID()
+}
+
+// BlindIndex returns the hex encoded HMAC-SHA256 of the value, so equal
+// values can be looked up without being decrypted.
+func (c *Cipher) BlindIndex(value string) string {
+	mac := hmac.New(sha256.New, c.indexKey)
+	mac.Write([]byte(value))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// dataKey returns the data key to encrypt with, generating and wrapping a new
+// one when there is none yet, it has been used too often or the master key
+// changed.
+func (c *Cipher) dataKey(ctx context.Context) (*dataKey, error) {
+	keyID := c.provider.CurrentKeyID()
+	if key := c.useCurrent(keyID); key != nil {
+		return key, nil
+	}
+
+	// Wrapping may call a remote KMS, the lock is not held meanwhile. The new
+	// key is shared by the callers waiting for it, it must not fail because
+	// the first of them gave up
+	wrapCtx := context.WithoutCancel(ctx)
+	result := c.generating.DoChan(keyID, func() (interface{}, error) {
+		return c.newDataKey(wrapCtx, keyID)
+	})
+
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case res := <-result:
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		key := res.Val.(*dataKey)
+		c.mu.Lock()
+		key.uses++
+		c.mu.Unlock()
+		return key, nil
+	}
+}
+
+// useCurrent counts one more use of the current data key and returns it,
+// unless there is none, it has been used too often or it is not wrapped by
+// the master key keyID.
+func (c *Cipher) useCurrent(keyID string) *dataKey {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := c.current
+	if key == nil || key.keyID != keyID || key.uses >= maxDataKeyUses {
+		return nil
+	}
+	key.uses++
+	return key
+}
+
+// newDataKey generates a data key, wraps it with the master key keyID and
+// publishes it as the current one, unused.
+func (c *Cipher) newDataKey(ctx context.Context, keyID string) (*dataKey, error) {
+	// Another generation may have published a key since it was checked
+	c.mu.Lock()
+	key := c.current
+	published := key != nil && key.keyID == keyID && key.uses < maxDataKeyUses
+	c.mu.Unlock()
+	if published {
+		return key, nil
+	}
+
+	plaintextKey := make([]byte, 32)
+	if _, err := rand.Read(plaintextKey); err != nil {
+		return nil, err
+	}
+
+	wrapped, err := c.provider.WrapKey(ctx, keyID, plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) > 0xffff {
+		return nil, errors.New("encryption: wrapped data key is too long")
+	}
+
+	aead, err := newAEAD(plaintextKey)
+	if err != nil {
+		return nil, err
+	}
+
+	key = &dataKey{keyID: keyID, wrapped: wrapped, aead: aead}
+	c.mu.Lock()
+	c.current = key
+	c.unwrapped[keyID+"\x00"+string(wrapped)] = aead
+	c.mu.Unlock()
+	return key, nil
+}
+
+// unwrap returns the data key of a value, asking the provider on the first
+// use only.
+func (c *Cipher) unwrap(ctx context.Context, keyID string, wrapped []byte) (cipher.AEAD, error) {
+	cacheKey := keyID + "\x00" + string(wrapped)
+
+	c.mu.Lock()
+	aead, ok := c.unwrapped[cacheKey]
+	c.mu.Unlock()
+	if ok {
+		return aead, nil
+	}
+
+	plaintextKey, err := c.provider.UnwrapKey(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+	if aead, err = newAEAD(plaintextKey); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	c.unwrapped[cacheKey] = aead
+	c.mu.Unlock()
+	return aead, nil
+}
+
+func parseValue(encoded string) (keyID string, wrapped, sealed []byte, err error) {
+	value, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(value) < 1 {
+		return "", nil, nil, ErrMalformedValue
+	}
+
+	keyIDLength := int(value[0])
+	value = value[1:]
+	if len(value) < keyIDLength+2 {
+		return "", nil, nil, ErrMalformedValue
+	}
+	keyID, value = string(value[:keyIDLength]), value[keyIDLength:]
+
+	wrappedLength := int(binary.BigEndian.Uint16(value))
+	value = value[2:]
+	if len(value) < wrappedLength {
+		return "", nil, nil, ErrMalformedValue
+	}
+
+	return keyID, value[:wrappedLength], value[wrappedLength:], nil
+}
@@ -0,0 +1,204 @@
+package encryption
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	oldKeyFile = "old MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"
+	keyFile    = "# newest first\nnew ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\n\n" + oldKeyFile
+)
+
+var indexKey = []byte("0123456789abcdef0123456789abcdef")
+
+func newTestCipher(t *testing.T, keys string) *Cipher {
+	provider, err := ParseKeyFile(strings.NewReader(keys))
+	assert.NoError(t, err)
+
+	c, err := NewCipher(provider, indexKey)
+	assert.NoError(t, err)
+	return c
+}
+
+func TestParseKeyFile(t *testing.T) {
+	// test 1 the first key is the current one
+	provider, err := ParseKeyFile(strings.NewReader(keyFile))
+	assert.NoError(t, err)
+	assert.Equal(t, "new", provider.CurrentKeyID())
+
+	// test 2 invalid files
+	for _, keys := range []string{
+		"",
+		"# no key\n",
+		"new\n",
+		"new c2hvcnQ=\n",
+		"new not-base64\n",
+		keyFile + "new ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\n",
+	} {
+		_, err := ParseKeyFile(strings.NewReader(keys))
+		assert.Error(t, err, keys)
+	}
+}
+
+func TestCipher(t *testing.T) {
+	ctx := context.Background()
+	c := newTestCipher(t, keyFile)
+
+	// test 1 round trip
+	value, err := c.Encrypt(ctx, "+628123456789", "users.phone_number:1")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(value, valuePrefix))
+	assert.NotContains(t, value, "628123456789")
+
+	plaintext, err := c.Decrypt(ctx, value, "users.phone_number:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "+628123456789", plaintext)
+
+	// test 2 encryption is randomised, the blind index is not
+	other, _ := c.Encrypt(ctx, "+628123456789", "users.phone_number:1")
+	assert.NotEqual(t, value, other)
+	assert.Equal(t, c.BlindIndex("+628123456789"), c.BlindIndex("+628123456789"))
+	assert.NotEqual(t, c.BlindIndex("+628123456789"), c.BlindIndex("+628123456780"))
+	assert.Len(t, c.BlindIndex("+628123456789"), 64)
+
+	// test 3 plaintext stored before encryption is returned as is
+	plaintext, err = c.Decrypt(ctx, "Leonardo", "users.full_name:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Leonardo", plaintext)
+	assert.True(t, c.NeedsReencrypt("Leonardo"))
+	assert.False(t, c.NeedsReencrypt(value))
+
+	// test 4 tampered values
+	tampered := value[:len(value)-2] + "AA"
+	if tampered == value {
+		tampered = value[:len(value)-2] + "BB"
+	}
+	_, err = c.Decrypt(ctx, tampered, "users.phone_number:1")
+	assert.ErrorIs(t, err, ErrMalformedValue)
+
+	_, err = c.Decrypt(ctx, valuePrefix+"!", "users.phone_number:1")
+	assert.ErrorIs(t, err, ErrMalformedValue)
+
+	// test 5 values copied to another row or column
+	for _, associatedData := range []string{"users.phone_number:2", "users.full_name:1", ""} {
+		_, err = c.Decrypt(ctx, value, associatedData)
+		assert.ErrorIs(t, err, ErrMalformedValue, associatedData)
+	}
+
+	// test 6 values of version 1, without associated data, are still
+	// decrypted, and re-encrypted
+	legacy, err := c.Encrypt(ctx, "+628123456789", "")
+	assert.NoError(t, err)
+	legacy = legacyValuePrefix + strings.TrimPrefix(legacy, valuePrefix)
+
+	plaintext, err = c.Decrypt(ctx, legacy, "users.phone_number:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "+628123456789", plaintext)
+	assert.True(t, c.NeedsReencrypt(legacy))
+
+	// test 7 short index key
+	_, err = NewCipher(c.provider, []byte("short"))
+	assert.Error(t, err)
+}
+
+func TestCipherRotation(t *testing.T) {
+	ctx := context.Background()
+	oldCipher := newTestCipher(t, oldKeyFile)
+	value, err := oldCipher.Encrypt(ctx, "Leonardo", "users.full_name:1")
+	assert.NoError(t, err)
+
+	// test 1 values of retired keys are still decrypted, and re-encrypted
+	c := newTestCipher(t, keyFile)
+	assert.True(t, c.NeedsReencrypt(value))
+
+	plaintext, err := c.Decrypt(ctx, value, "users.full_name:1")
+	assert.NoError(t, err)
+	assert.Equal(t, "Leonardo", plaintext)
+
+	reencrypted, err := c.Encrypt(ctx, plaintext, "users.full_name:1")
+	assert.NoError(t, err)
+	assert.False(t, c.NeedsReencrypt(reencrypted))
+
+	// test 2 the blind index only depends on the index key
+	assert.Equal(t, oldCipher.BlindIndex("+628123456789"), c.BlindIndex("+628123456789"))
+
+	// test 3 values of removed keys cannot be decrypted
+	_, err = newTestCipher(t, "new ZmVkY2JhOTg3NjU0MzIxMGZlZGNiYTk4NzY1NDMyMTA=\n").Decrypt(ctx, value, "users.full_name:1")
+	assert.ErrorIs(t, err, ErrUnknownKey)
+}
+
+// slowKeyProvider wraps the data keys once released, like a remote KMS taking
+// its time.
+type slowKeyProvider struct {
+	KeyProvider
+	wrapping chan struct{}
+	release  chan struct{}
+	wraps    atomic.Int32
+}
+
+func (p *slowKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	if p.wraps.Add(1) == 1 {
+		close(p.wrapping)
+	}
+	<-p.release
+	return p.KeyProvider.WrapKey(ctx, keyID, dataKey)
+}
+
+func TestCipherConcurrentDataKey(t *testing.T) {
+	ctx := context.Background()
+	value, err := newTestCipher(t, keyFile).Encrypt(ctx, "Leonardo", "users.full_name:1")
+	assert.NoError(t, err)
+
+	provider, err := ParseKeyFile(strings.NewReader(keyFile))
+	assert.NoError(t, err)
+	slow := &slowKeyProvider{KeyProvider: provider, wrapping: make(chan struct{}), release: make(chan struct{})}
+	c, err := NewCipher(slow, indexKey)
+	assert.NoError(t, err)
+
+	var wg sync.WaitGroup
+	encrypted := make([]string, 8)
+	for i := range encrypted {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			encrypted[i], _ = c.Encrypt(ctx, "+628123456789", "users.phone_number:1")
+		}(i)
+	}
+	<-slow.wrapping
+
+	// test 1 values are decrypted while a data key is being wrapped
+	decrypted := make(chan string)
+	go func() {
+		plaintext, _ := c.Decrypt(ctx, value, "users.full_name:1")
+		decrypted <- plaintext
+	}()
+	select {
+	case plaintext := <-decrypted:
+		assert.Equal(t, "Leonardo", plaintext)
+	case <-time.After(5 * time.Second):
+		t.Fatal("decrypt waited for the data key to be wrapped")
+	}
+
+	// test 2 a caller giving up does not wait for the wrap
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	_, err = c.Encrypt(canceled, "+628123456789", "users.phone_number:1")
+	assert.ErrorIs(t, err, context.Canceled)
+
+	// test 3 the encryptions waiting share one data key
+	close(slow.release)
+	wg.Wait()
+	assert.Equal(t, int32(1), slow.wraps.Load())
+	for _, value := range encrypted {
+		plaintext, err := c.Decrypt(ctx, value, "users.phone_number:1")
+		assert.NoError(t, err)
+		assert.Equal(t, "+628123456789", plaintext)
+	}
+}
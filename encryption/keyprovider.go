@@ -0,0 +1,164 @@
+// Package encryption implements the envelope encryption of the personal data
+// stored by the service.
+//
+// Values are encrypted with AES-256-GCM under a data key, which is itself
+// encrypted, wrapped, by a master key held by a KeyProvider and stored next to
+// the value. Rotating the master key only requires re-wrapping data keys, and
+// the master key never has to be loaded by the service when the provider is a
+// key management service.
+//
+// Values that must still be looked up, e.g. phone numbers, also get a blind
+// index: a keyed HMAC of the value, stable across encryptions.
+package encryption
+
+import (
+	"bufio"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// KeyProvider wraps and unwraps data keys with master keys.
+type KeyProvider interface {
+	// CurrentKeyID is the master key new data keys are wrapped with.
+	CurrentKeyID() string
+	WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// ErrUnknownKey is returned when unwrapping a data key wrapped by a master key
+// the provider does not hold.
+var ErrUnknownKey = errors.New("encryption: unknown master key")
+
+// FileKeyProvider holds the master keys in memory, read from a key file. It
+// is meant for local development; production deployments should keep their
+// master keys in a key management service.
+type FileKeyProvider struct {
+	keys    map[string]cipher.AEAD
+	current string
+}
+
+// LoadKeyFile reads the master keys of a key file, see ParseKeyFile.
+func LoadKeyFile(path string) (*FileKeyProvider, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	provider, err := ParseKeyFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return provider, nil
+}
+
+// ParseKeyFile reads master keys written one per line as a key id and the
+// base64 encoded 32 byte key, separated by a space, e.g.
+//
+//	2024-06 q83vEjRWeJCrze8SNFZ4kKvN7xI0VniQq83vEjRWeJA=
+//
+// The first key wraps the new data keys, the others only unwrap existing
+// ones. Blank lines and lines starting with # are skipped.
+func ParseKeyFile(r io.Reader) (*FileKeyProvider, error) {
+	provider := &FileKeyProvider{keys: make(map[string]cipher.AEAD)}
+
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		keyID, encoded, found := strings.Cut(text, " ")
+		if !found || len(keyID) > maxKeyIDLength {
+			return nil, fmt.Errorf("line %d: expected a key id and a key", line)
+		}
+		if _, exists := provider.keys[keyID]; exists {
+			return nil, fmt.Errorf("line %d: duplicate key id %q", line, keyID)
+		}
+
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(encoded))
+		if err != nil || len(key) != 32 {
+			return nil, fmt.Errorf("line %d: key must be 32 bytes encoded in base64", line)
+		}
+
+		aead, err := newAEAD(key)
+		if err != nil {
+			return nil, err
+		}
+		provider.keys[keyID] = aead
+		if provider.current == "" {
+			provider.current = keyID
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if provider.current == "" {
+		return nil, errors.New("no master key")
+	}
+	return provider, nil
+}
+
+// CurrentKeyID returns the id of the first key of the file.
+func (p *FileKeyProvider) CurrentKeyID() string {
+	return p.current
+}
+
+// WrapKey encrypts the data key with the master key.
+func (p *FileKeyProvider) WrapKey(ctx context.Context, keyID string, dataKey []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return seal(aead, dataKey, []byte(keyID))
+}
+
+// UnwrapKey decrypts a data key wrapped by WrapKey.
+func (p *FileKeyProvider) UnwrapKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	aead, ok := p.keys[keyID]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	return open(aead, wrapped, []byte(keyID))
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// seal encrypts the plaintext under a random nonce, prepended to the result.
+func seal(aead cipher.AEAD, plaintext, additionalData []byte) ([]byte, error) {
+	nonce := make([]byte, aead.NonceSize(), aead.NonceSize()+len(plaintext)+aead.Overhead())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return aead.Seal(nonce, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts the result of seal.
+func open(aead cipher.AEAD, sealed, additionalData []byte) ([]byte, error) {
+	if len(sealed) < aead.NonceSize() {
+		return nil, ErrMalformedValue
+	}
+	nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+
+	plaintext, err := aead.Open(nil, nonce, ciphertext, additionalData)
+	if err != nil {
+		return nil, ErrMalformedValue
+	}
+	return plaintext, nil
+}
@@ -0,0 +1,110 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const maxAuditEventsLimit = 500
+
+func (s *Server) ListAuditEvents(ctx echo.Context, params generated.ListAuditEventsParams) error {
+
+	var resp = generated.AuditEventsResponse{Events: []generated.AuditEvent{}}
+
+	if _, err := s.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	input := repository.GetAuditEventsInput{}
+	if params.UserId != nil {
+		input.UserID = *params.UserId
+	}
+	if params.EventType != nil {
+		input.EventType = *params.EventType
+	}
+	if params.AfterId != nil {
+		input.AfterID = *params.AfterId
+	}
+	if params.Limit != nil {
+		input.Limit = *params.Limit
+	}
+	if input.Limit > maxAuditEventsLimit {
+		input.Limit = maxAuditEventsLimit
+	}
+
+	events, err := s.Repository.GetAuditEvents(ctx.Request().Context(), input)
+	if err != nil {
+		return err
+	}
+
+	for _, event := range events {
+		resp.Events = append(resp.Events, generated.AuditEvent{
+			Id:        event.ID,
+			EventType: event.EventType,
+			UserId:    event.UserID,
+			ActorId:   event.ActorID,
+			Metadata:  event.Metadata,
+			IpAddress: event.IPAddress,
+			RequestId: event.RequestID,
+			CreatedAt: event.CreatedAt,
+			PrevHash:  event.PrevHash,
+			Hash:      event.Hash,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// authorizeAdmin validates the token of the request and checks it belongs to an
+// admin.
+func (s *Server) authorizeAdmin(ctx echo.Context) (userData model.User, err error) {
+	userData, err = s.authenticate(ctx)
+	if err != nil {
+		return userData, err
+	}
+
+	if !s.Config.IsAdmin(userData.UserID) {
+		return userData, apierror.New(apierror.CodeForbidden, i18n.DetailForbidden)
+	}
+
+	return userData, nil
+}
+
+// audit records a security relevant event, with the client and request of
+// ctx. Failing to write it must not fail the request that triggered it, the
+// error is logged instead.
+func (s *Server) audit(ctx context.Context, event repository.InsertAuditEventInput) {
+	event.IPAddress = clientIPFromContext(ctx)
+	event.RequestID = logger.RequestIDFromContext(ctx)
+
+	if _, err := s.Repository.InsertAuditEvent(ctx, event); err != nil {
+		s.log().ErrorContext(ctx, "insert audit event", "event_type", event.EventType, "error", err)
+	}
+}
+
+type clientIPKey struct{}
+
+// withClientIP returns ctx carrying the address of the client, recorded in the
+// audit log.
+func withClientIP(ctx context.Context, ip string) context.Context {
+	return context.WithValue(ctx, clientIPKey{}, ip)
+}
+
+func clientIPFromContext(ctx context.Context) string {
+	ip, _ := ctx.Value(clientIPKey{}).(string)
+	return ip
+}
+
+// requestContext returns the context of the request, carrying the address of
+// the client.
+func requestContext(ctx echo.Context) context.Context {
+	return withClientIP(ctx.Request().Context(), ctx.RealIP())
+}
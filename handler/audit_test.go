@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestListAuditEvents(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+
+	prvKey, err := os.ReadFile("../cert/id_rsa")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	pubKey, err := os.ReadFile("../cert/id_rsa.pub")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	jwtToken := config.NewJWT(prvKey, pubKey)
+
+	adminToken, _ := jwtToken.Create(time.Minute*1, model.User{
+		UserID: 1,
+	})
+
+	userToken, _ := jwtToken.Create(time.Minute*1, model.User{
+		UserID: 2,
+	})
+
+	limit := 1000
+	eventType := model.AuditEventLoginFailed
+
+	type args struct {
+		token  string
+		params generated.ListAuditEventsParams
+	}
+
+	var tests = []struct {
+		name   string
+		args   args
+		mock   func()
+		assert func(error, echo.Context)
+	}{
+		{
+			name: "success",
+			args: args{
+				token: adminToken,
+				params: generated.ListAuditEventsParams{
+					EventType: &eventType,
+					Limit:     &limit,
+				},
+			},
+			mock: func() {
+				repo.On("GetAuditEvents", mock.Anything, repository.GetAuditEventsInput{
+					EventType: eventType,
+					Limit:     maxAuditEventsLimit,
+				}).Return([]model.AuditEvent{
+					{ID: 1, EventType: eventType, UserID: 2},
+				}, nil).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, ctx.Response().Status)
+			},
+		},
+		{
+			name: "token missing",
+			args: args{},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusForbidden, ctx.Response().Status)
+			},
+		},
+		{
+			name: "not an admin",
+			args: args{
+				token: userToken,
+			},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusForbidden, ctx.Response().Status)
+			},
+		},
+		{
+			name: "fail - get audit events",
+			args: args{
+				token: adminToken,
+			},
+			mock: func() {
+				repo.On("GetAuditEvents", mock.Anything, repository.GetAuditEventsInput{}).Return(nil, errors.New("error")).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusInternalServerError, ctx.Response().Status)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, tt.args.token)
+		tt.mock()
+		s := Server{
+			Repository: repo,
+			Config: &config.Config{
+				JWT:          jwtToken,
+				AdminUserIDs: []int32{1},
+			},
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newTestContextWithToken("", tt.args.token)
+
+			err := handleError(&s, ctx, s.ListAuditEvents(ctx, tt.args.params))
+
+			tt.assert(err, ctx)
+		})
+	}
+}
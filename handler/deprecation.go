@@ -0,0 +1,50 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// LegacyRoutes maps the deprecated RPC-style routes to their /v1 successor.
+var LegacyRoutes = map[string]string{
+	"/register":    "/v1/users",
+	"/login":       "/v1/sessions",
+	"/users":       "/v1/users/me",
+	"/update-user": "/v1/users/me",
+}
+
+var (
+	// LegacyDeprecatedAt is when the legacy routes were deprecated.
+	LegacyDeprecatedAt = time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC)
+	// DefaultLegacySunset is when the legacy routes are planned to be removed.
+	DefaultLegacySunset = time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC)
+)
+
+type DeprecationOptions struct {
+	// Routes maps deprecated route paths to the path of their successor.
+	Routes       map[string]string
+	DeprecatedAt time.Time
+	Sunset       time.Time
+}
+
+// Deprecation marks responses of deprecated routes with the Deprecation
+// (RFC 9745) and Sunset (RFC 8594) headers, and links to the successor route.
+func Deprecation(opts DeprecationOptions) echo.MiddlewareFunc {
+	deprecation := fmt.Sprintf("@%d", opts.DeprecatedAt.Unix())
+	sunset := opts.Sunset.UTC().Format(http.TimeFormat)
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			if successor, ok := opts.Routes[ctx.Path()]; ok {
+				header := ctx.Response().Header()
+				header.Set("Deprecation", deprecation)
+				header.Set("Sunset", sunset)
+				header.Add("Link", fmt.Sprintf(`<%s>; rel="successor-version"`, successor))
+			}
+			return next(ctx)
+		}
+	}
+}
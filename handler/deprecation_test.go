@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeprecation(t *testing.T) {
+	e := echo.New()
+	e.Use(Deprecation(DeprecationOptions{
+		Routes:       LegacyRoutes,
+		DeprecatedAt: time.Date(2026, time.October, 19, 0, 0, 0, 0, time.UTC),
+		Sunset:       time.Date(2027, time.April, 19, 0, 0, 0, 0, time.UTC),
+	}))
+
+	ok := func(ctx echo.Context) error { return ctx.NoContent(http.StatusOK) }
+	e.GET("/users", ok)
+	e.GET("/v1/users/me", ok)
+
+	// test 1 legacy route
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+
+	assert.Equal(t, "@1792368000", rec.Header().Get("Deprecation"))
+	assert.Equal(t, "Mon, 19 Apr 2027 00:00:00 GMT", rec.Header().Get("Sunset"))
+	assert.Equal(t, `</v1/users/me>; rel="successor-version"`, rec.Header().Get("Link"))
+
+	// test 2 versioned route
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/v1/users/me", nil))
+
+	assert.Empty(t, rec.Header().Get("Deprecation"))
+	assert.Empty(t, rec.Header().Get("Sunset"))
+}
@@ -1,6 +1,7 @@
 package handler
 
 import (
+	"context"
 	"errors"
 	"log"
 	"net/http"
@@ -38,8 +39,20 @@ func newTestContextWithToken(requestBody string, token string) (echo.Context, er
 	return c, nil
 }
 
+// handleError renders the error returned by a handler like Echo does.
+func handleError(s *Server, ctx echo.Context, err error) error {
+	if err != nil {
+		s.HTTPErrorHandler(err, ctx)
+	}
+	return err
+}
+
 func TestUserRegistration(t *testing.T) {
 	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
 
 	type args struct {
 		requestBody string
@@ -109,7 +122,7 @@ func TestUserRegistration(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx, _ := newTestContext(tt.args.requestBody)
 
-			err := s.UserRegistration(ctx)
+			err := handleError(&s, ctx, s.UserRegistration(ctx))
 
 			tt.assert(err, ctx)
 		})
@@ -118,6 +131,10 @@ func TestUserRegistration(t *testing.T) {
 
 func TestLogin(t *testing.T) {
 	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
 
 	prvKey, err := os.ReadFile("../cert/id_rsa")
 	if err != nil {
@@ -156,6 +173,7 @@ func TestLogin(t *testing.T) {
 				}, nil).Once()
 
 				repo.On("UpdateSuccessfulLogin", mock.Anything, mock.Anything).Return(nil).Once()
+				repo.On("RehashPassword", mock.Anything, mock.Anything).Return(nil).Once()
 			},
 			assert: func(err error, ctx echo.Context) {
 				assert.NoError(t, err)
@@ -230,7 +248,7 @@ func TestLogin(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx, _ := newTestContext(tt.args.requestBody)
 
-			err := s.Login(ctx)
+			err := handleError(&s, ctx, s.Login(ctx))
 
 			tt.assert(err, ctx)
 		})
@@ -239,6 +257,10 @@ func TestLogin(t *testing.T) {
 
 func TestUsers(t *testing.T) {
 	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
 
 	prvKey, err := os.ReadFile("../cert/id_rsa")
 	if err != nil {
@@ -277,12 +299,14 @@ func TestUsers(t *testing.T) {
 				}).Return(model.User{
 					UserID:   1,
 					FullName: "Leonardo",
+					Version:  3,
 				}, nil).Once()
 
 			},
 			assert: func(err error, ctx echo.Context) {
 				assert.NoError(t, err)
 				assert.Equal(t, http.StatusOK, ctx.Response().Status)
+				assert.Equal(t, `"3"`, ctx.Response().Header().Get("ETag"))
 			},
 		},
 		{
@@ -311,6 +335,7 @@ func TestUsers(t *testing.T) {
 	}
 
 	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, tt.args.token)
 		tt.mock()
 		s := Server{
 			Repository: repo,
@@ -322,7 +347,7 @@ func TestUsers(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			ctx, _ := newTestContextWithToken("", tt.args.token)
 
-			err := s.Users(ctx)
+			err := handleError(&s, ctx, s.Users(ctx))
 
 			tt.assert(err, ctx)
 		})
@@ -331,6 +356,10 @@ func TestUsers(t *testing.T) {
 
 func TestUpdateUser(t *testing.T) {
 	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
 
 	prvKey, err := os.ReadFile("../cert/id_rsa")
 	if err != nil {
@@ -351,8 +380,12 @@ func TestUpdateUser(t *testing.T) {
 	type args struct {
 		token       string
 		requestBody string
+		ifMatch     string
 	}
 
+	phoneNumber, fullName := "+6281233245", "leo"
+	expectedVersion := int32(4)
+
 	var tests = []struct {
 		name   string
 		args   args
@@ -368,15 +401,63 @@ func TestUpdateUser(t *testing.T) {
 			mock: func() {
 				repo.On("UpdateUserData", mock.Anything, repository.UpdateUserDataInput{
 					UserID: 1,
-					Data: map[string]string{
-						"phone_number": "+6281233245",
-						"full_name":    "leo",
-					},
-				}).Return(nil).Once()
+					Patch:  repository.UserPatch{PhoneNumber: &phoneNumber, FullName: &fullName},
+				}).Return(repository.UpdateUserDataOutput{Version: 2}, nil).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusOK, ctx.Response().Status)
+				assert.Equal(t, `"2"`, ctx.Response().Header().Get("ETag"))
+			},
+		},
+		{
+			name: "success - matching If-Match",
+			args: args{
+				token:       token,
+				requestBody: `{"phone_number":"+6281233245","full_name":"leo"}`,
+				ifMatch:     `"4"`,
+			},
+			mock: func() {
+				repo.On("UpdateUserData", mock.Anything, repository.UpdateUserDataInput{
+					UserID:          1,
+					Patch:           repository.UserPatch{PhoneNumber: &phoneNumber, FullName: &fullName},
+					ExpectedVersion: &expectedVersion,
+				}).Return(repository.UpdateUserDataOutput{Version: 5}, nil).Once()
 			},
 			assert: func(err error, ctx echo.Context) {
 				assert.NoError(t, err)
 				assert.Equal(t, http.StatusOK, ctx.Response().Status)
+				assert.Equal(t, `"5"`, ctx.Response().Header().Get("ETag"))
+			},
+		},
+		{
+			name: "fail - stale If-Match",
+			args: args{
+				token:       token,
+				requestBody: `{"phone_number":"+6281233245","full_name":"leo"}`,
+				ifMatch:     `"4"`,
+			},
+			mock: func() {
+				repo.On("UpdateUserData", mock.Anything, repository.UpdateUserDataInput{
+					UserID:          1,
+					Patch:           repository.UserPatch{PhoneNumber: &phoneNumber, FullName: &fullName},
+					ExpectedVersion: &expectedVersion,
+				}).Return(repository.UpdateUserDataOutput{}, repository.ErrVersionConflict).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusPreconditionFailed, ctx.Response().Status)
+			},
+		},
+		{
+			name: "fail - invalid If-Match",
+			args: args{
+				token:       token,
+				requestBody: `{"phone_number":"+6281233245","full_name":"leo"}`,
+				ifMatch:     `W/"4"`,
+			},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusBadRequest, ctx.Response().Status)
 			},
 		},
 		{
@@ -396,11 +477,8 @@ func TestUpdateUser(t *testing.T) {
 			mock: func() {
 				repo.On("UpdateUserData", mock.Anything, repository.UpdateUserDataInput{
 					UserID: 1,
-					Data: map[string]string{
-						"phone_number": "+6281233245",
-						"full_name":    "leo",
-					},
-				}).Return(errors.New("error")).Once()
+					Patch:  repository.UserPatch{PhoneNumber: &phoneNumber, FullName: &fullName},
+				}).Return(repository.UpdateUserDataOutput{}, errors.New("error")).Once()
 			},
 			assert: func(err error, ctx echo.Context) {
 				assert.Equal(t, http.StatusInternalServerError, ctx.Response().Status)
@@ -409,6 +487,7 @@ func TestUpdateUser(t *testing.T) {
 	}
 
 	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, tt.args.token)
 		tt.mock()
 		s := Server{
 			Repository: repo,
@@ -419,8 +498,11 @@ func TestUpdateUser(t *testing.T) {
 
 		t.Run(tt.name, func(t *testing.T) {
 			ctx, _ := newTestContextWithToken(tt.args.requestBody, tt.args.token)
+			if tt.args.ifMatch != "" {
+				ctx.Request().Header.Set("If-Match", tt.args.ifMatch)
+			}
 
-			err := s.UpdateUser(ctx)
+			err := handleError(&s, ctx, s.UpdateUser(ctx))
 
 			tt.assert(err, ctx)
 		})
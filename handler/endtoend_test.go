@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestEndToEnd runs the user journey against the in-memory repository instead
+// of mocks, so the handlers are checked together with the repository contract.
+func TestEndToEnd(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT(), PasswordHasher: newTestPasswordHasher()},
+	}
+
+	login := func(password string) (*generated.Session, int) {
+		ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"`+password+`"}`, "")
+		if handleError(&s, ctx, s.CreateSession(ctx)) != nil {
+			return nil, rec.Code
+		}
+
+		var session generated.Session
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+		return &session, rec.Code
+	}
+
+	// test 1 register
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"0812-2312-9","full_name":"Leonardo","password":"Leo9999#"}`, "")
+	require.NoError(t, handleError(&s, ctx, s.CreateUser(ctx)))
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var user generated.User
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	assert.Equal(t, "+6281223129", user.PhoneNumber)
+
+	// test 2 the phone number is taken in any form
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+62 812 2312 9","full_name":"Other","password":"Leo9999#"}`, "")
+	assert.Error(t, handleError(&s, ctx, s.CreateUser(ctx)))
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"phone_number_taken"`)
+
+	// test 3 login
+	_, code := login("wrong")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	session, code := login("Leo9999#")
+	require.Equal(t, http.StatusCreated, code)
+	assert.Equal(t, user.Id, session.UserId)
+
+	// test 4 profile
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", session.AccessToken)
+	require.NoError(t, handleError(&s, ctx, s.GetCurrentUser(ctx)))
+	assert.Equal(t, `"1"`, rec.Header().Get("ETag"))
+	assert.JSONEq(t, `{"id":1,"full_name":"Leonardo","phone_number":"+6281223129"}`, rec.Body.String())
+
+	// test 5 conditional update
+	ctx, rec = newTestRequestContext(http.MethodPatch, mimeMergePatchJSON, `{"full_name":"Leo O'Neil"}`, session.AccessToken)
+	ctx.Request().Header.Set("If-Match", `"1"`)
+	require.NoError(t, handleError(&s, ctx, s.PatchCurrentUser(ctx)))
+	assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+	assert.Contains(t, rec.Body.String(), `"full_name":"Leo O'Neil"`)
+
+	// test 6 the preferred language applies to the tokens already issued
+	ctx, rec = newTestRequestContext(http.MethodPatch, mimeMergePatchJSON, `{"preferred_language":"id"}`, session.AccessToken)
+	require.NoError(t, handleError(&s, ctx, s.PatchCurrentUser(ctx)))
+	assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+
+	ctx, rec = newTestRequestContext(http.MethodPatch, mimeMergePatchJSON, `{"full_name":"Leo"}`, session.AccessToken)
+	ctx.Request().Header.Set("If-Match", `"1"`)
+	assert.Error(t, handleError(&s, ctx, s.PatchCurrentUser(ctx)))
+	assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+	assert.Equal(t, "id", rec.Header().Get("Content-Language"))
+
+	// test 7 change password, the tokens already issued are revoked
+	ctx, rec = newTestRequestContext(http.MethodPut, echo.MIMEApplicationJSON, `{"current_password":"Leo9999#","password":"Kebun#2024"}`, session.AccessToken)
+	require.NoError(t, handleError(&s, ctx, s.ChangePassword(ctx)))
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+
+	_, code = login("Leo9999#")
+	assert.Equal(t, http.StatusBadRequest, code)
+
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", session.AccessToken)
+	assert.Error(t, handleError(&s, ctx, s.GetCurrentUser(ctx)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_token"`)
+
+	session, code = login("Kebun#2024")
+	require.Equal(t, http.StatusCreated, code)
+
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", session.AccessToken)
+	require.NoError(t, handleError(&s, ctx, s.GetCurrentUser(ctx)))
+	assert.Equal(t, http.StatusOK, rec.Code)
+
+	// test 8 only successful logins are counted
+	stored, err := repo.GetUserDataByUserID(context.Background(), repository.GetUserDataByUserIDInput{UserID: user.Id})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), stored.SuccesfulLogin)
+
+	// test 9 logins are audited
+	events, err := repo.GetAuditEvents(context.Background(), repository.GetAuditEventsInput{})
+	require.NoError(t, err)
+	assert.NoError(t, model.VerifyAuditChain(events, 0, ""))
+
+	var eventTypes []string
+	for _, event := range events {
+		if event.UserID == user.Id && (event.EventType == model.AuditEventLoginFailed || event.EventType == model.AuditEventLoginSucceeded) {
+			eventTypes = append(eventTypes, event.EventType)
+		}
+	}
+	assert.Equal(t, []string{
+		model.AuditEventLoginFailed,
+		model.AuditEventLoginSucceeded,
+		model.AuditEventLoginFailed,
+		model.AuditEventLoginSucceeded,
+	}, eventTypes)
+}
@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const mimeProblemJSON = "application/problem+json"
+
+// HTTPErrorHandler renders every error returned by the handlers and by Echo
+// itself as an RFC 7807 problem. Errors that are not an apierror.Error are
+// mapped to a catalog code, and their message is only logged so database or
+// library internals never reach the client.
+func (s *Server) HTTPErrorHandler(err error, ctx echo.Context) {
+	if ctx.Response().Committed {
+		return
+	}
+
+	reqCtx := ctx.Request().Context()
+	apiErr := toAPIError(err)
+	definition := apierror.Lookup(apiErr.Code)
+	language := s.language(ctx)
+	title := apiErr.Code.Title(language)
+
+	detail := apiErr.LocalizedDetail(language)
+	if detail == "" {
+		detail = title
+	}
+
+	problem := generated.Problem{
+		Type:     apiErr.Code.Type(),
+		Title:    title,
+		Status:   definition.Status,
+		Detail:   &detail,
+		Instance: &ctx.Request().URL.Path,
+		Code:     generated.ProblemCode(apiErr.Code),
+		Message:  detail,
+	}
+
+	if requestID := logger.RequestIDFromContext(reqCtx); requestID != "" {
+		problem.RequestId = &requestID
+	}
+
+	if len(apiErr.Fields) > 0 {
+		fieldErrors := make([]generated.ProblemFieldError, 0, len(apiErr.Fields))
+		for _, field := range apiErr.Fields {
+			fieldError := generated.ProblemFieldError{
+				Field:   field.Field,
+				Rule:    field.Rule,
+				Message: field.LocalizedMessage(language),
+			}
+			if len(field.Params) > 0 {
+				params := field.Params
+				fieldError.Params = &params
+			}
+			fieldErrors = append(fieldErrors, fieldError)
+		}
+		problem.Errors = &fieldErrors
+	}
+
+	level := slog.LevelDebug
+	if definition.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	s.log().Log(reqCtx, level, "request failed", "status", definition.Status, "code", apiErr.Code, "error", err)
+
+	ctx.Response().Header().Set(echo.HeaderContentType, mimeProblemJSON)
+	if retryAfter, ok := retryAfterSeconds(err); ok {
+		ctx.Response().Header().Set(echo.HeaderRetryAfter, strconv.Itoa(retryAfter))
+	}
+	if ctx.Request().Method == http.MethodHead {
+		err = ctx.NoContent(definition.Status)
+	} else {
+		err = ctx.JSON(definition.Status, problem)
+	}
+	if err != nil {
+		s.log().ErrorContext(reqCtx, "write error response", "error", err)
+	}
+}
+
+// toAPIError maps err to a catalog code.
+func toAPIError(err error) *apierror.Error {
+	var apiErr *apierror.Error
+	if errors.As(err, &apiErr) {
+		return apiErr
+	}
+
+	var httpErr *echo.HTTPError
+	if errors.As(err, &httpErr) {
+		return fromHTTPError(httpErr)
+	}
+
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return apierror.Wrap(apierror.CodeNotFound, "", err)
+	case errors.Is(err, repository.ErrDuplicatePhone):
+		return apierror.Wrap(apierror.CodePhoneNumberTaken, "", err)
+	case errors.Is(err, repository.ErrVersionConflict):
+		return apierror.Wrap(apierror.CodeVersionConflict, i18n.DetailVersionConflict, err)
+	case errors.Is(err, repository.ErrConflict):
+		return apierror.Wrap(apierror.CodeConflict, "", err)
+	case errors.Is(err, repository.ErrTransient),
+		errors.Is(err, context.DeadlineExceeded):
+		return apierror.Wrap(apierror.CodeServiceUnavailable, "", err)
+	}
+
+	return apierror.Wrap(apierror.CodeInternal, "", err)
+}
+
+// retryAfterSeconds returns when the request may succeed if tried again, in
+// whole seconds, for the errors that tell.
+func retryAfterSeconds(err error) (int, bool) {
+	// Hashes take a fraction of a second, a slot is soon free again
+	if errors.Is(err, errHashingSaturated) {
+		return 1, true
+	}
+
+	var openErr *repository.CircuitOpenError
+	if !errors.As(err, &openErr) {
+		return 0, false
+	}
+	return max(1, int(math.Ceil(openErr.RetryAfter.Seconds()))), true
+}
+
+func fromHTTPError(httpErr *echo.HTTPError) *apierror.Error {
+	var code apierror.Code
+	switch httpErr.Code {
+	case http.StatusNotFound:
+		code = apierror.CodeRouteNotFound
+	case http.StatusMethodNotAllowed:
+		code = apierror.CodeMethodNotAllowed
+	case http.StatusUnsupportedMediaType:
+		code = apierror.CodeUnsupportedMediaType
+	case http.StatusUnauthorized, http.StatusForbidden:
+		code = apierror.CodeForbidden
+	case http.StatusServiceUnavailable:
+		code = apierror.CodeServiceUnavailable
+	default:
+		if httpErr.Code >= http.StatusInternalServerError {
+			code = apierror.CodeInternal
+		} else {
+			code = apierror.CodeInvalidRequest
+		}
+	}
+
+	// Echo only puts client facing messages in HTTP errors of the 4xx range
+	detail := ""
+	if message, ok := httpErr.Message.(string); ok && httpErr.Code < http.StatusInternalServerError {
+		detail = message
+	}
+
+	return &apierror.Error{Code: code, Detail: detail, Err: httpErr}
+}
@@ -0,0 +1,265 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPErrorHandler(t *testing.T) {
+	s := Server{}
+
+	var tests = []struct {
+		name           string
+		err            error
+		wantStatus     int
+		wantCode       apierror.Code
+		wantDetail     string
+		wantErrors     *[]generated.ProblemFieldError
+		wantRetryAfter string
+	}{
+		{
+			name:       "api error",
+			err:        apierror.New(apierror.CodeInvalidCredentials, "Invalid phone number or password."),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeInvalidCredentials,
+			wantDetail: "Invalid phone number or password.",
+		},
+		{
+			name: "validation error",
+			err: apierror.Validation("Invalid Request.", model.FieldError{
+				Field:   "full_name",
+				Rule:    model.RuleLength,
+				Params:  map[string]interface{}{"min": 3},
+				Message: "Too short",
+			}),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeValidationFailed,
+			wantDetail: "Invalid Request.",
+			wantErrors: &[]generated.ProblemFieldError{{
+				Field:   "full_name",
+				Rule:    model.RuleLength,
+				Params:  &map[string]interface{}{"min": float64(3)},
+				Message: "Too short",
+			}},
+		},
+		{
+			name:       "unique phone number",
+			err:        fmt.Errorf("%w: duplicate key value violates unique constraint", repository.ErrDuplicatePhone),
+			wantStatus: http.StatusConflict,
+			wantCode:   apierror.CodePhoneNumberTaken,
+			wantDetail: "Phone number already registered",
+		},
+		{
+			name:       "conflict",
+			err:        fmt.Errorf("%w: duplicate key value violates unique constraint", repository.ErrConflict),
+			wantStatus: http.StatusConflict,
+			wantCode:   apierror.CodeConflict,
+			wantDetail: "Conflict",
+		},
+		{
+			name:       "transient failure",
+			err:        fmt.Errorf("%w: could not serialize access", repository.ErrTransient),
+			wantStatus: http.StatusServiceUnavailable,
+			wantCode:   apierror.CodeServiceUnavailable,
+			wantDetail: "Service unavailable",
+		},
+		{
+			name:           "circuit breaker open",
+			err:            fmt.Errorf("get user: %w", &repository.CircuitOpenError{RetryAfter: 2500 * time.Millisecond}),
+			wantStatus:     http.StatusServiceUnavailable,
+			wantCode:       apierror.CodeServiceUnavailable,
+			wantDetail:     "Service unavailable",
+			wantRetryAfter: "3",
+		},
+		{
+			name:       "unexpected database error is not leaked",
+			err:        errors.New(`pq: relation "users" does not exist`),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   apierror.CodeInternal,
+			wantDetail: "Internal server error",
+		},
+		{
+			name:       "not found",
+			err:        repository.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.CodeNotFound,
+			wantDetail: "Resource not found",
+		},
+		{
+			name:       "version conflict",
+			err:        repository.ErrVersionConflict,
+			wantStatus: http.StatusPreconditionFailed,
+			wantCode:   apierror.CodeVersionConflict,
+			wantDetail: "The resource has been modified by another request. Please fetch the latest data and retry.",
+		},
+		{
+			name:       "echo bad request keeps its message",
+			err:        echo.NewHTTPError(http.StatusBadRequest, "Invalid format for parameter id"),
+			wantStatus: http.StatusBadRequest,
+			wantCode:   apierror.CodeInvalidRequest,
+			wantDetail: "Invalid format for parameter id",
+		},
+		{
+			name:       "echo route not found",
+			err:        echo.ErrNotFound,
+			wantStatus: http.StatusNotFound,
+			wantCode:   apierror.CodeRouteNotFound,
+			wantDetail: "Not Found",
+		},
+		{
+			name:       "unexpected error is not leaked",
+			err:        errors.New("dial tcp 10.0.0.1:5432: connection refused"),
+			wantStatus: http.StatusInternalServerError,
+			wantCode:   apierror.CodeInternal,
+			wantDetail: "Internal server error",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+			ctx.SetRequest(ctx.Request().WithContext(logger.WithRequestID(ctx.Request().Context(), "request-1")))
+
+			s.HTTPErrorHandler(tt.err, ctx)
+
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+			var problem generated.Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, generated.ProblemCode(tt.wantCode), problem.Code)
+			assert.Equal(t, tt.wantCode.Type(), problem.Type)
+			assert.Equal(t, apierror.Lookup(tt.wantCode).Title, problem.Title)
+			assert.Equal(t, tt.wantStatus, problem.Status)
+			if assert.NotNil(t, problem.Detail) {
+				assert.Equal(t, tt.wantDetail, *problem.Detail)
+			}
+			assert.Equal(t, tt.wantDetail, problem.Message)
+			assert.Equal(t, "/", *problem.Instance)
+			assert.Equal(t, "request-1", *problem.RequestId)
+			assert.Equal(t, tt.wantErrors, problem.Errors)
+			assert.Equal(t, tt.wantRetryAfter, rec.Header().Get(echo.HeaderRetryAfter))
+		})
+	}
+
+	// test committed responses are left untouched
+	ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	assert.NoError(t, ctx.NoContent(http.StatusNoContent))
+	s.HTTPErrorHandler(errors.New("error"), ctx)
+	assert.Equal(t, http.StatusNoContent, rec.Code)
+	assert.Empty(t, rec.Body.String())
+}
+
+func TestHTTPErrorHandlerLanguage(t *testing.T) {
+	jwtToken := newTestJWT()
+	repo := repository.NewMemoryRepository()
+
+	// The preferred language is read from the user, not the token
+	var tokens []string
+	for _, input := range []repository.InsertUserInput{
+		{PhoneNumber: "+6281223129", FullName: "Leonardo", PreferredLanguage: "id"},
+		{PhoneNumber: "+6281223130", FullName: "Leonardo"},
+	} {
+		out, err := repo.InsertUser(context.Background(), input)
+		require.NoError(t, err)
+
+		token, err := jwtToken.Create(time.Minute, model.User{UserID: out.UserID})
+		require.NoError(t, err)
+		tokens = append(tokens, token)
+	}
+	indonesianToken, englishToken := tokens[0], tokens[1]
+
+	validationErr := apierror.Validation(i18n.DetailCriteriaNotMet, model.Required("full_name"))
+
+	tests := []struct {
+		name            string
+		defaultLanguage i18n.Language
+		acceptLanguage  string
+		token           string
+		wantLanguage    i18n.Language
+		wantDetail      string
+		wantMessage     string
+	}{
+		{
+			name:         "english by default",
+			wantLanguage: i18n.English,
+			wantDetail:   "Invalid Request. Please meet the criteria",
+			wantMessage:  "This field is required",
+		},
+		{
+			name:            "configured default",
+			defaultLanguage: i18n.Indonesian,
+			wantLanguage:    i18n.Indonesian,
+			wantDetail:      "Permintaan tidak valid. Harap penuhi kriteria",
+			wantMessage:     "Kolom ini wajib diisi",
+		},
+		{
+			name:           "accept language",
+			acceptLanguage: "id-ID,id;q=0.9,en;q=0.8",
+			wantLanguage:   i18n.Indonesian,
+			wantDetail:     "Permintaan tidak valid. Harap penuhi kriteria",
+			wantMessage:    "Kolom ini wajib diisi",
+		},
+		{
+			name:           "preferred language of the user wins",
+			acceptLanguage: "en",
+			token:          indonesianToken,
+			wantLanguage:   i18n.Indonesian,
+			wantDetail:     "Permintaan tidak valid. Harap penuhi kriteria",
+			wantMessage:    "Kolom ini wajib diisi",
+		},
+		{
+			name:           "user without preference",
+			acceptLanguage: "id",
+			token:          englishToken,
+			wantLanguage:   i18n.Indonesian,
+			wantDetail:     "Permintaan tidak valid. Harap penuhi kriteria",
+			wantMessage:    "Kolom ini wajib diisi",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := Server{Repository: repo, Config: &config.Config{JWT: jwtToken, DefaultLanguage: tt.defaultLanguage}}
+
+			ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", tt.token)
+			if tt.acceptLanguage != "" {
+				ctx.Request().Header.Set("Accept-Language", tt.acceptLanguage)
+			}
+			if tt.token != "" {
+				_, err := s.authenticate(ctx)
+				assert.NoError(t, err)
+			}
+
+			s.HTTPErrorHandler(validationErr, ctx)
+
+			assert.Equal(t, string(tt.wantLanguage), rec.Header().Get("Content-Language"))
+			assert.Contains(t, rec.Header().Values(echo.HeaderVary), "Accept-Language")
+
+			var problem generated.Problem
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+			assert.Equal(t, apierror.CodeValidationFailed.Title(tt.wantLanguage), problem.Title)
+			assert.Equal(t, tt.wantDetail, *problem.Detail)
+			if assert.NotNil(t, problem.Errors) {
+				assert.Equal(t, tt.wantMessage, (*problem.Errors)[0].Message)
+				assert.Equal(t, model.RuleRequired, (*problem.Errors)[0].Rule)
+			}
+		})
+	}
+}
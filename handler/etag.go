@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/i18n"
+)
+
+var errInvalidIfMatch = apierror.New(apierror.CodeInvalidRequest, i18n.DetailInvalidIfMatch)
+
+// userETag returns the strong entity tag for a user at the given version.
+func userETag(version int32) string {
+	return fmt.Sprintf(`"%d"`, version)
+}
+
+// parseIfMatch extracts the expected user version from an If-Match header.
+// An empty header or "*" matches any version and yields nil. Weak tags are
+// rejected because If-Match requires strong comparison.
+func parseIfMatch(header string) (*int32, error) {
+	header = strings.TrimSpace(header)
+	if header == "" || header == "*" {
+		return nil, nil
+	}
+
+	if len(header) < 2 || header[0] != '"' || header[len(header)-1] != '"' {
+		return nil, errInvalidIfMatch
+	}
+
+	version, err := strconv.ParseInt(header[1:len(header)-1], 10, 32)
+	if err != nil || version < 1 {
+		return nil, errInvalidIfMatch
+	}
+
+	expected := int32(version)
+	return &expected, nil
+}
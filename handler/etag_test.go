@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseIfMatch(t *testing.T) {
+	version := int32(7)
+
+	var tests = []struct {
+		name    string
+		header  string
+		want    *int32
+		wantErr bool
+	}{
+		{name: "empty", header: ""},
+		{name: "any", header: "*"},
+		{name: "strong tag", header: `"7"`, want: &version},
+		{name: "surrounding spaces", header: ` "7" `, want: &version},
+		{name: "weak tag", header: `W/"7"`, wantErr: true},
+		{name: "unquoted", header: "7", wantErr: true},
+		{name: "not a version", header: `"abc"`, wantErr: true},
+		{name: "zero version", header: `"0"`, wantErr: true},
+		{name: "multiple tags", header: `"6", "7"`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseIfMatch(tt.header)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+
+	assert.Equal(t, `"7"`, userETag(version))
+}
@@ -0,0 +1,148 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	userservicev1 "github.com/SawitProRecruitment/UserService/proto/userservice/v1"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/reflection"
+)
+
+// grpcAuthenticatedMethods are the RPCs called with an access token.
+var grpcAuthenticatedMethods = map[string]bool{
+	userservicev1.UserService_GetUser_FullMethodName:    true,
+	userservicev1.UserService_UpdateUser_FullMethodName: true,
+}
+
+// GRPCServer returns a gRPC server serving the UserService of
+// proto/userservice/v1 and the server reflection. The RPCs share the domain
+// logic, errors and audit log of the REST handlers.
+func (s *Server) GRPCServer(opts ...grpc.ServerOption) *grpc.Server {
+	opts = append(opts, grpc.ChainUnaryInterceptor(s.grpcRequest, s.grpcAuthenticate, s.grpcErrors))
+
+	server := grpc.NewServer(opts...)
+	userservicev1.RegisterUserServiceServer(server, &userService{server: s})
+	reflection.Register(server)
+	return server
+}
+
+// userService implements the UserService RPCs on top of Server.
+type userService struct {
+	userservicev1.UnimplementedUserServiceServer
+	server *Server
+}
+
+func (u *userService) Register(ctx context.Context, req *userservicev1.RegisterRequest) (*userservicev1.RegisterResponse, error) {
+	user := model.User{
+		PhoneNumber:       req.GetPhoneNumber(),
+		FullName:          req.GetFullName(),
+		Password:          req.GetPassword(),
+		PreferredLanguage: req.GetPreferredLanguage(),
+	}
+
+	userID, err := u.server.createUser(ctx, &user)
+	if err != nil {
+		return nil, err
+	}
+
+	created, err := u.server.loadUser(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userservicev1.RegisterResponse{User: grpcUser(created)}, nil
+}
+
+func (u *userService) Login(ctx context.Context, req *userservicev1.LoginRequest) (*userservicev1.LoginResponse, error) {
+	userID, token, err := u.server.createSession(ctx, req.GetPhoneNumber(), req.GetPassword())
+	if err != nil {
+		return nil, err
+	}
+
+	return &userservicev1.LoginResponse{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int32(sessionTTL.Seconds()),
+		UserId:      userID,
+	}, nil
+}
+
+func (u *userService) GetUser(ctx context.Context, req *userservicev1.GetUserRequest) (*userservicev1.GetUserResponse, error) {
+	userData := grpcUserFromContext(ctx)
+
+	id := req.GetId()
+	if id == 0 {
+		id = userData.UserID
+	}
+
+	// Other users are reported as missing so ids cannot be enumerated
+	if id != userData.UserID && !u.server.Config.IsAdmin(userData.UserID) {
+		return nil, apierror.New(apierror.CodeNotFound, i18n.DetailUserNotFound)
+	}
+
+	user, err := u.server.loadUser(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userservicev1.GetUserResponse{User: grpcUser(user)}, nil
+}
+
+func (u *userService) UpdateUser(ctx context.Context, req *userservicev1.UpdateUserRequest) (*userservicev1.UpdateUserResponse, error) {
+	userData := grpcUserFromContext(ctx)
+
+	patch := repository.UserPatch{
+		FullName:          req.FullName,
+		PhoneNumber:       req.PhoneNumber,
+		PreferredLanguage: req.PreferredLanguage,
+	}
+
+	var expectedVersion *int32
+	if version := req.GetExpectedVersion(); version != 0 {
+		expectedVersion = &version
+	}
+
+	// An empty update leaves the user unchanged
+	if !patch.IsEmpty() {
+		if _, err := u.server.updateUser(ctx, userData.UserID, patch, expectedVersion); err != nil {
+			return nil, err
+		}
+	}
+
+	user, err := u.server.loadUser(ctx, userData.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &userservicev1.UpdateUserResponse{User: grpcUser(user)}, nil
+}
+
+func (u *userService) ValidateToken(ctx context.Context, req *userservicev1.ValidateTokenRequest) (*userservicev1.ValidateTokenResponse, error) {
+	if req.GetAccessToken() == "" {
+		return nil, apierror.New(apierror.CodeMissingToken, i18n.DetailForbidden)
+	}
+
+	userData, err := u.server.validateToken(ctx, req.GetAccessToken())
+	if err != nil {
+		return nil, err
+	}
+
+	return &userservicev1.ValidateTokenResponse{
+		UserId:            userData.UserID,
+		PreferredLanguage: userData.PreferredLanguage,
+	}, nil
+}
+
+func grpcUser(user model.User) *userservicev1.User {
+	return &userservicev1.User{
+		Id:                user.UserID,
+		FullName:          user.FullName,
+		PhoneNumber:       user.PhoneNumber,
+		PreferredLanguage: user.PreferredLanguage,
+		Version:           user.Version,
+	}
+}
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/SawitProRecruitment/UserService/model"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/protoadapt"
+	"google.golang.org/protobuf/types/known/durationpb"
+)
+
+// grpcErrorDomain is the domain of the google.rpc.ErrorInfo details.
+const grpcErrorDomain = "userservice"
+
+// grpcCodes maps the catalog codes to gRPC status codes.
+var grpcCodes = map[apierror.Code]codes.Code{
+	apierror.CodeInvalidRequest:       codes.InvalidArgument,
+	apierror.CodeValidationFailed:     codes.InvalidArgument,
+	apierror.CodeMissingToken:         codes.Unauthenticated,
+	apierror.CodeInvalidToken:         codes.Unauthenticated,
+	apierror.CodeForbidden:            codes.PermissionDenied,
+	apierror.CodeInvalidCredentials:   codes.Unauthenticated,
+	apierror.CodeNotFound:             codes.NotFound,
+	apierror.CodeRouteNotFound:        codes.NotFound,
+	apierror.CodeMethodNotAllowed:     codes.Unimplemented,
+	apierror.CodePhoneNumberTaken:     codes.AlreadyExists,
+	apierror.CodeConflict:             codes.Aborted,
+	apierror.CodeVersionConflict:      codes.FailedPrecondition,
+	apierror.CodeIdempotencyKeyReused: codes.FailedPrecondition,
+	apierror.CodeIdempotencyKeyInUse:  codes.Aborted,
+	apierror.CodeUnsupportedMediaType: codes.InvalidArgument,
+	apierror.CodeServiceUnavailable:   codes.Unavailable,
+	apierror.CodeInternal:             codes.Internal,
+}
+
+type grpcUserKey struct{}
+
+// grpcUserFromContext returns the user authenticated by grpcAuthenticate.
+func grpcUserFromContext(ctx context.Context) model.User {
+	userData, _ := ctx.Value(grpcUserKey{}).(model.User)
+	return userData
+}
+
+// grpcRequest is the gRPC counterpart of the RequestID and RequestLogger
+// middlewares: it reuses the x-request-id sent by the client or generates a
+// new one, records the address of the client and writes one log line per
+// call.
+func (s *Server) grpcRequest(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	start := time.Now()
+
+	requestID := metadataValue(ctx, "x-request-id")
+	if !validRequestID(requestID) {
+		requestID = newRequestID()
+	}
+	ctx = logger.WithRequestID(ctx, requestID)
+	_ = grpc.SetHeader(ctx, metadata.Pairs("x-request-id", requestID))
+
+	var remoteIP string
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		remoteIP = p.Addr.String()
+		if host, _, err := net.SplitHostPort(remoteIP); err == nil {
+			remoteIP = host
+		}
+	}
+	ctx = withClientIP(ctx, remoteIP)
+
+	resp, err := handler(ctx, req)
+
+	code := status.Code(err)
+	level := slog.LevelInfo
+	if code == codes.Internal || code == codes.Unavailable || code == codes.Unknown {
+		level = slog.LevelError
+	}
+
+	s.log().LogAttrs(ctx, level, "rpc",
+		slog.String("method", info.FullMethod),
+		slog.String("code", code.String()),
+		slog.Duration("latency", time.Since(start)),
+		slog.String("remote_ip", remoteIP),
+	)
+
+	return resp, err
+}
+
+// grpcAuthenticate validates the access token of the authorization metadata
+// of the authenticated RPCs. The preferred language of the user, if any,
+// becomes the language of the call.
+func (s *Server) grpcAuthenticate(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	if !grpcAuthenticatedMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	token := metadataValue(ctx, "authorization")
+	if token == "" {
+		return nil, s.grpcStatus(ctx, apierror.New(apierror.CodeMissingToken, i18n.DetailForbidden))
+	}
+
+	userData, err := s.validateToken(ctx, strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return nil, s.grpcStatus(ctx, err)
+	}
+
+	ctx = context.WithValue(ctx, grpcUserKey{}, userData)
+	if language, ok := i18n.ParseLanguage(userData.PreferredLanguage); ok {
+		ctx = i18n.WithLanguage(ctx, language)
+	}
+
+	return handler(ctx, req)
+}
+
+// grpcErrors reports the errors returned by the RPCs as gRPC statuses, see
+// grpcStatus.
+func (s *Server) grpcErrors(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+	resp, err := handler(ctx, req)
+	if err != nil {
+		return nil, s.grpcStatus(ctx, err)
+	}
+	return resp, nil
+}
+
+// grpcStatus is the gRPC counterpart of HTTPErrorHandler. Errors are mapped to
+// a catalog code like for the REST API, reported in a google.rpc.ErrorInfo
+// detail, with the invalid fields in a google.rpc.BadRequest detail. Their
+// message is only logged so database or library internals never reach the
+// client.
+func (s *Server) grpcStatus(ctx context.Context, err error) error {
+	if _, ok := status.FromError(err); ok {
+		return err
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	}
+
+	apiErr := toAPIError(err)
+	definition := apierror.Lookup(apiErr.Code)
+	language := s.negotiateLanguage(ctx, metadataValue(ctx, "accept-language"))
+
+	detail := apiErr.LocalizedDetail(language)
+	if detail == "" {
+		detail = apiErr.Code.Title(language)
+	}
+
+	level := slog.LevelDebug
+	if definition.Status >= http.StatusInternalServerError {
+		level = slog.LevelError
+	}
+	s.log().Log(ctx, level, "rpc failed", "code", apiErr.Code, "error", err)
+
+	code, ok := grpcCodes[apiErr.Code]
+	if !ok {
+		code = codes.Internal
+	}
+
+	info := &errdetails.ErrorInfo{Reason: string(apiErr.Code), Domain: grpcErrorDomain}
+	if requestID := logger.RequestIDFromContext(ctx); requestID != "" {
+		info.Metadata = map[string]string{"request_id": requestID}
+	}
+	details := []protoadapt.MessageV1{info}
+
+	if len(apiErr.Fields) > 0 {
+		badRequest := &errdetails.BadRequest{}
+		for _, field := range apiErr.Fields {
+			badRequest.FieldViolations = append(badRequest.FieldViolations, &errdetails.BadRequest_FieldViolation{
+				Field:       field.Field,
+				Description: field.LocalizedMessage(language),
+			})
+		}
+		details = append(details, badRequest)
+	}
+
+	if retryAfter, ok := retryAfterSeconds(err); ok {
+		details = append(details, &errdetails.RetryInfo{RetryDelay: durationpb.New(time.Duration(retryAfter) * time.Second)})
+	}
+
+	st := status.New(code, detail)
+	if withDetails, err := st.WithDetails(details...); err == nil {
+		st = withDetails
+	}
+	return st.Err()
+}
+
+// metadataValue returns the first value of the incoming metadata key.
+func metadataValue(ctx context.Context, key string) string {
+	if values := metadata.ValueFromIncomingContext(ctx, key); len(values) > 0 {
+		return values[0]
+	}
+	return ""
+}
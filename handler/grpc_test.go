@@ -0,0 +1,177 @@
+package handler
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/config"
+	userservicev1 "github.com/SawitProRecruitment/UserService/proto/userservice/v1"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/genproto/googleapis/rpc/errdetails"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/reflection/grpc_reflection_v1"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/proto"
+)
+
+// newTestGRPCClient serves s on an in-memory connection.
+func newTestGRPCClient(t *testing.T, s *Server) *grpc.ClientConn {
+	listener := bufconn.Listen(1024 * 1024)
+	server := s.GRPCServer()
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+// grpcErrorReason returns the error code of the REST API reported by err.
+func grpcErrorReason(err error) string {
+	for _, detail := range status.Convert(err).Details() {
+		if info, ok := detail.(*errdetails.ErrorInfo); ok {
+			return info.GetReason()
+		}
+	}
+	return ""
+}
+
+func TestGRPCServer(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := &Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT(), PasswordHasher: newTestPasswordHasher()},
+	}
+	client := userservicev1.NewUserServiceClient(newTestGRPCClient(t, s))
+	ctx := context.Background()
+
+	// test 1 register
+	registered, err := client.Register(ctx, &userservicev1.RegisterRequest{
+		PhoneNumber: "0812-2312-9",
+		FullName:    "Leonardo",
+		Password:    "Leo9999#",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "+6281223129", registered.GetUser().GetPhoneNumber())
+	assert.Equal(t, int32(1), registered.GetUser().GetVersion())
+
+	// test 2 the errors of the REST API are reported
+	_, err = client.Register(ctx, &userservicev1.RegisterRequest{
+		PhoneNumber: "+62 812 2312 9",
+		FullName:    "Other",
+		Password:    "Leo9999#",
+	})
+	assert.Equal(t, codes.AlreadyExists, status.Code(err))
+	assert.Equal(t, "phone_number_taken", grpcErrorReason(err))
+
+	_, err = client.Register(metadata.AppendToOutgoingContext(ctx, "accept-language", "id"), &userservicev1.RegisterRequest{
+		PhoneNumber: "+6281223130",
+		FullName:    "Leonardo",
+		Password:    "short",
+	})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	assert.Equal(t, "validation_failed", grpcErrorReason(err))
+	var badRequest *errdetails.BadRequest
+	for _, detail := range status.Convert(err).Details() {
+		if detail, ok := detail.(*errdetails.BadRequest); ok {
+			badRequest = detail
+		}
+	}
+	if assert.NotNil(t, badRequest) && assert.NotEmpty(t, badRequest.GetFieldViolations()) {
+		assert.Equal(t, "password", badRequest.GetFieldViolations()[0].GetField())
+	}
+	assert.Equal(t, "Permintaan tidak valid. Harap penuhi kriteria", status.Convert(err).Message())
+
+	// test 3 login
+	_, err = client.Login(ctx, &userservicev1.LoginRequest{PhoneNumber: "+6281223129", Password: "wrong"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "invalid_credentials", grpcErrorReason(err))
+
+	session, err := client.Login(ctx, &userservicev1.LoginRequest{PhoneNumber: "+6281223129", Password: "Leo9999#"})
+	require.NoError(t, err)
+	assert.Equal(t, "Bearer", session.GetTokenType())
+	assert.Equal(t, registered.GetUser().GetId(), session.GetUserId())
+
+	// test 4 authenticated calls
+	_, err = client.GetUser(ctx, &userservicev1.GetUserRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "missing_token", grpcErrorReason(err))
+
+	_, err = client.GetUser(metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer invalid"), &userservicev1.GetUserRequest{})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	assert.Equal(t, "invalid_token", grpcErrorReason(err))
+
+	authCtx := metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+session.GetAccessToken())
+	got, err := client.GetUser(authCtx, &userservicev1.GetUserRequest{})
+	require.NoError(t, err)
+	assert.True(t, proto.Equal(registered.GetUser(), got.GetUser()))
+
+	_, err = client.GetUser(authCtx, &userservicev1.GetUserRequest{Id: registered.GetUser().GetId() + 1})
+	assert.Equal(t, codes.NotFound, status.Code(err))
+
+	// test 5 conditional update
+	fullName := "Leo O'Neil"
+	updated, err := client.UpdateUser(authCtx, &userservicev1.UpdateUserRequest{FullName: &fullName, ExpectedVersion: 1})
+	require.NoError(t, err)
+	assert.Equal(t, fullName, updated.GetUser().GetFullName())
+	assert.Equal(t, int32(2), updated.GetUser().GetVersion())
+
+	_, err = client.UpdateUser(authCtx, &userservicev1.UpdateUserRequest{FullName: &fullName, ExpectedVersion: 1})
+	assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	assert.Equal(t, "version_conflict", grpcErrorReason(err))
+
+	// test 6 validate token
+	validated, err := client.ValidateToken(ctx, &userservicev1.ValidateTokenRequest{AccessToken: session.GetAccessToken()})
+	require.NoError(t, err)
+	assert.Equal(t, session.GetUserId(), validated.GetUserId())
+
+	_, err = client.ValidateToken(ctx, &userservicev1.ValidateTokenRequest{AccessToken: "invalid"})
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	// test 7 audit events are recorded with the request
+	events, err := repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{UserID: session.GetUserId(), Limit: 10})
+	require.NoError(t, err)
+	if assert.NotEmpty(t, events) {
+		assert.NotEmpty(t, events[0].RequestID)
+	}
+}
+
+func TestGRPCReflection(t *testing.T) {
+	conn := newTestGRPCClient(t, &Server{Config: &config.Config{JWT: newTestJWT()}})
+
+	stream, err := grpc_reflection_v1.NewServerReflectionClient(conn).ServerReflectionInfo(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, stream.Send(&grpc_reflection_v1.ServerReflectionRequest{
+		MessageRequest: &grpc_reflection_v1.ServerReflectionRequest_ListServices{},
+	}))
+
+	resp, err := stream.Recv()
+	require.NoError(t, err)
+
+	var services []string
+	for _, service := range resp.GetListServicesResponse().GetService() {
+		services = append(services, service.GetName())
+	}
+	assert.Contains(t, services, userservicev1.UserService_ServiceDesc.ServiceName)
+}
+
+func TestGRPCCodes(t *testing.T) {
+	for _, code := range apierror.Codes() {
+		_, ok := grpcCodes[code]
+		assert.True(t, ok, "no gRPC status code for %s", code)
+	}
+}
@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"expvar"
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+type readinessResponse struct {
+	Status   string                 `json:"status"`
+	Database databaseReadinessState `json:"database"`
+}
+
+type databaseReadinessState struct {
+	CircuitBreaker repository.BreakerState `json:"circuit_breaker"`
+}
+
+// Readiness serves GET /readyz, reporting whether the instance should receive
+// traffic. It is not ready while the circuit breaker around the database is
+// open; a half-open breaker is ready, requests are needed to probe the
+// database.
+func (s *Server) Readiness(ctx echo.Context) error {
+	response := readinessResponse{
+		Status:   "ready",
+		Database: databaseReadinessState{CircuitBreaker: s.Breaker.State()},
+	}
+
+	status := http.StatusOK
+	if response.Database.CircuitBreaker == repository.BreakerOpen {
+		response.Status = "unavailable"
+		status = http.StatusServiceUnavailable
+	}
+	return ctx.JSON(status, response)
+}
+
+// DebugVars serves GET /debug/vars, the variables published with expvar, to
+// admins only. They include the command line and the memory statistics of the
+// process.
+func (s *Server) DebugVars(ctx echo.Context) error {
+	if _, err := s.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	expvar.Handler().ServeHTTP(ctx.Response(), ctx.Request())
+	return nil
+}
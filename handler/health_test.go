@@ -0,0 +1,69 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestReadiness(t *testing.T) {
+	// test 1 no breaker configured
+	s := Server{}
+	ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	assert.NoError(t, s.Readiness(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.JSONEq(t, `{"status":"ready","database":{"circuit_breaker":"closed"}}`, rec.Body.String())
+
+	// test 2 open breaker
+	s.Breaker = repository.NewCircuitBreaker(repository.CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Minute})
+	failing := new(mocks.RepositoryInterface)
+	failing.On("GetUserDataByUserID", mock.Anything, mock.Anything).Return(model.User{}, repository.ErrTransient)
+	repo := repository.NewCircuitBreakerRepository(failing, s.Breaker)
+	_, err := repo.GetUserDataByUserID(ctx.Request().Context(), repository.GetUserDataByUserIDInput{UserID: 1})
+	assert.ErrorIs(t, err, repository.ErrTransient)
+
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	assert.NoError(t, s.Readiness(ctx))
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.JSONEq(t, `{"status":"unavailable","database":{"circuit_breaker":"open"}}`, rec.Body.String())
+}
+
+func TestDebugVars(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	jwtToken := newTestJWT()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: jwtToken, AdminUserIDs: []int32{9}},
+	}
+
+	userToken, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 1})
+	adminToken, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 9})
+
+	// test 1 token missing
+	ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	assert.Error(t, handleError(&s, ctx, s.DebugVars(ctx)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// test 2 not an admin
+	expectTokenUser(repo, jwtToken, userToken)
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", userToken)
+	assert.Error(t, handleError(&s, ctx, s.DebugVars(ctx)))
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+
+	// test 3 admin
+	expectTokenUser(repo, jwtToken, adminToken)
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", adminToken)
+	assert.NoError(t, s.DebugVars(ctx))
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"memstats"`)
+	repo.AssertExpectations(t)
+}
@@ -0,0 +1,194 @@
+package handler
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	anonymousCaller          = "anonymous"
+)
+
+// idempotencyExcludedRoutes are the POST routes the Idempotency-Key is ignored
+// on. Their responses carry an access token, which must not be stored, and
+// logging in again only issues another token.
+var idempotencyExcludedRoutes = map[string]bool{
+	"/v1/sessions": true,
+	"/login":       true,
+}
+
+// idempotentReplayedHeaders are the headers of the first response replayed to
+// the retries, the others describe the request being served.
+var idempotentReplayedHeaders = []string{
+	echo.HeaderContentType,
+	"Content-Language",
+	echo.HeaderLocation,
+	"ETag",
+}
+
+// Idempotency lets clients retry POST requests safely. The first response to
+// the Idempotency-Key of a caller is stored and replayed to the retries of the
+// same request. The key is rejected while the first request is processed, and
+// for requests with another method, path or body. Server errors are not
+// stored, the request may then be tried again with the same key. The routes
+// of idempotencyExcludedRoutes are served as if no key was sent.
+//
+// Callers are the users of valid access tokens, the other requests share one
+// anonymous caller.
+func (s *Server) Idempotency() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			req := ctx.Request()
+			key := req.Header.Get(headerIdempotencyKey)
+			if req.Method != http.MethodPost || key == "" || idempotencyExcludedRoutes[ctx.Path()] {
+				return next(ctx)
+			}
+			if !validIdempotencyKey(key) {
+				return apierror.New(apierror.CodeInvalidRequest, i18n.DetailInvalidIdempotencyKey)
+			}
+
+			fingerprint, err := requestFingerprint(req, s.Config.IdempotencyFingerprintKey)
+			if err != nil {
+				return apierror.Wrap(apierror.CodeInvalidRequest, i18n.DetailBodyUnreadable, err)
+			}
+
+			caller := s.idempotentCaller(ctx)
+			out, err := s.Repository.ReserveIdempotencyKey(req.Context(), repository.ReserveIdempotencyKeyInput{
+				Caller:      caller,
+				Key:         key,
+				Fingerprint: fingerprint,
+				TTL:         s.Config.IdempotencyKeyTTL,
+			})
+			if err != nil {
+				return err
+			}
+
+			if !out.Reserved {
+				record := out.Record
+				switch {
+				case record.Fingerprint != fingerprint:
+					return apierror.New(apierror.CodeIdempotencyKeyReused, i18n.DetailIdempotencyKeyReused)
+				case !record.Completed():
+					ctx.Response().Header().Set(echo.HeaderRetryAfter, "1")
+					return apierror.New(apierror.CodeIdempotencyKeyInUse, i18n.DetailIdempotencyKeyInUse)
+				}
+
+				for name, value := range record.Header {
+					ctx.Response().Header().Set(name, value)
+				}
+				ctx.Response().Header().Set(headerIdempotentReplayed, "true")
+				if len(record.Body) == 0 {
+					return ctx.NoContent(int(record.StatusCode))
+				}
+				return ctx.Blob(int(record.StatusCode), record.Header[echo.HeaderContentType], record.Body)
+			}
+
+			recorder := &responseRecorder{ResponseWriter: ctx.Response().Writer}
+			ctx.Response().Writer = recorder
+
+			if err := next(ctx); err != nil {
+				ctx.Error(err)
+			}
+			ctx.Response().Writer = recorder.ResponseWriter
+
+			// The response is sent, the key must be settled even when the
+			// client is gone
+			storeCtx := context.WithoutCancel(req.Context())
+			status := ctx.Response().Status
+			if status >= http.StatusInternalServerError {
+				err = s.Repository.ReleaseIdempotencyKey(storeCtx, repository.ReleaseIdempotencyKeyInput{Caller: caller, Key: key})
+			} else {
+				header := make(map[string]string)
+				for _, name := range idempotentReplayedHeaders {
+					if value := ctx.Response().Header().Get(name); value != "" {
+						header[name] = value
+					}
+				}
+				err = s.Repository.CompleteIdempotencyKey(storeCtx, repository.CompleteIdempotencyKeyInput{
+					Caller:     caller,
+					Key:        key,
+					StatusCode: int32(status),
+					Header:     header,
+					Body:       recorder.body.Bytes(),
+				})
+			}
+			if err != nil {
+				s.log().WarnContext(req.Context(), "store idempotent response", "status", status, "error", err)
+			}
+
+			return nil
+		}
+	}
+}
+
+// idempotentCaller returns the caller the keys of the request belong to.
+func (s *Server) idempotentCaller(ctx echo.Context) string {
+	token := ctx.Request().Header.Get(echo.HeaderAuthorization)
+	if token == "" {
+		return anonymousCaller
+	}
+
+	userData, err := s.Config.JWT.Validate(strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return anonymousCaller
+	}
+	return "user:" + strconv.Itoa(int(userData.UserID))
+}
+
+// requestFingerprint returns the HMAC under key of the method, URI and body of
+// the request, leaving the body to be read again by the handler.
+func requestFingerprint(req *http.Request, key []byte) (string, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		if body, err = io.ReadAll(req.Body); err != nil {
+			return "", err
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	hash := hmac.New(sha256.New, key)
+	hash.Write([]byte(req.Method + " " + req.URL.RequestURI() + "\n"))
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+func validIdempotencyKey(key string) bool {
+	if len(key) > maxIdempotencyKeyLength {
+		return false
+	}
+
+	for _, r := range key {
+		if r < 0x20 || r > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+// responseRecorder copies the body written to the client.
+type responseRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *responseRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
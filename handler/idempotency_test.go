@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIdempotency(t *testing.T) {
+	repo := repository.NewMemoryRepository()
+	s := Server{
+		Repository: repo,
+		Config: &config.Config{
+			JWT:                       newTestJWT(),
+			PasswordHasher:            newTestPasswordHasher(),
+			IdempotencyFingerprintKey: []byte("fingerprint key"),
+		},
+	}
+
+	var flakyCalls int
+	e := echo.New()
+	e.HTTPErrorHandler = s.HTTPErrorHandler
+	e.Use(s.Idempotency())
+	e.POST("/v1/users", s.CreateUser)
+	e.POST("/v1/sessions", s.CreateSession)
+	e.POST("/flaky", func(ctx echo.Context) error {
+		flakyCalls++
+		if flakyCalls == 1 {
+			return repository.ErrTransient
+		}
+		return ctx.NoContent(http.StatusAccepted)
+	})
+
+	post := func(path, key, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, path, strings.NewReader(body))
+		req.Header.Set(echo.HeaderContentType, echo.MIMEApplicationJSON)
+		if key != "" {
+			req.Header.Set("Idempotency-Key", key)
+		}
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, req)
+		return rec
+	}
+
+	body := `{"phone_number":"+6281223129","full_name":"Leonardo","password":"Leo9999#"}`
+
+	// test 1 the first response is replayed
+	rec := post("/v1/users", "key-1", body)
+	require.Equal(t, http.StatusCreated, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	first := rec.Body.String()
+
+	rec = post("/v1/users", "key-1", body)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, echo.MIMEApplicationJSONCharsetUTF8, rec.Header().Get(echo.HeaderContentType))
+	assert.Equal(t, first, rec.Body.String())
+
+	// test 2 the request is run again without the key
+	rec = post("/v1/users", "", body)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"phone_number_taken"`)
+
+	// test 3 the key is reused for another request
+	rec = post("/v1/users", "key-1", strings.Replace(body, "Leonardo", "Other", 1))
+	assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"idempotency_key_reused"`)
+
+	// test 4 the first request is still processed
+	req := httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	fingerprint, err := requestFingerprint(req, s.Config.IdempotencyFingerprintKey)
+	require.NoError(t, err)
+	_, err = repo.ReserveIdempotencyKey(context.Background(), repository.ReserveIdempotencyKeyInput{
+		Caller:      anonymousCaller,
+		Key:         "key-2",
+		Fingerprint: fingerprint,
+	})
+	require.NoError(t, err)
+
+	rec = post("/v1/users", "key-2", body)
+	assert.Equal(t, http.StatusConflict, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+	assert.Contains(t, rec.Body.String(), `"code":"idempotency_key_in_use"`)
+
+	// test 5 server errors are not stored
+	rec = post("/flaky", "key-3", "")
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	rec = post("/flaky", "key-3", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+
+	rec = post("/flaky", "key-3", "")
+	assert.Equal(t, http.StatusAccepted, rec.Code)
+	assert.Equal(t, "true", rec.Header().Get("Idempotent-Replayed"))
+	assert.Equal(t, 2, flakyCalls)
+
+	// test 6 invalid key
+	rec = post("/v1/users", strings.Repeat("k", 256), body)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_request"`)
+
+	// test 7 sessions are created again, their tokens are not stored
+	login := `{"phone_number":"+6281223129","password":"Leo9999#"}`
+	for i := 0; i < 2; i++ {
+		rec = post("/v1/sessions", "key-4", login)
+		assert.Equal(t, http.StatusCreated, rec.Code)
+		assert.Empty(t, rec.Header().Get("Idempotent-Replayed"))
+	}
+
+	out, err := repo.ReserveIdempotencyKey(context.Background(), repository.ReserveIdempotencyKeyInput{
+		Caller: anonymousCaller,
+		Key:    "key-4",
+	})
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 8 the fingerprint depends on the key
+	req = httptest.NewRequest(http.MethodPost, "/v1/users", strings.NewReader(body))
+	other, err := requestFingerprint(req, []byte("other key"))
+	require.NoError(t, err)
+	assert.NotEqual(t, fingerprint, other)
+}
@@ -0,0 +1,49 @@
+package handler
+
+import (
+	"context"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/labstack/echo/v4"
+)
+
+// language returns the language the response is served in: the preferred
+// language of the authenticated user, else the one negotiated from the
+// Accept-Language header, else the configured default. It records the choice
+// in the Content-Language and Vary response headers.
+func (s *Server) language(ctx echo.Context) i18n.Language {
+	language := s.negotiateLanguage(ctx.Request().Context(), ctx.Request().Header.Get("Accept-Language"))
+
+	header := ctx.Response().Header()
+	header.Set("Content-Language", string(language))
+	header.Add(echo.HeaderVary, "Accept-Language")
+	return language
+}
+
+// negotiateLanguage returns the language of the user of ctx, else the one
+// negotiated from acceptLanguage, else the configured default.
+func (s *Server) negotiateLanguage(ctx context.Context, acceptLanguage string) i18n.Language {
+	language, ok := i18n.FromContext(ctx)
+	if !ok {
+		language, ok = i18n.Negotiate(acceptLanguage)
+	}
+	if !ok {
+		language = i18n.DefaultLanguage
+		if s.Config != nil && s.Config.DefaultLanguage != "" {
+			language = s.Config.DefaultLanguage
+		}
+	}
+	return language
+}
+
+// setUserLanguage makes the preferred language of the user, if supported, the
+// language of the request.
+func setUserLanguage(ctx echo.Context, preferredLanguage string) {
+	language, ok := i18n.ParseLanguage(preferredLanguage)
+	if !ok {
+		return
+	}
+
+	req := ctx.Request()
+	ctx.SetRequest(req.WithContext(i18n.WithLanguage(req.Context(), language)))
+}
@@ -0,0 +1,59 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+// parseUserMergePatch reads a JSON Merge Patch (RFC 7396) document for the user
+// resource. Members that are absent are left untouched. Removing a required
+// field with null is rejected, as are unknown members; removing the preferred
+// language clears it.
+func parseUserMergePatch(body []byte) (patch repository.UserPatch, err error) {
+	var members map[string]json.RawMessage
+	if err = json.Unmarshal(body, &members); err != nil || members == nil {
+		return patch, apierror.New(apierror.CodeInvalidRequest, i18n.DetailMergePatchNotObject)
+	}
+
+	for name, value := range members {
+		var target **string
+		removable := false
+		switch name {
+		case "full_name":
+			target = &patch.FullName
+		case "phone_number":
+			target = &patch.PhoneNumber
+		case "preferred_language":
+			target = &patch.PreferredLanguage
+			removable = true
+		default:
+			return patch, invalidMergePatch(model.NewFieldError(name, model.RuleUnknownField, i18n.ValidationUnknownField, nil))
+		}
+
+		if bytes.Equal(bytes.TrimSpace(value), []byte("null")) {
+			if !removable {
+				return patch, invalidMergePatch(model.NewFieldError(name, model.RuleRequired, i18n.ValidationCannotRemove, nil))
+			}
+			cleared := ""
+			*target = &cleared
+			continue
+		}
+
+		var str string
+		if err = json.Unmarshal(value, &str); err != nil {
+			return patch, invalidMergePatch(model.NewFieldError(name, model.RuleType, i18n.ValidationType, map[string]interface{}{"type": "string"}))
+		}
+		*target = &str
+	}
+
+	return patch, nil
+}
+
+func invalidMergePatch(fieldError model.FieldError) error {
+	return apierror.Validation(i18n.DetailInvalidMergePatch, fieldError)
+}
@@ -0,0 +1,84 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"log/slog"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/logger"
+	"github.com/labstack/echo/v4"
+)
+
+const maxRequestIDLength = 128
+
+// RequestID reuses the X-Request-ID sent by the client or generates a new one,
+// echoes it back in the response and stores it in the request context so every
+// log line and error response can refer to it.
+func RequestID() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			requestID := ctx.Request().Header.Get(echo.HeaderXRequestID)
+			if !validRequestID(requestID) {
+				requestID = newRequestID()
+			}
+
+			req := ctx.Request()
+			ctx.SetRequest(req.WithContext(logger.WithRequestID(req.Context(), requestID)))
+			ctx.Response().Header().Set(echo.HeaderXRequestID, requestID)
+
+			return next(ctx)
+		}
+	}
+}
+
+// RequestLogger writes one log line per handled request.
+func (s *Server) RequestLogger() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(ctx echo.Context) error {
+			start := time.Now()
+
+			err := next(ctx)
+			if err != nil {
+				ctx.Error(err)
+			}
+
+			level := slog.LevelInfo
+			if ctx.Response().Status >= 500 {
+				level = slog.LevelError
+			}
+
+			s.log().LogAttrs(ctx.Request().Context(), level, "request",
+				slog.String("method", ctx.Request().Method),
+				slog.String("path", ctx.Path()),
+				slog.Int("status", ctx.Response().Status),
+				slog.Duration("latency", time.Since(start)),
+				slog.String("remote_ip", ctx.RealIP()),
+			)
+
+			return nil
+		}
+	}
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > maxRequestIDLength {
+		return false
+	}
+
+	for _, r := range requestID {
+		if r < 0x21 || r > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
+
+func newRequestID() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return time.Now().UTC().Format("20060102150405.000000000")
+	}
+	return hex.EncodeToString(b)
+}
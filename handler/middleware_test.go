@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRequestID(t *testing.T) {
+	s := Server{
+		Repository: new(mocks.RepositoryInterface),
+	}
+
+	e := echo.New()
+	e.HTTPErrorHandler = s.HTTPErrorHandler
+	e.Use(RequestID())
+	e.POST("/register", s.UserRegistration)
+
+	// test 1 request id from client is propagated to the error response
+	req := httptest.NewRequest(http.MethodPost, "/register", nil)
+	req.Header.Set(echo.HeaderXRequestID, "client-request-id")
+	rec := httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	var errResp generated.Problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &errResp))
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, "client-request-id", rec.Header().Get(echo.HeaderXRequestID))
+	if assert.NotNil(t, errResp.RequestId) {
+		assert.Equal(t, "client-request-id", *errResp.RequestId)
+	}
+
+	// test 2 request id is generated when missing
+	req = httptest.NewRequest(http.MethodPost, "/register", nil)
+	rec = httptest.NewRecorder()
+	e.ServeHTTP(rec, req)
+
+	assert.Len(t, rec.Header().Get(echo.HeaderXRequestID), 32)
+}
@@ -0,0 +1,121 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+// passwordPolicy returns the configured password policy, or the default one
+// when the server has been built without it (e.g. in tests).
+func (s *Server) passwordPolicy() model.PasswordPolicy {
+	if s.Config == nil || s.Config.PasswordPolicy.MinLength == 0 {
+		return model.DefaultPasswordPolicy()
+	}
+	return s.Config.PasswordPolicy
+}
+
+func (s *Server) GetPasswordPolicy(ctx echo.Context) error {
+	policy := s.passwordPolicy()
+
+	// The policy only changes on deployment, clients may cache it briefly
+	ctx.Response().Header().Set("Cache-Control", "public, max-age=300")
+	return ctx.JSON(http.StatusOK, generated.PasswordPolicy{
+		MinLength:             policy.MinLength,
+		MaxLength:             policy.MaxLength,
+		RequireUppercase:      policy.RequireUppercase,
+		RequireLowercase:      policy.RequireLowercase,
+		RequireDigit:          policy.RequireDigit,
+		RequireSpecial:        policy.RequireSpecial,
+		SpecialCharacters:     policy.SpecialCharacters,
+		MaxRepeatedCharacters: policy.MaxRepeatedCharacters,
+		DisallowPersonalInfo:  policy.DisallowPersonalInfo,
+		HistoryDepth:          policy.HistoryDepth,
+		ScreenBreached:        policy.Blocklist != nil,
+	})
+}
+
+func (s *Server) ChangePassword(ctx echo.Context) error {
+
+	userData, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get request body data
+	body := new(generated.ChangePasswordRequest)
+	if err := ctx.Bind(body); err != nil {
+		return err
+	}
+
+	// Validate request body content exist
+	if body.CurrentPassword == "" || body.Password == "" {
+		return apierror.Validation(i18n.DetailPasswordFieldsMissing,
+			requiredFieldErrors(map[string]string{
+				"current_password": body.CurrentPassword,
+				"password":         body.Password,
+			})...)
+	}
+
+	user, err := s.loadUser(ctx.Request().Context(), userData.UserID)
+	if err != nil {
+		return err
+	}
+
+	policy := s.passwordPolicy()
+	history, err := s.Repository.GetPasswordHistory(ctx.Request().Context(), repository.GetPasswordHistoryInput{
+		UserID: user.UserID,
+		Limit:  max(policy.HistoryDepth, 1),
+	})
+	if err != nil {
+		return err
+	}
+
+	var hashedPassword string
+	err = s.withHashSlot(func() (err error) {
+		// The current password comes first in the history
+		if len(history) == 0 || !s.passwordMatches(history[0], body.CurrentPassword) {
+			return apierror.New(apierror.CodeInvalidCredentials, i18n.DetailInvalidCurrentPassword)
+		}
+
+		user.Password = body.Password
+		fieldErrors := user.ValidatePassword(policy)
+		fieldErrors = append(fieldErrors, policy.CheckHistory(body.Password, history, s.passwordMatches)...)
+		if len(fieldErrors) > 0 {
+			return apierror.Validation(i18n.DetailCriteriaNotMet, fieldErrors...)
+		}
+
+		hashedPassword, err = s.passwordHasher().Hash(body.Password)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	// The history keeps the replaced passwords, the current one is the new.
+	// The update only applies if the password was not changed since the token
+	// version was checked, so concurrent changes cannot both pass against the
+	// same current password.
+	err = s.Repository.UpdatePassword(ctx.Request().Context(), repository.UpdatePasswordInput{
+		UserID:               user.UserID,
+		Password:             hashedPassword,
+		KeepHistory:          max(policy.HistoryDepth-1, 0),
+		ExpectedTokenVersion: &userData.TokenVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit(requestContext(ctx), repository.InsertAuditEventInput{
+		EventType: model.AuditEventPasswordChanged,
+		UserID:    user.UserID,
+		ActorID:   user.UserID,
+	})
+
+	return ctx.NoContent(http.StatusNoContent)
+}
@@ -0,0 +1,254 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/blocklist"
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/passwordhash"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+// newTestPasswordHasher returns an argon2id hasher with the lowest accepted
+// parameters, so tests hash quickly.
+func newTestPasswordHasher() model.PasswordHasher {
+	hasher, err := passwordhash.NewArgon2id(passwordhash.Argon2idParams{
+		Memory:      8 * 1024,
+		Iterations:  1,
+		Parallelism: 1,
+		SaltLength:  16,
+		KeyLength:   32,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return hasher
+}
+
+func TestGetPasswordPolicy(t *testing.T) {
+	// test 1 default policy
+	s := Server{Config: &config.Config{}}
+
+	ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	err := handleError(&s, ctx, s.GetPasswordPolicy(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("Cache-Control"))
+
+	var policy generated.PasswordPolicy
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &policy))
+	assert.Equal(t, 6, policy.MinLength)
+	assert.Equal(t, 64, policy.MaxLength)
+	assert.Equal(t, model.DefaultSpecialCharacters, policy.SpecialCharacters)
+
+	// test 2 configured policy
+	s.Config.PasswordPolicy = model.PasswordPolicy{MinLength: 12, MaxLength: 72, RequireLowercase: true, HistoryDepth: 5}
+
+	ctx, rec = newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", "")
+	err = handleError(&s, ctx, s.GetPasswordPolicy(ctx))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{
+		"min_length": 12,
+		"max_length": 72,
+		"require_uppercase": false,
+		"require_lowercase": true,
+		"require_digit": false,
+		"require_special": false,
+		"special_characters": "",
+		"max_repeated_characters": 0,
+		"disallow_personal_info": false,
+		"history_depth": 5,
+		"screen_breached": false
+	}`, rec.Body.String())
+}
+
+func TestChangePassword(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+		Return(model.User{UserID: 1, FullName: "Leonardo", PhoneNumber: "+6281223129"}, nil).Maybe()
+	repo.On("GetTokenVersion", mock.Anything, repository.GetTokenVersionInput{UserID: 1}).Return(int32(0), nil).Maybe()
+	jwtToken := newTestJWT()
+
+	policy := model.DefaultPasswordPolicy()
+	policy.DisallowPersonalInfo = true
+	policy.HistoryDepth = 2
+	policy.Blocklist, _ = blocklist.Load(blocklist.Options{})
+	hasher := newTestPasswordHasher()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: jwtToken, PasswordPolicy: policy, PasswordHasher: hasher},
+	}
+
+	token, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 1})
+	currentHash := "$2a$04$a2o3BiK8KiH79TFt9QE1hOutA9115oKSUIYQpFAoLldhotz7pwYQe" // Leo9999#
+	previousHash, _ := hasher.Hash("Kebun#2023")
+
+	var tests = []struct {
+		name        string
+		requestBody string
+		mock        func()
+		assert      func(*httptest.ResponseRecorder)
+	}{
+		{
+			name:        "success",
+			requestBody: `{"current_password":"Leo9999#","password":"Kebun#2024"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, repository.GetPasswordHistoryInput{UserID: 1, Limit: 2}).
+					Return([]string{currentHash, previousHash}, nil).Once()
+				repo.On("UpdatePassword", mock.Anything, mock.MatchedBy(func(in repository.UpdatePasswordInput) bool {
+					return in.UserID == 1 && in.KeepHistory == 1 && s.passwordMatches(in.Password, "Kebun#2024") &&
+						in.ExpectedTokenVersion != nil && *in.ExpectedTokenVersion == 0
+				})).Return(nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNoContent, rec.Code)
+			},
+		},
+		{
+			name:        "password changed concurrently",
+			requestBody: `{"current_password":"Leo9999#","password":"Kebun#2024"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, mock.Anything).Return([]string{currentHash, previousHash}, nil).Once()
+				repo.On("UpdatePassword", mock.Anything, mock.Anything).Return(repository.ErrVersionConflict).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusPreconditionFailed, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"code":"version_conflict"`)
+			},
+		},
+		{
+			name:        "missing fields",
+			requestBody: `{"password":"Kebun#2024"}`,
+			mock:        func() {},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"field":"current_password"`)
+			},
+		},
+		{
+			name:        "wrong current password",
+			requestBody: `{"current_password":"wrong","password":"Kebun#2024"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, mock.Anything).Return([]string{currentHash}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+			},
+		},
+		{
+			name:        "password does not satisfy the policy",
+			requestBody: `{"current_password":"Leo9999#","password":"Leonardo#1"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, mock.Anything).Return([]string{currentHash}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"rule":"personal_info"`)
+			},
+		},
+		{
+			name:        "breached password",
+			requestBody: `{"current_password":"Leo9999#","password":"P@ssw0rd1"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, mock.Anything).Return([]string{currentHash}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"rule":"breached"`)
+			},
+		},
+		{
+			name:        "reused password",
+			requestBody: `{"current_password":"Leo9999#","password":"Kebun#2023"}`,
+			mock: func() {
+				repo.On("GetPasswordHistory", mock.Anything, mock.Anything).Return([]string{currentHash, previousHash}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusBadRequest, rec.Code)
+				assert.Contains(t, rec.Body.String(), `"rule":"reused"`)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt.mock()
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, rec := newTestRequestContext(http.MethodPut, echo.MIMEApplicationJSON, tt.requestBody, token)
+
+			err := handleError(&s, ctx, s.ChangePassword(ctx))
+
+			assert.Equal(t, rec.Code >= http.StatusBadRequest, err != nil)
+			tt.assert(rec)
+		})
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestRehashPasswordOnLogin(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
+	repo.On("UpdateSuccessfulLogin", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+	hasher := newTestPasswordHasher()
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT(), PasswordHasher: hasher},
+	}
+
+	bcryptHash := "$2a$04$a2o3BiK8KiH79TFt9QE1hOutA9115oKSUIYQpFAoLldhotz7pwYQe" // Leo9999#
+	argon2idHash, _ := hasher.Hash("Leo9999#")
+	requestBody := `{"phone_number":"+6281223129","password":"Leo9999#"}`
+
+	// test 1 weaker hash is replaced
+	repo.On("GetLoginData", mock.Anything, mock.Anything).
+		Return(repository.GetLoginDataOutput{UserID: 1, HashedPassword: bcryptHash}, nil).Once()
+	repo.On("RehashPassword", mock.Anything, mock.MatchedBy(func(in repository.RehashPasswordInput) bool {
+		return in.UserID == 1 && in.PreviousPassword == bcryptHash &&
+			strings.HasPrefix(in.Password, "$argon2id$") && s.passwordMatches(in.Password, "Leo9999#")
+	})).Return(nil).Once()
+
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, requestBody, "")
+	err := handleError(&s, ctx, s.CreateSession(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// test 2 current hash is kept
+	repo.On("GetLoginData", mock.Anything, mock.Anything).
+		Return(repository.GetLoginDataOutput{UserID: 1, HashedPassword: argon2idHash}, nil).Once()
+
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, requestBody, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	// test 3 failing to rehash does not fail the login
+	repo.On("GetLoginData", mock.Anything, mock.Anything).
+		Return(repository.GetLoginDataOutput{UserID: 1, HashedPassword: bcryptHash}, nil).Once()
+	repo.On("RehashPassword", mock.Anything, mock.Anything).Return(repository.ErrNotFound).Once()
+
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, requestBody, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	repo.AssertExpectations(t)
+}
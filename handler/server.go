@@ -1,6 +1,9 @@
 package handler
 
 import (
+	"log/slog"
+	"sync"
+
 	"github.com/SawitProRecruitment/UserService/config"
 	"github.com/SawitProRecruitment/UserService/repository"
 )
@@ -8,16 +11,38 @@ import (
 type Server struct {
 	Repository repository.RepositoryInterface
 	Config     *config.Config
+	Logger     *slog.Logger
+	// Breaker guards the repository, its state is reported by Readiness.
+	Breaker *repository.CircuitBreaker
+
+	dummyHashOnce sync.Once
+	dummyHash     string
+
+	hashSlotsOnce sync.Once
+	hashSlots     chan struct{}
 }
 
 type NewServerOptions struct {
 	Repository repository.RepositoryInterface
 	Config     *config.Config
+	Logger     *slog.Logger
+	Breaker    *repository.CircuitBreaker
 }
 
 func NewServer(opts NewServerOptions) *Server {
 	return &Server{
 		Repository: opts.Repository,
 		Config:     opts.Config,
+		Logger:     opts.Logger,
+		Breaker:    opts.Breaker,
+	}
+}
+
+// log returns the injected logger, falling back to the default one when the
+// server has been built without it (e.g. in tests).
+func (s *Server) log() *slog.Logger {
+	if s.Logger == nil {
+		return slog.Default()
 	}
+	return s.Logger
 }
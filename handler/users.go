@@ -0,0 +1,462 @@
+package handler
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const (
+	sessionTTL = config.MaxTokenTTL
+
+	mimeMergePatchJSON = "application/merge-patch+json"
+)
+
+func (s *Server) CreateUser(ctx echo.Context) error {
+
+	// Get request body data
+	body := new(generated.CreateUserRequest)
+	if err := ctx.Bind(body); err != nil {
+		return err
+	}
+
+	user := model.User{
+		PhoneNumber: body.PhoneNumber,
+		FullName:    body.FullName,
+		Password:    body.Password,
+	}
+	if body.PreferredLanguage != nil {
+		user.PreferredLanguage = *body.PreferredLanguage
+	}
+
+	userID, err := s.createUser(requestContext(ctx), &user)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.User{
+		Id:          userID,
+		FullName:    user.FullName,
+		PhoneNumber: user.PhoneNumber,
+	}
+	if user.PreferredLanguage != "" {
+		resp.PreferredLanguage = &user.PreferredLanguage
+	}
+
+	ctx.Response().Header().Set(echo.HeaderLocation, fmt.Sprintf("/v1/users/%d", userID))
+	return ctx.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) CreateSession(ctx echo.Context) error {
+
+	// Get request body data
+	body := new(generated.CreateSessionRequest)
+	if err := ctx.Bind(body); err != nil {
+		return err
+	}
+
+	userID, token, err := s.createSession(requestContext(ctx), body.PhoneNumber, body.Password)
+	if err != nil {
+		return err
+	}
+
+	return ctx.JSON(http.StatusCreated, generated.Session{
+		AccessToken: token,
+		TokenType:   "Bearer",
+		ExpiresIn:   int(sessionTTL.Seconds()),
+		UserId:      userID,
+	})
+}
+
+func (s *Server) GetCurrentUser(ctx echo.Context) error {
+
+	userData, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.userJSON(ctx, userData.UserID)
+}
+
+func (s *Server) GetUser(ctx echo.Context, id int32) error {
+
+	userData, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Other users are reported as missing so ids cannot be enumerated
+	if id != userData.UserID && !s.Config.IsAdmin(userData.UserID) {
+		return apierror.New(apierror.CodeNotFound, i18n.DetailUserNotFound)
+	}
+
+	return s.userJSON(ctx, id)
+}
+
+func (s *Server) PatchCurrentUser(ctx echo.Context) error {
+
+	userData, err := s.authenticate(ctx)
+	if err != nil {
+		return err
+	}
+
+	mediaType, _, _ := mime.ParseMediaType(ctx.Request().Header.Get(echo.HeaderContentType))
+	if mediaType != mimeMergePatchJSON && mediaType != echo.MIMEApplicationJSON {
+		return apierror.New(apierror.CodeUnsupportedMediaType, i18n.DetailUnsupportedContentType).
+			WithParams(map[string]interface{}{"content_type": mimeMergePatchJSON})
+	}
+
+	body, err := io.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return apierror.Wrap(apierror.CodeInvalidRequest, i18n.DetailBodyUnreadable, err)
+	}
+
+	patch, err := parseUserMergePatch(body)
+	if err != nil {
+		return err
+	}
+
+	// An empty merge patch leaves the user unchanged
+	if !patch.IsEmpty() {
+		// Only apply the update when the client edited the latest version
+		expectedVersion, err := parseIfMatch(ctx.Request().Header.Get("If-Match"))
+		if err != nil {
+			return err
+		}
+
+		if _, err := s.updateUser(requestContext(ctx), userData.UserID, patch, expectedVersion); err != nil {
+			return err
+		}
+	}
+
+	return s.userJSON(ctx, userData.UserID)
+}
+
+// userJSON writes the user resource together with its ETag.
+func (s *Server) userJSON(ctx echo.Context, userID int32) error {
+	user, err := s.loadUser(ctx.Request().Context(), userID)
+	if err != nil {
+		return err
+	}
+
+	resp := generated.User{
+		Id:          user.UserID,
+		FullName:    user.FullName,
+		PhoneNumber: user.PhoneNumber,
+	}
+	if user.PreferredLanguage != "" {
+		resp.PreferredLanguage = &user.PreferredLanguage
+	}
+
+	ctx.Response().Header().Set("ETag", userETag(user.Version))
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+// authenticate validates the token of the request. The preferred language of
+// the user, if any, becomes the language of the request.
+func (s *Server) authenticate(ctx echo.Context) (userData model.User, err error) {
+	token := ctx.Request().Header.Get("Authorization")
+	if token == "" {
+		return userData, apierror.New(apierror.CodeMissingToken, i18n.DetailForbidden)
+	}
+
+	userData, err = s.validateToken(ctx.Request().Context(), strings.TrimPrefix(token, "Bearer "))
+	if err != nil {
+		return userData, err
+	}
+
+	setUserLanguage(ctx, userData.PreferredLanguage)
+	return userData, nil
+}
+
+// validateToken returns the user the access token was issued to, as currently
+// stored so changes made since the token was issued apply at once. Tokens
+// issued before the password of the user changed are rejected, against the
+// token version read uncached, the profile may be served from a cache.
+func (s *Server) validateToken(ctx context.Context, token string) (userData model.User, err error) {
+	claims, err := s.Config.JWT.Validate(token)
+	if err != nil {
+		return userData, apierror.Wrap(apierror.CodeInvalidToken, i18n.DetailInvalidToken, err)
+	}
+
+	userData, err = s.Repository.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{
+		UserID: claims.UserID,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return userData, apierror.Wrap(apierror.CodeInvalidToken, i18n.DetailInvalidToken, err)
+	}
+	if err != nil {
+		return userData, err
+	}
+
+	userData.TokenVersion, err = s.Repository.GetTokenVersion(ctx, repository.GetTokenVersionInput{
+		UserID: claims.UserID,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return model.User{}, apierror.Wrap(apierror.CodeInvalidToken, i18n.DetailInvalidToken, err)
+	}
+	if err != nil {
+		return model.User{}, err
+	}
+
+	if userData.TokenVersion != claims.TokenVersion {
+		return model.User{}, apierror.New(apierror.CodeInvalidToken, i18n.DetailInvalidToken)
+	}
+
+	return userData, nil
+}
+
+// createUser validates and stores a new user, its phone number in the
+// canonical form it is normalised to. It backs both POST /v1/users and the
+// legacy POST /register, and the Register RPC.
+func (s *Server) createUser(ctx context.Context, user *model.User) (userID int32, err error) {
+	// Validate request body content exist
+	if user.FullName == "" || user.PhoneNumber == "" || user.Password == "" {
+		return 0, apierror.Validation(i18n.DetailRegisterFieldsMissing,
+			requiredFieldErrors(map[string]string{
+				"phone_number": user.PhoneNumber,
+				"full_name":    user.FullName,
+				"password":     user.Password,
+			})...)
+	}
+
+	if fieldErrors := user.ValidateRegisterUser(s.phoneNumberPolicy(), s.passwordPolicy()); len(fieldErrors) > 0 {
+		return 0, apierror.Validation(i18n.DetailCriteriaNotMet, fieldErrors...)
+	}
+
+	// Hashed password
+	var hashedPassword string
+	err = s.withHashSlot(func() (err error) {
+		hashedPassword, err = s.passwordHasher().Hash(user.Password)
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	// Insert user data to DB
+	out, err := s.Repository.InsertUser(ctx, repository.InsertUserInput{
+		PhoneNumber:       user.PhoneNumber,
+		FullName:          user.FullName,
+		Password:          hashedPassword,
+		PreferredLanguage: user.PreferredLanguage,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit(ctx, repository.InsertAuditEventInput{
+		EventType: model.AuditEventUserRegistered,
+		UserID:    out.UserID,
+		ActorID:   out.UserID,
+	})
+
+	return out.UserID, nil
+}
+
+// phoneNumberPolicy returns the configured phone number policy, or the
+// default one when the server has been built without it (e.g. in tests).
+func (s *Server) phoneNumberPolicy() model.PhoneNumberPolicy {
+	if s.Config == nil || len(s.Config.PhoneNumberPolicy.Countries) == 0 {
+		return model.DefaultPhoneNumberPolicy()
+	}
+	return s.Config.PhoneNumberPolicy
+}
+
+// createSession checks the credentials and issues a token. It backs both
+// POST /v1/sessions, the legacy POST /login and the Login RPC.
+func (s *Server) createSession(ctx context.Context, phoneNumber, password string) (userID int32, token string, err error) {
+	// Validate request body content exist
+	if phoneNumber == "" || password == "" {
+		return 0, "", apierror.Validation(i18n.DetailLoginFieldsMissing,
+			requiredFieldErrors(map[string]string{
+				"phone_number": phoneNumber,
+				"password":     password,
+			})...)
+	}
+
+	// Numbers are stored in their canonical form, one that cannot be
+	// normalised cannot belong to a user
+	phoneNumber, fieldErrors := s.phoneNumberPolicy().Normalize(phoneNumber)
+	if len(fieldErrors) > 0 {
+		return 0, "", apierror.Wrap(apierror.CodeInvalidCredentials, i18n.DetailInvalidCredentials, fieldErrors)
+	}
+
+	userData, err := s.Repository.GetLoginData(ctx, repository.GetLoginDataInput{
+		PhoneNumber: phoneNumber,
+	})
+	// Unknown phone numbers are reported like wrong passwords so registered
+	// numbers cannot be discovered, a password is verified all the same so
+	// they cannot be told apart by the response time either
+	if errors.Is(err, repository.ErrNotFound) {
+		if hashErr := s.withHashSlot(func() error {
+			s.passwordMatches(s.dummyPasswordHash(), password)
+			return nil
+		}); hashErr != nil {
+			return 0, "", hashErr
+		}
+		return 0, "", apierror.Wrap(apierror.CodeInvalidCredentials, i18n.DetailInvalidCredentials, err)
+	}
+	if err != nil {
+		return 0, "", err
+	}
+
+	// Validate password match
+	var matches bool
+	err = s.withHashSlot(func() error {
+		matches = s.passwordMatches(userData.HashedPassword, password)
+		return nil
+	})
+	if err != nil {
+		return 0, "", err
+	}
+	if !matches {
+		s.audit(ctx, repository.InsertAuditEventInput{
+			EventType: model.AuditEventLoginFailed,
+			UserID:    userData.UserID,
+			Metadata:  map[string]string{"reason": "invalid password"},
+		})
+
+		return 0, "", apierror.New(apierror.CodeInvalidCredentials, i18n.DetailInvalidCredentials)
+	}
+
+	// Increment succesfull login and create JWT token in one transaction, so the
+	// counter is not incremented when no token is issued
+	err = s.Repository.WithTx(ctx, func(repo repository.RepositoryInterface) error {
+		err := repo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{
+			PhoneNumber: phoneNumber,
+			UserID:      userData.UserID,
+		})
+		if err != nil {
+			return err
+		}
+
+		token, err = s.Config.JWT.Create(sessionTTL, model.User{
+			UserID:       userData.UserID,
+			TokenVersion: userData.TokenVersion,
+		})
+		return err
+	})
+	if err != nil {
+		return 0, "", err
+	}
+
+	s.audit(ctx, repository.InsertAuditEventInput{
+		EventType: model.AuditEventLoginSucceeded,
+		UserID:    userData.UserID,
+		ActorID:   userData.UserID,
+	})
+
+	s.rehashPassword(ctx, userData, password)
+
+	return userData.UserID, token, nil
+}
+
+// rehashPassword replaces a hash created with another algorithm or weaker
+// parameters than configured, now that the password is known. Failing to
+// replace it must not fail the login, the error is logged instead and the
+// hash is replaced on a later login.
+func (s *Server) rehashPassword(ctx context.Context, userData repository.GetLoginDataOutput, password string) {
+	hasher := s.passwordHasher()
+	if !hasher.NeedsRehash(userData.HashedPassword) {
+		return
+	}
+
+	var hashedPassword string
+	err := s.withHashSlot(func() (err error) {
+		hashedPassword, err = hasher.Hash(password)
+		return err
+	})
+	if err == nil {
+		err = s.Repository.RehashPassword(ctx, repository.RehashPasswordInput{
+			UserID:           userData.UserID,
+			Password:         hashedPassword,
+			PreviousPassword: userData.HashedPassword,
+		})
+	}
+	if err != nil {
+		s.log().WarnContext(ctx, "rehash password", "user_id", userData.UserID, "error", err)
+	}
+}
+
+// loadUser fetches the user, reporting a missing one as not found.
+func (s *Server) loadUser(ctx context.Context, userID int32) (user model.User, err error) {
+	user, err = s.Repository.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{
+		UserID: userID,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return user, apierror.Wrap(apierror.CodeNotFound, i18n.DetailUserNotFound, err)
+	}
+
+	return user, err
+}
+
+// updateUser validates and applies a partial update of the user, only when it
+// is still at expectedVersion unless nil. It returns the new version of the
+// user.
+func (s *Server) updateUser(ctx context.Context, userID int32, patch repository.UserPatch, expectedVersion *int32) (version int32, err error) {
+	user := model.User{}
+
+	var fieldErrors model.ValidationErrors
+	if patch.PhoneNumber != nil {
+		user.PhoneNumber = *patch.PhoneNumber
+		fieldErrors = append(fieldErrors, user.ValidatePhoneNumber(s.phoneNumberPolicy())...)
+		patch.PhoneNumber = &user.PhoneNumber
+	}
+
+	if patch.FullName != nil {
+		user.FullName = *patch.FullName
+		fieldErrors = append(fieldErrors, user.ValidateFullName()...)
+	}
+
+	if patch.PreferredLanguage != nil {
+		user.PreferredLanguage = *patch.PreferredLanguage
+		fieldErrors = append(fieldErrors, user.ValidatePreferredLanguage()...)
+	}
+
+	if len(fieldErrors) > 0 {
+		return 0, apierror.Validation(i18n.DetailCriteriaNotMet, fieldErrors...)
+	}
+
+	out, err := s.Repository.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID:          userID,
+		Patch:           patch,
+		ExpectedVersion: expectedVersion,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	s.audit(ctx, repository.InsertAuditEventInput{
+		EventType: model.AuditEventUserUpdated,
+		UserID:    userID,
+		ActorID:   userID,
+		Metadata:  map[string]string{"fields": strings.Join(patch.Fields(), ",")},
+	})
+
+	return out.Version, nil
+}
+
+// requiredFieldErrors reports the fields that are empty, in a stable order.
+func requiredFieldErrors(values map[string]string) (fieldErrors model.ValidationErrors) {
+	for field, value := range values {
+		if value == "" {
+			fieldErrors = append(fieldErrors, model.Required(field))
+		}
+	}
+	sort.Slice(fieldErrors, func(i, j int) bool { return fieldErrors[i].Field < fieldErrors[j].Field })
+	return
+}
@@ -0,0 +1,580 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func newTestRequestContext(method, contentType, requestBody, token string) (echo.Context, *httptest.ResponseRecorder) {
+	e := echo.New()
+	req, _ := http.NewRequest(method, "/", strings.NewReader(requestBody))
+	req.Header.Set(echo.HeaderContentType, contentType)
+	if token != "" {
+		req.Header.Set(echo.HeaderAuthorization, "Bearer "+token)
+	}
+	rec := httptest.NewRecorder()
+	return e.NewContext(req, rec), rec
+}
+
+func newTestJWT() config.JWT {
+	prvKey, err := os.ReadFile("../cert/id_rsa")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	pubKey, err := os.ReadFile("../cert/id_rsa.pub")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	return config.NewJWT(prvKey, pubKey)
+}
+
+// expectTokenUser stubs the lookup of the user of a valid token by
+// authenticate.
+func expectTokenUser(repo *mocks.RepositoryInterface, jwt config.JWT, token string) {
+	claims, err := jwt.Validate(token)
+	if err != nil {
+		return
+	}
+
+	repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: claims.UserID}).
+		Return(model.User{UserID: claims.UserID}, nil).Once()
+	repo.On("GetTokenVersion", mock.Anything, repository.GetTokenVersionInput{UserID: claims.UserID}).
+		Return(claims.TokenVersion, nil).Once()
+}
+
+func TestCreateUser(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT()},
+	}
+
+	// test 1 created
+	repo.On("InsertUser", mock.Anything, mock.MatchedBy(func(in repository.InsertUserInput) bool {
+		return in.PhoneNumber == "+6281223129" && in.FullName == "Leonardo"
+	})).Return(repository.InsertUserOutput{UserID: 7}, nil).Once()
+
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","full_name":"Leonardo","password":"Leo9999#"}`, "")
+	err := handleError(&s, ctx, s.CreateUser(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Equal(t, "/v1/users/7", rec.Header().Get(echo.HeaderLocation))
+
+	var user generated.User
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &user))
+	assert.Equal(t, generated.User{Id: 7, FullName: "Leonardo", PhoneNumber: "+6281223129"}, user)
+
+	// test 2 phone number is stored and returned normalised
+	repo.On("InsertUser", mock.Anything, mock.MatchedBy(func(in repository.InsertUserInput) bool {
+		return in.PhoneNumber == "+6281223129"
+	})).Return(repository.InsertUserOutput{UserID: 8}, nil).Once()
+
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"0812-2312-9","full_name":"Leonardo","password":"Leo9999#"}`, "")
+	err = handleError(&s, ctx, s.CreateUser(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"phone_number":"+6281223129"`)
+
+	// test 3 invalid input
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129"}`, "")
+	err = handleError(&s, ctx, s.CreateUser(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Equal(t, mimeProblemJSON, rec.Header().Get(echo.HeaderContentType))
+
+	var problem generated.Problem
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	assert.Equal(t, generated.ValidationFailed, problem.Code)
+	if assert.NotNil(t, problem.Errors) {
+		assert.Equal(t, []generated.ProblemFieldError{
+			{Field: "full_name", Rule: model.RuleRequired, Message: "This field is required"},
+			{Field: "password", Rule: model.RuleRequired, Message: "This field is required"},
+		}, *problem.Errors)
+	}
+
+	// test 4 rule violations are reported per field with their params
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","full_name":"Le","password":"Leo9999"}`, "")
+	err = handleError(&s, ctx, s.CreateUser(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+
+	problem = generated.Problem{}
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &problem))
+	if assert.NotNil(t, problem.Errors) {
+		assert.Equal(t, []generated.ProblemFieldError{
+			{
+				Field:   "full_name",
+				Rule:    model.RuleLength,
+				Params:  &map[string]interface{}{"min": float64(3), "max": float64(60)},
+				Message: "Full name must be between 3 and 60 characters",
+			},
+			{
+				Field:   "password",
+				Rule:    model.RuleSpecialChar,
+				Params:  &map[string]interface{}{"min": float64(1), "characters": "~!@#$%^&*()-_+=<>?/[]{}|"},
+				Message: "Password must contain at least one special character",
+			},
+		}, *problem.Errors)
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestCreateSession(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	repo.On("WithTx", mock.Anything, mock.Anything).Return(func(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+		return fn(repo)
+	}).Maybe()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT()},
+	}
+
+	// test 1 created
+	repo.On("GetLoginData", mock.Anything, repository.GetLoginDataInput{
+		PhoneNumber: "+6281223129",
+	}).Return(repository.GetLoginDataOutput{
+		UserID:         1,
+		HashedPassword: "$2a$04$a2o3BiK8KiH79TFt9QE1hOutA9115oKSUIYQpFAoLldhotz7pwYQe",
+	}, nil).Twice()
+	repo.On("UpdateSuccessfulLogin", mock.Anything, repository.UpdateSuccessfulLoginInput{
+		PhoneNumber: "+6281223129",
+		UserID:      1,
+	}).Return(nil).Once()
+	repo.On("RehashPassword", mock.Anything, mock.Anything).Return(nil).Once()
+
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"Leo9999#"}`, "")
+	err := handleError(&s, ctx, s.CreateSession(ctx))
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusCreated, rec.Code)
+
+	var session generated.Session
+	assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &session))
+	assert.Equal(t, "Bearer", session.TokenType)
+	assert.Equal(t, 3600, session.ExpiresIn)
+	assert.Equal(t, int32(1), session.UserId)
+
+	userData, err := s.Config.JWT.Validate(session.AccessToken)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), userData.UserID)
+
+	// test 2 wrong password, the number is looked up normalised
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"0812 2312 9","password":"wrong"}`, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+
+	// test 3 numbers that cannot be normalised are unknown
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+1 555 0100","password":"Leo9999#"}`, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+	repo.AssertExpectations(t)
+}
+
+// verifyRecordingHasher records the hashes it verifies.
+type verifyRecordingHasher struct {
+	model.PasswordHasher
+	verified []string
+}
+
+func (h *verifyRecordingHasher) Verify(hash, password string) (bool, error) {
+	h.verified = append(h.verified, hash)
+	return h.PasswordHasher.Verify(hash, password)
+}
+
+func TestCreateSessionUnknownPhoneNumber(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	hasher := &verifyRecordingHasher{PasswordHasher: newTestPasswordHasher()}
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT(), PasswordHasher: hasher},
+	}
+
+	repo.On("GetLoginData", mock.Anything, repository.GetLoginDataInput{
+		PhoneNumber: "+6281223129",
+	}).Return(repository.GetLoginDataOutput{}, repository.ErrNotFound).Twice()
+
+	// test 1 a password is verified against a hash of the configured hasher
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"Leo9999#"}`, "")
+	err := handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+	if assert.Len(t, hasher.verified, 1) {
+		assert.True(t, strings.HasPrefix(hasher.verified[0], "$argon2id$"))
+	}
+
+	// test 2 the hash is made once
+	ctx, _ = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"Leo9999#"}`, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	if assert.Len(t, hasher.verified, 2) {
+		assert.Equal(t, hasher.verified[0], hasher.verified[1])
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestPasswordHashingSaturated(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: newTestJWT(), PasswordHasher: newTestPasswordHasher(), PasswordHashConcurrency: 1},
+	}
+
+	// Another request holds the only slot
+	holding, release := make(chan struct{}), make(chan struct{})
+	done := make(chan error)
+	go func() {
+		done <- s.withHashSlot(func() error {
+			close(holding)
+			<-release
+			return nil
+		})
+	}()
+	<-holding
+
+	// test 1 registration is refused without hashing
+	ctx, rec := newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","full_name":"Leonardo","password":"Leo9999#"}`, "")
+	err := handleError(&s, ctx, s.CreateUser(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+	assert.Equal(t, "1", rec.Header().Get(echo.HeaderRetryAfter))
+
+	// test 2 login of an unknown user is refused too
+	repo.On("GetLoginData", mock.Anything, repository.GetLoginDataInput{PhoneNumber: "+6281223129"}).
+		Return(repository.GetLoginDataOutput{}, repository.ErrNotFound).Twice()
+
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"Leo9999#"}`, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusServiceUnavailable, rec.Code)
+
+	// test 3 the slot is free again
+	close(release)
+	assert.NoError(t, <-done)
+
+	ctx, rec = newTestRequestContext(http.MethodPost, echo.MIMEApplicationJSON, `{"phone_number":"+6281223129","password":"Leo9999#"}`, "")
+	err = handleError(&s, ctx, s.CreateSession(ctx))
+	assert.Error(t, err)
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `"code":"invalid_credentials"`)
+
+	repo.AssertExpectations(t)
+}
+
+func TestValidateTokenVersion(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	jwtToken := newTestJWT()
+	s := Server{Repository: repo, Config: &config.Config{JWT: jwtToken}}
+
+	token, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 1, TokenVersion: 1})
+
+	// test 1 the token version is not taken from a stale profile
+	repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+		Return(model.User{UserID: 1, TokenVersion: 1}, nil).Twice()
+	repo.On("GetTokenVersion", mock.Anything, repository.GetTokenVersionInput{UserID: 1}).Return(int32(2), nil).Once()
+
+	_, err := s.validateToken(context.Background(), token)
+	assert.ErrorContains(t, err, "invalid_token")
+
+	// test 2 current token version
+	repo.On("GetTokenVersion", mock.Anything, repository.GetTokenVersionInput{UserID: 1}).Return(int32(1), nil).Once()
+
+	userData, err := s.validateToken(context.Background(), token)
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), userData.UserID)
+	repo.AssertExpectations(t)
+}
+
+func TestGetUser(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	jwtToken := newTestJWT()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: jwtToken, AdminUserIDs: []int32{9}},
+	}
+
+	userToken, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 1})
+	adminToken, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 9})
+
+	type args struct {
+		token string
+		id    int32
+		me    bool
+	}
+
+	var tests = []struct {
+		name   string
+		args   args
+		mock   func()
+		assert func(*httptest.ResponseRecorder)
+	}{
+		{
+			name: "me",
+			args: args{token: userToken, me: true},
+			mock: func() {
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, FullName: "Leonardo", PhoneNumber: "+6281223129", Version: 2}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, `"2"`, rec.Header().Get("ETag"))
+				assert.JSONEq(t, `{"id":1,"full_name":"Leonardo","phone_number":"+6281223129"}`, rec.Body.String())
+			},
+		},
+		{
+			name: "self by id",
+			args: args{token: userToken, id: 1},
+			mock: func() {
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, Version: 1}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name: "other user is not found",
+			args: args{token: userToken, id: 2},
+			mock: func() {},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			name: "admin reads other user",
+			args: args{token: adminToken, id: 2},
+			mock: func() {
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 2}).
+					Return(model.User{UserID: 2, Version: 1}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name: "missing user",
+			args: args{token: adminToken, id: 3},
+			mock: func() {
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 3}).
+					Return(model.User{}, repository.ErrNotFound).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			},
+		},
+		{
+			name:   "token missing",
+			args:   args{me: true},
+			mock:   func() {},
+			assert: func(rec *httptest.ResponseRecorder) { assert.Equal(t, http.StatusForbidden, rec.Code) },
+		},
+	}
+
+	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, tt.args.token)
+		tt.mock()
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, rec := newTestRequestContext(http.MethodGet, echo.MIMEApplicationJSON, "", tt.args.token)
+
+			var err error
+			if tt.args.me {
+				err = handleError(&s, ctx, s.GetCurrentUser(ctx))
+			} else {
+				err = handleError(&s, ctx, s.GetUser(ctx, tt.args.id))
+			}
+
+			assert.Equal(t, rec.Code >= http.StatusBadRequest, err != nil)
+			tt.assert(rec)
+		})
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestPatchCurrentUser(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+	jwtToken := newTestJWT()
+
+	s := Server{
+		Repository: repo,
+		Config:     &config.Config{JWT: jwtToken},
+	}
+
+	token, _ := jwtToken.Create(time.Minute*1, model.User{UserID: 1})
+	fullName := "Leo O'Neil"
+
+	type args struct {
+		contentType string
+		requestBody string
+	}
+
+	var tests = []struct {
+		name   string
+		args   args
+		mock   func()
+		assert func(*httptest.ResponseRecorder)
+	}{
+		{
+			name: "success",
+			args: args{contentType: mimeMergePatchJSON, requestBody: `{"full_name":"Leo O'Neil"}`},
+			mock: func() {
+				repo.On("UpdateUserData", mock.Anything, repository.UpdateUserDataInput{
+					UserID: 1,
+					Patch:  repository.UserPatch{FullName: &fullName},
+				}).Return(repository.UpdateUserDataOutput{Version: 3}, nil).Once()
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, FullName: fullName, PhoneNumber: "+6281223129", Version: 3}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.Equal(t, `"3"`, rec.Header().Get("ETag"))
+				assert.JSONEq(t, `{"id":1,"full_name":"Leo O'Neil","phone_number":"+6281223129"}`, rec.Body.String())
+			},
+		},
+		{
+			name: "empty patch leaves the user unchanged",
+			args: args{contentType: mimeMergePatchJSON, requestBody: `{}`},
+			mock: func() {
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, Version: 2}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name:   "removing a field",
+			args:   args{contentType: mimeMergePatchJSON, requestBody: `{"full_name":null}`},
+			mock:   func() {},
+			assert: func(rec *httptest.ResponseRecorder) { assert.Equal(t, http.StatusBadRequest, rec.Code) },
+		},
+		{
+			name: "phone number is normalised",
+			args: args{contentType: mimeMergePatchJSON, requestBody: `{"phone_number":"+62 812-2312-9"}`},
+			mock: func() {
+				repo.On("UpdateUserData", mock.Anything, mock.MatchedBy(func(in repository.UpdateUserDataInput) bool {
+					return in.Patch.PhoneNumber != nil && *in.Patch.PhoneNumber == "+6281223129"
+				})).Return(repository.UpdateUserDataOutput{Version: 4}, nil).Once()
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, FullName: fullName, PhoneNumber: "+6281223129", Version: 4}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+			},
+		},
+		{
+			name:   "invalid value",
+			args:   args{contentType: mimeMergePatchJSON, requestBody: `{"phone_number":"123"}`},
+			mock:   func() {},
+			assert: func(rec *httptest.ResponseRecorder) { assert.Equal(t, http.StatusBadRequest, rec.Code) },
+		},
+		{
+			name: "preferred language",
+			args: args{contentType: mimeMergePatchJSON, requestBody: `{"preferred_language":"id"}`},
+			mock: func() {
+				repo.On("UpdateUserData", mock.Anything, mock.MatchedBy(func(in repository.UpdateUserDataInput) bool {
+					return in.Patch.PreferredLanguage != nil && *in.Patch.PreferredLanguage == "id"
+				})).Return(repository.UpdateUserDataOutput{Version: 4}, nil).Once()
+				repo.On("GetUserDataByUserID", mock.Anything, repository.GetUserDataByUserIDInput{UserID: 1}).
+					Return(model.User{UserID: 1, FullName: fullName, PhoneNumber: "+6281223129", Version: 4, PreferredLanguage: "id"}, nil).Once()
+			},
+			assert: func(rec *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, rec.Code)
+				assert.JSONEq(t, `{"id":1,"full_name":"Leo O'Neil","phone_number":"+6281223129","preferred_language":"id"}`, rec.Body.String())
+			},
+		},
+		{
+			name:   "unsupported preferred language",
+			args:   args{contentType: mimeMergePatchJSON, requestBody: `{"preferred_language":"fr"}`},
+			mock:   func() {},
+			assert: func(rec *httptest.ResponseRecorder) { assert.Equal(t, http.StatusBadRequest, rec.Code) },
+		},
+		{
+			name:   "unsupported content type",
+			args:   args{contentType: "text/plain", requestBody: `{"full_name":"Leo"}`},
+			mock:   func() {},
+			assert: func(rec *httptest.ResponseRecorder) { assert.Equal(t, http.StatusUnsupportedMediaType, rec.Code) },
+		},
+	}
+
+	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, token)
+		tt.mock()
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, rec := newTestRequestContext(http.MethodPatch, tt.args.contentType, tt.args.requestBody, token)
+
+			err := handleError(&s, ctx, s.PatchCurrentUser(ctx))
+
+			assert.Equal(t, rec.Code >= http.StatusBadRequest, err != nil)
+			tt.assert(rec)
+		})
+	}
+	repo.AssertExpectations(t)
+}
+
+func TestParseUserMergePatch(t *testing.T) {
+	fullName, phoneNumber, noLanguage, indonesian := "Leo", "+6281223129", "", "id"
+
+	var tests = []struct {
+		name    string
+		body    string
+		want    repository.UserPatch
+		wantErr bool
+	}{
+		{name: "empty object", body: `{}`},
+		{name: "one member", body: `{"full_name":"Leo"}`, want: repository.UserPatch{FullName: &fullName}},
+		{name: "all members", body: `{"full_name":"Leo","phone_number":"+6281223129"}`, want: repository.UserPatch{FullName: &fullName, PhoneNumber: &phoneNumber}},
+		{name: "null member", body: `{"phone_number":null}`, wantErr: true},
+		{name: "preferred language", body: `{"preferred_language":"id"}`, want: repository.UserPatch{PreferredLanguage: &indonesian}},
+		{name: "null preferred language clears it", body: `{"preferred_language":null}`, want: repository.UserPatch{PreferredLanguage: &noLanguage}},
+		{name: "unknown member", body: `{"password":"x"}`, wantErr: true},
+		{name: "not a string", body: `{"full_name":1}`, wantErr: true},
+		{name: "not an object", body: `["full_name"]`, wantErr: true},
+		{name: "null document", body: `null`, wantErr: true},
+		{name: "invalid json", body: `{`, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseUserMergePatch([]byte(tt.body))
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
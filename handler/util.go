@@ -1,22 +1,72 @@
 package handler
 
 import (
-	"golang.org/x/crypto/bcrypt"
+	"errors"
+	"runtime"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/passwordhash"
 )
 
-func HashedPassword(password string) (string, error) {
-	result, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.MinCost)
+// errHashingSaturated is returned when every password hashing slot is taken.
+var errHashingSaturated = errors.New("too many password hashes in progress")
+
+// passwordHasher returns the configured password hasher, or argon2id with the
+// default parameters when the server has been built without it (e.g. in
+// tests).
+func (s *Server) passwordHasher() model.PasswordHasher {
+	if s.Config == nil || s.Config.PasswordHasher == nil {
+		return passwordhash.Default()
+	}
+	return s.Config.PasswordHasher
+}
+
+// passwordMatches reports whether the hash is the hash of the password. A hash
+// that cannot be read never matches and is logged, since it can only come
+// from a corrupted row.
+func (s *Server) passwordMatches(hash, password string) bool {
+	ok, err := s.passwordHasher().Verify(hash, password)
 	if err != nil {
-		return "", err
+		s.log().Error("verify password hash", "error", err)
 	}
-	return string(result), nil
+	return ok
+}
+
+// dummyPasswordHash returns a hash made by the configured hasher, verified
+// against the password of a login for an unknown user so it takes as long as
+// one for a registered user. It is made once, on the first use.
+func (s *Server) dummyPasswordHash() string {
+	s.dummyHashOnce.Do(func() {
+		hash, err := s.passwordHasher().Hash("dummy password")
+		if err != nil {
+			s.log().Error("hash dummy password", "error", err)
+			return
+		}
+		s.dummyHash = hash
+	})
+	return s.dummyHash
 }
 
-func CompareHashAndPassword(hashedPassword string, plainPassword string) bool {
-	byteHash := []byte(hashedPassword)
-	bytePlain := []byte(plainPassword)
+// withHashSlot runs fn, which hashes or verifies passwords, in one of the
+// PasswordHashConcurrency slots. Hashes are slow and memory hungry on purpose,
+// so when every slot is taken the request is refused at once with a 503
+// instead of queueing until the instance runs out of memory.
+func (s *Server) withHashSlot(fn func() error) error {
+	s.hashSlotsOnce.Do(func() {
+		concurrency := runtime.NumCPU()
+		if s.Config != nil && s.Config.PasswordHashConcurrency > 0 {
+			concurrency = s.Config.PasswordHashConcurrency
+		}
+		s.hashSlots = make(chan struct{}, concurrency)
+	})
 
-	err := bcrypt.CompareHashAndPassword(byteHash, bytePlain)
+	select {
+	case s.hashSlots <- struct{}{}:
+	default:
+		return apierror.Wrap(apierror.CodeServiceUnavailable, "", errHashingSaturated)
+	}
+	defer func() { <-s.hashSlots }()
 
-	return err == nil
+	return fn()
 }
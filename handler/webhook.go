@@ -0,0 +1,227 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	"github.com/SawitProRecruitment/UserService/apierror"
+	"github.com/SawitProRecruitment/UserService/generated"
+	"github.com/SawitProRecruitment/UserService/i18n"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/labstack/echo/v4"
+)
+
+const maxWebhookDeliveriesLimit = 500
+
+func (s *Server) CreateWebhookSubscription(ctx echo.Context) error {
+
+	admin, err := s.authorizeAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Get request body data
+	body := new(generated.CreateWebhookSubscriptionRequest)
+	if err := ctx.Bind(body); err != nil {
+		return err
+	}
+
+	target, err := url.Parse(body.Url)
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return apierror.Validation(i18n.DetailInvalidWebhookURL,
+			model.NewFieldError("url", model.RuleURL, i18n.ValidationURL, map[string]interface{}{"schemes": []string{"http", "https"}}))
+	}
+
+	secret := ""
+	if body.Secret != nil {
+		secret = *body.Secret
+	} else {
+		secret, err = newWebhookSecret()
+		if err != nil {
+			return err
+		}
+	}
+
+	eventTypes := body.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	// An empty secret would sign the deliveries with an empty key, and an
+	// unknown event type never fires
+	fieldErrors := model.WebhookSubscription{EventTypes: eventTypes, Secret: secret}.Validate()
+	if len(fieldErrors) > 0 {
+		return apierror.Validation(i18n.DetailCriteriaNotMet, fieldErrors...)
+	}
+
+	subscription, err := s.Repository.InsertWebhookSubscription(ctx.Request().Context(), repository.InsertWebhookSubscriptionInput{
+		URL:        body.Url,
+		EventTypes: eventTypes,
+		Secret:     secret,
+	})
+	if err != nil {
+		return err
+	}
+
+	s.audit(requestContext(ctx), repository.InsertAuditEventInput{
+		EventType: model.AuditEventWebhookCreated,
+		ActorID:   admin.UserID,
+		Metadata: map[string]string{
+			"subscription_id": strconv.Itoa(int(subscription.ID)),
+			"url":             subscription.URL,
+		},
+	})
+
+	resp := toWebhookSubscriptionResponse(subscription)
+	resp.Secret = &secret
+
+	return ctx.JSON(http.StatusCreated, resp)
+}
+
+func (s *Server) ListWebhookSubscriptions(ctx echo.Context) error {
+
+	var resp = generated.WebhookSubscriptionsResponse{Subscriptions: []generated.WebhookSubscription{}}
+
+	if _, err := s.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	subscriptions, err := s.Repository.GetWebhookSubscriptions(ctx.Request().Context(), repository.GetWebhookSubscriptionsInput{})
+	if err != nil {
+		return err
+	}
+
+	for _, subscription := range subscriptions {
+		resp.Subscriptions = append(resp.Subscriptions, toWebhookSubscriptionResponse(subscription))
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) DeleteWebhookSubscription(ctx echo.Context, id int32) error {
+
+	admin, err := s.authorizeAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.DeactivateWebhookSubscription(ctx.Request().Context(), repository.DeactivateWebhookSubscriptionInput{
+		ID: id,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return apierror.Wrap(apierror.CodeNotFound, i18n.DetailWebhookSubscriptionNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.audit(requestContext(ctx), repository.InsertAuditEventInput{
+		EventType: model.AuditEventWebhookDeactivated,
+		ActorID:   admin.UserID,
+		Metadata:  map[string]string{"subscription_id": strconv.Itoa(int(id))},
+	})
+
+	return ctx.NoContent(http.StatusNoContent)
+}
+
+func (s *Server) ListWebhookDeliveries(ctx echo.Context, id int32, params generated.ListWebhookDeliveriesParams) error {
+
+	var resp = generated.WebhookDeliveriesResponse{Deliveries: []generated.WebhookDelivery{}}
+
+	if _, err := s.authorizeAdmin(ctx); err != nil {
+		return err
+	}
+
+	input := repository.GetWebhookDeliveriesInput{
+		SubscriptionID: id,
+	}
+	if params.Status != nil {
+		input.Status = string(*params.Status)
+	}
+	if params.AfterId != nil {
+		input.AfterID = *params.AfterId
+	}
+	if params.Limit != nil {
+		input.Limit = *params.Limit
+	}
+	if input.Limit > maxWebhookDeliveriesLimit {
+		input.Limit = maxWebhookDeliveriesLimit
+	}
+
+	deliveries, err := s.Repository.GetWebhookDeliveries(ctx.Request().Context(), input)
+	if err != nil {
+		return err
+	}
+
+	for _, delivery := range deliveries {
+		resp.Deliveries = append(resp.Deliveries, generated.WebhookDelivery{
+			Id:             delivery.ID,
+			SubscriptionId: delivery.SubscriptionID,
+			EventId:        delivery.EventID,
+			EventType:      delivery.EventType,
+			Status:         generated.WebhookDeliveryStatus(delivery.Status),
+			Attempts:       delivery.Attempts,
+			NextAttemptAt:  delivery.NextAttemptAt,
+			LastStatusCode: delivery.LastStatusCode,
+			LastError:      delivery.LastError,
+			CreatedAt:      delivery.CreatedAt,
+			UpdatedAt:      delivery.UpdatedAt,
+		})
+	}
+
+	return ctx.JSON(http.StatusOK, resp)
+}
+
+func (s *Server) RedeliverWebhookDelivery(ctx echo.Context, id int64) error {
+
+	admin, err := s.authorizeAdmin(ctx)
+	if err != nil {
+		return err
+	}
+
+	err = s.Repository.RedeliverWebhookDelivery(ctx.Request().Context(), repository.RedeliverWebhookDeliveryInput{
+		ID: id,
+	})
+	if errors.Is(err, repository.ErrNotFound) {
+		return apierror.Wrap(apierror.CodeNotFound, i18n.DetailWebhookDeliveryNotFound, err)
+	}
+	if err != nil {
+		return err
+	}
+
+	s.audit(requestContext(ctx), repository.InsertAuditEventInput{
+		EventType: model.AuditEventWebhookRedelivered,
+		ActorID:   admin.UserID,
+		Metadata:  map[string]string{"delivery_id": strconv.FormatInt(id, 10)},
+	})
+
+	return ctx.NoContent(http.StatusAccepted)
+}
+
+func toWebhookSubscriptionResponse(subscription model.WebhookSubscription) generated.WebhookSubscription {
+	eventTypes := subscription.EventTypes
+	if eventTypes == nil {
+		eventTypes = []string{}
+	}
+
+	return generated.WebhookSubscription{
+		Id:         subscription.ID,
+		Url:        subscription.URL,
+		EventTypes: eventTypes,
+		Active:     subscription.Active,
+		CreatedAt:  subscription.CreatedAt,
+	}
+}
+
+func newWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,217 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/config"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/labstack/echo/v4"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestCreateWebhookSubscription(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+
+	prvKey, err := os.ReadFile("../cert/id_rsa")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	pubKey, err := os.ReadFile("../cert/id_rsa.pub")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	jwtToken := config.NewJWT(prvKey, pubKey)
+
+	adminToken, _ := jwtToken.Create(time.Minute*1, model.User{
+		UserID: 1,
+	})
+
+	type args struct {
+		token       string
+		requestBody string
+	}
+
+	var tests = []struct {
+		name   string
+		args   args
+		mock   func()
+		assert func(error, echo.Context)
+	}{
+		{
+			name: "success",
+			args: args{
+				token:       adminToken,
+				requestBody: `{"url":"https://partner.example.com/hooks","event_types":["UserRegistered"],"secret":"s3cret-s3cret-s3cret-s3cret-s3cret"}`,
+			},
+			mock: func() {
+				repo.On("InsertWebhookSubscription", mock.Anything, repository.InsertWebhookSubscriptionInput{
+					URL:        "https://partner.example.com/hooks",
+					EventTypes: []string{model.EventUserRegistered},
+					Secret:     "s3cret-s3cret-s3cret-s3cret-s3cret",
+				}).Return(model.WebhookSubscription{ID: 1, Active: true}, nil).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusCreated, ctx.Response().Status)
+			},
+		},
+		{
+			name: "bad request - invalid url",
+			args: args{
+				token:       adminToken,
+				requestBody: `{"url":"ftp://partner.example.com","event_types":[]}`,
+			},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusBadRequest, ctx.Response().Status)
+			},
+		},
+		{
+			name: "bad request - empty secret",
+			args: args{
+				token:       adminToken,
+				requestBody: `{"url":"https://partner.example.com/hooks","event_types":[],"secret":""}`,
+			},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusBadRequest, ctx.Response().Status)
+				assert.Contains(t, ctx.Response().Writer.(*httptest.ResponseRecorder).Body.String(), `"field":"secret"`)
+			},
+		},
+		{
+			name: "bad request - unknown event type",
+			args: args{
+				token:       adminToken,
+				requestBody: `{"url":"https://partner.example.com/hooks","event_types":["UserRegisterd"]}`,
+			},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusBadRequest, ctx.Response().Status)
+				assert.Contains(t, ctx.Response().Writer.(*httptest.ResponseRecorder).Body.String(), `"field":"event_types"`)
+			},
+		},
+		{
+			name: "token missing",
+			args: args{},
+			mock: func() {},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusForbidden, ctx.Response().Status)
+			},
+		},
+		{
+			name: "fail - insert webhook subscription",
+			args: args{
+				token:       adminToken,
+				requestBody: `{"url":"https://partner.example.com/hooks","event_types":[]}`,
+			},
+			mock: func() {
+				repo.On("InsertWebhookSubscription", mock.Anything, mock.Anything).Return(model.WebhookSubscription{}, errors.New("error")).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusInternalServerError, ctx.Response().Status)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, tt.args.token)
+		tt.mock()
+		s := Server{
+			Repository: repo,
+			Config: &config.Config{
+				JWT:          jwtToken,
+				AdminUserIDs: []int32{1},
+			},
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newTestContextWithToken(tt.args.requestBody, tt.args.token)
+
+			err := handleError(&s, ctx, s.CreateWebhookSubscription(ctx))
+
+			tt.assert(err, ctx)
+		})
+	}
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	repo.On("InsertAuditEvent", mock.Anything, mock.Anything).Return(repository.InsertAuditEventOutput{}, nil).Maybe()
+
+	prvKey, err := os.ReadFile("../cert/id_rsa")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	pubKey, err := os.ReadFile("../cert/id_rsa.pub")
+	if err != nil {
+		log.Fatalln(err)
+	}
+
+	jwtToken := config.NewJWT(prvKey, pubKey)
+
+	adminToken, _ := jwtToken.Create(time.Minute*1, model.User{
+		UserID: 1,
+	})
+
+	var tests = []struct {
+		name   string
+		id     int64
+		mock   func()
+		assert func(error, echo.Context)
+	}{
+		{
+			name: "success",
+			id:   1,
+			mock: func() {
+				repo.On("RedeliverWebhookDelivery", mock.Anything, repository.RedeliverWebhookDeliveryInput{ID: 1}).Return(nil).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.NoError(t, err)
+				assert.Equal(t, http.StatusAccepted, ctx.Response().Status)
+			},
+		},
+		{
+			name: "not found",
+			id:   2,
+			mock: func() {
+				repo.On("RedeliverWebhookDelivery", mock.Anything, repository.RedeliverWebhookDeliveryInput{ID: 2}).Return(repository.ErrNotFound).Once()
+			},
+			assert: func(err error, ctx echo.Context) {
+				assert.Equal(t, http.StatusNotFound, ctx.Response().Status)
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		expectTokenUser(repo, jwtToken, adminToken)
+		tt.mock()
+		s := Server{
+			Repository: repo,
+			Config: &config.Config{
+				JWT:          jwtToken,
+				AdminUserIDs: []int32{1},
+			},
+		}
+
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, _ := newTestContextWithToken("", adminToken)
+
+			err := handleError(&s, ctx, s.RedeliverWebhookDelivery(ctx, tt.id))
+
+			tt.assert(err, ctx)
+		})
+	}
+}
@@ -0,0 +1,135 @@
+// Package i18n holds the message catalog of the service and negotiates the
+// language messages are served in.
+//
+// Messages are looked up by key, so the English text can be reworded or
+// translated without touching the code that reports it. Every key must have a
+// translation in every supported language, which the tests enforce.
+package i18n
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type Language string
+
+const (
+	English    Language = "en"
+	Indonesian Language = "id"
+)
+
+// DefaultLanguage is served when neither the user nor the request asks for a
+// supported language.
+const DefaultLanguage = English
+
+// Supported returns the languages of the catalog, sorted.
+func Supported() []Language {
+	return []Language{English, Indonesian}
+}
+
+// ParseLanguage maps a BCP 47 language tag such as "id-ID" to a supported
+// language. Only the primary subtag is considered.
+func ParseLanguage(tag string) (Language, bool) {
+	primary, _, _ := strings.Cut(strings.TrimSpace(tag), "-")
+	switch strings.ToLower(primary) {
+	case "en":
+		return English, true
+	// "in" is the deprecated ISO 639 code of Indonesian, still sent by some
+	// older Android devices
+	case "id", "in":
+		return Indonesian, true
+	}
+	return "", false
+}
+
+// Negotiate picks the supported language the Accept-Language header value
+// prefers most. Ranges with a zero quality are never picked and "*" matches
+// the default language. It reports false when nothing matches.
+func Negotiate(acceptLanguage string) (Language, bool) {
+	type candidate struct {
+		language Language
+		quality  float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		quality := 1.0
+		if name, value, ok := strings.Cut(strings.TrimSpace(params), "="); ok && strings.TrimSpace(name) == "q" {
+			parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+			if err != nil {
+				continue
+			}
+			quality = parsed
+		}
+		if quality <= 0 {
+			continue
+		}
+
+		language, ok := ParseLanguage(tag)
+		if strings.TrimSpace(tag) == "*" {
+			language, ok = DefaultLanguage, true
+		}
+		if ok {
+			candidates = append(candidates, candidate{language, quality})
+		}
+	}
+	if len(candidates) == 0 {
+		return "", false
+	}
+
+	// Equal qualities keep the order of the header
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].quality > candidates[j].quality })
+	return candidates[0].language, true
+}
+
+type contextKey struct{}
+
+// WithLanguage returns a copy of ctx that carries the language.
+func WithLanguage(ctx context.Context, language Language) context.Context {
+	return context.WithValue(ctx, contextKey{}, language)
+}
+
+// FromContext returns the language carried by ctx, if any.
+func FromContext(ctx context.Context) (Language, bool) {
+	language, ok := ctx.Value(contextKey{}).(Language)
+	return language, ok
+}
+
+// Translate renders the message of key in the language, substituting the
+// {name} placeholders with params. Messages missing in the language fall back
+// to English.
+func Translate(language Language, key Key, params map[string]interface{}) string {
+	if key == "" {
+		return ""
+	}
+
+	message, ok := catalog[key][language]
+	if !ok {
+		message, ok = catalog[key][English]
+	}
+	if !ok {
+		return string(key)
+	}
+
+	for name, value := range params {
+		message = strings.ReplaceAll(message, "{"+name+"}", formatParam(value))
+	}
+	return message
+}
+
+// Has reports whether key is translated in the language.
+func Has(language Language, key Key) bool {
+	_, ok := catalog[key][language]
+	return ok
+}
+
+func formatParam(value interface{}) string {
+	if values, ok := value.([]string); ok {
+		return strings.Join(values, ", ")
+	}
+	return fmt.Sprint(value)
+}
@@ -0,0 +1,106 @@
+package i18n
+
+import (
+	"context"
+	"regexp"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var placeholderRegex = regexp.MustCompile(`\{[a-z_]+\}`)
+
+func placeholders(message string) []string {
+	found := placeholderRegex.FindAllString(message, -1)
+	sort.Strings(found)
+	return found
+}
+
+func TestCatalogComplete(t *testing.T) {
+	for _, key := range Keys() {
+		for _, language := range Supported() {
+			if !assert.True(t, Has(language, key), "%s has no %s translation", key, language) {
+				continue
+			}
+
+			// Translations must use the same placeholders as the English message
+			assert.Equal(t, placeholders(catalog[key][English]), placeholders(catalog[key][language]), "placeholders of %s in %s", key, language)
+		}
+		assert.Len(t, catalog[key], len(Supported()), "%s has translations in unsupported languages", key)
+	}
+}
+
+func TestTranslate(t *testing.T) {
+	params := map[string]interface{}{"min": 7, "max": 12, "calling_code": "+62"}
+	assert.Equal(t, "Phone number must have between 7 and 12 digits after the country code +62",
+		Translate(English, ValidationPhoneNumberLength, params))
+	assert.Equal(t, "Nomor telepon harus memiliki 7 sampai 12 digit setelah kode negara +62",
+		Translate(Indonesian, ValidationPhoneNumberLength, params))
+	assert.Equal(t, "Kolom ini harus salah satu dari en, id",
+		Translate(Indonesian, ValidationOneOf, map[string]interface{}{"values": []string{"en", "id"}}))
+
+	// unsupported languages fall back to English, unknown keys to the key
+	assert.Equal(t, "User not found.", Translate(Language("fr"), DetailUserNotFound, nil))
+	assert.Equal(t, "unknown.key", Translate(English, Key("unknown.key"), nil))
+	assert.Equal(t, "", Translate(English, "", nil))
+}
+
+func TestParseLanguage(t *testing.T) {
+	tests := []struct {
+		tag      string
+		expected Language
+		ok       bool
+	}{
+		{"en", English, true},
+		{"en-US", English, true},
+		{"id", Indonesian, true},
+		{"ID-id", Indonesian, true},
+		{"in", Indonesian, true},
+		{" id ", Indonesian, true},
+		{"fr", "", false},
+		{"", "", false},
+	}
+
+	for _, test := range tests {
+		language, ok := ParseLanguage(test.tag)
+		assert.Equal(t, test.expected, language, test.tag)
+		assert.Equal(t, test.ok, ok, test.tag)
+	}
+}
+
+func TestNegotiate(t *testing.T) {
+	tests := []struct {
+		name           string
+		acceptLanguage string
+		expected       Language
+		ok             bool
+	}{
+		{"empty", "", "", false},
+		{"single", "id-ID", Indonesian, true},
+		{"first of equal quality", "id, en", Indonesian, true},
+		{"highest quality", "en;q=0.5, id;q=0.9", Indonesian, true},
+		{"skips unsupported", "fr-FR, de;q=0.9, id;q=0.1", Indonesian, true},
+		{"zero quality is refused", "id;q=0, en;q=0.2", English, true},
+		{"wildcard is the default", "fr, *;q=0.5", DefaultLanguage, true},
+		{"nothing supported", "fr, de", "", false},
+		{"invalid quality is ignored", "id;q=abc, en;q=0.1", English, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			language, ok := Negotiate(test.acceptLanguage)
+			assert.Equal(t, test.expected, language)
+			assert.Equal(t, test.ok, ok)
+		})
+	}
+}
+
+func TestContext(t *testing.T) {
+	_, ok := FromContext(context.Background())
+	assert.False(t, ok)
+
+	language, ok := FromContext(WithLanguage(context.Background(), Indonesian))
+	assert.True(t, ok)
+	assert.Equal(t, Indonesian, language)
+}
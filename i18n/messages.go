@@ -0,0 +1,332 @@
+package i18n
+
+// Key identifies a message of the catalog.
+type Key string
+
+// Titles of the API error codes are keyed "error.<code>", see
+// apierror.Code.TitleKey.
+
+// Details of the API errors.
+const (
+	DetailBodyUnreadable              Key = "detail.body_unreadable"
+	DetailCriteriaNotMet              Key = "detail.criteria_not_met"
+	DetailForbidden                   Key = "detail.forbidden"
+	DetailIdempotencyKeyInUse         Key = "detail.idempotency_key_in_use"
+	DetailIdempotencyKeyReused        Key = "detail.idempotency_key_reused"
+	DetailInvalidCredentials          Key = "detail.invalid_credentials"
+	DetailInvalidCurrentPassword      Key = "detail.invalid_current_password"
+	DetailInvalidIdempotencyKey       Key = "detail.invalid_idempotency_key"
+	DetailInvalidIfMatch              Key = "detail.invalid_if_match"
+	DetailInvalidMergePatch           Key = "detail.invalid_merge_patch"
+	DetailInvalidToken                Key = "detail.invalid_token"
+	DetailInvalidWebhookURL           Key = "detail.invalid_webhook_url"
+	DetailLoginFieldsMissing          Key = "detail.login_fields_missing"
+	DetailMergePatchNotObject         Key = "detail.merge_patch_not_object"
+	DetailNothingToUpdate             Key = "detail.nothing_to_update"
+	DetailPasswordFieldsMissing       Key = "detail.password_fields_missing"
+	DetailRegisterFieldsMissing       Key = "detail.register_fields_missing"
+	DetailUnsupportedContentType      Key = "detail.unsupported_content_type"
+	DetailUserNotFound                Key = "detail.user_not_found"
+	DetailVersionConflict             Key = "detail.version_conflict"
+	DetailWebhookDeliveryNotFound     Key = "detail.webhook_delivery_not_found"
+	DetailWebhookSubscriptionNotFound Key = "detail.webhook_subscription_not_found"
+)
+
+// Messages of the validation rules, see model.FieldError.
+const (
+	ValidationCannotRemove        Key = "validation.cannot_remove"
+	ValidationFullNameLength      Key = "validation.full_name.length"
+	ValidationOneOf               Key = "validation.one_of"
+	ValidationPasswordBreached    Key = "validation.password.breached"
+	ValidationPasswordDigit       Key = "validation.password.digit"
+	ValidationPasswordFullName    Key = "validation.password.full_name"
+	ValidationPasswordLength      Key = "validation.password.length"
+	ValidationPasswordLowercase   Key = "validation.password.lowercase"
+	ValidationPasswordMaxRepeated Key = "validation.password.max_repeated"
+	ValidationPasswordPhoneNumber Key = "validation.password.phone_number"
+	ValidationPasswordReused      Key = "validation.password.reused"
+	ValidationPasswordSpecial     Key = "validation.password.special_char"
+	ValidationPasswordUppercase   Key = "validation.password.uppercase"
+	ValidationPhoneNumberFormat   Key = "validation.phone_number.format"
+	ValidationPhoneNumberLength   Key = "validation.phone_number.length"
+	ValidationPhoneNumberPrefix   Key = "validation.phone_number.prefix"
+	ValidationRequired            Key = "validation.required"
+	ValidationType                Key = "validation.type"
+	ValidationUnknownField        Key = "validation.unknown_field"
+	ValidationURL                 Key = "validation.url"
+	ValidationWebhookSecretLength Key = "validation.webhook_secret.length"
+)
+
+// Messages of the successful legacy responses.
+const (
+	MessageUserCreated  Key = "message.user_created"
+	MessageUserLoggedIn Key = "message.user_logged_in"
+	MessageUserUpdated  Key = "message.user_updated"
+)
+
+var catalog = map[Key]map[Language]string{
+	"error.conflict": {
+		English:    "Conflict",
+		Indonesian: "Konflik",
+	},
+	"error.forbidden": {
+		English:    "Forbidden",
+		Indonesian: "Akses ditolak",
+	},
+	"error.idempotency_key_in_use": {
+		English:    "Request already in progress",
+		Indonesian: "Permintaan sedang diproses",
+	},
+	"error.idempotency_key_reused": {
+		English:    "Idempotency key reused",
+		Indonesian: "Kunci idempotensi sudah digunakan",
+	},
+	"error.internal_error": {
+		English:    "Internal server error",
+		Indonesian: "Terjadi kesalahan pada server",
+	},
+	"error.invalid_credentials": {
+		English:    "Invalid credentials",
+		Indonesian: "Kredensial tidak valid",
+	},
+	"error.invalid_request": {
+		English:    "Invalid request",
+		Indonesian: "Permintaan tidak valid",
+	},
+	"error.invalid_token": {
+		English:    "Invalid access token",
+		Indonesian: "Token akses tidak valid",
+	},
+	"error.method_not_allowed": {
+		English:    "Method not allowed",
+		Indonesian: "Metode tidak diizinkan",
+	},
+	"error.missing_token": {
+		English:    "Missing access token",
+		Indonesian: "Token akses tidak ada",
+	},
+	"error.not_found": {
+		English:    "Resource not found",
+		Indonesian: "Data tidak ditemukan",
+	},
+	"error.phone_number_taken": {
+		English:    "Phone number already registered",
+		Indonesian: "Nomor telepon sudah terdaftar",
+	},
+	"error.route_not_found": {
+		English:    "Route not found",
+		Indonesian: "Rute tidak ditemukan",
+	},
+	"error.service_unavailable": {
+		English:    "Service unavailable",
+		Indonesian: "Layanan tidak tersedia",
+	},
+	"error.unsupported_media_type": {
+		English:    "Unsupported media type",
+		Indonesian: "Tipe media tidak didukung",
+	},
+	"error.validation_failed": {
+		English:    "Validation failed",
+		Indonesian: "Validasi gagal",
+	},
+	"error.version_conflict": {
+		English:    "Resource has been modified",
+		Indonesian: "Data telah diubah",
+	},
+
+	DetailBodyUnreadable: {
+		English:    "Request body cannot be read.",
+		Indonesian: "Isi permintaan tidak dapat dibaca.",
+	},
+	DetailCriteriaNotMet: {
+		English:    "Invalid Request. Please meet the criteria",
+		Indonesian: "Permintaan tidak valid. Harap penuhi kriteria",
+	},
+	DetailForbidden: {
+		English:    "Forbidden Code",
+		Indonesian: "Akses ditolak",
+	},
+	DetailIdempotencyKeyInUse: {
+		English:    "A request with this Idempotency-Key is still being processed. Please retry later.",
+		Indonesian: "Permintaan dengan Idempotency-Key ini masih diproses. Silakan coba lagi nanti.",
+	},
+	DetailIdempotencyKeyReused: {
+		English:    "This Idempotency-Key was already used for a different request.",
+		Indonesian: "Idempotency-Key ini sudah digunakan untuk permintaan yang berbeda.",
+	},
+	DetailInvalidCredentials: {
+		English:    "Invalid phone number or password.",
+		Indonesian: "Nomor telepon atau kata sandi salah.",
+	},
+	DetailInvalidCurrentPassword: {
+		English:    "Current password is incorrect.",
+		Indonesian: "Kata sandi saat ini salah.",
+	},
+	DetailInvalidIdempotencyKey: {
+		English:    "Idempotency-Key must be between 1 and 255 printable ASCII characters.",
+		Indonesian: "Idempotency-Key harus terdiri dari 1 sampai 255 karakter ASCII yang dapat dicetak.",
+	},
+	DetailInvalidIfMatch: {
+		English:    "If-Match must be a single entity tag returned by GET /users or *",
+		Indonesian: "If-Match harus berupa satu entity tag dari GET /users atau *",
+	},
+	DetailInvalidMergePatch: {
+		English:    "Invalid merge patch.",
+		Indonesian: "Merge patch tidak valid.",
+	},
+	DetailInvalidToken: {
+		English:    "Access token is invalid or expired.",
+		Indonesian: "Token akses tidak valid atau sudah kedaluwarsa.",
+	},
+	DetailInvalidWebhookURL: {
+		English:    "Invalid webhook url. Please use an absolute http or https url.",
+		Indonesian: "Url webhook tidak valid. Gunakan url http atau https yang lengkap.",
+	},
+	DetailLoginFieldsMissing: {
+		English:    "Phone Number or Password is missing.",
+		Indonesian: "Nomor Telepon atau Kata Sandi belum diisi.",
+	},
+	DetailMergePatchNotObject: {
+		English:    "Merge patch must be a JSON object.",
+		Indonesian: "Merge patch harus berupa objek JSON.",
+	},
+	DetailNothingToUpdate: {
+		English:    "Phone Number and Full Name is missing. Nothing to update",
+		Indonesian: "Nomor Telepon dan Nama Lengkap belum diisi. Tidak ada yang diperbarui",
+	},
+	DetailPasswordFieldsMissing: {
+		English:    "Current Password or Password is missing.",
+		Indonesian: "Kata Sandi Saat Ini atau Kata Sandi belum diisi.",
+	},
+	DetailRegisterFieldsMissing: {
+		English:    "Phone Number or Full Name or Password is missing.",
+		Indonesian: "Nomor Telepon, Nama Lengkap, atau Kata Sandi belum diisi.",
+	},
+	DetailUnsupportedContentType: {
+		English:    "Unsupported content type. Please use {content_type}.",
+		Indonesian: "Tipe konten tidak didukung. Gunakan {content_type}.",
+	},
+	DetailUserNotFound: {
+		English:    "User not found.",
+		Indonesian: "Pengguna tidak ditemukan.",
+	},
+	DetailVersionConflict: {
+		English:    "The resource has been modified by another request. Please fetch the latest data and retry.",
+		Indonesian: "Data telah diubah oleh permintaan lain. Silakan ambil data terbaru lalu coba lagi.",
+	},
+	DetailWebhookDeliveryNotFound: {
+		English:    "Webhook delivery not found.",
+		Indonesian: "Pengiriman webhook tidak ditemukan.",
+	},
+	DetailWebhookSubscriptionNotFound: {
+		English:    "Webhook subscription not found.",
+		Indonesian: "Langganan webhook tidak ditemukan.",
+	},
+
+	ValidationCannotRemove: {
+		English:    "This field is required and cannot be removed",
+		Indonesian: "Kolom ini wajib diisi dan tidak dapat dihapus",
+	},
+	ValidationFullNameLength: {
+		English:    "Full name must be between {min} and {max} characters",
+		Indonesian: "Nama lengkap harus terdiri dari {min} sampai {max} karakter",
+	},
+	ValidationOneOf: {
+		English:    "This field must be one of {values}",
+		Indonesian: "Kolom ini harus salah satu dari {values}",
+	},
+	ValidationPasswordBreached: {
+		English:    "Password is too common or has appeared in a data breach",
+		Indonesian: "Kata sandi terlalu umum atau pernah bocor dalam pelanggaran data",
+	},
+	ValidationPasswordDigit: {
+		English:    "Password must contain at least one digit",
+		Indonesian: "Kata sandi harus mengandung minimal satu angka",
+	},
+	ValidationPasswordFullName: {
+		English:    "Password must not contain your name",
+		Indonesian: "Kata sandi tidak boleh mengandung nama Anda",
+	},
+	ValidationPasswordLength: {
+		English:    "Password must be between {min} and {max} characters",
+		Indonesian: "Kata sandi harus terdiri dari {min} sampai {max} karakter",
+	},
+	ValidationPasswordLowercase: {
+		English:    "Password must contain at least one lowercase letter",
+		Indonesian: "Kata sandi harus mengandung minimal satu huruf kecil",
+	},
+	ValidationPasswordMaxRepeated: {
+		English:    "Password must not repeat a character more than {max} times in a row",
+		Indonesian: "Kata sandi tidak boleh mengulang karakter yang sama lebih dari {max} kali berturut-turut",
+	},
+	ValidationPasswordPhoneNumber: {
+		English:    "Password must not contain your phone number",
+		Indonesian: "Kata sandi tidak boleh mengandung nomor telepon Anda",
+	},
+	ValidationPasswordReused: {
+		English:    "Password must differ from your last {depth} passwords",
+		Indonesian: "Kata sandi harus berbeda dari {depth} kata sandi terakhir Anda",
+	},
+	ValidationPasswordSpecial: {
+		English:    "Password must contain at least one special character",
+		Indonesian: "Kata sandi harus mengandung minimal satu karakter khusus",
+	},
+	ValidationPasswordUppercase: {
+		English:    "Password must contain at least one uppercase letter",
+		Indonesian: "Kata sandi harus mengandung minimal satu huruf kapital",
+	},
+	ValidationPhoneNumberFormat: {
+		English:    "Phone number may only contain digits, spaces, dashes, dots, parentheses and a leading +",
+		Indonesian: "Nomor telepon hanya boleh berisi angka, spasi, tanda hubung, titik, tanda kurung dan + di awal",
+	},
+	ValidationPhoneNumberLength: {
+		English:    "Phone number must have between {min} and {max} digits after the country code {calling_code}",
+		Indonesian: "Nomor telepon harus memiliki {min} sampai {max} digit setelah kode negara {calling_code}",
+	},
+	ValidationPhoneNumberPrefix: {
+		English:    "Phone number must have one of the country codes {prefix}",
+		Indonesian: "Nomor telepon harus memiliki salah satu kode negara {prefix}",
+	},
+	ValidationRequired: {
+		English:    "This field is required",
+		Indonesian: "Kolom ini wajib diisi",
+	},
+	ValidationType: {
+		English:    "This field must be a {type}",
+		Indonesian: "Kolom ini harus berupa {type}",
+	},
+	ValidationUnknownField: {
+		English:    "Unknown field",
+		Indonesian: "Kolom tidak dikenal",
+	},
+	ValidationURL: {
+		English:    "Must be an absolute http or https url",
+		Indonesian: "Harus berupa url http atau https yang lengkap",
+	},
+	ValidationWebhookSecretLength: {
+		English:    "Secret must be at least {min} characters",
+		Indonesian: "Secret harus terdiri dari minimal {min} karakter",
+	},
+
+	MessageUserCreated: {
+		English:    "Successfuly create user with id : {id}",
+		Indonesian: "Berhasil membuat pengguna dengan id : {id}",
+	},
+	MessageUserLoggedIn: {
+		English:    "Successfuly login user with id : {id}",
+		Indonesian: "Berhasil masuk sebagai pengguna dengan id : {id}",
+	},
+	MessageUserUpdated: {
+		English:    "Successfuly update user data.",
+		Indonesian: "Berhasil memperbarui data pengguna.",
+	},
+}
+
+// Keys returns every key of the catalog.
+func Keys() []Key {
+	keys := make([]Key, 0, len(catalog))
+	for key := range catalog {
+		keys = append(keys, key)
+	}
+	return keys
+}
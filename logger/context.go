@@ -0,0 +1,23 @@
+package logger
+
+import (
+	"context"
+)
+
+// RequestIDKey is the attribute name used for the request ID in log records.
+const RequestIDKey = "request_id"
+
+type requestIDContextKey struct{}
+
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDContextKey{}, requestID)
+}
+
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	requestID, _ := ctx.Value(requestIDContextKey{}).(string)
+	return requestID
+}
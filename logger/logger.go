@@ -0,0 +1,61 @@
+// This file contains the structured logger used across the service.
+// Every record is emitted as JSON, carries the request ID stored in the
+// context (if any) and has sensitive attributes masked before being written.
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+)
+
+type NewLoggerOptions struct {
+	Output io.Writer
+	Level  string
+}
+
+func NewLogger(opts NewLoggerOptions) *slog.Logger {
+	handler := slog.NewJSONHandler(opts.Output, &slog.HandlerOptions{
+		Level:       ParseLevel(opts.Level),
+		ReplaceAttr: redactAttr,
+	})
+
+	return slog.New(&contextHandler{Handler: handler})
+}
+
+// ParseLevel converts a level name such as "debug" or "WARN" into a slog.Level.
+// Unknown or empty names fall back to info.
+func ParseLevel(level string) slog.Level {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return slog.LevelDebug
+	case "warn", "warning":
+		return slog.LevelWarn
+	case "error":
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// contextHandler adds request scoped attributes found in the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, record slog.Record) error {
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		record.AddAttrs(slog.String(RequestIDKey, requestID))
+	}
+
+	return h.Handler.Handle(ctx, record)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,84 @@
+package logger
+
+import (
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+var (
+	// Attribute keys whose values must never be written to the logs.
+	secretKeys = map[string]bool{
+		"password":      true,
+		"token":         true,
+		"jwt":           true,
+		"authorization": true,
+		"secret":        true,
+	}
+
+	// Attribute keys holding phone numbers, only the last digits are kept.
+	phoneKeys = map[string]bool{
+		"phone":        true,
+		"phone_number": true,
+	}
+
+	phoneRegex = regexp.MustCompile(`\+?\d[\d\- ]{7,}\d`)
+	tokenRegex = regexp.MustCompile(`(?i)(bearer\s+)?eyJ[\w-]*\.[\w-]*\.[\w-]*`)
+)
+
+func redactAttr(groups []string, attr slog.Attr) slog.Attr {
+	key := strings.ToLower(attr.Key)
+
+	if key == RequestIDKey {
+		return attr
+	}
+
+	if secretKeys[key] {
+		return slog.String(attr.Key, redacted)
+	}
+
+	if err, ok := attr.Value.Any().(error); ok {
+		return slog.String(attr.Key, Redact(err.Error()))
+	}
+
+	if attr.Value.Kind() != slog.KindString {
+		return attr
+	}
+
+	if phoneKeys[key] {
+		return slog.String(attr.Key, MaskPhoneNumber(attr.Value.String()))
+	}
+
+	return slog.String(attr.Key, Redact(attr.Value.String()))
+}
+
+// Redact masks tokens and phone numbers found anywhere inside a free text value.
+func Redact(value string) string {
+	value = tokenRegex.ReplaceAllString(value, redacted)
+	return phoneRegex.ReplaceAllStringFunc(value, MaskPhoneNumber)
+}
+
+// MaskPhoneNumber hides every digit of the phone number except the last three.
+func MaskPhoneNumber(phoneNumber string) string {
+	var (
+		masked = []byte(phoneNumber)
+		keep   = 3
+	)
+
+	for i := len(masked) - 1; i >= 0; i-- {
+		if masked[i] < '0' || masked[i] > '9' {
+			continue
+		}
+
+		if keep > 0 {
+			keep--
+			continue
+		}
+
+		masked[i] = '*'
+	}
+
+	return string(masked)
+}
@@ -0,0 +1,90 @@
+package model
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+)
+
+const (
+	AuditEventUserRegistered  = "user.registered"
+	AuditEventLoginSucceeded  = "user.login_succeeded"
+	AuditEventLoginFailed     = "user.login_failed"
+	AuditEventUserUpdated     = "user.updated"
+	AuditEventPasswordChanged = "user.password_changed"
+
+	AuditEventWebhookCreated     = "admin.webhook_created"
+	AuditEventWebhookDeactivated = "admin.webhook_deactivated"
+	AuditEventWebhookRedelivered = "admin.webhook_redelivered"
+)
+
+type AuditEvent struct {
+	ID        int64
+	EventType string
+	UserID    int32
+	ActorID   int32
+	Metadata  map[string]string
+	IPAddress string
+	RequestID string
+	CreatedAt time.Time
+	PrevHash  string
+	Hash      string
+}
+
+// ComputeHash returns the SHA-256 of the event content chained with the hash
+// of the previous event, so that changing or removing any row breaks the chain.
+func (e AuditEvent) ComputeHash() string {
+	metadata, _ := json.Marshal(e.Metadata) // map keys are marshalled in sorted order
+
+	h := sha256.New()
+	for _, field := range []string{
+		strconv.FormatInt(e.ID, 10),
+		e.EventType,
+		strconv.FormatInt(int64(e.UserID), 10),
+		strconv.FormatInt(int64(e.ActorID), 10),
+		string(metadata),
+		e.IPAddress,
+		e.RequestID,
+		e.CreatedAt.UTC().Format(time.RFC3339Nano),
+		e.PrevHash,
+	} {
+		h.Write([]byte(field))
+		h.Write([]byte{0})
+	}
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+type AuditChainError struct {
+	EventID int64
+	Reason  string
+}
+
+func (e *AuditChainError) Error() string {
+	return fmt.Sprintf("audit chain broken at event %d: %s", e.EventID, e.Reason)
+}
+
+// VerifyAuditChain checks that events, ordered by ID, directly follow the event
+// with ID prevID and hash prevHash. Use 0 and "" to verify from the first event.
+func VerifyAuditChain(events []AuditEvent, prevID int64, prevHash string) error {
+	for _, event := range events {
+		if event.ID != prevID+1 {
+			return &AuditChainError{EventID: event.ID, Reason: fmt.Sprintf("missing events after %d", prevID)}
+		}
+
+		if event.PrevHash != prevHash {
+			return &AuditChainError{EventID: event.ID, Reason: "previous hash mismatch"}
+		}
+
+		if event.Hash != event.ComputeHash() {
+			return &AuditChainError{EventID: event.ID, Reason: "content hash mismatch"}
+		}
+
+		prevID, prevHash = event.ID, event.Hash
+	}
+
+	return nil
+}
@@ -0,0 +1,66 @@
+package model
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func newAuditChain(size int) []AuditEvent {
+	var (
+		events   []AuditEvent
+		prevHash string
+	)
+
+	for i := 1; i <= size; i++ {
+		event := AuditEvent{
+			ID:        int64(i),
+			EventType: AuditEventLoginSucceeded,
+			UserID:    1,
+			ActorID:   1,
+			Metadata:  map[string]string{"attempt": "1"},
+			CreatedAt: time.Date(2023, 10, 1, 0, 0, i, 0, time.UTC),
+			PrevHash:  prevHash,
+		}
+		event.Hash = event.ComputeHash()
+		prevHash = event.Hash
+
+		events = append(events, event)
+	}
+
+	return events
+}
+
+func TestVerifyAuditChain(t *testing.T) {
+	tests := []struct {
+		name     string
+		tamper   func([]AuditEvent) []AuditEvent
+		brokenAt int64
+	}{
+		{"valid chain", func(e []AuditEvent) []AuditEvent { return e }, 0},
+		{"modified content", func(e []AuditEvent) []AuditEvent {
+			e[1].UserID = 2
+			return e
+		}, 2},
+		{"modified and re-hashed", func(e []AuditEvent) []AuditEvent {
+			e[1].Metadata = map[string]string{"attempt": "2"}
+			e[1].Hash = e[1].ComputeHash()
+			return e
+		}, 3},
+		{"removed event", func(e []AuditEvent) []AuditEvent {
+			return append(e[:1], e[2:]...)
+		}, 3},
+	}
+
+	for _, test := range tests {
+		err := VerifyAuditChain(test.tamper(newAuditChain(4)), 0, "")
+
+		var chainErr *AuditChainError
+		if test.brokenAt == 0 && err != nil {
+			t.Errorf("%s: expected valid chain, but got %v", test.name, err)
+		}
+		if test.brokenAt != 0 && (!errors.As(err, &chainErr) || chainErr.EventID != test.brokenAt) {
+			t.Errorf("%s: expected chain broken at %d, but got %v", test.name, test.brokenAt, err)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Domain events published to other services through the outbox.
+const (
+	EventUserRegistered     = "UserRegistered"
+	EventPhoneNumberChanged = "PhoneNumberChanged"
+	EventFullNameChanged    = "FullNameChanged"
+)
+
+// EventTypes returns the types of the domain events.
+func EventTypes() []string {
+	return []string{EventUserRegistered, EventPhoneNumberChanged, EventFullNameChanged}
+}
+
+type OutboxEvent struct {
+	ID          int64
+	EventType   string
+	AggregateID int32
+	Payload     json.RawMessage
+	CreatedAt   time.Time
+	Attempts    int32
+}
+
+// UserEventPayload is the payload of the events of a user. It only carries
+// the id of the user, so the personal data is not copied in plaintext to the
+// outbox and the webhook deliveries; consumers read the user from the API.
+type UserEventPayload struct {
+	UserID int32 `json:"user_id"`
+}
@@ -0,0 +1,24 @@
+package model
+
+import "time"
+
+// IdempotencyRecord is the response to the first request a caller sent with an
+// Idempotency-Key, replayed to the retries of that request until it expires.
+type IdempotencyRecord struct {
+	Caller string
+	Key    string
+	// Fingerprint identifies the request, the key must not be reused for a
+	// different one.
+	Fingerprint string
+	// StatusCode is zero while the first request is being handled.
+	StatusCode int32
+	Header     map[string]string
+	Body       []byte
+	CreatedAt  time.Time
+	ExpiresAt  time.Time
+}
+
+// Completed reports whether the response to the first request is stored.
+func (r IdempotencyRecord) Completed() bool {
+	return r.StatusCode != 0
+}
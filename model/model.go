@@ -1,8 +1,7 @@
 package model
 
 import (
-	"regexp"
-	"strings"
+	"github.com/SawitProRecruitment/UserService/i18n"
 )
 
 type User struct {
@@ -11,74 +10,64 @@ type User struct {
 	Password       string
 	PhoneNumber    string
 	SuccesfulLogin int32
+	Version        int32
+	// PreferredLanguage is the language messages are served in, overriding
+	// the Accept-Language header. Empty when the user did not choose one.
+	PreferredLanguage string
+	// TokenVersion is incremented when the password changes, the access
+	// tokens issued for another version are rejected.
+	TokenVersion int32
 }
 
-func (u *User) ValidateRegisterUser() (isValid bool, errorMessages []string) {
-
-	isValidPhoneNumber, errorPhoneNumber := u.ValidatePhoneNumber()
-	if !isValidPhoneNumber {
-		errorMessages = append(errorMessages, errorPhoneNumber...)
-	}
-
-	isValidFullName, errorFullname := u.ValidateFullName()
-	if !isValidFullName {
-		errorMessages = append(errorMessages, errorFullname...)
-	}
-
-	isValidPassword, errorPassword := u.ValidatePassword()
-	if !isValidPassword {
-		errorMessages = append(errorMessages, errorPassword...)
-	}
-
-	return len(errorMessages) == 0, errorMessages
+// ValidateRegisterUser validates every field of a new user, the phone number
+// and password against their policies. The phone number is normalised first,
+// see ValidatePhoneNumber.
+func (u *User) ValidateRegisterUser(phoneNumbers PhoneNumberPolicy, passwords PasswordPolicy) (errs ValidationErrors) {
+	errs = append(errs, u.ValidatePhoneNumber(phoneNumbers)...)
+	errs = append(errs, u.ValidateFullName()...)
+	errs = append(errs, u.ValidatePassword(passwords)...)
+	errs = append(errs, u.ValidatePreferredLanguage()...)
+	return errs
 }
 
-func (u *User) ValidatePhoneNumber() (isValid bool, errorMessages []string) {
-	// Check phone number length
-	if len(u.PhoneNumber) < 10 || len(u.PhoneNumber) > 13 {
-		errorMessages = append(errorMessages, "Phone number must be between 10 and 13 characters")
+// ValidatePhoneNumber validates the phone number against the policy and, when
+// valid, replaces it by its canonical E.164 form.
+func (u *User) ValidatePhoneNumber(policy PhoneNumberPolicy) (errs ValidationErrors) {
+	normalized, errs := policy.Normalize(u.PhoneNumber)
+	if len(errs) == 0 {
+		u.PhoneNumber = normalized
 	}
-
-	// Check phone number start with +62
-	phoneRegex := `^\+62`
-	if !regexp.MustCompile(phoneRegex).MatchString(u.PhoneNumber) {
-		errorMessages = append(errorMessages, "Invalid phone number")
-	}
-
-	return len(errorMessages) == 0, errorMessages
+	return errs
 }
 
-func (u *User) ValidateFullName() (isValid bool, errorMessages []string) {
+func (u *User) ValidateFullName() (errs ValidationErrors) {
 	// Check full name length
 	if len(u.FullName) < 3 || len(u.FullName) > 60 {
-		errorMessages = append(errorMessages, "Full name must be between 3 and 60 characters")
+		errs = append(errs, NewFieldError("full_name", RuleLength, i18n.ValidationFullNameLength, map[string]interface{}{"min": 3, "max": 60}))
 	}
 
-	return len(errorMessages) == 0, errorMessages
+	return errs
 }
 
-func (u *User) ValidatePassword() (isValid bool, errorMessages []string) {
-
-	// Check the length constraint
-	if len(u.Password) < 6 || len(u.Password) > 64 {
-		errorMessages = append(errorMessages, "Password must be between 6 and 64 characters")
-	}
-
-	// Check for at least one uppercase letter
-	if !strings.ContainsAny(u.Password, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") {
-		errorMessages = append(errorMessages, "Password must contain at least one uppercase letter")
-	}
+// ValidatePassword validates the password against the policy.
+func (u *User) ValidatePassword(policy PasswordPolicy) (errs ValidationErrors) {
+	return policy.Check(u)
+}
 
-	// Check for at least one digit
-	if !strings.ContainsAny(u.Password, "0123456789") {
-		errorMessages = append(errorMessages, "Password must contain at least one digit")
+// ValidatePreferredLanguage accepts the supported languages, or empty to
+// clear the preference.
+func (u *User) ValidatePreferredLanguage() (errs ValidationErrors) {
+	if u.PreferredLanguage == "" {
+		return nil
 	}
 
-	// Check for at least one special (non-alphanumeric) character
-	specialChars := "~!@#$%^&*()-_+=<>?/[]{}|"
-	if !strings.ContainsAny(u.Password, specialChars) {
-		errorMessages = append(errorMessages, "Password must contain at least one special character")
+	var values []string
+	for _, language := range i18n.Supported() {
+		if u.PreferredLanguage == string(language) {
+			return nil
+		}
+		values = append(values, string(language))
 	}
 
-	return len(errorMessages) == 0, errorMessages
+	return append(errs, NewFieldError("preferred_language", RuleOneOf, i18n.ValidationOneOf, map[string]interface{}{"values": values}))
 }
@@ -1,7 +1,10 @@
 package model
 
 import (
+	"strings"
 	"testing"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
 )
 
 func TestValidateRegisterUser(t *testing.T) {
@@ -33,7 +36,7 @@ func TestValidateRegisterUser(t *testing.T) {
 
 	for _, test := range tests {
 		user := test.input
-		isValid, _ := user.ValidateRegisterUser()
+		isValid := len(user.ValidateRegisterUser(DefaultPhoneNumberPolicy(), DefaultPasswordPolicy())) == 0
 		if isValid != test.expected {
 			t.Errorf("For input '%+v', expected validation result %v, but got %v", test.input, test.expected, isValid)
 		}
@@ -41,23 +44,48 @@ func TestValidateRegisterUser(t *testing.T) {
 }
 
 func TestValidatePhoneNumber(t *testing.T) {
+	malaysia, _ := LookupPhoneCountry("MY")
+	indonesia, _ := LookupPhoneCountry("ID")
+	both := PhoneNumberPolicy{Countries: []PhoneCountry{indonesia, malaysia}}
+
 	tests := []struct {
+		policy   PhoneNumberPolicy
 		input    string
-		expected bool
+		expected string
+		rule     string
 	}{
-		{"+628123456789", true},
-		{"+62123", false},         // Missing digit
-		{"+6281234567890", false}, // Too long
-		{"628123456789", false},   // Missing '+'
-		{"08123456789", false},    // Missing '+62'
-		{"+62abc", false},         // Invalid characters
+		{DefaultPhoneNumberPolicy(), "+628123456789", "+628123456789", ""},
+		{DefaultPhoneNumberPolicy(), "628123456789", "+628123456789", ""},     // Missing '+'
+		{DefaultPhoneNumberPolicy(), "08123456789", "+628123456789", ""},      // National form
+		{DefaultPhoneNumberPolicy(), "0812-3456-789", "+628123456789", ""},    // Dashes
+		{DefaultPhoneNumberPolicy(), "+62 812 3456 789", "+628123456789", ""}, // Spaces
+		{DefaultPhoneNumberPolicy(), "+62 (0)812.3456.789", "+628123456789", ""},
+		{DefaultPhoneNumberPolicy(), "00628123456789", "+628123456789", ""},
+		{DefaultPhoneNumberPolicy(), "+628123456789012", "", RuleLength}, // Too long
+		{DefaultPhoneNumberPolicy(), "+62123", "", RuleLength},           // Missing digit
+		{DefaultPhoneNumberPolicy(), "+60123456789", "", RulePrefix},     // Country not allowed
+		{DefaultPhoneNumberPolicy(), "+8123456789", "", RulePrefix},      // Unknown country
+		{DefaultPhoneNumberPolicy(), "+62abc", "", RuleFormat},           // Invalid characters
+		{DefaultPhoneNumberPolicy(), "+", "", RuleFormat},                // No digits
+		{both, "+60 12-345 6789", "+60123456789", ""},
+		{both, "0312345678", "+62312345678", ""}, // National numbers are Indonesian
+		{both, "60 3-1234 5678", "+60312345678", ""},
+		{both, "+60 12-345 678901", "", RuleLength},
 	}
 
 	for _, test := range tests {
 		user := User{PhoneNumber: test.input}
-		isValid, _ := user.ValidatePhoneNumber()
-		if isValid != test.expected {
-			t.Errorf("For input '%s', expected validation result %v, but got %v", test.input, test.expected, isValid)
+		errs := user.ValidatePhoneNumber(test.policy)
+
+		switch {
+		case test.rule == "" && len(errs) > 0:
+			t.Errorf("For input '%s', expected no error, but got %v", test.input, errs)
+		case test.rule == "" && user.PhoneNumber != test.expected:
+			t.Errorf("For input '%s', expected '%s', but got '%s'", test.input, test.expected, user.PhoneNumber)
+		case test.rule != "" && (len(errs) != 1 || errs[0].Rule != test.rule):
+			t.Errorf("For input '%s', expected rule %s, but got %v", test.input, test.rule, errs)
+		case test.rule != "" && user.PhoneNumber != test.input:
+			t.Errorf("For input '%s', expected the number to be kept, but got '%s'", test.input, user.PhoneNumber)
 		}
 	}
 }
@@ -74,7 +102,7 @@ func TestValidateFullName(t *testing.T) {
 
 	for _, test := range tests {
 		user := User{FullName: test.input}
-		isValid, _ := user.ValidateFullName()
+		isValid := len(user.ValidateFullName()) == 0
 		if isValid != test.expected {
 			t.Errorf("For input '%s', expected validation result %v, but got %v", test.input, test.expected, isValid)
 		}
@@ -95,9 +123,69 @@ func TestValidatePassword(t *testing.T) {
 
 	for _, test := range tests {
 		user := User{Password: test.input}
-		isValid, _ := user.ValidatePassword()
+		isValid := len(user.ValidatePassword(DefaultPasswordPolicy())) == 0
+		if isValid != test.expected {
+			t.Errorf("For input '%s', expected validation result %v, but got %v", test.input, test.expected, isValid)
+		}
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	user := User{FullName: "Leonardo", Password: "password", PhoneNumber: "+628123456789"}
+
+	errs := user.ValidateRegisterUser(DefaultPhoneNumberPolicy(), DefaultPasswordPolicy())
+	rules := []string{}
+	for _, fieldError := range errs {
+		if fieldError.Field != "password" {
+			t.Errorf("Unexpected error for field '%s'", fieldError.Field)
+		}
+		rules = append(rules, fieldError.Rule)
+	}
+
+	expected := []string{RuleUppercase, RuleDigit, RuleSpecialChar}
+	if strings.Join(rules, ",") != strings.Join(expected, ",") {
+		t.Errorf("Expected rules %v, but got %v", expected, rules)
+	}
+	if len(errs.Messages()) != 3 {
+		t.Errorf("Expected 3 messages, but got %v", errs.Messages())
+	}
+	if errs.Error() != "password: Password must contain at least one uppercase letter; password: Password must contain at least one digit; password: Password must contain at least one special character" {
+		t.Errorf("Unexpected error string '%s'", errs.Error())
+	}
+}
+
+func TestValidatePreferredLanguage(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected bool
+	}{
+		{"", true}, // No preference
+		{"en", true},
+		{"id", true},
+		{"fr", false},    // Unsupported
+		{"id-ID", false}, // Not a language code
+	}
+
+	for _, test := range tests {
+		user := User{PreferredLanguage: test.input}
+		isValid := len(user.ValidatePreferredLanguage()) == 0
 		if isValid != test.expected {
 			t.Errorf("For input '%s', expected validation result %v, but got %v", test.input, test.expected, isValid)
 		}
 	}
 }
+
+func TestLocalizedMessage(t *testing.T) {
+	user := User{FullName: "Le"}
+
+	errs := user.ValidateFullName()
+	if len(errs) != 1 {
+		t.Fatalf("Expected 1 error, but got %v", errs)
+	}
+	if message := errs[0].LocalizedMessage(i18n.Indonesian); message != "Nama lengkap harus terdiri dari 3 sampai 60 karakter" {
+		t.Errorf("Unexpected Indonesian message '%s'", message)
+	}
+	if message := errs[0].LocalizedMessage(i18n.English); message != errs[0].Message {
+		t.Errorf("Expected English message '%s', but got '%s'", errs[0].Message, message)
+	}
+}
@@ -0,0 +1,222 @@
+package model
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+)
+
+// DefaultSpecialCharacters are the characters counted as special when the
+// policy does not configure its own set.
+const DefaultSpecialCharacters = "~!@#$%^&*()-_+=<>?/[]{}|"
+
+// PasswordPolicy are the rules a new password must satisfy. Zero values of
+// the optional rules disable them.
+type PasswordPolicy struct {
+	MinLength int
+	MaxLength int
+
+	RequireUppercase  bool
+	RequireLowercase  bool
+	RequireDigit      bool
+	RequireSpecial    bool
+	SpecialCharacters string
+
+	// MaxRepeatedCharacters limits how many times in a row the same
+	// character may be used.
+	MaxRepeatedCharacters int
+	// DisallowPersonalInfo rejects passwords containing the phone number or
+	// a part of the full name of the user.
+	DisallowPersonalInfo bool
+	// HistoryDepth is how many of the latest passwords of the user, the
+	// current one included, cannot be reused.
+	HistoryDepth int
+	// Blocklist rejects common and breached passwords when set.
+	Blocklist PasswordBlocklist
+}
+
+// PasswordBlocklist is a corpus of passwords that must not be used, e.g.
+// the most common ones or the ones exposed by data breaches.
+type PasswordBlocklist interface {
+	Contains(password string) bool
+}
+
+// DefaultPasswordPolicy returns the policy used when none is configured.
+func DefaultPasswordPolicy() PasswordPolicy {
+	return PasswordPolicy{
+		MinLength:         6,
+		MaxLength:         64,
+		RequireUppercase:  true,
+		RequireDigit:      true,
+		RequireSpecial:    true,
+		SpecialCharacters: DefaultSpecialCharacters,
+	}
+}
+
+// maxPasswordLength is the limit of bcrypt, which ignores the bytes past it.
+const maxPasswordLength = 72
+
+// Validate reports a policy that no password can satisfy or that the service
+// cannot enforce.
+func (p PasswordPolicy) Validate() error {
+	switch {
+	case p.MinLength < 1:
+		return errors.New("password policy: min length must be at least 1")
+	case p.MaxLength < p.MinLength:
+		return errors.New("password policy: max length must not be less than min length")
+	case p.MaxLength > maxPasswordLength:
+		return fmt.Errorf("password policy: max length must not exceed %d", maxPasswordLength)
+	case p.RequireSpecial && p.SpecialCharacters == "":
+		return errors.New("password policy: special characters are required but none are configured")
+	case p.MaxRepeatedCharacters < 0:
+		return errors.New("password policy: max repeated characters must not be negative")
+	case p.HistoryDepth < 0:
+		return errors.New("password policy: history depth must not be negative")
+	}
+	return nil
+}
+
+// Check validates the password of the user against the policy. The phone
+// number and full name of the user are used by the personal info rule.
+func (p PasswordPolicy) Check(u *User) (errs ValidationErrors) {
+	password := u.Password
+
+	// Check the length constraint
+	if len(password) < p.MinLength || len(password) > p.MaxLength {
+		errs = append(errs, NewFieldError("password", RuleLength, i18n.ValidationPasswordLength, map[string]interface{}{"min": p.MinLength, "max": p.MaxLength}))
+	}
+
+	// Check for at least one uppercase letter
+	if p.RequireUppercase && !strings.ContainsFunc(password, unicode.IsUpper) {
+		errs = append(errs, NewFieldError("password", RuleUppercase, i18n.ValidationPasswordUppercase, map[string]interface{}{"min": 1}))
+	}
+
+	// Check for at least one lowercase letter
+	if p.RequireLowercase && !strings.ContainsFunc(password, unicode.IsLower) {
+		errs = append(errs, NewFieldError("password", RuleLowercase, i18n.ValidationPasswordLowercase, map[string]interface{}{"min": 1}))
+	}
+
+	// Check for at least one digit
+	if p.RequireDigit && !strings.ContainsAny(password, "0123456789") {
+		errs = append(errs, NewFieldError("password", RuleDigit, i18n.ValidationPasswordDigit, map[string]interface{}{"min": 1}))
+	}
+
+	// Check for at least one special (non-alphanumeric) character
+	if p.RequireSpecial && !strings.ContainsAny(password, p.SpecialCharacters) {
+		errs = append(errs, NewFieldError("password", RuleSpecialChar, i18n.ValidationPasswordSpecial, map[string]interface{}{"min": 1, "characters": p.SpecialCharacters}))
+	}
+
+	// Check for runs of the same character
+	if p.MaxRepeatedCharacters > 0 && longestRun(password) > p.MaxRepeatedCharacters {
+		errs = append(errs, NewFieldError("password", RuleMaxRepeated, i18n.ValidationPasswordMaxRepeated, map[string]interface{}{"max": p.MaxRepeatedCharacters}))
+	}
+
+	if p.DisallowPersonalInfo {
+		if containsPhoneNumber(password, u.PhoneNumber) {
+			errs = append(errs, NewFieldError("password", RulePersonalInfo, i18n.ValidationPasswordPhoneNumber, map[string]interface{}{"field": "phone_number"}))
+		}
+		if containsName(password, u.FullName) {
+			errs = append(errs, NewFieldError("password", RulePersonalInfo, i18n.ValidationPasswordFullName, map[string]interface{}{"field": "full_name"}))
+		}
+	}
+
+	// Check the password is not a common or breached one
+	if p.Blocklist != nil && p.Blocklist.Contains(password) {
+		errs = append(errs, NewFieldError("password", RuleBreached, i18n.ValidationPasswordBreached, nil))
+	}
+
+	return errs
+}
+
+// CheckHistory rejects a password that matches one of the previous password
+// hashes of the user, newest first. Only the latest HistoryDepth hashes are
+// considered; matches reports whether a hash is the hash of the password.
+func (p PasswordPolicy) CheckHistory(password string, previous []string, matches func(hash, password string) bool) (errs ValidationErrors) {
+	if len(previous) > p.HistoryDepth {
+		previous = previous[:p.HistoryDepth]
+	}
+
+	for _, hash := range previous {
+		if matches(hash, password) {
+			return append(errs, NewFieldError("password", RuleReused, i18n.ValidationPasswordReused, map[string]interface{}{"depth": p.HistoryDepth}))
+		}
+	}
+	return nil
+}
+
+func longestRun(s string) (longest int) {
+	var previous rune
+	run := 0
+	for i, r := range []rune(s) {
+		if i > 0 && r == previous {
+			run++
+		} else {
+			run = 1
+		}
+		previous = r
+		if run > longest {
+			longest = run
+		}
+	}
+	return longest
+}
+
+// Personal info shorter than these is ignored, e.g. initials, as it would
+// reject too many passwords.
+const (
+	minPhoneDigits    = 6
+	minNamePartLength = 3
+)
+
+// containsPhoneNumber reports whether the password contains the digits of
+// the phone number, with its country code or in its national form.
+func containsPhoneNumber(password, phoneNumber string) bool {
+	digits := strings.Map(func(r rune) rune {
+		if r < '0' || r > '9' {
+			return -1
+		}
+		return r
+	}, phoneNumber)
+
+	candidates := []string{digits}
+	for _, country := range phoneCountries {
+		if national, ok := strings.CutPrefix(digits, country.CallingCode); ok {
+			candidates = append(candidates, national)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if len(candidate) >= minPhoneDigits && strings.Contains(password, candidate) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsName reports whether the password contains a part of the full
+// name, ignoring case.
+func containsName(password, fullName string) bool {
+	password = strings.ToLower(password)
+	for _, part := range strings.Fields(strings.ToLower(fullName)) {
+		if len(part) >= minNamePartLength && strings.Contains(password, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// PasswordHasher hashes passwords and verifies them against the stored
+// hashes, whatever algorithm created them.
+type PasswordHasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether the hash is the hash of the password. An error
+	// is returned when the hash cannot be read.
+	Verify(hash, password string) (bool, error)
+	// NeedsRehash reports whether the hash was created with another
+	// algorithm or weaker parameters than the hasher uses, and should be
+	// replaced by a new hash of the password once verified.
+	NeedsRehash(hash string) bool
+}
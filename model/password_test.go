@@ -0,0 +1,117 @@
+package model
+
+import (
+	"testing"
+)
+
+func rulesOf(errs ValidationErrors) (rules []string) {
+	for _, fieldError := range errs {
+		rules = append(rules, fieldError.Rule)
+	}
+	return rules
+}
+
+type passwordSet map[string]bool
+
+func (s passwordSet) Contains(password string) bool {
+	return s[password]
+}
+
+func TestPasswordPolicyCheck(t *testing.T) {
+	screened := DefaultPasswordPolicy()
+	screened.Blocklist = passwordSet{"P@ssw0rd1": true}
+
+	strict := PasswordPolicy{
+		MinLength:             8,
+		MaxLength:             20,
+		RequireUppercase:      true,
+		RequireLowercase:      true,
+		RequireDigit:          true,
+		RequireSpecial:        true,
+		SpecialCharacters:     "!#",
+		MaxRepeatedCharacters: 2,
+		DisallowPersonalInfo:  true,
+	}
+
+	tests := []struct {
+		name     string
+		policy   PasswordPolicy
+		password string
+		expected []string
+	}{
+		{"default policy accepts", DefaultPasswordPolicy(), "P@ssw0rd", nil},
+		{"default policy needs no lowercase", DefaultPasswordPolicy(), "P@SSW0RD", nil},
+		{"strict policy accepts", strict, "Kebun#Sawit9", nil},
+		{"too short", strict, "Ke#9a", []string{RuleLength}},
+		{"missing lowercase", strict, "KEBUN#SAWIT9", []string{RuleLowercase}},
+		{"configured special characters", strict, "Kebun@Sawit9", []string{RuleSpecialChar}},
+		{"repeated characters", strict, "Kebuuun#Sawit9", []string{RuleMaxRepeated}},
+		{"contains phone number", strict, "Kb#81234567890", []string{RulePersonalInfo}},
+		{"contains local phone number", strict, "Kb#812345678", []string{RulePersonalInfo}},
+		{"contains name in any case", strict, "LEONARDO#Kb9", []string{RulePersonalInfo}},
+		{"short name parts are ignored", strict, "Kebun#Da9vin", nil},
+		{"personal info allowed by default", DefaultPasswordPolicy(), "Leonardo#1", nil},
+		{"breached password", screened, "P@ssw0rd1", []string{RuleBreached}},
+		{"unlisted password", screened, "P@ssw0rd2", nil},
+		{"breached password not screened by default", DefaultPasswordPolicy(), "P@ssw0rd1", nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			user := User{FullName: "Leonardo da Vinci", PhoneNumber: "+62812345678", Password: test.password}
+
+			rules := rulesOf(test.policy.Check(&user))
+			if len(rules) != len(test.expected) {
+				t.Fatalf("Expected rules %v, but got %v", test.expected, rules)
+			}
+			for i := range rules {
+				if rules[i] != test.expected[i] {
+					t.Errorf("Expected rules %v, but got %v", test.expected, rules)
+				}
+			}
+		})
+	}
+}
+
+func TestPasswordPolicyCheckHistory(t *testing.T) {
+	policy := PasswordPolicy{HistoryDepth: 2}
+	matches := func(hash, password string) bool { return hash == "hash:"+password }
+	history := []string{"hash:current", "hash:previous", "hash:oldest"}
+
+	if errs := policy.CheckHistory("current", history, matches); len(errs) != 1 || errs[0].Rule != RuleReused {
+		t.Errorf("Expected the current password to be rejected, but got %v", errs)
+	}
+	if errs := policy.CheckHistory("previous", history, matches); len(errs) != 1 {
+		t.Errorf("Expected the previous password to be rejected, but got %v", errs)
+	}
+	if errs := policy.CheckHistory("oldest", history, matches); len(errs) != 0 {
+		t.Errorf("Expected passwords past the depth to be accepted, but got %v", errs)
+	}
+
+	policy.HistoryDepth = 0
+	if errs := policy.CheckHistory("current", history, matches); len(errs) != 0 {
+		t.Errorf("Expected reuse to be allowed without history, but got %v", errs)
+	}
+}
+
+func TestPasswordPolicyValidate(t *testing.T) {
+	if err := DefaultPasswordPolicy().Validate(); err != nil {
+		t.Errorf("Expected the default policy to be valid, but got %v", err)
+	}
+
+	invalid := []func(*PasswordPolicy){
+		func(p *PasswordPolicy) { p.MinLength = 0 },
+		func(p *PasswordPolicy) { p.MaxLength = p.MinLength - 1 },
+		func(p *PasswordPolicy) { p.MaxLength = 100 },
+		func(p *PasswordPolicy) { p.SpecialCharacters = "" },
+		func(p *PasswordPolicy) { p.MaxRepeatedCharacters = -1 },
+		func(p *PasswordPolicy) { p.HistoryDepth = -1 },
+	}
+	for i, modify := range invalid {
+		policy := DefaultPasswordPolicy()
+		modify(&policy)
+		if err := policy.Validate(); err == nil {
+			t.Errorf("Expected policy %d to be invalid", i)
+		}
+	}
+}
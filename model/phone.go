@@ -0,0 +1,136 @@
+package model
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+)
+
+// PhoneCountry are the numbering rules of a country.
+type PhoneCountry struct {
+	// Code is the ISO 3166-1 alpha-2 code of the country, e.g. ID.
+	Code string
+	// CallingCode is the country calling code without the +, e.g. 62.
+	CallingCode string
+	// MinDigits and MaxDigits bound the length of the national significant
+	// number, the digits after the calling code.
+	MinDigits int
+	MaxDigits int
+}
+
+// phoneCountries are the countries numbers can be accepted from. Numbers are
+// written with a 0 trunk prefix instead of the calling code within all of
+// them.
+var phoneCountries = []PhoneCountry{
+	{Code: "ID", CallingCode: "62", MinDigits: 7, MaxDigits: 12},
+	{Code: "MY", CallingCode: "60", MinDigits: 8, MaxDigits: 10},
+}
+
+// LookupPhoneCountry returns the rules of the country, by ISO code.
+func LookupPhoneCountry(code string) (PhoneCountry, bool) {
+	for _, country := range phoneCountries {
+		if strings.EqualFold(country.Code, code) {
+			return country, true
+		}
+	}
+	return PhoneCountry{}, false
+}
+
+// PhoneNumberPolicy are the countries phone numbers are accepted from.
+type PhoneNumberPolicy struct {
+	// Countries are the allowed countries. National numbers, e.g.
+	// 0812..., are read as numbers of the first one.
+	Countries []PhoneCountry
+}
+
+// DefaultPhoneNumberPolicy returns the policy used when none is configured,
+// which only accepts Indonesian numbers.
+func DefaultPhoneNumberPolicy() PhoneNumberPolicy {
+	country, _ := LookupPhoneCountry("ID")
+	return PhoneNumberPolicy{Countries: []PhoneCountry{country}}
+}
+
+// Validate reports a policy that accepts no number.
+func (p PhoneNumberPolicy) Validate() error {
+	if len(p.Countries) == 0 {
+		return errors.New("phone number policy: at least one country must be allowed")
+	}
+	return nil
+}
+
+// Normalize returns the canonical E.164 form of the number, e.g.
+// +628123456789. Numbers may be written with or without the + or 00 before the
+// calling code, with the 0 trunk prefix instead of it and with spaces, dashes,
+// dots or parentheses between the digits.
+func (p PhoneNumberPolicy) Normalize(number string) (string, ValidationErrors) {
+	digits, international, ok := phoneDigits(number)
+	if !ok || len(p.Countries) == 0 {
+		return "", ValidationErrors{NewFieldError("phone_number", RuleFormat, i18n.ValidationPhoneNumberFormat, nil)}
+	}
+
+	var (
+		country  PhoneCountry
+		national string
+		found    bool
+	)
+	if !international && strings.HasPrefix(digits, "0") {
+		country, national, found = p.Countries[0], digits[1:], true
+	} else {
+		for _, allowed := range p.Countries {
+			if strings.HasPrefix(digits, allowed.CallingCode) {
+				country, national, found = allowed, digits[len(allowed.CallingCode):], true
+				break
+			}
+		}
+	}
+	if !found {
+		return "", ValidationErrors{NewFieldError("phone_number", RulePrefix, i18n.ValidationPhoneNumberPrefix, map[string]interface{}{"prefix": p.callingCodes()})}
+	}
+
+	// The trunk prefix is often kept after the calling code, e.g. +62 0812
+	national = strings.TrimPrefix(national, "0")
+
+	if len(national) < country.MinDigits || len(national) > country.MaxDigits {
+		return "", ValidationErrors{NewFieldError("phone_number", RuleLength, i18n.ValidationPhoneNumberLength, map[string]interface{}{
+			"min":          country.MinDigits,
+			"max":          country.MaxDigits,
+			"calling_code": "+" + country.CallingCode,
+		})}
+	}
+
+	return "+" + country.CallingCode + national, nil
+}
+
+func (p PhoneNumberPolicy) callingCodes() (codes []string) {
+	for _, country := range p.Countries {
+		codes = append(codes, "+"+country.CallingCode)
+	}
+	return codes
+}
+
+// phoneDigits strips the separators of the number. International reports
+// whether it starts with + or 00, which are stripped too.
+func phoneDigits(number string) (digits string, international bool, ok bool) {
+	number = strings.TrimSpace(number)
+	if rest, found := strings.CutPrefix(number, "+"); found {
+		number, international = rest, true
+	}
+
+	var b strings.Builder
+	for _, r := range number {
+		switch {
+		case r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ' || r == '-' || r == '.' || r == '(' || r == ')':
+		default:
+			return "", false, false
+		}
+	}
+
+	digits = b.String()
+	if !international {
+		digits, international = strings.CutPrefix(digits, "00")
+	}
+	return digits, international, digits != ""
+}
@@ -0,0 +1,86 @@
+package model
+
+import (
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+)
+
+// Rule codes identify the validation rule a field does not satisfy. They are
+// part of the API contract: clients match on them, together with the params,
+// to highlight the offending field and word their own message.
+const (
+	RuleRequired     = "required"
+	RuleUnknownField = "unknown_field"
+	RuleType         = "type"
+	RuleLength       = "length"
+	RulePrefix       = "prefix"
+	RuleUppercase    = "uppercase"
+	RuleDigit        = "digit"
+	RuleSpecialChar  = "special_char"
+	RuleLowercase    = "lowercase"
+	RuleMaxRepeated  = "max_repeated"
+	RulePersonalInfo = "personal_info"
+	RuleReused       = "reused"
+	RuleBreached     = "breached"
+	RuleURL          = "url"
+	RuleOneOf        = "one_of"
+	RuleFormat       = "format"
+)
+
+// FieldError describes a validation rule a single field does not satisfy.
+// Params holds the arguments of the rule, e.g. the bounds of a length rule.
+// Message is the English message of Key.
+type FieldError struct {
+	Field   string
+	Rule    string
+	Params  map[string]interface{}
+	Key     i18n.Key
+	Message string
+}
+
+// NewFieldError returns the error of field for rule, with the catalog message
+// of key.
+func NewFieldError(field, rule string, key i18n.Key, params map[string]interface{}) FieldError {
+	return FieldError{
+		Field:   field,
+		Rule:    rule,
+		Params:  params,
+		Key:     key,
+		Message: i18n.Translate(i18n.English, key, params),
+	}
+}
+
+// LocalizedMessage returns the message in the language.
+func (e FieldError) LocalizedMessage(language i18n.Language) string {
+	if e.Key == "" {
+		return e.Message
+	}
+	return i18n.Translate(language, e.Key, e.Params)
+}
+
+// ValidationErrors is the result of a validation. It is empty when the value
+// is valid.
+type ValidationErrors []FieldError
+
+func (v ValidationErrors) Error() string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Field+": "+fieldError.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+// Messages returns the message of every error, in order.
+func (v ValidationErrors) Messages() []string {
+	messages := make([]string, 0, len(v))
+	for _, fieldError := range v {
+		messages = append(messages, fieldError.Message)
+	}
+	return messages
+}
+
+// Required reports that field is missing.
+func Required(field string) FieldError {
+	return NewFieldError(field, RuleRequired, i18n.ValidationRequired, nil)
+}
@@ -0,0 +1,80 @@
+package model
+
+import (
+	"encoding/json"
+	"slices"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/i18n"
+)
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// MinWebhookSecretLength is the length of the shortest secret the deliveries
+// may be signed with.
+const MinWebhookSecretLength = 32
+
+type WebhookSubscription struct {
+	ID         int32
+	URL        string
+	EventTypes []string
+	Secret     string
+	Active     bool
+	CreatedAt  time.Time
+}
+
+// Matches reports whether the subscription wants events of the given type.
+// A subscription without event types receives every event.
+func (s WebhookSubscription) Matches(eventType string) bool {
+	if len(s.EventTypes) == 0 {
+		return true
+	}
+
+	for _, subscribed := range s.EventTypes {
+		if subscribed == eventType || subscribed == "*" {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Validate checks the event types of the subscription are known, or "*", and
+// its secret is long enough to sign the deliveries.
+func (s WebhookSubscription) Validate() (errs ValidationErrors) {
+	values := append(EventTypes(), "*")
+	for _, eventType := range s.EventTypes {
+		if !slices.Contains(values, eventType) {
+			errs = append(errs, NewFieldError("event_types", RuleOneOf, i18n.ValidationOneOf, map[string]interface{}{"values": values}))
+			break
+		}
+	}
+
+	if len(s.Secret) < MinWebhookSecretLength {
+		errs = append(errs, NewFieldError("secret", RuleLength, i18n.ValidationWebhookSecretLength, map[string]interface{}{"min": MinWebhookSecretLength}))
+	}
+
+	return errs
+}
+
+type WebhookDelivery struct {
+	ID             int64
+	SubscriptionID int32
+	EventID        int64
+	EventType      string
+	Payload        json.RawMessage
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode int32
+	LastError      string
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	// URL and Secret of the subscription, only set on claimed deliveries.
+	URL    string
+	Secret string
+}
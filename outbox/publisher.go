@@ -0,0 +1,115 @@
+// Package outbox publishes the domain events stored in the outbox table.
+package outbox
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// Publisher delivers a domain event to its consumers. Publishing is at least
+// once, consumers must tolerate receiving the same event ID more than once.
+type Publisher interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+// Handler consumes events delivered by the InProcessPublisher.
+type Handler func(ctx context.Context, event model.OutboxEvent) error
+
+// InProcessPublisher fans events out to handlers living in the same process.
+type InProcessPublisher struct {
+	mu       sync.RWMutex
+	handlers map[string][]Handler
+}
+
+func NewInProcessPublisher() *InProcessPublisher {
+	return &InProcessPublisher{
+		handlers: map[string][]Handler{},
+	}
+}
+
+// Subscribe registers handler for the given event types, or for every event
+// when no type is given.
+func (p *InProcessPublisher) Subscribe(handler Handler, eventTypes ...string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if len(eventTypes) == 0 {
+		eventTypes = []string{"*"}
+	}
+
+	for _, eventType := range eventTypes {
+		p.handlers[eventType] = append(p.handlers[eventType], handler)
+	}
+}
+
+// Publish calls every matching handler and stops at the first error, the
+// relay then retries the whole event.
+func (p *InProcessPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	p.mu.RLock()
+	handlers := append(append([]Handler{}, p.handlers[event.EventType]...), p.handlers["*"]...)
+	p.mu.RUnlock()
+
+	for _, handler := range handlers {
+		if err := handler(ctx, event); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MultiPublisher publishes every event to all of its publishers in order.
+type MultiPublisher []Publisher
+
+func (p MultiPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	for _, publisher := range p {
+		if err := publisher.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriterPublisher writes every event as one JSON line, e.g. to stdout or a file.
+type WriterPublisher struct {
+	mu     sync.Mutex
+	writer io.Writer
+}
+
+func NewWriterPublisher(writer io.Writer) *WriterPublisher {
+	return &WriterPublisher{
+		writer: writer,
+	}
+}
+
+type writerEvent struct {
+	ID          int64           `json:"id"`
+	EventType   string          `json:"event_type"`
+	AggregateID int32           `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func (p *WriterPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	line, err := json.Marshal(writerEvent{
+		ID:          event.ID,
+		EventType:   event.EventType,
+		AggregateID: event.AggregateID,
+		Payload:     event.Payload,
+		CreatedAt:   event.CreatedAt,
+	})
+	if err != nil {
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, err = p.writer.Write(append(line, '\n'))
+	return err
+}
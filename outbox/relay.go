@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"log/slog"
+	"math"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultInterval    = time.Second
+	defaultMaxAttempts = 20
+	defaultBaseBackoff = time.Second
+	defaultMaxBackoff  = 10 * time.Minute
+)
+
+// Relay periodically moves events from the outbox table to the Publisher.
+// Events the Publisher rejects are retried with an exponential back-off until
+// MaxAttempts, they are then dead and left in the table for inspection.
+type Relay struct {
+	Repository  repository.RepositoryInterface
+	Publisher   Publisher
+	Logger      *slog.Logger
+	BatchSize   int
+	Interval    time.Duration
+	Lease       time.Duration
+	MaxAttempts int32
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Now         func() time.Time
+}
+
+type NewRelayOptions struct {
+	Repository  repository.RepositoryInterface
+	Publisher   Publisher
+	Logger      *slog.Logger
+	BatchSize   int
+	Interval    time.Duration
+	Lease       time.Duration
+	MaxAttempts int32
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewRelay(opts NewRelayOptions) *Relay {
+	relay := &Relay{
+		Repository:  opts.Repository,
+		Publisher:   opts.Publisher,
+		Logger:      opts.Logger,
+		BatchSize:   opts.BatchSize,
+		Interval:    opts.Interval,
+		Lease:       opts.Lease,
+		MaxAttempts: opts.MaxAttempts,
+		BaseBackoff: opts.BaseBackoff,
+		MaxBackoff:  opts.MaxBackoff,
+		Now:         time.Now,
+	}
+
+	if relay.Logger == nil {
+		relay.Logger = slog.Default()
+	}
+	if relay.BatchSize <= 0 {
+		relay.BatchSize = defaultBatchSize
+	}
+	if relay.Interval <= 0 {
+		relay.Interval = defaultInterval
+	}
+	if relay.MaxAttempts <= 0 {
+		relay.MaxAttempts = defaultMaxAttempts
+	}
+	if relay.BaseBackoff <= 0 {
+		relay.BaseBackoff = defaultBaseBackoff
+	}
+	if relay.MaxBackoff <= 0 {
+		relay.MaxBackoff = defaultMaxBackoff
+	}
+
+	return relay
+}
+
+// Run relays events until ctx is cancelled. A full batch is followed
+// immediately by the next one, otherwise the relay waits for Interval.
+func (r *Relay) Run(ctx context.Context) {
+	for {
+		published, err := r.RelayOnce(ctx)
+		if err != nil {
+			r.Logger.ErrorContext(ctx, "relay outbox events", "error", err)
+		}
+
+		if published < r.BatchSize || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(r.Interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// RelayOnce claims one batch of events and publishes them, returning the
+// number of events claimed.
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.Repository.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{
+		Limit: r.BatchSize,
+		Lease: r.Lease,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, event := range events {
+		if err := r.Publisher.Publish(ctx, event); err != nil {
+			failed := repository.MarkOutboxEventFailedInput{
+				ID:            event.ID,
+				Error:         err.Error(),
+				NextAttemptAt: r.Now().Add(r.Backoff(event.Attempts)),
+				Dead:          event.Attempts >= r.MaxAttempts,
+			}
+
+			r.Logger.WarnContext(ctx, "publish outbox event",
+				"event_id", event.ID,
+				"event_type", event.EventType,
+				"attempts", event.Attempts,
+				"dead", failed.Dead,
+				"error", err,
+			)
+
+			if err := r.Repository.MarkOutboxEventFailed(ctx, failed); err != nil {
+				return len(events), err
+			}
+			continue
+		}
+
+		err = r.Repository.MarkOutboxEventPublished(ctx, repository.MarkOutboxEventPublishedInput{
+			ID: event.ID,
+		})
+		if err != nil {
+			return len(events), err
+		}
+	}
+
+	return len(events), nil
+}
+
+// Backoff returns the delay before the next attempt: BaseBackoff doubled after
+// every failed attempt, capped at MaxBackoff.
+func (r *Relay) Backoff(attempts int32) time.Duration {
+	backoff := float64(r.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	if backoff > float64(r.MaxBackoff) {
+		return r.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
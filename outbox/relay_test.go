@@ -0,0 +1,108 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestRelayOnce(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	publisher := NewInProcessPublisher()
+
+	var received []int64
+	publisher.Subscribe(func(ctx context.Context, event model.OutboxEvent) error {
+		if event.ID == 2 {
+			return errors.New("consumer down")
+		}
+		received = append(received, event.ID)
+		return nil
+	}, model.EventUserRegistered)
+
+	relay := NewRelay(NewRelayOptions{
+		Repository:  repo,
+		Publisher:   publisher,
+		BatchSize:   10,
+		MaxAttempts: 3,
+	})
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	relay.Now = func() time.Time { return now }
+
+	repo.On("ClaimOutboxEvents", mock.Anything, repository.ClaimOutboxEventsInput{Limit: 10}).Return([]model.OutboxEvent{
+		{ID: 1, EventType: model.EventUserRegistered, Attempts: 1},
+		{ID: 2, EventType: model.EventUserRegistered, Attempts: 2},
+		{ID: 3, EventType: model.EventPhoneNumberChanged, Attempts: 1},
+	}, nil).Once()
+	repo.On("MarkOutboxEventPublished", mock.Anything, repository.MarkOutboxEventPublishedInput{ID: 1}).Return(nil).Once()
+	repo.On("MarkOutboxEventFailed", mock.Anything, repository.MarkOutboxEventFailedInput{
+		ID:            2,
+		Error:         "consumer down",
+		NextAttemptAt: now.Add(2 * time.Second),
+	}).Return(nil).Once()
+	repo.On("MarkOutboxEventPublished", mock.Anything, repository.MarkOutboxEventPublishedInput{ID: 3}).Return(nil).Once()
+
+	// test 1 failed event is retried later, the others are published
+	claimed, err := relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 3, claimed)
+	assert.Equal(t, []int64{1}, received)
+
+	// test 2 the event is dead after the last attempt
+	repo.On("ClaimOutboxEvents", mock.Anything, repository.ClaimOutboxEventsInput{Limit: 10}).Return([]model.OutboxEvent{
+		{ID: 2, EventType: model.EventUserRegistered, Attempts: 3},
+	}, nil).Once()
+	repo.On("MarkOutboxEventFailed", mock.Anything, repository.MarkOutboxEventFailedInput{
+		ID:            2,
+		Error:         "consumer down",
+		NextAttemptAt: now.Add(4 * time.Second),
+		Dead:          true,
+	}).Return(nil).Once()
+
+	claimed, err = relay.RelayOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, claimed)
+
+	// test 3 claim error
+	repo.On("ClaimOutboxEvents", mock.Anything, mock.Anything).Return(nil, errors.New("error")).Once()
+
+	claimed, err = relay.RelayOnce(context.Background())
+	assert.Error(t, err)
+	assert.Zero(t, claimed)
+
+	repo.AssertExpectations(t)
+}
+
+func TestWriterPublisher(t *testing.T) {
+	var buf bytes.Buffer
+	publisher := NewWriterPublisher(&buf)
+
+	err := publisher.Publish(context.Background(), model.OutboxEvent{
+		ID:          1,
+		EventType:   model.EventUserRegistered,
+		AggregateID: 7,
+		Payload:     json.RawMessage(`{"user_id":7}`),
+	})
+	assert.NoError(t, err)
+
+	var line map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &line))
+	assert.Equal(t, model.EventUserRegistered, line["event_type"])
+	assert.Equal(t, map[string]interface{}{"user_id": float64(7)}, line["payload"])
+}
+
+func TestRelayBackoff(t *testing.T) {
+	relay := NewRelay(NewRelayOptions{BaseBackoff: time.Second, MaxBackoff: time.Minute})
+
+	assert.Equal(t, time.Second, relay.Backoff(1))
+	assert.Equal(t, 32*time.Second, relay.Backoff(6))
+	assert.Equal(t, time.Minute, relay.Backoff(7))
+}
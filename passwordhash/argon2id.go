@@ -0,0 +1,150 @@
+package passwordhash
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Argon2idParams are the cost parameters of argon2id.
+type Argon2idParams struct {
+	// Memory is the memory used in KiB.
+	Memory uint32
+	// Iterations is the number of passes over the memory.
+	Iterations uint32
+	// Parallelism is the number of threads used.
+	Parallelism uint8
+	// SaltLength and KeyLength are in bytes.
+	SaltLength uint32
+	KeyLength  uint32
+}
+
+// DefaultArgon2idParams returns the second recommended option of RFC 9106,
+// for environments where 2 GiB of memory per hash is not affordable.
+func DefaultArgon2idParams() Argon2idParams {
+	return Argon2idParams{
+		Memory:      64 * 1024,
+		Iterations:  3,
+		Parallelism: 4,
+		SaltLength:  16,
+		KeyLength:   32,
+	}
+}
+
+// Validate reports parameters too weak to protect a password.
+func (p Argon2idParams) Validate() error {
+	switch {
+	case p.Memory < 8*uint32(p.Parallelism) || p.Memory < 8*1024:
+		return errors.New("argon2id: memory must be at least 8 MiB")
+	case p.Iterations < 1:
+		return errors.New("argon2id: iterations must be at least 1")
+	case p.Parallelism < 1:
+		return errors.New("argon2id: parallelism must be at least 1")
+	case p.SaltLength < 16:
+		return errors.New("argon2id: salt length must be at least 16 bytes")
+	case p.KeyLength < 16:
+		return errors.New("argon2id: key length must be at least 16 bytes")
+	}
+	return nil
+}
+
+// Argon2id hashes passwords with argon2id.
+type Argon2id struct {
+	params Argon2idParams
+}
+
+// NewArgon2id returns an argon2id hasher with the parameters.
+func NewArgon2id(params Argon2idParams) (*Argon2id, error) {
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+	return &Argon2id{params: params}, nil
+}
+
+// Hash returns the PHC string of the password, e.g.
+// $argon2id$v=19$m=65536,t=3,p=4$<salt>$<key>.
+func (h *Argon2id) Hash(password string) (string, error) {
+	salt := make([]byte, h.params.SaltLength)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, h.params.Iterations, h.params.Memory, h.params.Parallelism, h.params.KeyLength)
+
+	return fmt.Sprintf(
+		"$%s$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		AlgorithmArgon2id,
+		argon2.Version,
+		h.params.Memory,
+		h.params.Iterations,
+		h.params.Parallelism,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// Verify checks the password against a hash of any supported algorithm.
+func (h *Argon2id) Verify(hash, password string) (bool, error) {
+	return verify(hash, password)
+}
+
+// NeedsRehash reports hashes of another algorithm or with less memory,
+// iterations, salt or key than configured.
+func (h *Argon2id) NeedsRehash(hash string) bool {
+	params, _, _, err := parseArgon2id(hash)
+	if err != nil {
+		return true
+	}
+
+	return params.Memory < h.params.Memory ||
+		params.Iterations < h.params.Iterations ||
+		params.SaltLength < h.params.SaltLength ||
+		params.KeyLength < h.params.KeyLength
+}
+
+func verifyArgon2id(hash, password string) (bool, error) {
+	params, salt, key, err := parseArgon2id(hash)
+	if err != nil {
+		return false, err
+	}
+
+	other := argon2.IDKey([]byte(password), salt, params.Iterations, params.Memory, params.Parallelism, params.KeyLength)
+	return subtle.ConstantTimeCompare(key, other) == 1, nil
+}
+
+// maxArgon2idMemory bounds the memory of the hashes verified, 4 GiB, so a
+// tampered hash cannot exhaust the memory of the service.
+const maxArgon2idMemory = 4 * 1024 * 1024
+
+func parseArgon2id(hash string) (params Argon2idParams, salt, key []byte, err error) {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 || parts[0] != "" || parts[1] != AlgorithmArgon2id {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &params.Memory, &params.Iterations, &params.Parallelism)
+	if err != nil || params.Iterations < 1 || params.Parallelism < 1 || params.Memory > maxArgon2idMemory {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	if salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return params, nil, nil, ErrMalformedHash
+	}
+	if key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(key) == 0 {
+		return params, nil, nil, ErrMalformedHash
+	}
+
+	params.SaltLength = uint32(len(salt))
+	params.KeyLength = uint32(len(key))
+	return params, salt, key, nil
+}
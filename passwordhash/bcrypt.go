@@ -0,0 +1,67 @@
+package passwordhash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost is the cost of the bcrypt hasher when none is configured.
+const DefaultBcryptCost = 12
+
+// Bcrypt hashes passwords with bcrypt, in the $2a$<cost>$ format.
+type Bcrypt struct {
+	cost int
+}
+
+// NewBcrypt returns a bcrypt hasher of the cost, DefaultBcryptCost when 0.
+func NewBcrypt(cost int) (*Bcrypt, error) {
+	if cost == 0 {
+		cost = DefaultBcryptCost
+	}
+	if cost < 10 || cost > bcrypt.MaxCost {
+		return nil, fmt.Errorf("bcrypt: cost must be between 10 and %d", bcrypt.MaxCost)
+	}
+	return &Bcrypt{cost: cost}, nil
+}
+
+// Hash returns the bcrypt hash of the password.
+func (h *Bcrypt) Hash(password string) (string, error) {
+	result, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+	return string(result), nil
+}
+
+// Verify checks the password against a hash of any supported algorithm.
+func (h *Bcrypt) Verify(hash, password string) (bool, error) {
+	return verify(hash, password)
+}
+
+// NeedsRehash reports hashes of another algorithm or of a lower cost than
+// configured.
+func (h *Bcrypt) NeedsRehash(hash string) bool {
+	if !isBcryptHash(hash) {
+		return true
+	}
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost < h.cost
+}
+
+func isBcryptHash(hash string) bool {
+	return strings.HasPrefix(hash, "$2a$") || strings.HasPrefix(hash, "$2b$") || strings.HasPrefix(hash, "$2y$")
+}
+
+func verifyBcrypt(hash, password string) (bool, error) {
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, ErrMalformedHash
+	}
+	return true, nil
+}
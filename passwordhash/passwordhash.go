@@ -0,0 +1,55 @@
+// Package passwordhash implements the password hashers of the service:
+// argon2id, the default, and bcrypt, kept for the hashes created before
+// argon2id was introduced.
+//
+// Hashes record their algorithm and parameters, argon2id hashes in the PHC
+// string format and bcrypt hashes in their own modular crypt format, so every
+// hasher verifies the hashes of both algorithms and reports the ones created
+// with weaker parameters than its own, to be rehashed on the next login.
+package passwordhash
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// Algorithm names, as written in the hashes and read from the configuration.
+const (
+	AlgorithmArgon2id = "argon2id"
+	AlgorithmBcrypt   = "bcrypt"
+)
+
+// ErrMalformedHash is returned when verifying a hash of an unknown algorithm
+// or with invalid parameters.
+var ErrMalformedHash = errors.New("passwordhash: malformed hash")
+
+// New returns the hasher of the algorithm, configured with argon2id or
+// bcrypt parameters, e.g. New(AlgorithmArgon2id, DefaultArgon2idParams(), 0).
+func New(algorithm string, argon2idParams Argon2idParams, bcryptCost int) (model.PasswordHasher, error) {
+	switch algorithm {
+	case AlgorithmArgon2id:
+		return NewArgon2id(argon2idParams)
+	case AlgorithmBcrypt:
+		return NewBcrypt(bcryptCost)
+	}
+	return nil, fmt.Errorf("passwordhash: unsupported algorithm %q", algorithm)
+}
+
+// Default returns the argon2id hasher with the default parameters.
+func Default() model.PasswordHasher {
+	return &Argon2id{params: DefaultArgon2idParams()}
+}
+
+// verify checks the password against a hash of any supported algorithm.
+func verify(hash, password string) (bool, error) {
+	switch {
+	case strings.HasPrefix(hash, "$"+AlgorithmArgon2id+"$"):
+		return verifyArgon2id(hash, password)
+	case isBcryptHash(hash):
+		return verifyBcrypt(hash, password)
+	}
+	return false, ErrMalformedHash
+}
@@ -0,0 +1,125 @@
+package passwordhash
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// testParams are the lowest accepted argon2id parameters, so tests hash
+// quickly.
+var testParams = Argon2idParams{Memory: 8 * 1024, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+const bcryptHash = "$2a$04$a2o3BiK8KiH79TFt9QE1hOutA9115oKSUIYQpFAoLldhotz7pwYQe" // Leo9999#
+
+func TestArgon2id(t *testing.T) {
+	hasher, err := NewArgon2id(testParams)
+	assert.NoError(t, err)
+
+	// test 1 PHC string recording the parameters
+	hash, err := hasher.Hash("Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$argon2id$v=19$m=8192,t=1,p=1$"), hash)
+
+	other, _ := hasher.Hash("Leo9999#")
+	assert.NotEqual(t, hash, other, "salt must be random")
+
+	// test 2 verify
+	ok, err := hasher.Verify(hash, "Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = hasher.Verify(hash, "wrong")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// test 3 verify bcrypt hashes
+	ok, err = hasher.Verify(bcryptHash, "Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// test 4 rehash weaker hashes only
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, hasher.NeedsRehash(bcryptHash))
+
+	stronger := testParams
+	stronger.Iterations = 2
+	strongerHasher, _ := NewArgon2id(stronger)
+	strongerHash, _ := strongerHasher.Hash("Leo9999#")
+	assert.True(t, strongerHasher.NeedsRehash(hash))
+	assert.False(t, hasher.NeedsRehash(strongerHash))
+
+	// test 5 weak parameters
+	weak := testParams
+	weak.Memory = 1024
+	_, err = NewArgon2id(weak)
+	assert.Error(t, err)
+}
+
+func TestBcrypt(t *testing.T) {
+	hasher, err := NewBcrypt(10)
+	assert.NoError(t, err)
+
+	// test 1 hash and verify
+	hash, err := hasher.Hash("Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, strings.HasPrefix(hash, "$2a$10$"), hash)
+
+	ok, err := hasher.Verify(hash, "Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// test 2 rehash lower costs and other algorithms
+	assert.False(t, hasher.NeedsRehash(hash))
+	assert.True(t, hasher.NeedsRehash(bcryptHash))
+
+	argon2idHasher, _ := NewArgon2id(testParams)
+	argon2idHash, _ := argon2idHasher.Hash("Leo9999#")
+	assert.True(t, hasher.NeedsRehash(argon2idHash))
+
+	ok, err = hasher.Verify(argon2idHash, "Leo9999#")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+
+	// test 3 default and invalid costs
+	hasher, err = NewBcrypt(0)
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultBcryptCost, hasher.cost)
+
+	_, err = NewBcrypt(4)
+	assert.Error(t, err)
+}
+
+func TestVerifyMalformedHash(t *testing.T) {
+	hasher := Default()
+
+	for _, hash := range []string{
+		"",
+		"plain",
+		"$argon2i$v=19$m=8192,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=16$m=8192,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=0,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=99999999,t=1,p=1$c2FsdHNhbHRzYWx0c2FsdA$a2V5",
+		"$argon2id$v=19$m=8192,t=1,p=1$!!!$a2V5",
+		"$2a$04$short",
+	} {
+		ok, err := hasher.Verify(hash, "Leo9999#")
+		assert.ErrorIs(t, err, ErrMalformedHash, hash)
+		assert.False(t, ok)
+		assert.True(t, hasher.NeedsRehash(hash))
+	}
+}
+
+func TestNew(t *testing.T) {
+	hasher, err := New(AlgorithmArgon2id, testParams, 0)
+	assert.NoError(t, err)
+	assert.IsType(t, &Argon2id{}, hasher)
+
+	hasher, err = New(AlgorithmBcrypt, testParams, 11)
+	assert.NoError(t, err)
+	assert.IsType(t, &Bcrypt{}, hasher)
+
+	_, err = New("md5", testParams, 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,149 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// auditChainLockKey identifies the advisory lock serialising audit event inserts,
+// each event needs the hash of the one before it.
+const auditChainLockKey = 727100
+
+const defaultAuditEventsLimit = 100
+
+func (r *Repository) InsertAuditEvent(ctx context.Context, input InsertAuditEventInput) (output InsertAuditEventOutput, err error) {
+	defer translatePostgresError(&err)
+
+	event := model.AuditEvent{
+		EventType: input.EventType,
+		UserID:    input.UserID,
+		ActorID:   input.ActorID,
+		Metadata:  input.Metadata,
+		IPAddress: input.IPAddress,
+		RequestID: input.RequestID,
+		// Postgres keeps microseconds, truncate so the stored value hashes the same.
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if event.Metadata == nil {
+		event.Metadata = map[string]string{}
+	}
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return
+	}
+
+	err = r.inTx(ctx, func(txRepo *Repository) error {
+		if _, err := txRepo.conn().ExecContext(ctx, "SELECT pg_advisory_xact_lock($1)", auditChainLockKey); err != nil {
+			return err
+		}
+
+		err := txRepo.conn().QueryRowContext(
+			ctx,
+			"SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1",
+		).Scan(&event.ID, &event.PrevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		event.ID++
+		event.Hash = event.ComputeHash()
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO audit_events (id, event_type, user_id, actor_id, metadata, ip_address, request_id, created_at, prev_hash, hash) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)",
+			event.ID,
+			event.EventType,
+			event.UserID,
+			event.ActorID,
+			metadata,
+			event.IPAddress,
+			event.RequestID,
+			event.CreatedAt,
+			event.PrevHash,
+			event.Hash,
+		)
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	output.ID = event.ID
+	output.Hash = event.Hash
+	return
+}
+
+func (r *Repository) GetAuditEvents(ctx context.Context, input GetAuditEventsInput) (events []model.AuditEvent, err error) {
+	defer translatePostgresError(&err)
+
+	var (
+		conditions = []string{"id > $1"}
+		args       = []interface{}{input.AfterID}
+	)
+
+	if input.UserID != 0 {
+		args = append(args, input.UserID)
+		conditions = append(conditions, fmt.Sprintf("user_id = $%d", len(args)))
+	}
+
+	if input.EventType != "" {
+		args = append(args, input.EventType)
+		conditions = append(conditions, fmt.Sprintf("event_type = $%d", len(args)))
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultAuditEventsLimit
+	}
+	args = append(args, input.Limit)
+
+	query := fmt.Sprintf(
+		"SELECT id, event_type, user_id, actor_id, metadata, ip_address, request_id, created_at, prev_hash, hash FROM audit_events WHERE %s ORDER BY id LIMIT $%d",
+		strings.Join(conditions, " AND "),
+		len(args),
+	)
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event    model.AuditEvent
+			metadata []byte
+		)
+
+		err = rows.Scan(
+			&event.ID,
+			&event.EventType,
+			&event.UserID,
+			&event.ActorID,
+			&metadata,
+			&event.IPAddress,
+			&event.RequestID,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
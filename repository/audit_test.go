@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertAuditEvent(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	lockQuery := "SELECT pg_advisory_xact_lock\\(\\$1\\)"
+	lastQuery := "SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1"
+	insertQuery := "INSERT INTO audit_events \\(id, event_type, user_id, actor_id, metadata, ip_address, request_id, created_at, prev_hash, hash\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6, \\$7, \\$8, \\$9, \\$10\\)"
+
+	input := InsertAuditEventInput{
+		EventType: model.AuditEventLoginSucceeded,
+		UserID:    u.UserID,
+		ActorID:   u.UserID,
+	}
+
+	// test 1 first event of the chain
+	mock.ExpectBegin()
+	mock.ExpectExec(lockQuery).WithArgs(auditChainLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lastQuery).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(insertQuery).WithArgs(int64(1), input.EventType, u.UserID, u.UserID, []byte("{}"), "", "", sqlmock.AnyArg(), "", sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	out, err := repo.InsertAuditEvent(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), out.ID)
+	assert.Len(t, out.Hash, 64)
+
+	// test 2 event chained to the previous one
+	mock.ExpectBegin()
+	mock.ExpectExec(lockQuery).WithArgs(auditChainLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lastQuery).WillReturnRows(sqlmock.NewRows([]string{"id", "hash"}).AddRow(1, out.Hash))
+	mock.ExpectExec(insertQuery).WithArgs(int64(2), input.EventType, u.UserID, u.UserID, []byte("{}"), "", "", sqlmock.AnyArg(), out.Hash, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(2, 1))
+	mock.ExpectCommit()
+
+	out, err = repo.InsertAuditEvent(context.Background(), input)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), out.ID)
+
+	// test 3 insert error
+	mock.ExpectBegin()
+	mock.ExpectExec(lockQuery).WithArgs(auditChainLockKey).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(lastQuery).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	outError, err := repo.InsertAuditEvent(context.Background(), input)
+	assert.Empty(t, outError)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestGetAuditEvents(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	columns := []string{"id", "event_type", "user_id", "actor_id", "metadata", "ip_address", "request_id", "created_at", "prev_hash", "hash"}
+
+	// test 1 get all
+	query := "SELECT (.+) FROM audit_events WHERE id > \\$1 ORDER BY id LIMIT \\$2"
+	rows := sqlmock.NewRows(columns).
+		AddRow(1, model.AuditEventUserRegistered, u.UserID, u.UserID, []byte(`{"fields":"full_name"}`), "127.0.0.1", "req-1", time.Now(), "", "hash")
+	mock.ExpectQuery(query).WithArgs(int64(0), defaultAuditEventsLimit).WillReturnRows(rows)
+
+	events, err := repo.GetAuditEvents(context.Background(), GetAuditEventsInput{})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, "full_name", events[0].Metadata["fields"])
+	}
+
+	// test 2 filtered
+	query = "SELECT (.+) FROM audit_events WHERE id > \\$1 AND user_id = \\$2 AND event_type = \\$3 ORDER BY id LIMIT \\$4"
+	mock.ExpectQuery(query).WithArgs(int64(10), u.UserID, model.AuditEventLoginFailed, 5).WillReturnRows(sqlmock.NewRows(columns))
+
+	events, err = repo.GetAuditEvents(context.Background(), GetAuditEventsInput{
+		UserID:    u.UserID,
+		EventType: model.AuditEventLoginFailed,
+		AfterID:   10,
+		Limit:     5,
+	})
+	assert.NoError(t, err)
+	assert.Empty(t, events)
+
+	// test 3 get error
+	query = "SELECT (.+) FROM audit_events WHERE id > \\$1 ORDER BY id LIMIT \\$2"
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+
+	events, err = repo.GetAuditEvents(context.Background(), GetAuditEventsInput{})
+	assert.Empty(t, events)
+	assert.Error(t, err)
+}
@@ -0,0 +1,351 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+const (
+	defaultBreakerFailureThreshold = 5
+	defaultBreakerCooldown         = 10 * time.Second
+)
+
+// ErrCircuitOpen is returned, in a *CircuitOpenError, without calling the
+// database while the circuit breaker is open. It is an ErrTransient.
+var ErrCircuitOpen = fmt.Errorf("circuit breaker is open: %w", ErrTransient)
+
+// CircuitOpenError is returned while the circuit breaker is open, it tells
+// when the database is tried again.
+type CircuitOpenError struct {
+	RetryAfter time.Duration
+}
+
+func (e *CircuitOpenError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrCircuitOpen, e.RetryAfter)
+}
+
+func (e *CircuitOpenError) Unwrap() error {
+	return ErrCircuitOpen
+}
+
+type BreakerState string
+
+const (
+	// BreakerClosed lets every call through.
+	BreakerClosed BreakerState = "closed"
+	// BreakerOpen fails every call until the cooldown has elapsed.
+	BreakerOpen BreakerState = "open"
+	// BreakerHalfOpen lets one call through to probe the database, closing
+	// the circuit when it succeeds and opening it again otherwise.
+	BreakerHalfOpen BreakerState = "half-open"
+)
+
+type CircuitBreakerOptions struct {
+	// FailureThreshold is the number of calls failing in a row with
+	// ErrTransient that opens the circuit. Defaults to 5.
+	FailureThreshold int
+	// Cooldown is how long the circuit stays open before probing the
+	// database again. Defaults to 10s.
+	Cooldown time.Duration
+}
+
+// CircuitBreaker stops calling the database after sustained failures, so
+// requests fail fast instead of piling up while it is unavailable. Only
+// ErrTransient failures count, the other errors are answers of the database.
+type CircuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    BreakerState
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+func NewCircuitBreaker(opts CircuitBreakerOptions) *CircuitBreaker {
+	if opts.FailureThreshold <= 0 {
+		opts.FailureThreshold = defaultBreakerFailureThreshold
+	}
+	if opts.Cooldown <= 0 {
+		opts.Cooldown = defaultBreakerCooldown
+	}
+	return &CircuitBreaker{
+		threshold: opts.FailureThreshold,
+		cooldown:  opts.Cooldown,
+		state:     BreakerClosed,
+	}
+}
+
+// State returns the state of the circuit, BreakerClosed for a nil breaker.
+func (b *CircuitBreaker) State() BreakerState {
+	if b == nil {
+		return BreakerClosed
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state == BreakerOpen && time.Since(b.openedAt) >= b.cooldown {
+		return BreakerHalfOpen
+	}
+	return b.state
+}
+
+// do runs call unless the circuit is open, and records its outcome.
+func (b *CircuitBreaker) do(ctx context.Context, call func() error) error {
+	probe, err := b.allow()
+	if err != nil {
+		return err
+	}
+
+	err = call()
+	b.record(probe, err, ctx.Err() != nil)
+	return err
+}
+
+// allow reports whether a call may run, and whether it is the probe of a
+// half-open circuit.
+func (b *CircuitBreaker) allow() (probe bool, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case BreakerClosed:
+		return false, nil
+	case BreakerOpen:
+		if elapsed := time.Since(b.openedAt); elapsed < b.cooldown {
+			return false, &CircuitOpenError{RetryAfter: b.cooldown - elapsed}
+		}
+		b.state = BreakerHalfOpen
+	}
+
+	// Other calls wait for the probe to answer
+	if b.probing {
+		return false, &CircuitOpenError{RetryAfter: time.Second}
+	}
+	b.probing = true
+	return true, nil
+}
+
+func (b *CircuitBreaker) record(probe bool, err error, cancelled bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if probe {
+		b.probing = false
+	}
+
+	switch {
+	case cancelled && err != nil:
+		// The caller gave up, the database may be fine
+	case !errors.Is(err, ErrTransient):
+		b.state, b.failures = BreakerClosed, 0
+	case probe:
+		b.state, b.openedAt = BreakerOpen, time.Now()
+	case b.state == BreakerClosed:
+		b.failures++
+		if b.failures >= b.threshold {
+			b.state, b.openedAt = BreakerOpen, time.Now()
+		}
+	}
+}
+
+// CircuitBreakerRepository decorates a repository with a CircuitBreaker. A
+// transaction run by WithTx counts as one call.
+type CircuitBreakerRepository struct {
+	RepositoryInterface
+	Breaker *CircuitBreaker
+}
+
+func NewCircuitBreakerRepository(repo RepositoryInterface, breaker *CircuitBreaker) *CircuitBreakerRepository {
+	return &CircuitBreakerRepository{RepositoryInterface: repo, Breaker: breaker}
+}
+
+// guard runs call through the circuit breaker of r.
+func guard[T any](ctx context.Context, r *CircuitBreakerRepository, call func() (T, error)) (out T, err error) {
+	err = r.Breaker.do(ctx, func() error {
+		out, err = call()
+		return err
+	})
+	return out, err
+}
+
+func (r *CircuitBreakerRepository) GetLoginData(ctx context.Context, input GetLoginDataInput) (GetLoginDataOutput, error) {
+	return guard(ctx, r, func() (GetLoginDataOutput, error) {
+		return r.RepositoryInterface.GetLoginData(ctx, input)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
+	return guard(ctx, r, func() (model.User, error) {
+		return r.RepositoryInterface.GetUserDataByUserID(ctx, input)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetTokenVersion(ctx context.Context, in GetTokenVersionInput) (int32, error) {
+	return guard(ctx, r, func() (int32, error) {
+		return r.RepositoryInterface.GetTokenVersion(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) InsertUser(ctx context.Context, in InsertUserInput) (InsertUserOutput, error) {
+	return guard(ctx, r, func() (InsertUserOutput, error) {
+		return r.RepositoryInterface.InsertUser(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) UpdateSuccessfulLogin(ctx context.Context, in UpdateSuccessfulLoginInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.UpdateSuccessfulLogin(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) UpdateUserData(ctx context.Context, in UpdateUserDataInput) (UpdateUserDataOutput, error) {
+	return guard(ctx, r, func() (UpdateUserDataOutput, error) {
+		return r.RepositoryInterface.UpdateUserData(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetPasswordHistory(ctx context.Context, in GetPasswordHistoryInput) ([]string, error) {
+	return guard(ctx, r, func() ([]string, error) {
+		return r.RepositoryInterface.GetPasswordHistory(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) UpdatePassword(ctx context.Context, in UpdatePasswordInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.UpdatePassword(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) RehashPassword(ctx context.Context, in RehashPasswordInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.RehashPassword(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) InsertAuditEvent(ctx context.Context, in InsertAuditEventInput) (InsertAuditEventOutput, error) {
+	return guard(ctx, r, func() (InsertAuditEventOutput, error) {
+		return r.RepositoryInterface.InsertAuditEvent(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetAuditEvents(ctx context.Context, in GetAuditEventsInput) ([]model.AuditEvent, error) {
+	return guard(ctx, r, func() ([]model.AuditEvent, error) {
+		return r.RepositoryInterface.GetAuditEvents(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) ClaimOutboxEvents(ctx context.Context, in ClaimOutboxEventsInput) ([]model.OutboxEvent, error) {
+	return guard(ctx, r, func() ([]model.OutboxEvent, error) {
+		return r.RepositoryInterface.ClaimOutboxEvents(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) MarkOutboxEventPublished(ctx context.Context, in MarkOutboxEventPublishedInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.MarkOutboxEventPublished(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) MarkOutboxEventFailed(ctx context.Context, in MarkOutboxEventFailedInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.MarkOutboxEventFailed(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) DeletePublishedOutboxEvents(ctx context.Context, in DeletePublishedOutboxEventsInput) (int64, error) {
+	return guard(ctx, r, func() (int64, error) {
+		return r.RepositoryInterface.DeletePublishedOutboxEvents(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) InsertWebhookSubscription(ctx context.Context, in InsertWebhookSubscriptionInput) (model.WebhookSubscription, error) {
+	return guard(ctx, r, func() (model.WebhookSubscription, error) {
+		return r.RepositoryInterface.InsertWebhookSubscription(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetWebhookSubscriptions(ctx context.Context, in GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error) {
+	return guard(ctx, r, func() ([]model.WebhookSubscription, error) {
+		return r.RepositoryInterface.GetWebhookSubscriptions(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) DeactivateWebhookSubscription(ctx context.Context, in DeactivateWebhookSubscriptionInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.DeactivateWebhookSubscription(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) InsertWebhookDeliveries(ctx context.Context, in InsertWebhookDeliveriesInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.InsertWebhookDeliveries(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) ClaimWebhookDeliveries(ctx context.Context, in ClaimWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	return guard(ctx, r, func() ([]model.WebhookDelivery, error) {
+		return r.RepositoryInterface.ClaimWebhookDeliveries(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) UpdateWebhookDelivery(ctx context.Context, in UpdateWebhookDeliveryInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.UpdateWebhookDelivery(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) GetWebhookDeliveries(ctx context.Context, in GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	return guard(ctx, r, func() ([]model.WebhookDelivery, error) {
+		return r.RepositoryInterface.GetWebhookDeliveries(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) RedeliverWebhookDelivery(ctx context.Context, in RedeliverWebhookDeliveryInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.RedeliverWebhookDelivery(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) DeleteDeliveredWebhookDeliveries(ctx context.Context, in DeleteDeliveredWebhookDeliveriesInput) (int64, error) {
+	return guard(ctx, r, func() (int64, error) {
+		return r.RepositoryInterface.DeleteDeliveredWebhookDeliveries(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) ReserveIdempotencyKey(ctx context.Context, in ReserveIdempotencyKeyInput) (ReserveIdempotencyKeyOutput, error) {
+	return guard(ctx, r, func() (ReserveIdempotencyKeyOutput, error) {
+		return r.RepositoryInterface.ReserveIdempotencyKey(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) CompleteIdempotencyKey(ctx context.Context, in CompleteIdempotencyKeyInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.CompleteIdempotencyKey(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) ReleaseIdempotencyKey(ctx context.Context, in ReleaseIdempotencyKeyInput) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.ReleaseIdempotencyKey(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, in DeleteExpiredIdempotencyKeysInput) (int64, error) {
+	return guard(ctx, r, func() (int64, error) {
+		return r.RepositoryInterface.DeleteExpiredIdempotencyKeys(ctx, in)
+	})
+}
+
+func (r *CircuitBreakerRepository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	return r.Breaker.do(ctx, func() error {
+		return r.RepositoryInterface.WithTx(ctx, fn)
+	})
+}
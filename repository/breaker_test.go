@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCircuitBreakerRepository(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewMockRepositoryInterface(ctrl)
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 2, Cooldown: 20 * time.Millisecond})
+	repo := NewCircuitBreakerRepository(mock, breaker)
+
+	getUser := func() error {
+		_, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+		return err
+	}
+
+	// test 1 other errors are answers of the database
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrNotFound).Times(3)
+	for i := 0; i < 3; i++ {
+		assert.ErrorIs(t, getUser(), ErrNotFound)
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// test 2 sustained transient failures open the circuit
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient).Times(2)
+	assert.ErrorIs(t, getUser(), ErrTransient)
+	assert.Equal(t, BreakerClosed, breaker.State())
+	assert.ErrorIs(t, getUser(), ErrTransient)
+	assert.Equal(t, BreakerOpen, breaker.State())
+
+	err := repo.UpdatePassword(context.Background(), UpdatePasswordInput{UserID: u.UserID})
+	assert.ErrorIs(t, err, ErrCircuitOpen)
+	assert.ErrorIs(t, err, ErrTransient)
+
+	var openErr *CircuitOpenError
+	if assert.True(t, errors.As(err, &openErr)) {
+		assert.Greater(t, openErr.RetryAfter, time.Duration(0))
+		assert.LessOrEqual(t, openErr.RetryAfter, 20*time.Millisecond)
+	}
+
+	// test 3 a failed probe opens the circuit again
+	time.Sleep(25 * time.Millisecond)
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient)
+	assert.ErrorIs(t, getUser(), ErrTransient)
+	assert.Equal(t, BreakerOpen, breaker.State())
+	assert.ErrorIs(t, getUser(), ErrCircuitOpen)
+
+	// test 4 a successful probe closes the circuit
+	time.Sleep(25 * time.Millisecond)
+	mock.EXPECT().WithTx(gomock.Any(), gomock.Any()).Return(nil)
+	assert.NoError(t, repo.WithTx(context.Background(), func(RepositoryInterface) error { return nil }))
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// test 5 the failures must be in a row
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient)
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, nil)
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient)
+	for i := 0; i < 3; i++ {
+		getUser()
+	}
+	assert.Equal(t, BreakerClosed, breaker.State())
+}
+
+func TestCircuitBreakerProbe(t *testing.T) {
+	breaker := NewCircuitBreaker(CircuitBreakerOptions{FailureThreshold: 1, Cooldown: time.Millisecond})
+	breaker.do(context.Background(), func() error { return ErrTransient })
+	time.Sleep(2 * time.Millisecond)
+
+	// test 1 a single call probes the database
+	err := breaker.do(context.Background(), func() error {
+		assert.ErrorIs(t, breaker.do(context.Background(), func() error { return nil }), ErrCircuitOpen)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, BreakerClosed, breaker.State())
+
+	// test 2 a probe given up by its caller does not decide
+	breaker.do(context.Background(), func() error { return ErrTransient })
+	time.Sleep(2 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	breaker.do(ctx, func() error { return ErrTransient })
+	assert.Equal(t, BreakerHalfOpen, breaker.State())
+
+	// test 3 a nil breaker is closed
+	assert.Equal(t, BreakerClosed, (*CircuitBreaker)(nil).State())
+}
@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var identifierRegex = regexp.MustCompile(`^[a-z_][a-z0-9_]*$`)
+
+// updateBuilder assembles a parameterised UPDATE statement with a dynamic SET
+// clause. Values are always bound as placeholders so they reach the database
+// verbatim; only column names and expressions written in code end up in the
+// query text. Every statement also sets updated_at = NOW(), so tables updated
+// through the builder must have that column.
+type updateBuilder struct {
+	table     string
+	sets      []string
+	where     []string
+	returning []string
+	args      []interface{}
+	err       error
+}
+
+func newUpdateBuilder(table string) *updateBuilder {
+	b := &updateBuilder{table: table}
+	b.checkIdentifier(table)
+	return b
+}
+
+// Set assigns a bound value to column.
+func (b *updateBuilder) Set(column string, value interface{}) *updateBuilder {
+	b.checkIdentifier(column)
+	b.sets = append(b.sets, fmt.Sprintf("%s = %s", column, b.bind(value)))
+	return b
+}
+
+// SetExpr assigns a SQL expression to column, e.g. "successful_login + 1".
+// expr must be a constant from code and never carry request data.
+func (b *updateBuilder) SetExpr(column, expr string) *updateBuilder {
+	b.checkIdentifier(column)
+	b.sets = append(b.sets, fmt.Sprintf("%s = %s", column, expr))
+	return b
+}
+
+// Where adds an equality condition; multiple conditions are joined by AND.
+func (b *updateBuilder) Where(column string, value interface{}) *updateBuilder {
+	b.checkIdentifier(column)
+	b.where = append(b.where, fmt.Sprintf("%s = %s", column, b.bind(value)))
+	return b
+}
+
+// Returning adds a RETURNING clause with the given columns.
+func (b *updateBuilder) Returning(columns ...string) *updateBuilder {
+	for _, column := range columns {
+		b.checkIdentifier(column)
+	}
+	b.returning = append(b.returning, columns...)
+	return b
+}
+
+// Build returns the query and its arguments. A statement without any SET or
+// WHERE condition is rejected so a bug can never rewrite the whole table.
+func (b *updateBuilder) Build() (query string, args []interface{}, err error) {
+	if b.err != nil {
+		return "", nil, b.err
+	}
+	if len(b.sets) == 0 {
+		return "", nil, errors.New("update builder: nothing to set")
+	}
+	if len(b.where) == 0 {
+		return "", nil, errors.New("update builder: missing where condition")
+	}
+
+	sets := append(append([]string{}, b.sets...), "updated_at = NOW()")
+	query = fmt.Sprintf(
+		"UPDATE %s SET %s WHERE %s",
+		b.table,
+		strings.Join(sets, ", "),
+		strings.Join(b.where, " AND "),
+	)
+	if len(b.returning) > 0 {
+		query += " RETURNING " + strings.Join(b.returning, ", ")
+	}
+	return query, b.args, nil
+}
+
+func (b *updateBuilder) bind(value interface{}) string {
+	b.args = append(b.args, value)
+	return fmt.Sprintf("$%d", len(b.args))
+}
+
+func (b *updateBuilder) checkIdentifier(name string) {
+	if b.err == nil && !identifierRegex.MatchString(name) {
+		b.err = fmt.Errorf("update builder: invalid identifier %q", name)
+	}
+}
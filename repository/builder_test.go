@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpdateBuilder(t *testing.T) {
+	var tests = []struct {
+		name      string
+		builder   *updateBuilder
+		wantQuery string
+		wantArgs  []interface{}
+		wantErr   bool
+	}{
+		{
+			name:      "set values and expressions",
+			builder:   newUpdateBuilder("users").Set("full_name", "leo").SetExpr("successful_login", "successful_login + 1").Where("id", int32(1)),
+			wantQuery: "UPDATE users SET full_name = $1, successful_login = successful_login + 1, updated_at = NOW() WHERE id = $2",
+			wantArgs:  []interface{}{"leo", int32(1)},
+		},
+		{
+			name:      "multiple where conditions",
+			builder:   newUpdateBuilder("users").Set("full_name", "leo").Where("id", int32(1)).Where("phone_number", "+6281234567"),
+			wantQuery: "UPDATE users SET full_name = $1, updated_at = NOW() WHERE id = $2 AND phone_number = $3",
+			wantArgs:  []interface{}{"leo", int32(1), "+6281234567"},
+		},
+		{
+			name:      "returning columns",
+			builder:   newUpdateBuilder("users").SetExpr("version", "version + 1").Where("id", int32(1)).Returning("version"),
+			wantQuery: "UPDATE users SET version = version + 1, updated_at = NOW() WHERE id = $1 RETURNING version",
+			wantArgs:  []interface{}{int32(1)},
+		},
+		{
+			name:      "values are never spliced into the query",
+			builder:   newUpdateBuilder("users").Set("full_name", "'; DROP TABLE users; --").Where("id", int32(1)),
+			wantQuery: "UPDATE users SET full_name = $1, updated_at = NOW() WHERE id = $2",
+			wantArgs:  []interface{}{"'; DROP TABLE users; --", int32(1)},
+		},
+		{
+			name:    "nothing to set",
+			builder: newUpdateBuilder("users").Where("id", int32(1)),
+			wantErr: true,
+		},
+		{
+			name:    "missing where",
+			builder: newUpdateBuilder("users").Set("full_name", "leo"),
+			wantErr: true,
+		},
+		{
+			name:    "invalid column",
+			builder: newUpdateBuilder("users").Set("full_name = 'x' --", "leo").Where("id", int32(1)),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			query, args, err := tt.builder.Build()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantQuery, query)
+			assert.Equal(t, tt.wantArgs, args)
+		})
+	}
+}
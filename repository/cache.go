@@ -0,0 +1,229 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"golang.org/x/sync/singleflight"
+)
+
+const (
+	defaultCacheTTL  = time.Minute
+	defaultCacheSize = 10000
+)
+
+// CacheBackend stores the profiles cached by CachedRepository, encoded, for
+// up to ttl.
+type CacheBackend interface {
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	Delete(ctx context.Context, keys ...string) error
+}
+
+type CacheOptions struct {
+	// Backend stores the profiles. An LRUCache of Size entries when nil.
+	Backend CacheBackend
+	// TTL is how long a profile is served from the cache. Defaults to 1m.
+	TTL time.Duration
+	// Size bounds the number of profiles of the default backend. Defaults
+	// to 10000.
+	Size int
+}
+
+// CacheStats counts the profile reads served by CachedRepository.
+type CacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	// Errors counts the failures of the backend, and of the lookups of the
+	// users to invalidate. Profiles are then read from the repository, or
+	// served until they expire.
+	Errors uint64 `json:"errors"`
+}
+
+// CachedRepository decorates a repository with a cache of the profiles
+// returned by GetUserDataByUserID. Concurrent misses on a profile share one
+// read of the repository. Profiles are invalidated when UpdateUserData,
+// UpdateSuccessfulLogin or UpdatePassword change them, once their transaction
+// ends.
+//
+// The token versions are not read through the cache, see GetTokenVersion, a
+// stale profile can only show outdated data.
+//
+// The in-process default backend is for a single instance: updates made
+// through other instances are only seen once the TTL expires. Share a backend
+// such as Redis between several instances.
+type CachedRepository struct {
+	RepositoryInterface
+
+	backend CacheBackend
+	ttl     time.Duration
+	group   singleflight.Group
+
+	// generation is bumped by every invalidation, profiles read before it
+	// changed are not stored
+	generation atomic.Uint64
+
+	hits, misses, errors atomic.Uint64
+}
+
+// NewCachedRepository returns repo with its profile reads cached.
+func NewCachedRepository(repo RepositoryInterface, opts CacheOptions) *CachedRepository {
+	if opts.TTL <= 0 {
+		opts.TTL = defaultCacheTTL
+	}
+	if opts.Backend == nil {
+		if opts.Size <= 0 {
+			opts.Size = defaultCacheSize
+		}
+		opts.Backend = NewLRUCache(opts.Size)
+	}
+
+	return &CachedRepository{
+		RepositoryInterface: repo,
+		backend:             opts.Backend,
+		ttl:                 opts.TTL,
+	}
+}
+
+// Stats returns the counters of the profile reads.
+func (c *CachedRepository) Stats() CacheStats {
+	return CacheStats{
+		Hits:   c.hits.Load(),
+		Misses: c.misses.Load(),
+		Errors: c.errors.Load(),
+	}
+}
+
+func (c *CachedRepository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
+	key := userWriteKey(input.UserID)
+
+	value, ok, err := c.backend.Get(ctx, key)
+	if err != nil {
+		c.errors.Add(1)
+	}
+	if ok {
+		var user model.User
+		if err := json.Unmarshal(value, &user); err == nil {
+			c.hits.Add(1)
+			return user, nil
+		}
+		c.errors.Add(1)
+	}
+	c.misses.Add(1)
+
+	// The read is shared by the callers waiting for the same profile, it must
+	// not fail because the first of them gave up
+	loadCtx := context.WithoutCancel(ctx)
+	result := c.group.DoChan(key, func() (interface{}, error) {
+		generation := c.generation.Load()
+
+		user, err := c.RepositoryInterface.GetUserDataByUserID(loadCtx, input)
+		if err != nil {
+			return user, err
+		}
+
+		if value, err := json.Marshal(user); err == nil && c.generation.Load() == generation {
+			if err := c.backend.Set(loadCtx, key, value, c.ttl); err != nil {
+				c.errors.Add(1)
+			}
+
+			// An invalidation landing while the profile was stored may
+			// have deleted it before, it is deleted again
+			if c.generation.Load() != generation {
+				if err := c.backend.Delete(loadCtx, key); err != nil {
+					c.errors.Add(1)
+				}
+			}
+		}
+		return user, nil
+	})
+
+	select {
+	case <-ctx.Done():
+		return model.User{}, ctx.Err()
+	case res := <-result:
+		return res.Val.(model.User), res.Err
+	}
+}
+
+func (c *CachedRepository) UpdateUserData(ctx context.Context, in UpdateUserDataInput) (out UpdateUserDataOutput, err error) {
+	// The update may have been applied even when it failed, e.g. on a
+	// connection lost before the commit was acknowledged
+	defer c.invalidate(ctx, in.UserID)
+	return c.RepositoryInterface.UpdateUserData(ctx, in)
+}
+
+// UpdatePassword invalidates the profile, its token version is checked against
+// the access tokens.
+func (c *CachedRepository) UpdatePassword(ctx context.Context, in UpdatePasswordInput) error {
+	defer c.invalidate(ctx, in.UserID)
+	return c.RepositoryInterface.UpdatePassword(ctx, in)
+}
+
+func (c *CachedRepository) UpdateSuccessfulLogin(ctx context.Context, in UpdateSuccessfulLoginInput) error {
+	defer c.invalidate(ctx, in.UserID)
+	return c.RepositoryInterface.UpdateSuccessfulLogin(ctx, in)
+}
+
+// WithTx runs fn in a transaction of the decorated repository. Profiles are
+// read from the transaction, and the ones it updates are invalidated once it
+// ends.
+func (c *CachedRepository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	var updated []int32
+	defer func() { c.invalidate(ctx, updated...) }()
+
+	return c.RepositoryInterface.WithTx(ctx, func(repo RepositoryInterface) error {
+		return fn(&txCachedRepository{RepositoryInterface: repo, cache: c, updated: &updated})
+	})
+}
+
+func (c *CachedRepository) invalidate(ctx context.Context, userIDs ...int32) {
+	if len(userIDs) == 0 {
+		return
+	}
+
+	c.generation.Add(1)
+
+	keys := make([]string, len(userIDs))
+	for i, userID := range userIDs {
+		keys[i] = userWriteKey(userID)
+		c.group.Forget(keys[i])
+	}
+
+	// The profiles must go even when the caller gave up
+	if err := c.backend.Delete(context.WithoutCancel(ctx), keys...); err != nil {
+		c.errors.Add(1)
+	}
+}
+
+// txCachedRepository is handed to the WithTx callbacks of CachedRepository. It
+// bypasses the cache and notes the users updated.
+type txCachedRepository struct {
+	RepositoryInterface
+	cache   *CachedRepository
+	updated *[]int32
+}
+
+func (r *txCachedRepository) UpdateUserData(ctx context.Context, in UpdateUserDataInput) (out UpdateUserDataOutput, err error) {
+	*r.updated = append(*r.updated, in.UserID)
+	return r.RepositoryInterface.UpdateUserData(ctx, in)
+}
+
+func (r *txCachedRepository) UpdatePassword(ctx context.Context, in UpdatePasswordInput) error {
+	*r.updated = append(*r.updated, in.UserID)
+	return r.RepositoryInterface.UpdatePassword(ctx, in)
+}
+
+func (r *txCachedRepository) UpdateSuccessfulLogin(ctx context.Context, in UpdateSuccessfulLoginInput) error {
+	*r.updated = append(*r.updated, in.UserID)
+	return r.RepositoryInterface.UpdateSuccessfulLogin(ctx, in)
+}
+
+func (r *txCachedRepository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	return r.RepositoryInterface.WithTx(ctx, func(repo RepositoryInterface) error {
+		return fn(&txCachedRepository{RepositoryInterface: repo, cache: r.cache, updated: r.updated})
+	})
+}
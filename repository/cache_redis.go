@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const redisCacheKeyPrefix = "userservice:"
+
+// RedisCache is a CacheBackend on Redis, or any server speaking its protocol,
+// shared by the instances of the service. The profiles are stored decrypted,
+// the server must be protected like the database.
+type RedisCache struct {
+	Client redis.UniversalClient
+}
+
+// NewRedisCache connects to the server named by url, e.g.
+// redis://:password@localhost:6379/0, see redis.ParseURL.
+func NewRedisCache(url string) (*RedisCache, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parse redis url: %w", err)
+	}
+	return &RedisCache{Client: redis.NewClient(opts)}, nil
+}
+
+func (c *RedisCache) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, err := c.Client.Get(ctx, redisCacheKeyPrefix+key).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+func (c *RedisCache) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	return c.Client.Set(ctx, redisCacheKeyPrefix+key, value, ttl).Err()
+}
+
+func (c *RedisCache) Delete(ctx context.Context, keys ...string) error {
+	prefixed := make([]string, len(keys))
+	for i, key := range keys {
+		prefixed[i] = redisCacheKeyPrefix + key
+	}
+	return c.Client.Del(ctx, prefixed...).Err()
+}
+
+// Close closes the connections to the server.
+func (c *RedisCache) Close() error {
+	return c.Client.Close()
+}
@@ -0,0 +1,232 @@
+package repository
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachedRepository(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewMockRepositoryInterface(ctrl)
+	repo := NewCachedRepository(mock, CacheOptions{})
+
+	user := model.User{UserID: u.UserID, FullName: u.FullName, PhoneNumber: u.PhoneNumber, Version: 1}
+	getUser := func() (model.User, error) {
+		return repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+	}
+
+	// test 1 the profile is read once
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), GetUserDataByUserIDInput{UserID: u.UserID}).Return(user, nil)
+	for i := 0; i < 3; i++ {
+		got, err := getUser()
+		assert.NoError(t, err)
+		assert.Equal(t, user, got)
+	}
+	assert.Equal(t, CacheStats{Hits: 2, Misses: 1}, repo.Stats())
+
+	// test 2 an update invalidates the profile
+	patch := UserPatch{FullName: &u.FullName}
+	mock.EXPECT().UpdateUserData(gomock.Any(), UpdateUserDataInput{UserID: u.UserID, Patch: patch}).Return(UpdateUserDataOutput{Version: 2}, nil)
+	_, err := repo.UpdateUserData(context.Background(), UpdateUserDataInput{UserID: u.UserID, Patch: patch})
+	assert.NoError(t, err)
+
+	user.Version = 2
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(user, nil)
+	got, err := getUser()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), got.Version)
+
+	// test 3 a successful login invalidates the profile of its user
+	login := UpdateSuccessfulLoginInput{PhoneNumber: u.PhoneNumber, UserID: u.UserID}
+	mock.EXPECT().UpdateSuccessfulLogin(gomock.Any(), login).Return(nil)
+	assert.NoError(t, repo.UpdateSuccessfulLogin(context.Background(), login))
+
+	user.SuccesfulLogin = 1
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(user, nil)
+	got, err = getUser()
+	assert.NoError(t, err)
+	assert.Equal(t, int32(1), got.SuccesfulLogin)
+
+	// test 4 updates in a transaction invalidate the profile once it ends
+	mock.EXPECT().WithTx(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, fn func(RepositoryInterface) error) error {
+		return fn(mock)
+	})
+	mock.EXPECT().UpdateUserData(gomock.Any(), gomock.Any()).Return(UpdateUserDataOutput{Version: 3}, nil)
+	err = repo.WithTx(context.Background(), func(txRepo RepositoryInterface) error {
+		_, err := txRepo.UpdateUserData(context.Background(), UpdateUserDataInput{UserID: u.UserID, Patch: patch})
+		return err
+	})
+	assert.NoError(t, err)
+
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(user, nil)
+	_, err = getUser()
+	assert.NoError(t, err)
+
+	// test 5 errors are not cached
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), GetUserDataByUserIDInput{UserID: 2}).Return(model.User{}, ErrNotFound).Times(2)
+	for i := 0; i < 2; i++ {
+		_, err = repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: 2})
+		assert.ErrorIs(t, err, ErrNotFound)
+	}
+}
+
+func TestCachedRepositorySingleflight(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewMockRepositoryInterface(ctrl)
+	repo := NewCachedRepository(mock, CacheOptions{})
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).DoAndReturn(func(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
+		close(started)
+		<-release
+		return model.User{UserID: input.UserID}, nil
+	})
+
+	var wg sync.WaitGroup
+	read := func() {
+		defer wg.Done()
+		user, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+		assert.NoError(t, err)
+		assert.Equal(t, u.UserID, user.UserID)
+	}
+
+	// Callers arriving after the read either share it or find its result
+	wg.Add(1)
+	go read()
+	<-started
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go read()
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	// test a caller giving up does not fail the others
+	started = make(chan struct{})
+	release = make(chan struct{})
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), GetUserDataByUserIDInput{UserID: 2}).DoAndReturn(func(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
+		close(started)
+		<-release
+		return model.User{UserID: input.UserID}, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+		close(release)
+	}()
+	_, err := repo.GetUserDataByUserID(ctx, GetUserDataByUserIDInput{UserID: 2})
+	assert.ErrorIs(t, err, context.Canceled)
+
+	user, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: 2})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), user.UserID)
+}
+
+// invalidatingBackend runs invalidate while a profile is being stored, once.
+type invalidatingBackend struct {
+	CacheBackend
+	invalidate func()
+}
+
+func (b *invalidatingBackend) Set(ctx context.Context, key string, value []byte, ttl time.Duration) error {
+	if invalidate := b.invalidate; invalidate != nil {
+		b.invalidate = nil
+		invalidate()
+	}
+	return b.CacheBackend.Set(ctx, key, value, ttl)
+}
+
+func TestCachedRepositoryInvalidationWhileStoring(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewMockRepositoryInterface(ctrl)
+	backend := &invalidatingBackend{CacheBackend: NewLRUCache(10)}
+	repo := NewCachedRepository(mock, CacheOptions{Backend: backend})
+
+	// The profile read before the invalidation must not be served after it
+	backend.invalidate = func() { repo.invalidate(context.Background(), u.UserID) }
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{UserID: u.UserID, Version: 1}, nil)
+	_, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+	assert.NoError(t, err)
+
+	mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{UserID: u.UserID, Version: 2}, nil)
+	user, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), user.Version)
+}
+
+func TestLRUCache(t *testing.T) {
+	ctx := context.Background()
+	cache := NewLRUCache(2)
+
+	// test 1 the least recently used entry is evicted
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Minute))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2"), time.Minute))
+	_, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+	require.NoError(t, cache.Set(ctx, "c", []byte("3"), time.Minute))
+
+	_, ok, _ = cache.Get(ctx, "b")
+	assert.False(t, ok)
+	value, ok, _ := cache.Get(ctx, "a")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("1"), value)
+	assert.Equal(t, 2, cache.Len())
+
+	// test 2 expired entries are not served
+	require.NoError(t, cache.Set(ctx, "a", []byte("1"), time.Nanosecond))
+	time.Sleep(time.Millisecond)
+	_, ok, _ = cache.Get(ctx, "a")
+	assert.False(t, ok)
+	assert.Equal(t, 1, cache.Len())
+
+	// test 3 delete
+	require.NoError(t, cache.Delete(ctx, "c", "unknown"))
+	assert.Equal(t, 0, cache.Len())
+}
+
+func TestRedisCache(t *testing.T) {
+	ctx := context.Background()
+	server := miniredis.RunT(t)
+
+	cache, err := NewRedisCache("redis://" + server.Addr())
+	require.NoError(t, err)
+	defer cache.Close()
+
+	// test 1 round trip
+	require.NoError(t, cache.Set(ctx, "user:1", []byte("profile"), time.Minute))
+	value, ok, err := cache.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("profile"), value)
+	assert.True(t, server.Exists("userservice:user:1"))
+
+	// test 2 expiry
+	server.FastForward(time.Minute)
+	_, ok, err = cache.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// test 3 delete
+	require.NoError(t, cache.Set(ctx, "user:1", []byte("profile"), time.Minute))
+	require.NoError(t, cache.Delete(ctx, "user:1"))
+	_, ok, err = cache.Get(ctx, "user:1")
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// test 4 an unreachable server is reported
+	server.Close()
+	_, _, err = cache.Get(ctx, "user:1")
+	assert.Error(t, err)
+}
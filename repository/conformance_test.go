@@ -0,0 +1,76 @@
+package repository_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/encryption"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/repositorytest"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		return repository.NewMemoryRepository()
+	})
+}
+
+func TestCachedRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		return repository.NewCachedRepository(repository.NewMemoryRepository(), repository.CacheOptions{})
+	})
+}
+
+// TestRepositoryConformance runs the suite against the Postgres database at
+// TEST_DATABASE_URL, created from database.sql. Its tables are emptied before
+// every test, so never point it at a database holding real data.
+func TestRepositoryConformance(t *testing.T) {
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	repo, err := repository.NewPostgresRepository(repository.NewRepositoryOptions{Dsn: dsn})
+	require.NoError(t, err)
+	t.Cleanup(func() { repo.Db.Close() })
+
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		_, err := repo.Db.Exec("TRUNCATE users, password_history, audit_events, outbox_events, webhook_subscriptions, webhook_deliveries, idempotency_keys RESTART IDENTITY")
+		require.NoError(t, err)
+		return repo
+	})
+}
+
+func TestSQLiteRepositoryConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		repo, err := repository.NewSQLiteRepository(repository.NewRepositoryOptions{
+			Dsn:          "sqlite://" + t.TempDir() + "/users.db",
+			TxMaxRetries: 3,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Db.Close() })
+		return repo
+	})
+}
+
+// TestEncryptedSQLiteRepositoryConformance runs the suite with the personal
+// data encrypted, bound to the row it is stored in.
+func TestEncryptedSQLiteRepositoryConformance(t *testing.T) {
+	provider, err := encryption.ParseKeyFile(strings.NewReader("test MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"))
+	require.NoError(t, err)
+	cipher, err := encryption.NewCipher(provider, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	repositorytest.Run(t, func(t *testing.T) repository.RepositoryInterface {
+		repo, err := repository.NewSQLiteRepository(repository.NewRepositoryOptions{
+			Dsn:          "sqlite://" + t.TempDir() + "/users.db",
+			TxMaxRetries: 3,
+			Cipher:       cipher,
+		})
+		require.NoError(t, err)
+		t.Cleanup(func() { repo.Db.Close() })
+		return repo
+	})
+}
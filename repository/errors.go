@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+)
+
+// The errors returned by the repositories wrap one of these, whatever the
+// database, so callers check them with errors.Is and never with driver types.
+// The driver error stays in the chain for logging.
+var (
+	// ErrNotFound is returned when the row read or written does not exist.
+	ErrNotFound = errors.New("not found")
+
+	// ErrDuplicatePhone is returned when the phone number is already
+	// registered to another user.
+	ErrDuplicatePhone = errors.New("phone number already registered")
+
+	// ErrConflict is returned when a write conflicts with the stored data,
+	// e.g. a duplicate key other than the phone number.
+	ErrConflict = errors.New("conflict with stored data")
+
+	// ErrTransient is returned when the operation failed because of the
+	// database and not of its input, e.g. a lost connection or a
+	// serialization failure. It may succeed when tried again later.
+	ErrTransient = errors.New("transient database failure")
+)
+
+// ErrVersionConflict is returned by UpdateUserData and UpdatePassword when the
+// expected version no longer matches the stored one, i.e. someone else updated
+// the user first.
+// It is an ErrConflict.
+var ErrVersionConflict = fmt.Errorf("user data has been modified: %w", ErrConflict)
+
+const phoneNumberConstraint = "users_phone_number_index_key"
+
+// translateError wraps err in the domain error it stands for. Errors of the
+// database driver are translated by driverError, which returns them as is
+// when they have no domain meaning.
+func translateError(err error, driverError func(error) error) error {
+	switch {
+	case err == nil,
+		errors.Is(err, ErrNotFound),
+		errors.Is(err, ErrDuplicatePhone),
+		errors.Is(err, ErrConflict),
+		errors.Is(err, ErrTransient):
+		return err
+	case errors.Is(err, sql.ErrNoRows):
+		return fmt.Errorf("%w: %w", ErrNotFound, err)
+	case errors.Is(err, sql.ErrConnDone),
+		errors.Is(err, driver.ErrBadConn),
+		errors.Is(err, context.DeadlineExceeded):
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+
+	return driverError(err)
+}
+
+// uniqueViolation returns the error for a write violating the unique
+// constraint, named as in Postgres.
+func uniqueViolation(constraint string, err error) error {
+	if constraint == phoneNumberConstraint {
+		return fmt.Errorf("%w: %w", ErrDuplicatePhone, err)
+	}
+	return fmt.Errorf("%w: %w", ErrConflict, err)
+}
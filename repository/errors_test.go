@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"testing"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTranslatePostgresError(t *testing.T) {
+	var tests = []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"duplicate phone number", &pgconn.PgError{Code: "23505", ConstraintName: "users_phone_number_index_key"}, ErrDuplicatePhone},
+		{"other duplicate key", &pgconn.PgError{Code: "23505", ConstraintName: "webhook_deliveries_pkey"}, ErrConflict},
+		{"foreign key", &pgconn.PgError{Code: "23503"}, ErrConflict},
+		{"serialization failure", &pgconn.PgError{Code: "40001"}, ErrTransient},
+		{"connection failure", &pgconn.PgError{Code: "08006"}, ErrTransient},
+		{"bad connection", driver.ErrBadConn, ErrTransient},
+		{"deadline", fmt.Errorf("query: %w", context.DeadlineExceeded), ErrTransient},
+		{"already translated", ErrVersionConflict, ErrVersionConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.err
+			translatePostgresError(&err)
+			assert.ErrorIs(t, err, tt.want)
+			assert.ErrorIs(t, err, tt.err, "the driver error is kept in the chain")
+		})
+	}
+
+	// test nil and errors without a domain meaning are returned as is
+	var err error
+	translatePostgresError(&err)
+	assert.NoError(t, err)
+
+	syntaxErr := &pgconn.PgError{Code: "42601"}
+	err = syntaxErr
+	translatePostgresError(&err)
+	assert.Same(t, syntaxErr, err)
+}
+
+func TestTranslateSQLiteError(t *testing.T) {
+	ctx := context.Background()
+	path := "file:" + t.TempDir() + "/errors.db"
+	db, err := sql.Open(sqliteDriverName, path+"?_pragma=foreign_keys(1)&_txlock=immediate")
+	require.NoError(t, err)
+	defer db.Close()
+
+	_, err = db.Exec("CREATE TABLE parents (id INTEGER PRIMARY KEY); CREATE TABLE children (parent_id INTEGER REFERENCES parents (id))")
+	require.NoError(t, err)
+	_, err = db.Exec("INSERT INTO parents (id) VALUES (1)")
+	require.NoError(t, err)
+
+	// The errors are raised by the driver, its error type cannot be built
+	_, duplicateErr := db.Exec("INSERT INTO parents (id) VALUES (1)")
+	_, foreignKeyErr := db.Exec("INSERT INTO children (parent_id) VALUES (2)")
+
+	// Without a busy timeout, a writer fails at once while another holds the
+	// write lock
+	lock, err := db.BeginTx(ctx, nil)
+	require.NoError(t, err)
+	defer lock.Rollback()
+	other, err := sql.Open(sqliteDriverName, path)
+	require.NoError(t, err)
+	defer other.Close()
+	_, busyErr := other.Exec("INSERT INTO parents (id) VALUES (3)")
+
+	var tests = []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"no rows", sql.ErrNoRows, ErrNotFound},
+		{"duplicate key", duplicateErr, ErrConflict},
+		{"foreign key", foreignKeyErr, ErrConflict},
+		{"busy", busyErr, ErrTransient},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Error(t, tt.err)
+			err := tt.err
+			translateSQLiteError(&err)
+			assert.ErrorIs(t, err, tt.want)
+		})
+	}
+}
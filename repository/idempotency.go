@@ -0,0 +1,180 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+const (
+	defaultIdempotencyTTL         = 24 * time.Hour
+	defaultIdempotencyLease       = time.Minute
+	defaultPurgeLimit             = 1000
+	idempotencyReserveMaxAttempts = 3
+)
+
+const idempotencyRecordColumns = "caller, idempotency_key, fingerprint, status_code, header, body, created_at, expires_at"
+
+// rowScanner is implemented by both *sql.Row and *sql.Rows.
+type rowScanner interface {
+	Scan(dest ...interface{}) error
+}
+
+func (input *ReserveIdempotencyKeyInput) applyDefaults() {
+	if input.TTL <= 0 {
+		input.TTL = defaultIdempotencyTTL
+	}
+	if input.Lease <= 0 {
+		input.Lease = defaultIdempotencyLease
+	}
+}
+
+// ReserveIdempotencyKey hands the key to the caller, unless a record holds it:
+// one that has not expired, whose first request is completed or still within
+// its lease. The record is returned instead, the caller then compares its
+// fingerprint and replays its response.
+func (r *Repository) ReserveIdempotencyKey(ctx context.Context, input ReserveIdempotencyKeyInput) (output ReserveIdempotencyKeyOutput, err error) {
+	defer translatePostgresError(&err)
+
+	input.applyDefaults()
+
+	// The record found may be released before it is read, the key is then
+	// reserved again
+	for attempt := 0; attempt < idempotencyReserveMaxAttempts; attempt++ {
+		var res sql.Result
+		res, err = r.conn().ExecContext(
+			ctx,
+			`INSERT INTO idempotency_keys (caller, idempotency_key, fingerprint, locked_until, expires_at)
+			VALUES ($1, $2, $3, NOW() + $4 * INTERVAL '1 millisecond', NOW() + $5 * INTERVAL '1 millisecond')
+			ON CONFLICT (caller, idempotency_key) DO UPDATE SET
+				fingerprint = EXCLUDED.fingerprint, status_code = 0, header = '{}', body = NULL,
+				created_at = NOW(), locked_until = EXCLUDED.locked_until, expires_at = EXCLUDED.expires_at
+			WHERE idempotency_keys.expires_at <= NOW()
+				OR (idempotency_keys.status_code = 0 AND idempotency_keys.locked_until <= NOW())`,
+			input.Caller,
+			input.Key,
+			input.Fingerprint,
+			input.Lease.Milliseconds(),
+			input.TTL.Milliseconds(),
+		)
+		if err != nil {
+			return output, err
+		}
+
+		if err = requireAffected(res); err == nil {
+			output.Reserved = true
+			return output, nil
+		}
+
+		output.Record, err = scanIdempotencyRecord(r.conn().QueryRowContext(
+			ctx,
+			"SELECT "+idempotencyRecordColumns+" FROM idempotency_keys WHERE caller = $1 AND idempotency_key = $2",
+			input.Caller,
+			input.Key,
+		))
+		if err == nil {
+			err = decryptIdempotencyBody(ctx, r.cipher(), &output.Record)
+		}
+		if !errors.Is(translateError(err, postgresDriverError), ErrNotFound) {
+			return output, err
+		}
+	}
+
+	return output, ErrConflict
+}
+
+// CompleteIdempotencyKey stores the response to the first request. It returns
+// ErrNotFound when the caller no longer holds the key.
+func (r *Repository) CompleteIdempotencyKey(ctx context.Context, input CompleteIdempotencyKeyInput) (err error) {
+	defer translatePostgresError(&err)
+
+	header, err := json.Marshal(input.Header)
+	if err != nil {
+		return
+	}
+
+	// The response may hold the personal data of a user
+	body, err := encryptIdempotencyBody(ctx, r.cipher(), input.Caller, input.Key, input.Body)
+	if err != nil {
+		return
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE idempotency_keys SET status_code = $3, header = $4, body = $5 WHERE caller = $1 AND idempotency_key = $2 AND status_code = 0",
+		input.Caller,
+		input.Key,
+		input.StatusCode,
+		header,
+		body,
+	)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+// ReleaseIdempotencyKey forgets a key whose first request failed, so it can be
+// tried again.
+func (r *Repository) ReleaseIdempotencyKey(ctx context.Context, input ReleaseIdempotencyKeyInput) (err error) {
+	defer translatePostgresError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"DELETE FROM idempotency_keys WHERE caller = $1 AND idempotency_key = $2 AND status_code = 0",
+		input.Caller,
+		input.Key,
+	)
+	return
+}
+
+// DeleteExpiredIdempotencyKeys deletes up to input.Limit expired records, 1000
+// when not positive.
+func (r *Repository) DeleteExpiredIdempotencyKeys(ctx context.Context, input DeleteExpiredIdempotencyKeysInput) (deleted int64, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		`DELETE FROM idempotency_keys WHERE (caller, idempotency_key) IN (
+			SELECT caller, idempotency_key FROM idempotency_keys WHERE expires_at <= NOW() LIMIT $1
+		)`,
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
+
+func scanIdempotencyRecord(row rowScanner) (record model.IdempotencyRecord, err error) {
+	var header []byte
+
+	err = row.Scan(
+		&record.Caller,
+		&record.Key,
+		&record.Fingerprint,
+		&record.StatusCode,
+		&header,
+		&record.Body,
+		&record.CreatedAt,
+		&record.ExpiresAt,
+	)
+	if err != nil {
+		return model.IdempotencyRecord{}, err
+	}
+
+	if err = json.Unmarshal(header, &record.Header); err != nil {
+		return model.IdempotencyRecord{}, err
+	}
+	return record, nil
+}
@@ -0,0 +1,86 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReserveIdempotencyKey(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	input := ReserveIdempotencyKeyInput{Caller: "user:1", Key: "key", Fingerprint: "fingerprint"}
+	insert := "INSERT INTO idempotency_keys"
+	query := "SELECT caller, idempotency_key, fingerprint, status_code, header, body, created_at, expires_at FROM idempotency_keys WHERE caller = \\$1 AND idempotency_key = \\$2"
+	columns := []string{"caller", "idempotency_key", "fingerprint", "status_code", "header", "body", "created_at", "expires_at"}
+	now := time.Now()
+
+	// test 1 reserved with the default lease and TTL
+	mock.ExpectExec(insert).
+		WithArgs(input.Caller, input.Key, input.Fingerprint, int64(60000), int64(86400000)).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	out, err := repo.ReserveIdempotencyKey(context.Background(), input)
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 2 the record holding the key is returned
+	mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(query).WithArgs(input.Caller, input.Key).WillReturnRows(sqlmock.NewRows(columns).
+		AddRow(input.Caller, input.Key, input.Fingerprint, 201, []byte(`{"Content-Type":"application/json"}`), []byte(`{"id":1}`), now, now.Add(time.Hour)))
+
+	out, err = repo.ReserveIdempotencyKey(context.Background(), input)
+	require.NoError(t, err)
+	assert.False(t, out.Reserved)
+	assert.Equal(t, int32(201), out.Record.StatusCode)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, out.Record.Header)
+	assert.Equal(t, `{"id":1}`, string(out.Record.Body))
+
+	// test 3 the key is reserved again when the record is released meanwhile
+	mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectQuery(query).WithArgs(input.Caller, input.Key).WillReturnError(sql.ErrNoRows)
+	mock.ExpectExec(insert).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	out, err = repo.ReserveIdempotencyKey(context.Background(), input)
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestCompleteIdempotencyKey(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE idempotency_keys SET status_code = \\$3, header = \\$4, body = \\$5 WHERE caller = \\$1 AND idempotency_key = \\$2 AND status_code = 0"
+	input := CompleteIdempotencyKeyInput{Caller: "user:1", Key: "key", StatusCode: 201, Header: map[string]string{"ETag": `"1"`}, Body: []byte("{}")}
+
+	// test 1 complete success
+	mock.ExpectExec(query).
+		WithArgs(input.Caller, input.Key, input.StatusCode, []byte(`{"ETag":"\"1\""}`), input.Body).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.CompleteIdempotencyKey(context.Background(), input)
+	assert.NoError(t, err)
+
+	// test 2 the key is no longer held
+	mock.ExpectExec(query).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.CompleteIdempotencyKey(context.Background(), input)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// test 3 the response is stored encrypted, bound to the key
+	repo.Cipher = testCipher{}
+	mock.ExpectExec(query).
+		WithArgs(input.Caller, input.Key, input.StatusCode, []byte(`{"ETag":"\"1\""}`), []byte(sealed("{}", "idempotency_keys.body:user:1:key"))).
+		WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.CompleteIdempotencyKey(context.Background(), input)
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
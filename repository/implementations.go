@@ -2,90 +2,196 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
 	"fmt"
-	"strings"
 
 	"github.com/SawitProRecruitment/UserService/model"
 )
 
 func (r *Repository) InsertUser(ctx context.Context, input InsertUserInput) (output InsertUserOutput, err error) {
-	err = r.Db.QueryRowContext(
-		ctx,
-		"INSERT INTO users (phone_number, full_name, password) VALUES ($1, $2, $3) RETURNING id",
-		input.PhoneNumber,
-		input.FullName,
-		input.Password,
-	).Scan(&output.UserID)
+	defer translatePostgresError(&err)
+
+	err = r.inTx(ctx, func(txRepo *Repository) error {
+		// The id is drawn first, the personal data is encrypted bound to it
+		err := txRepo.conn().QueryRowContext(ctx, "SELECT nextval(pg_get_serial_sequence('users', 'id'))").Scan(&output.UserID)
+		if err != nil {
+			return err
+		}
+
+		phoneNumber, fullName := input.PhoneNumber, input.FullName
+		if err = txRepo.encrypt(ctx, output.UserID, &phoneNumber, &fullName); err != nil {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO users (id, phone_number, phone_number_index, full_name, password, preferred_language) VALUES ($1, $2, $3, $4, $5, $6)",
+			output.UserID,
+			phoneNumber,
+			r.cipher().BlindIndex(input.PhoneNumber),
+			fullName,
+			input.Password,
+			input.PreferredLanguage,
+		)
+		if err != nil {
+			return err
+		}
+
+		return txRepo.insertOutboxEvent(ctx, model.EventUserRegistered, output.UserID, model.UserEventPayload{UserID: output.UserID})
+	})
 	if err != nil {
-		return
+		return InsertUserOutput{}, err
 	}
+
+	r.Replicas.recordWrite(userWriteKey(output.UserID))
 	return
 }
 
 func (r *Repository) GetLoginData(ctx context.Context, input GetLoginDataInput) (output GetLoginDataOutput, err error) {
-	err = r.Db.QueryRowContext(
+	defer translatePostgresError(&err)
+
+	// Credentials are always read from the primary: a lagging replica could
+	// still accept a password or token version that was just changed.
+	err = r.conn().QueryRowContext(
 		ctx,
-		"SELECT id, full_name, password FROM users WHERE phone_number = $1",
-		input.PhoneNumber,
-	).Scan(&output.UserID, &output.FullName, &output.HashedPassword)
+		"SELECT id, full_name, password, preferred_language, token_version FROM users WHERE phone_number_index = $1",
+		r.cipher().BlindIndex(input.PhoneNumber),
+	).Scan(&output.UserID, &output.FullName, &output.HashedPassword, &output.PreferredLanguage, &output.TokenVersion)
 	if err != nil {
 		return
 	}
+
+	err = r.decrypt(ctx, output.UserID, nil, &output.FullName)
 	return
 }
 
 func (r *Repository) UpdateSuccessfulLogin(ctx context.Context, input UpdateSuccessfulLoginInput) (err error) {
-	_, err = r.Db.ExecContext(
-		ctx,
-		"UPDATE users SET successful_login = successful_login + 1 WHERE phone_number = $1",
-		input.PhoneNumber,
-	)
+	defer translatePostgresError(&err)
+
+	query, args, err := newUpdateBuilder("users").
+		SetExpr("successful_login", "successful_login + 1").
+		Where("phone_number_index", r.cipher().BlindIndex(input.PhoneNumber)).
+		Build()
+	if err != nil {
+		return
+	}
+
+	_, err = r.conn().ExecContext(ctx, query, args...)
 	if err != nil {
 		return
 	}
 	return
 }
 
-func (r *Repository) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (err error) {
-	if len(input.Data) == 0 {
-		return fmt.Errorf("update user data is empty")
+func (r *Repository) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (out UpdateUserDataOutput, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Patch.IsEmpty() {
+		err = fmt.Errorf("update user data is empty")
+		return
 	}
 
-	var (
-		query    = "UPDATE users SET %s WHERE id = $1"
-		setQuery = []string{}
-	)
+	builder := newUpdateBuilder("users")
+	if input.Patch.FullName != nil {
+		fullName := *input.Patch.FullName
+		if err = r.encrypt(ctx, input.UserID, nil, &fullName); err != nil {
+			return
+		}
+		builder.Set("full_name", fullName)
+	}
+	if input.Patch.PhoneNumber != nil {
+		phoneNumber := *input.Patch.PhoneNumber
+		if err = r.encrypt(ctx, input.UserID, &phoneNumber, nil); err != nil {
+			return
+		}
+		builder.Set("phone_number", phoneNumber).
+			Set("phone_number_index", r.cipher().BlindIndex(*input.Patch.PhoneNumber))
+	}
+	if input.Patch.PreferredLanguage != nil {
+		builder.Set("preferred_language", *input.Patch.PreferredLanguage)
+	}
 
-	if fullName, ok := input.Data["full_name"]; ok {
-		setQuery = append(setQuery, fmt.Sprintf("full_name = '%s'", fullName))
+	builder.SetExpr("version", "version + 1").Where("id", input.UserID)
+	if input.ExpectedVersion != nil {
+		builder.Where("version", *input.ExpectedVersion)
 	}
 
-	if phoneNumber, ok := input.Data["phone_number"]; ok {
-		setQuery = append(setQuery, fmt.Sprintf("phone_number = '%s'", phoneNumber))
+	query, args, err := builder.Returning("version").Build()
+	if err != nil {
+		return
 	}
 
-	query = fmt.Sprintf(query, strings.Join(setQuery, ","))
+	err = r.inTx(ctx, func(txRepo *Repository) error {
+		var old model.User
+		err := txRepo.conn().QueryRowContext(
+			ctx,
+			"SELECT full_name, phone_number FROM users WHERE id = $1 FOR UPDATE",
+			input.UserID,
+		).Scan(&old.FullName, &old.PhoneNumber)
+		if err != nil {
+			return err
+		}
+		if err = txRepo.decrypt(ctx, input.UserID, &old.PhoneNumber, &old.FullName); err != nil {
+			return err
+		}
 
-	_, err = r.Db.ExecContext(
-		ctx,
-		query,
-		input.UserID,
-	)
+		err = txRepo.conn().QueryRowContext(ctx, query, args...).Scan(&out.Version)
+		if errors.Is(err, sql.ErrNoRows) {
+			return ErrVersionConflict
+		}
+		if err != nil {
+			return err
+		}
+
+		if fullName := input.Patch.FullName; fullName != nil && *fullName != old.FullName {
+			err = txRepo.insertOutboxEvent(ctx, model.EventFullNameChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		if phoneNumber := input.Patch.PhoneNumber; phoneNumber != nil && *phoneNumber != old.PhoneNumber {
+			err = txRepo.insertOutboxEvent(ctx, model.EventPhoneNumberChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
 	if err != nil {
 		return
 	}
 
+	r.Replicas.recordWrite(userWriteKey(input.UserID))
+	return
+}
+
+// GetTokenVersion returns the version of the access tokens of the user, read
+// from the primary: a token revoked there must not validate on a lagging
+// replica.
+func (r *Repository) GetTokenVersion(ctx context.Context, input GetTokenVersionInput) (tokenVersion int32, err error) {
+	defer translatePostgresError(&err)
+
+	err = r.conn().QueryRowContext(ctx, "SELECT token_version FROM users WHERE id = $1", input.UserID).Scan(&tokenVersion)
 	return
 }
 
 func (r *Repository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (out model.User, err error) {
-	err = r.Db.QueryRowContext(
-		ctx,
-		"SELECT id, full_name, phone_number, successful_login FROM users WHERE id = $1",
-		input.UserID,
-	).Scan(&out.UserID, &out.FullName, &out.PhoneNumber, &out.SuccesfulLogin)
+	defer translatePostgresError(&err)
+
+	err = r.read(ctx, []string{userWriteKey(input.UserID)}, func(conn dbConn) error {
+		return conn.QueryRowContext(
+			ctx,
+			"SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = $1",
+			input.UserID,
+		).Scan(&out.UserID, &out.FullName, &out.PhoneNumber, &out.SuccesfulLogin, &out.Version, &out.PreferredLanguage, &out.TokenVersion)
+	})
 	if err != nil {
 		return
 	}
+
+	err = r.decrypt(ctx, out.UserID, &out.PhoneNumber, &out.FullName)
 	return
 }
@@ -3,7 +3,6 @@ package repository
 import (
 	"context"
 	"database/sql"
-	"fmt"
 	"log"
 	"testing"
 
@@ -31,26 +30,37 @@ var u = &model.User{
 
 func TestInsertUser(t *testing.T) {
 	db, mock := NewMock()
-	repo := &Repository{db}
+	repo := &Repository{Db: db}
 
-	query := "INSERT INTO users \\(phone_number, full_name, password\\) VALUES \\(\\$1, \\$2, \\$3\\) RETURNING id"
+	idQuery := "SELECT nextval\\(pg_get_serial_sequence\\('users', 'id'\\)\\)"
+	query := "INSERT INTO users \\(id, phone_number, phone_number_index, full_name, password, preferred_language\\) VALUES \\(\\$1, \\$2, \\$3, \\$4, \\$5, \\$6\\)"
+	outboxQuery := "INSERT INTO outbox_events \\(event_type, aggregate_id, payload\\) VALUES \\(\\$1, \\$2, \\$3\\)"
 
-	rows := sqlmock.NewRows([]string{"id"}).
-		AddRow(u.UserID)
+	idRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"nextval"}).AddRow(u.UserID)
+	}
 
 	// test 1 insert success
-	mock.ExpectQuery(query).WithArgs(u.PhoneNumber, u.FullName, u.Password).WillReturnRows(rows)
+	mock.ExpectBegin()
+	mock.ExpectQuery(idQuery).WillReturnRows(idRows())
+	mock.ExpectExec(query).WithArgs(u.UserID, u.PhoneNumber, u.PhoneNumber, u.FullName, u.Password, "id").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventUserRegistered, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
 
 	user, err := repo.InsertUser(context.Background(), InsertUserInput{
-		PhoneNumber: u.PhoneNumber,
-		FullName:    u.FullName,
-		Password:    u.Password,
+		PhoneNumber:       u.PhoneNumber,
+		FullName:          u.FullName,
+		Password:          u.Password,
+		PreferredLanguage: "id",
 	})
 	assert.NotNil(t, user)
 	assert.NoError(t, err)
 
-	// test 2 insert success
-	mock.ExpectQuery(query).WithArgs(u.PhoneNumber, u.FullName, u.Password).WillReturnError(sql.ErrConnDone)
+	// test 2 insert error
+	mock.ExpectBegin()
+	mock.ExpectQuery(idQuery).WillReturnRows(idRows())
+	mock.ExpectExec(query).WithArgs(u.UserID, u.PhoneNumber, u.PhoneNumber, u.FullName, u.Password, "").WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
 
 	userError, err := repo.InsertUser(context.Background(), InsertUserInput{
 		PhoneNumber: u.PhoneNumber,
@@ -59,16 +69,32 @@ func TestInsertUser(t *testing.T) {
 	})
 	assert.Empty(t, userError)
 	assert.Error(t, err)
+
+	// test 3 outbox error rolls back the user
+	mock.ExpectBegin()
+	mock.ExpectQuery(idQuery).WillReturnRows(idRows())
+	mock.ExpectExec(query).WithArgs(u.UserID, u.PhoneNumber, u.PhoneNumber, u.FullName, u.Password, "").WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(outboxQuery).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	userError, err = repo.InsertUser(context.Background(), InsertUserInput{
+		PhoneNumber: u.PhoneNumber,
+		FullName:    u.FullName,
+		Password:    u.Password,
+	})
+	assert.Empty(t, userError)
+	assert.Error(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
 
 func TestGetLoginData(t *testing.T) {
 	db, mock := NewMock()
-	repo := &Repository{db}
+	repo := &Repository{Db: db}
 
-	query := "SELECT id, full_name, password FROM users WHERE phone_number = \\$1"
+	query := "SELECT id, full_name, password, preferred_language, token_version FROM users WHERE phone_number_index = \\$1"
 
-	rows := sqlmock.NewRows([]string{"id", "full_name", "password"}).
-		AddRow(u.UserID, u.FullName, u.Password)
+	rows := sqlmock.NewRows([]string{"id", "full_name", "password", "preferred_language", "token_version"}).
+		AddRow(u.UserID, u.FullName, u.Password, "id", 0)
 
 	// test 1 get success
 	mock.ExpectQuery(query).WithArgs(u.PhoneNumber).WillReturnRows(rows)
@@ -78,6 +104,7 @@ func TestGetLoginData(t *testing.T) {
 	})
 	assert.NotNil(t, users)
 	assert.NoError(t, err)
+	assert.Equal(t, "id", users.PreferredLanguage)
 
 	// test 2 get error
 	mock.ExpectQuery(query).WithArgs(u.PhoneNumber).WillReturnError(sql.ErrConnDone)
@@ -91,9 +118,9 @@ func TestGetLoginData(t *testing.T) {
 
 func TestUpdateSuccessfulLogin(t *testing.T) {
 	db, mock := NewMock()
-	repo := &Repository{db}
+	repo := &Repository{Db: db}
 
-	query := "UPDATE users SET successful_login = successful_login \\+ 1 WHERE phone_number = \\$1"
+	query := "UPDATE users SET successful_login = successful_login \\+ 1, updated_at = NOW\\(\\) WHERE phone_number_index = \\$1"
 
 	// test 1 update success
 	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnResult(sqlmock.NewResult(0, 0))
@@ -114,12 +141,12 @@ func TestUpdateSuccessfulLogin(t *testing.T) {
 
 func TestGetUserDataByUserID(t *testing.T) {
 	db, mock := NewMock()
-	repo := &Repository{db}
+	repo := &Repository{Db: db}
 
-	query := "SELECT id, full_name, phone_number, successful_login FROM users WHERE id = \\$1"
+	query := "SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = \\$1"
 
-	rows := sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login"}).
-		AddRow(u.UserID, u.FullName, u.PhoneNumber, u.SuccesfulLogin)
+	rows := sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login", "version", "preferred_language", "token_version"}).
+		AddRow(u.UserID, u.FullName, u.PhoneNumber, u.SuccesfulLogin, 1, "", 0)
 
 	// test 1 get success
 	mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows)
@@ -140,61 +167,165 @@ func TestGetUserDataByUserID(t *testing.T) {
 	assert.Error(t, err)
 }
 
+func TestGetTokenVersion(t *testing.T) {
+	db, mock := NewMock()
+	replica, replicaMock := NewMock()
+	repo := &Repository{Db: db, Replicas: NewReplicaSet([]*sql.DB{replica}, 0)}
+
+	query := "SELECT token_version FROM users WHERE id = \\$1"
+
+	// test 1 read from the primary even with a replica
+	mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(2))
+
+	tokenVersion, err := repo.GetTokenVersion(context.Background(), GetTokenVersionInput{UserID: u.UserID})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), tokenVersion)
+
+	// test 2 unknown user
+	mock.ExpectQuery(query).WithArgs(int32(2)).WillReturnError(sql.ErrNoRows)
+
+	_, err = repo.GetTokenVersion(context.Background(), GetTokenVersionInput{UserID: 2})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
+
 func TestUpdateUserData(t *testing.T) {
 	db, mock := NewMock()
-	repo := &Repository{db}
+	repo := &Repository{Db: db}
 
-	// test 1 only phone number
-	onlyPhoneNumberQuery := fmt.Sprintf("UPDATE users SET phone_number = \\'\\%s\\' WHERE id = \\$1", u.PhoneNumber)
-	mock.ExpectExec(onlyPhoneNumberQuery).WithArgs(u.UserID).WillReturnResult(sqlmock.NewResult(0, 0))
+	selectQuery := "SELECT full_name, phone_number FROM users WHERE id = \\$1 FOR UPDATE"
+	outboxQuery := "INSERT INTO outbox_events \\(event_type, aggregate_id, payload\\) VALUES \\(\\$1, \\$2, \\$3\\)"
+	oldRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"full_name", "phone_number"}).AddRow("old name", "+628111111111")
+	}
+	versionRows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"version"}).AddRow(2)
+	}
 
-	err := repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+	// test 1 only phone number
+	onlyPhoneNumberQuery := "UPDATE users SET phone_number = \\$1, phone_number_index = \\$2, version = version \\+ 1, updated_at = NOW\\(\\) WHERE id = \\$3 RETURNING version"
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(onlyPhoneNumberQuery).WithArgs(u.PhoneNumber, u.PhoneNumber, u.UserID).WillReturnRows(versionRows())
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventPhoneNumberChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, err := repo.UpdateUserData(context.Background(), UpdateUserDataInput{
 		UserID: u.UserID,
-		Data: map[string]string{
-			"phone_number": u.PhoneNumber,
-		},
+		Patch:  UserPatch{PhoneNumber: &u.PhoneNumber},
 	})
 	assert.NoError(t, err)
 
 	// test 2 only full name
-	onlyFullNameQuery := fmt.Sprintf("UPDATE users SET full_name = \\'%s\\' WHERE id = \\$1", u.FullName)
-	mock.ExpectExec(onlyFullNameQuery).WithArgs(u.UserID).WillReturnResult(sqlmock.NewResult(0, 0))
-
-	err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+	onlyFullNameQuery := "UPDATE users SET full_name = \\$1, version = version \\+ 1, updated_at = NOW\\(\\) WHERE id = \\$2 RETURNING version"
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(onlyFullNameQuery).WithArgs(u.FullName, u.UserID).WillReturnRows(versionRows())
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventFullNameChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
 		UserID: u.UserID,
-		Data: map[string]string{
-			"full_name": u.FullName,
-		},
+		Patch:  UserPatch{FullName: &u.FullName},
 	})
 	assert.NoError(t, err)
 
 	// test 3 both phone number and full name
-	bothQuery := fmt.Sprintf("UPDATE users SET full_name = \\'%s\\'\\,phone_number = \\'\\%s\\' WHERE id = \\$1", u.FullName, u.PhoneNumber)
-	mock.ExpectExec(bothQuery).WithArgs(u.UserID).WillReturnResult(sqlmock.NewResult(0, 0))
-
-	err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+	bothQuery := "UPDATE users SET full_name = \\$1, phone_number = \\$2, phone_number_index = \\$3, version = version \\+ 1, updated_at = NOW\\(\\) WHERE id = \\$4 RETURNING version"
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(bothQuery).WithArgs(u.FullName, u.PhoneNumber, u.PhoneNumber, u.UserID).WillReturnRows(versionRows())
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventFullNameChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventPhoneNumberChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
 		UserID: u.UserID,
-		Data: map[string]string{
-			"full_name":    u.FullName,
-			"phone_number": u.PhoneNumber,
-		},
+		Patch:  UserPatch{FullName: &u.FullName, PhoneNumber: &u.PhoneNumber},
 	})
 	assert.NoError(t, err)
 
 	// test 4 empty data
-	err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
 		UserID: u.UserID,
 	})
 	assert.Error(t, err)
 
 	// test 5 update error
-	mock.ExpectExec(bothQuery).WithArgs(u.UserID).WillReturnError(sql.ErrConnDone)
-	err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(bothQuery).WithArgs(u.FullName, u.PhoneNumber, u.PhoneNumber, u.UserID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
 		UserID: u.UserID,
-		Data: map[string]string{
-			"full_name":    u.FullName,
-			"phone_number": u.PhoneNumber,
-		},
+		Patch:  UserPatch{FullName: &u.FullName, PhoneNumber: &u.PhoneNumber},
 	})
 	assert.Error(t, err)
+
+	// test 6 unchanged values do not emit events
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(sqlmock.NewRows([]string{"full_name", "phone_number"}).AddRow(u.FullName, u.PhoneNumber))
+	mock.ExpectQuery(bothQuery).WithArgs(u.FullName, u.PhoneNumber, u.PhoneNumber, u.UserID).WillReturnRows(versionRows())
+	mock.ExpectCommit()
+
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+		UserID: u.UserID,
+		Patch:  UserPatch{FullName: &u.FullName, PhoneNumber: &u.PhoneNumber},
+	})
+	assert.NoError(t, err)
+
+	// test 7 quotes and injection payloads are bound verbatim, never spliced into the query
+	for _, payload := range []string{
+		"O'Brien",
+		"x', phone_number = '+620000000000",
+		"'; DROP TABLE users; --",
+		`Robert"); DELETE FROM users WHERE ("1"="1`,
+	} {
+		payload := payload
+		mock.ExpectBegin()
+		mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+		mock.ExpectQuery(onlyFullNameQuery).WithArgs(payload, u.UserID).WillReturnRows(versionRows())
+		mock.ExpectExec(outboxQuery).WithArgs(model.EventFullNameChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+		mock.ExpectCommit()
+
+		_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+			UserID: u.UserID,
+			Patch:  UserPatch{FullName: &payload},
+		})
+		assert.NoError(t, err)
+	}
+
+	// test 8 compare-and-swap on the expected version
+	casQuery := "UPDATE users SET full_name = \\$1, version = version \\+ 1, updated_at = NOW\\(\\) WHERE id = \\$2 AND version = \\$3 RETURNING version"
+	expectedVersion := int32(1)
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(casQuery).WithArgs(u.FullName, u.UserID, expectedVersion).WillReturnRows(versionRows())
+	mock.ExpectExec(outboxQuery).WithArgs(model.EventFullNameChanged, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	out, err := repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+		UserID:          u.UserID,
+		Patch:           UserPatch{FullName: &u.FullName},
+		ExpectedVersion: &expectedVersion,
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), out.Version)
+
+	// test 9 stale version is a conflict and emits no event
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(u.UserID).WillReturnRows(oldRows())
+	mock.ExpectQuery(casQuery).WithArgs(u.FullName, u.UserID, expectedVersion).WillReturnRows(sqlmock.NewRows([]string{"version"}))
+	mock.ExpectRollback()
+
+	_, err = repo.UpdateUserData(context.Background(), UpdateUserDataInput{
+		UserID:          u.UserID,
+		Patch:           UserPatch{FullName: &u.FullName},
+		ExpectedVersion: &expectedVersion,
+	})
+	assert.ErrorIs(t, err, ErrVersionConflict)
+	assert.NoError(t, mock.ExpectationsWereMet())
 }
@@ -13,9 +13,33 @@ import (
 type RepositoryInterface interface {
 	GetLoginData(ctx context.Context, input GetLoginDataInput) (output GetLoginDataOutput, err error)
 	GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error)
+	GetTokenVersion(ctx context.Context, in GetTokenVersionInput) (int32, error)
 
 	InsertUser(ctx context.Context, in InsertUserInput) (out InsertUserOutput, err error)
 
 	UpdateSuccessfulLogin(ctx context.Context, in UpdateSuccessfulLoginInput) error
-	UpdateUserData(ctx context.Context, in UpdateUserDataInput) error
+	UpdateUserData(ctx context.Context, in UpdateUserDataInput) (out UpdateUserDataOutput, err error)
+	GetPasswordHistory(ctx context.Context, in GetPasswordHistoryInput) ([]string, error)
+	UpdatePassword(ctx context.Context, in UpdatePasswordInput) error
+	RehashPassword(ctx context.Context, in RehashPasswordInput) error
+	InsertAuditEvent(ctx context.Context, in InsertAuditEventInput) (out InsertAuditEventOutput, err error)
+	GetAuditEvents(ctx context.Context, in GetAuditEventsInput) ([]model.AuditEvent, error)
+	ClaimOutboxEvents(ctx context.Context, in ClaimOutboxEventsInput) ([]model.OutboxEvent, error)
+	MarkOutboxEventPublished(ctx context.Context, in MarkOutboxEventPublishedInput) error
+	MarkOutboxEventFailed(ctx context.Context, in MarkOutboxEventFailedInput) error
+	DeletePublishedOutboxEvents(ctx context.Context, in DeletePublishedOutboxEventsInput) (deleted int64, err error)
+	InsertWebhookSubscription(ctx context.Context, in InsertWebhookSubscriptionInput) (model.WebhookSubscription, error)
+	GetWebhookSubscriptions(ctx context.Context, in GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error)
+	DeactivateWebhookSubscription(ctx context.Context, in DeactivateWebhookSubscriptionInput) error
+	InsertWebhookDeliveries(ctx context.Context, in InsertWebhookDeliveriesInput) error
+	ClaimWebhookDeliveries(ctx context.Context, in ClaimWebhookDeliveriesInput) ([]model.WebhookDelivery, error)
+	UpdateWebhookDelivery(ctx context.Context, in UpdateWebhookDeliveryInput) error
+	GetWebhookDeliveries(ctx context.Context, in GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error)
+	RedeliverWebhookDelivery(ctx context.Context, in RedeliverWebhookDeliveryInput) error
+	DeleteDeliveredWebhookDeliveries(ctx context.Context, in DeleteDeliveredWebhookDeliveriesInput) (deleted int64, err error)
+	ReserveIdempotencyKey(ctx context.Context, in ReserveIdempotencyKeyInput) (out ReserveIdempotencyKeyOutput, err error)
+	CompleteIdempotencyKey(ctx context.Context, in CompleteIdempotencyKeyInput) error
+	ReleaseIdempotencyKey(ctx context.Context, in ReleaseIdempotencyKeyInput) error
+	DeleteExpiredIdempotencyKeys(ctx context.Context, in DeleteExpiredIdempotencyKeysInput) (deleted int64, err error)
+	WithTx(ctx context.Context, fn func(RepositoryInterface) error) error
 }
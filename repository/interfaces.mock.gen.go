@@ -35,6 +35,124 @@ func (m *MockRepositoryInterface) EXPECT() *MockRepositoryInterfaceMockRecorder
 	return m.recorder
 }
 
+// ClaimOutboxEvents mocks base method.
+func (m *MockRepositoryInterface) ClaimOutboxEvents(ctx context.Context, in ClaimOutboxEventsInput) ([]model.OutboxEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimOutboxEvents", ctx, in)
+	ret0, _ := ret[0].([]model.OutboxEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimOutboxEvents indicates an expected call of ClaimOutboxEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimOutboxEvents(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimOutboxEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimOutboxEvents), ctx, in)
+}
+
+// ClaimWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) ClaimWebhookDeliveries(ctx context.Context, in ClaimWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ClaimWebhookDeliveries", ctx, in)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ClaimWebhookDeliveries indicates an expected call of ClaimWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) ClaimWebhookDeliveries(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ClaimWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).ClaimWebhookDeliveries), ctx, in)
+}
+
+// CompleteIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) CompleteIdempotencyKey(ctx context.Context, in CompleteIdempotencyKeyInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CompleteIdempotencyKey", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// CompleteIdempotencyKey indicates an expected call of CompleteIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) CompleteIdempotencyKey(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CompleteIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).CompleteIdempotencyKey), ctx, in)
+}
+
+// DeactivateWebhookSubscription mocks base method.
+func (m *MockRepositoryInterface) DeactivateWebhookSubscription(ctx context.Context, in DeactivateWebhookSubscriptionInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeactivateWebhookSubscription", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeactivateWebhookSubscription indicates an expected call of DeactivateWebhookSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) DeactivateWebhookSubscription(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeactivateWebhookSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).DeactivateWebhookSubscription), ctx, in)
+}
+
+// DeleteDeliveredWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) DeleteDeliveredWebhookDeliveries(ctx context.Context, in DeleteDeliveredWebhookDeliveriesInput) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteDeliveredWebhookDeliveries", ctx, in)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteDeliveredWebhookDeliveries indicates an expected call of DeleteDeliveredWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteDeliveredWebhookDeliveries(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteDeliveredWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteDeliveredWebhookDeliveries), ctx, in)
+}
+
+// DeleteExpiredIdempotencyKeys mocks base method.
+func (m *MockRepositoryInterface) DeleteExpiredIdempotencyKeys(ctx context.Context, in DeleteExpiredIdempotencyKeysInput) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeleteExpiredIdempotencyKeys", ctx, in)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeleteExpiredIdempotencyKeys indicates an expected call of DeleteExpiredIdempotencyKeys.
+func (mr *MockRepositoryInterfaceMockRecorder) DeleteExpiredIdempotencyKeys(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeleteExpiredIdempotencyKeys", reflect.TypeOf((*MockRepositoryInterface)(nil).DeleteExpiredIdempotencyKeys), ctx, in)
+}
+
+// DeletePublishedOutboxEvents mocks base method.
+func (m *MockRepositoryInterface) DeletePublishedOutboxEvents(ctx context.Context, in DeletePublishedOutboxEventsInput) (int64, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeletePublishedOutboxEvents", ctx, in)
+	ret0, _ := ret[0].(int64)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// DeletePublishedOutboxEvents indicates an expected call of DeletePublishedOutboxEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) DeletePublishedOutboxEvents(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeletePublishedOutboxEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).DeletePublishedOutboxEvents), ctx, in)
+}
+
+// GetAuditEvents mocks base method.
+func (m *MockRepositoryInterface) GetAuditEvents(ctx context.Context, in GetAuditEventsInput) ([]model.AuditEvent, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetAuditEvents", ctx, in)
+	ret0, _ := ret[0].([]model.AuditEvent)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetAuditEvents indicates an expected call of GetAuditEvents.
+func (mr *MockRepositoryInterfaceMockRecorder) GetAuditEvents(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetAuditEvents", reflect.TypeOf((*MockRepositoryInterface)(nil).GetAuditEvents), ctx, in)
+}
+
 // GetLoginData mocks base method.
 func (m *MockRepositoryInterface) GetLoginData(ctx context.Context, input GetLoginDataInput) (GetLoginDataOutput, error) {
 	m.ctrl.T.Helper()
@@ -50,6 +168,36 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetLoginData(ctx, input interface
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetLoginData", reflect.TypeOf((*MockRepositoryInterface)(nil).GetLoginData), ctx, input)
 }
 
+// GetPasswordHistory mocks base method.
+func (m *MockRepositoryInterface) GetPasswordHistory(ctx context.Context, in GetPasswordHistoryInput) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetPasswordHistory", ctx, in)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetPasswordHistory indicates an expected call of GetPasswordHistory.
+func (mr *MockRepositoryInterfaceMockRecorder) GetPasswordHistory(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetPasswordHistory", reflect.TypeOf((*MockRepositoryInterface)(nil).GetPasswordHistory), ctx, in)
+}
+
+// GetTokenVersion mocks base method.
+func (m *MockRepositoryInterface) GetTokenVersion(ctx context.Context, in GetTokenVersionInput) (int32, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetTokenVersion", ctx, in)
+	ret0, _ := ret[0].(int32)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetTokenVersion indicates an expected call of GetTokenVersion.
+func (mr *MockRepositoryInterfaceMockRecorder) GetTokenVersion(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetTokenVersion", reflect.TypeOf((*MockRepositoryInterface)(nil).GetTokenVersion), ctx, in)
+}
+
 // GetUserDataByUserID mocks base method.
 func (m *MockRepositoryInterface) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +213,51 @@ func (mr *MockRepositoryInterfaceMockRecorder) GetUserDataByUserID(ctx, input in
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetUserDataByUserID", reflect.TypeOf((*MockRepositoryInterface)(nil).GetUserDataByUserID), ctx, input)
 }
 
+// GetWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) GetWebhookDeliveries(ctx context.Context, in GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookDeliveries", ctx, in)
+	ret0, _ := ret[0].([]model.WebhookDelivery)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookDeliveries indicates an expected call of GetWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhookDeliveries(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhookDeliveries), ctx, in)
+}
+
+// GetWebhookSubscriptions mocks base method.
+func (m *MockRepositoryInterface) GetWebhookSubscriptions(ctx context.Context, in GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWebhookSubscriptions", ctx, in)
+	ret0, _ := ret[0].([]model.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetWebhookSubscriptions indicates an expected call of GetWebhookSubscriptions.
+func (mr *MockRepositoryInterfaceMockRecorder) GetWebhookSubscriptions(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWebhookSubscriptions", reflect.TypeOf((*MockRepositoryInterface)(nil).GetWebhookSubscriptions), ctx, in)
+}
+
+// InsertAuditEvent mocks base method.
+func (m *MockRepositoryInterface) InsertAuditEvent(ctx context.Context, in InsertAuditEventInput) (InsertAuditEventOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertAuditEvent", ctx, in)
+	ret0, _ := ret[0].(InsertAuditEventOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertAuditEvent indicates an expected call of InsertAuditEvent.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertAuditEvent(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertAuditEvent", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertAuditEvent), ctx, in)
+}
+
 // InsertUser mocks base method.
 func (m *MockRepositoryInterface) InsertUser(ctx context.Context, in InsertUserInput) (InsertUserOutput, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +273,134 @@ func (mr *MockRepositoryInterfaceMockRecorder) InsertUser(ctx, in interface{}) *
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertUser", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertUser), ctx, in)
 }
 
+// InsertWebhookDeliveries mocks base method.
+func (m *MockRepositoryInterface) InsertWebhookDeliveries(ctx context.Context, in InsertWebhookDeliveriesInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWebhookDeliveries", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// InsertWebhookDeliveries indicates an expected call of InsertWebhookDeliveries.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertWebhookDeliveries(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWebhookDeliveries", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertWebhookDeliveries), ctx, in)
+}
+
+// InsertWebhookSubscription mocks base method.
+func (m *MockRepositoryInterface) InsertWebhookSubscription(ctx context.Context, in InsertWebhookSubscriptionInput) (model.WebhookSubscription, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "InsertWebhookSubscription", ctx, in)
+	ret0, _ := ret[0].(model.WebhookSubscription)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// InsertWebhookSubscription indicates an expected call of InsertWebhookSubscription.
+func (mr *MockRepositoryInterfaceMockRecorder) InsertWebhookSubscription(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "InsertWebhookSubscription", reflect.TypeOf((*MockRepositoryInterface)(nil).InsertWebhookSubscription), ctx, in)
+}
+
+// MarkOutboxEventFailed mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventFailed(ctx context.Context, in MarkOutboxEventFailedInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventFailed", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventFailed indicates an expected call of MarkOutboxEventFailed.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventFailed(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventFailed", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventFailed), ctx, in)
+}
+
+// MarkOutboxEventPublished mocks base method.
+func (m *MockRepositoryInterface) MarkOutboxEventPublished(ctx context.Context, in MarkOutboxEventPublishedInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MarkOutboxEventPublished", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MarkOutboxEventPublished indicates an expected call of MarkOutboxEventPublished.
+func (mr *MockRepositoryInterfaceMockRecorder) MarkOutboxEventPublished(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MarkOutboxEventPublished", reflect.TypeOf((*MockRepositoryInterface)(nil).MarkOutboxEventPublished), ctx, in)
+}
+
+// RedeliverWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) RedeliverWebhookDelivery(ctx context.Context, in RedeliverWebhookDeliveryInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RedeliverWebhookDelivery", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RedeliverWebhookDelivery indicates an expected call of RedeliverWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) RedeliverWebhookDelivery(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RedeliverWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).RedeliverWebhookDelivery), ctx, in)
+}
+
+// RehashPassword mocks base method.
+func (m *MockRepositoryInterface) RehashPassword(ctx context.Context, in RehashPasswordInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RehashPassword", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RehashPassword indicates an expected call of RehashPassword.
+func (mr *MockRepositoryInterfaceMockRecorder) RehashPassword(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RehashPassword", reflect.TypeOf((*MockRepositoryInterface)(nil).RehashPassword), ctx, in)
+}
+
+// ReleaseIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ReleaseIdempotencyKey(ctx context.Context, in ReleaseIdempotencyKeyInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReleaseIdempotencyKey", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ReleaseIdempotencyKey indicates an expected call of ReleaseIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ReleaseIdempotencyKey(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReleaseIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ReleaseIdempotencyKey), ctx, in)
+}
+
+// ReserveIdempotencyKey mocks base method.
+func (m *MockRepositoryInterface) ReserveIdempotencyKey(ctx context.Context, in ReserveIdempotencyKeyInput) (ReserveIdempotencyKeyOutput, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReserveIdempotencyKey", ctx, in)
+	ret0, _ := ret[0].(ReserveIdempotencyKeyOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReserveIdempotencyKey indicates an expected call of ReserveIdempotencyKey.
+func (mr *MockRepositoryInterfaceMockRecorder) ReserveIdempotencyKey(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReserveIdempotencyKey", reflect.TypeOf((*MockRepositoryInterface)(nil).ReserveIdempotencyKey), ctx, in)
+}
+
+// UpdatePassword mocks base method.
+func (m *MockRepositoryInterface) UpdatePassword(ctx context.Context, in UpdatePasswordInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdatePassword", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdatePassword indicates an expected call of UpdatePassword.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdatePassword(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdatePassword", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdatePassword), ctx, in)
+}
+
 // UpdateSuccessfulLogin mocks base method.
 func (m *MockRepositoryInterface) UpdateSuccessfulLogin(ctx context.Context, in UpdateSuccessfulLoginInput) error {
 	m.ctrl.T.Helper()
@@ -95,11 +416,12 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateSuccessfulLogin(ctx, in int
 }
 
 // UpdateUserData mocks base method.
-func (m *MockRepositoryInterface) UpdateUserData(ctx context.Context, in UpdateUserDataInput) error {
+func (m *MockRepositoryInterface) UpdateUserData(ctx context.Context, in UpdateUserDataInput) (UpdateUserDataOutput, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "UpdateUserData", ctx, in)
-	ret0, _ := ret[0].(error)
-	return ret0
+	ret0, _ := ret[0].(UpdateUserDataOutput)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // UpdateUserData indicates an expected call of UpdateUserData.
@@ -107,3 +429,31 @@ func (mr *MockRepositoryInterfaceMockRecorder) UpdateUserData(ctx, in interface{
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateUserData", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateUserData), ctx, in)
 }
+
+// UpdateWebhookDelivery mocks base method.
+func (m *MockRepositoryInterface) UpdateWebhookDelivery(ctx context.Context, in UpdateWebhookDeliveryInput) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateWebhookDelivery", ctx, in)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateWebhookDelivery indicates an expected call of UpdateWebhookDelivery.
+func (mr *MockRepositoryInterfaceMockRecorder) UpdateWebhookDelivery(ctx, in interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateWebhookDelivery", reflect.TypeOf((*MockRepositoryInterface)(nil).UpdateWebhookDelivery), ctx, in)
+}
+
+// WithTx mocks base method.
+func (m *MockRepositoryInterface) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "WithTx", ctx, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// WithTx indicates an expected call of WithTx.
+func (mr *MockRepositoryInterfaceMockRecorder) WithTx(ctx, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "WithTx", reflect.TypeOf((*MockRepositoryInterface)(nil).WithTx), ctx, fn)
+}
@@ -0,0 +1,816 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// MemoryRepository is a RepositoryInterface keeping its data in memory, for
+// tests and local runs without a database. It honours the same contract as
+// Repository, both are held to it by the suite in repository/repositorytest.
+//
+// Calls are serialised by a single lock. WithTx holds the lock while fn runs,
+// so fn must only use the repository it is given, like with Repository where
+// the outer one would wait for the locks taken by the transaction.
+type MemoryRepository struct {
+	mu    *sync.Mutex
+	state *memoryState
+
+	// tx is set on the copies handed to WithTx callbacks, which already hold mu.
+	tx bool
+}
+
+type memoryPassword struct {
+	ID       int64
+	UserID   int32
+	Password string
+}
+
+type memoryIdempotencyKey struct {
+	Caller string
+	Key    string
+}
+
+type memoryIdempotencyRecord struct {
+	model.IdempotencyRecord
+	LockedUntil time.Time
+}
+
+type memoryOutboxEvent struct {
+	model.OutboxEvent
+	NextAttemptAt time.Time
+	ClaimedUntil  time.Time
+	PublishedAt   time.Time
+	Dead          bool
+	LastError     string
+}
+
+// memoryState holds the tables. Rows are stored by value and the slices and
+// maps they contain are never modified in place, so clone only copies the
+// containers.
+type memoryState struct {
+	users           map[int32]model.User
+	userIDsByPhone  map[string]int32
+	lastUserID      int32
+	passwordHistory []memoryPassword
+	lastPasswordID  int64
+	auditEvents     []model.AuditEvent
+	outboxEvents    []memoryOutboxEvent
+	lastOutboxID    int64
+	subscriptions   []model.WebhookSubscription
+	deliveries      []model.WebhookDelivery
+	lastDeliveryID  int64
+	idempotencyKeys map[memoryIdempotencyKey]memoryIdempotencyRecord
+}
+
+func NewMemoryRepository() *MemoryRepository {
+	return &MemoryRepository{
+		mu: &sync.Mutex{},
+		state: &memoryState{
+			users:           map[int32]model.User{},
+			userIDsByPhone:  map[string]int32{},
+			idempotencyKeys: map[memoryIdempotencyKey]memoryIdempotencyRecord{},
+		},
+	}
+}
+
+func (s *memoryState) clone() *memoryState {
+	c := *s
+
+	c.users = make(map[int32]model.User, len(s.users))
+	for id, user := range s.users {
+		c.users[id] = user
+	}
+
+	c.userIDsByPhone = make(map[string]int32, len(s.userIDsByPhone))
+	for phoneNumber, id := range s.userIDsByPhone {
+		c.userIDsByPhone[phoneNumber] = id
+	}
+
+	c.idempotencyKeys = make(map[memoryIdempotencyKey]memoryIdempotencyRecord, len(s.idempotencyKeys))
+	for key, record := range s.idempotencyKeys {
+		c.idempotencyKeys[key] = record
+	}
+
+	c.passwordHistory = append([]memoryPassword(nil), s.passwordHistory...)
+	c.auditEvents = append([]model.AuditEvent(nil), s.auditEvents...)
+	c.outboxEvents = append([]memoryOutboxEvent(nil), s.outboxEvents...)
+	c.subscriptions = append([]model.WebhookSubscription(nil), s.subscriptions...)
+	c.deliveries = append([]model.WebhookDelivery(nil), s.deliveries...)
+	return &c
+}
+
+// run calls fn with the state, holding the lock unless the repository is
+// bound to a transaction.
+func (r *MemoryRepository) run(ctx context.Context, fn func(state *memoryState) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if !r.tx {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+	}
+
+	return fn(r.state)
+}
+
+// WithTx runs fn with a repository working on a copy of the data, which
+// replaces the data when fn returns nil. Nested calls work on a copy of the
+// copy, so their failure only undoes their own changes.
+func (r *MemoryRepository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) error {
+	return r.run(ctx, func(state *memoryState) error {
+		txRepo := &MemoryRepository{mu: r.mu, state: state.clone(), tx: true}
+		if err := fn(txRepo); err != nil {
+			return err
+		}
+
+		*state = *txRepo.state
+		return nil
+	})
+}
+
+// memoryNow returns the current time with the precision Postgres keeps.
+func memoryNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// memoryUniqueViolation returns the error Repository returns for a duplicate
+// key, so callers handle every implementation alike.
+func memoryUniqueViolation(constraint string) error {
+	return uniqueViolation(constraint, fmt.Errorf("duplicate key value violates unique constraint %q", constraint))
+}
+
+func (s *memoryState) insertOutboxEvent(eventType string, aggregateID int32, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	s.lastOutboxID++
+	s.outboxEvents = append(s.outboxEvents, memoryOutboxEvent{
+		OutboxEvent: model.OutboxEvent{
+			ID:          s.lastOutboxID,
+			EventType:   eventType,
+			AggregateID: aggregateID,
+			Payload:     data,
+			CreatedAt:   memoryNow(),
+		},
+	})
+	return nil
+}
+
+func (r *MemoryRepository) InsertUser(ctx context.Context, input InsertUserInput) (output InsertUserOutput, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		if _, ok := state.userIDsByPhone[input.PhoneNumber]; ok {
+			return memoryUniqueViolation(phoneNumberConstraint)
+		}
+
+		payload := model.UserEventPayload{UserID: state.lastUserID + 1}
+		if err := state.insertOutboxEvent(model.EventUserRegistered, payload.UserID, payload); err != nil {
+			return err
+		}
+
+		state.lastUserID++
+		state.users[state.lastUserID] = model.User{
+			UserID:            state.lastUserID,
+			FullName:          input.FullName,
+			Password:          input.Password,
+			PhoneNumber:       input.PhoneNumber,
+			Version:           1,
+			PreferredLanguage: input.PreferredLanguage,
+		}
+		state.userIDsByPhone[input.PhoneNumber] = state.lastUserID
+
+		output.UserID = state.lastUserID
+		return nil
+	})
+	if err != nil {
+		return InsertUserOutput{}, err
+	}
+	return
+}
+
+func (r *MemoryRepository) GetLoginData(ctx context.Context, input GetLoginDataInput) (output GetLoginDataOutput, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		id, ok := state.userIDsByPhone[input.PhoneNumber]
+		if !ok {
+			return ErrNotFound
+		}
+
+		user := state.users[id]
+		output = GetLoginDataOutput{
+			UserID:            user.UserID,
+			FullName:          user.FullName,
+			HashedPassword:    user.Password,
+			PreferredLanguage: user.PreferredLanguage,
+			TokenVersion:      user.TokenVersion,
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) UpdateSuccessfulLogin(ctx context.Context, input UpdateSuccessfulLoginInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		if id, ok := state.userIDsByPhone[input.PhoneNumber]; ok {
+			user := state.users[id]
+			user.SuccesfulLogin++
+			state.users[id] = user
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (out UpdateUserDataOutput, err error) {
+	if input.Patch.IsEmpty() {
+		err = fmt.Errorf("update user data is empty")
+		return
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		old, ok := state.users[input.UserID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != old.Version {
+			return ErrVersionConflict
+		}
+
+		user := old
+		if fullName := input.Patch.FullName; fullName != nil {
+			user.FullName = *fullName
+		}
+		if phoneNumber := input.Patch.PhoneNumber; phoneNumber != nil {
+			if id, ok := state.userIDsByPhone[*phoneNumber]; ok && id != input.UserID {
+				return memoryUniqueViolation(phoneNumberConstraint)
+			}
+			user.PhoneNumber = *phoneNumber
+		}
+		if preferredLanguage := input.Patch.PreferredLanguage; preferredLanguage != nil {
+			user.PreferredLanguage = *preferredLanguage
+		}
+		user.Version++
+
+		if user.FullName != old.FullName {
+			err := state.insertOutboxEvent(model.EventFullNameChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		if user.PhoneNumber != old.PhoneNumber {
+			err := state.insertOutboxEvent(model.EventPhoneNumberChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		delete(state.userIDsByPhone, old.PhoneNumber)
+		state.userIDsByPhone[user.PhoneNumber] = user.UserID
+		state.users[user.UserID] = user
+
+		out.Version = user.Version
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (out model.User, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		user, ok := state.users[input.UserID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		out = user
+		out.Password = ""
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) GetTokenVersion(ctx context.Context, input GetTokenVersionInput) (tokenVersion int32, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		user, ok := state.users[input.UserID]
+		if !ok {
+			return ErrNotFound
+		}
+
+		tokenVersion = user.TokenVersion
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) GetPasswordHistory(ctx context.Context, input GetPasswordHistoryInput) (hashes []string, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		user, ok := state.users[input.UserID]
+		if !ok {
+			return nil
+		}
+
+		hashes = append(hashes, user.Password)
+		for i := len(state.passwordHistory) - 1; i >= 0; i-- {
+			if state.passwordHistory[i].UserID == input.UserID {
+				hashes = append(hashes, state.passwordHistory[i].Password)
+			}
+		}
+
+		if len(hashes) > input.Limit {
+			hashes = hashes[:max(input.Limit, 0)]
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) UpdatePassword(ctx context.Context, input UpdatePasswordInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		user, ok := state.users[input.UserID]
+		if !ok {
+			return ErrNotFound
+		}
+		if input.ExpectedTokenVersion != nil && *input.ExpectedTokenVersion != user.TokenVersion {
+			return ErrVersionConflict
+		}
+
+		state.lastPasswordID++
+		history := append(state.passwordHistory, memoryPassword{
+			ID:       state.lastPasswordID,
+			UserID:   input.UserID,
+			Password: user.Password,
+		})
+
+		// Keep the newest KeepHistory entries of the user
+		kept := 0
+		state.passwordHistory = make([]memoryPassword, 0, len(history))
+		for i := len(history) - 1; i >= 0; i-- {
+			if history[i].UserID == input.UserID {
+				if kept >= input.KeepHistory {
+					continue
+				}
+				kept++
+			}
+			state.passwordHistory = append(state.passwordHistory, history[i])
+		}
+		for i, j := 0, len(state.passwordHistory)-1; i < j; i, j = i+1, j-1 {
+			state.passwordHistory[i], state.passwordHistory[j] = state.passwordHistory[j], state.passwordHistory[i]
+		}
+
+		user.Password = input.Password
+		user.TokenVersion++
+		state.users[input.UserID] = user
+		return nil
+	})
+}
+
+func (r *MemoryRepository) RehashPassword(ctx context.Context, input RehashPasswordInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		user, ok := state.users[input.UserID]
+		if !ok || user.Password != input.PreviousPassword {
+			return ErrNotFound
+		}
+
+		user.Password = input.Password
+		user.TokenVersion++
+		state.users[input.UserID] = user
+		return nil
+	})
+}
+
+func (r *MemoryRepository) InsertAuditEvent(ctx context.Context, input InsertAuditEventInput) (output InsertAuditEventOutput, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		event := model.AuditEvent{
+			ID:        1,
+			EventType: input.EventType,
+			UserID:    input.UserID,
+			ActorID:   input.ActorID,
+			Metadata:  map[string]string{},
+			IPAddress: input.IPAddress,
+			RequestID: input.RequestID,
+			CreatedAt: memoryNow(),
+		}
+		for key, value := range input.Metadata {
+			event.Metadata[key] = value
+		}
+
+		if n := len(state.auditEvents); n > 0 {
+			event.ID = state.auditEvents[n-1].ID + 1
+			event.PrevHash = state.auditEvents[n-1].Hash
+		}
+		event.Hash = event.ComputeHash()
+
+		state.auditEvents = append(state.auditEvents, event)
+
+		output.ID = event.ID
+		output.Hash = event.Hash
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) GetAuditEvents(ctx context.Context, input GetAuditEventsInput) (events []model.AuditEvent, err error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultAuditEventsLimit
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		for _, event := range state.auditEvents {
+			if len(events) == input.Limit {
+				break
+			}
+
+			if event.ID <= input.AfterID ||
+				(input.UserID != 0 && event.UserID != input.UserID) ||
+				(input.EventType != "" && event.EventType != input.EventType) {
+				continue
+			}
+
+			metadata := make(map[string]string, len(event.Metadata))
+			for key, value := range event.Metadata {
+				metadata[key] = value
+			}
+			event.Metadata = metadata
+
+			events = append(events, event)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) ClaimOutboxEvents(ctx context.Context, input ClaimOutboxEventsInput) (events []model.OutboxEvent, err error) {
+	if input.Lease <= 0 {
+		input.Lease = defaultOutboxLease
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		now := memoryNow()
+
+		for i := range state.outboxEvents {
+			if len(events) >= input.Limit {
+				break
+			}
+
+			event := &state.outboxEvents[i]
+			if !event.PublishedAt.IsZero() || event.Dead || event.NextAttemptAt.After(now) || event.ClaimedUntil.After(now) {
+				continue
+			}
+
+			event.ClaimedUntil = now.Add(input.Lease)
+			event.Attempts++
+			events = append(events, event.OutboxEvent)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) MarkOutboxEventPublished(ctx context.Context, input MarkOutboxEventPublishedInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		if event := state.outboxEvent(input.ID); event != nil {
+			event.PublishedAt = memoryNow()
+			event.ClaimedUntil = time.Time{}
+			event.LastError = ""
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) MarkOutboxEventFailed(ctx context.Context, input MarkOutboxEventFailedInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		if event := state.outboxEvent(input.ID); event != nil {
+			event.ClaimedUntil = time.Time{}
+			event.NextAttemptAt = input.NextAttemptAt
+			event.Dead = input.Dead
+			event.LastError = input.Error
+		}
+		return nil
+	})
+}
+
+func (s *memoryState) outboxEvent(id int64) *memoryOutboxEvent {
+	for i := range s.outboxEvents {
+		if s.outboxEvents[i].ID == id {
+			return &s.outboxEvents[i]
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) DeletePublishedOutboxEvents(ctx context.Context, input DeletePublishedOutboxEventsInput) (deleted int64, err error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		events := make([]memoryOutboxEvent, 0, len(state.outboxEvents))
+		for _, event := range state.outboxEvents {
+			if deleted < int64(input.Limit) && !event.PublishedAt.IsZero() && event.PublishedAt.Before(input.Before) {
+				deleted++
+				continue
+			}
+			events = append(events, event)
+		}
+		state.outboxEvents = events
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) InsertWebhookSubscription(ctx context.Context, input InsertWebhookSubscriptionInput) (output model.WebhookSubscription, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		output = model.WebhookSubscription{
+			ID:         int32(len(state.subscriptions)) + 1,
+			URL:        input.URL,
+			EventTypes: append([]string(nil), input.EventTypes...),
+			Secret:     input.Secret,
+			Active:     true,
+			CreatedAt:  memoryNow(),
+		}
+
+		state.subscriptions = append(state.subscriptions, output)
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) GetWebhookSubscriptions(ctx context.Context, input GetWebhookSubscriptionsInput) (subscriptions []model.WebhookSubscription, err error) {
+	err = r.run(ctx, func(state *memoryState) error {
+		for _, subscription := range state.subscriptions {
+			if input.ActiveOnly && !subscription.Active {
+				continue
+			}
+
+			subscription.EventTypes = append([]string(nil), subscription.EventTypes...)
+			subscriptions = append(subscriptions, subscription)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) DeactivateWebhookSubscription(ctx context.Context, input DeactivateWebhookSubscriptionInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		subscription := state.subscription(input.ID)
+		if subscription == nil {
+			return ErrNotFound
+		}
+
+		subscription.Active = false
+		return nil
+	})
+}
+
+func (s *memoryState) subscription(id int32) *model.WebhookSubscription {
+	// Subscriptions are never deleted, so their IDs follow their position
+	if id < 1 || int(id) > len(s.subscriptions) {
+		return nil
+	}
+	return &s.subscriptions[id-1]
+}
+
+func (r *MemoryRepository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) error {
+	if len(input.SubscriptionIDs) == 0 {
+		return nil
+	}
+
+	return r.run(ctx, func(state *memoryState) error {
+		for _, subscriptionID := range input.SubscriptionIDs {
+			if state.subscription(subscriptionID) == nil {
+				return fmt.Errorf("%w: insert or update violates foreign key constraint %q", ErrConflict, "webhook_deliveries_subscription_id_fkey")
+			}
+		}
+
+		now := memoryNow()
+
+	subscriptions:
+		for _, subscriptionID := range input.SubscriptionIDs {
+			for _, delivery := range state.deliveries {
+				if delivery.SubscriptionID == subscriptionID && delivery.EventID == input.Event.ID {
+					continue subscriptions
+				}
+			}
+
+			state.lastDeliveryID++
+			state.deliveries = append(state.deliveries, model.WebhookDelivery{
+				ID:             state.lastDeliveryID,
+				SubscriptionID: subscriptionID,
+				EventID:        input.Event.ID,
+				EventType:      input.Event.EventType,
+				Payload:        append(json.RawMessage(nil), input.Event.Payload...),
+				Status:         model.WebhookDeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+				UpdatedAt:      now,
+			})
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	if input.Lease <= 0 {
+		input.Lease = defaultWebhookLease
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		now := memoryNow()
+
+		var due []*model.WebhookDelivery
+		for i := range state.deliveries {
+			delivery := &state.deliveries[i]
+			if delivery.Status == model.WebhookDeliveryPending && !delivery.NextAttemptAt.After(now) && state.subscription(delivery.SubscriptionID).Active {
+				due = append(due, delivery)
+			}
+		}
+
+		sort.SliceStable(due, func(i, j int) bool {
+			return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+		})
+
+		for _, delivery := range due {
+			if len(deliveries) >= input.Limit {
+				break
+			}
+
+			delivery.NextAttemptAt = now.Add(input.Lease)
+
+			claimed := *delivery
+			subscription := state.subscription(delivery.SubscriptionID)
+			claimed.URL = subscription.URL
+			claimed.Secret = subscription.Secret
+			deliveries = append(deliveries, claimed)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) UpdateWebhookDelivery(ctx context.Context, input UpdateWebhookDeliveryInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		if delivery := state.delivery(input.ID); delivery != nil {
+			delivery.Status = input.Status
+			delivery.Attempts = input.Attempts
+			delivery.NextAttemptAt = input.NextAttemptAt.UTC().Truncate(time.Microsecond)
+			delivery.LastStatusCode = input.LastStatusCode
+			delivery.LastError = input.LastError
+			delivery.UpdatedAt = memoryNow()
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultWebhookDeliveriesLimit
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		for _, delivery := range state.deliveries {
+			if len(deliveries) == input.Limit {
+				break
+			}
+
+			if delivery.SubscriptionID != input.SubscriptionID ||
+				delivery.ID <= input.AfterID ||
+				(input.Status != "" && delivery.Status != input.Status) {
+				continue
+			}
+
+			deliveries = append(deliveries, delivery)
+		}
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		delivery := state.delivery(input.ID)
+		if delivery == nil {
+			return ErrNotFound
+		}
+
+		now := memoryNow()
+		delivery.Status = model.WebhookDeliveryPending
+		delivery.NextAttemptAt = now
+		delivery.UpdatedAt = now
+		return nil
+	})
+}
+
+func (r *MemoryRepository) DeleteDeliveredWebhookDeliveries(ctx context.Context, input DeleteDeliveredWebhookDeliveriesInput) (deleted int64, err error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		deliveries := make([]model.WebhookDelivery, 0, len(state.deliveries))
+		for _, delivery := range state.deliveries {
+			if deleted < int64(input.Limit) && delivery.Status == model.WebhookDeliveryDelivered && delivery.UpdatedAt.Before(input.Before) {
+				deleted++
+				continue
+			}
+			deliveries = append(deliveries, delivery)
+		}
+		state.deliveries = deliveries
+		return nil
+	})
+	return
+}
+
+func (s *memoryState) delivery(id int64) *model.WebhookDelivery {
+	for i := range s.deliveries {
+		if s.deliveries[i].ID == id {
+			return &s.deliveries[i]
+		}
+	}
+	return nil
+}
+
+func (r *MemoryRepository) ReserveIdempotencyKey(ctx context.Context, input ReserveIdempotencyKeyInput) (output ReserveIdempotencyKeyOutput, err error) {
+	input.applyDefaults()
+
+	err = r.run(ctx, func(state *memoryState) error {
+		now := memoryNow()
+		key := memoryIdempotencyKey{Caller: input.Caller, Key: input.Key}
+
+		if record, ok := state.idempotencyKeys[key]; ok && record.ExpiresAt.After(now) &&
+			(record.Completed() || record.LockedUntil.After(now)) {
+			output.Record = record.IdempotencyRecord
+			return nil
+		}
+
+		state.idempotencyKeys[key] = memoryIdempotencyRecord{
+			IdempotencyRecord: model.IdempotencyRecord{
+				Caller:      input.Caller,
+				Key:         input.Key,
+				Fingerprint: input.Fingerprint,
+				Header:      map[string]string{},
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(input.TTL),
+			},
+			LockedUntil: now.Add(input.Lease),
+		}
+		output.Reserved = true
+		return nil
+	})
+	return
+}
+
+func (r *MemoryRepository) CompleteIdempotencyKey(ctx context.Context, input CompleteIdempotencyKeyInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		key := memoryIdempotencyKey{Caller: input.Caller, Key: input.Key}
+
+		record, ok := state.idempotencyKeys[key]
+		if !ok || record.Completed() {
+			return ErrNotFound
+		}
+
+		record.StatusCode = input.StatusCode
+		record.Header = input.Header
+		record.Body = input.Body
+		state.idempotencyKeys[key] = record
+		return nil
+	})
+}
+
+func (r *MemoryRepository) ReleaseIdempotencyKey(ctx context.Context, input ReleaseIdempotencyKeyInput) error {
+	return r.run(ctx, func(state *memoryState) error {
+		key := memoryIdempotencyKey{Caller: input.Caller, Key: input.Key}
+		if record, ok := state.idempotencyKeys[key]; ok && !record.Completed() {
+			delete(state.idempotencyKeys, key)
+		}
+		return nil
+	})
+}
+
+func (r *MemoryRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, input DeleteExpiredIdempotencyKeysInput) (deleted int64, err error) {
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	err = r.run(ctx, func(state *memoryState) error {
+		now := memoryNow()
+		for key, record := range state.idempotencyKeys {
+			if deleted == int64(input.Limit) {
+				break
+			}
+			if !record.ExpiresAt.After(now) {
+				delete(state.idempotencyKeys, key)
+				deleted++
+			}
+		}
+		return nil
+	})
+	return
+}
@@ -0,0 +1,91 @@
+-- SQLite counterpart of database.sql. Timestamps are written by the
+-- repository in UTC, so they compare correctly as text.
+
+CREATE TABLE users (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  -- Encrypted when a cipher is configured, see database.sql.
+  phone_number TEXT NOT NULL,
+  phone_number_index TEXT NOT NULL UNIQUE,
+  full_name TEXT NOT NULL,
+  password TEXT,
+  successful_login INTEGER NOT NULL DEFAULT 0,
+  version INTEGER NOT NULL DEFAULT 1,
+  updated_at TIMESTAMP NOT NULL,
+  preferred_language TEXT NOT NULL DEFAULT ''
+);
+
+CREATE TABLE password_history (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  user_id INTEGER NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+  password TEXT NOT NULL,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX password_history_user_id_idx ON password_history (user_id, id);
+
+CREATE TABLE audit_events (
+  id INTEGER PRIMARY KEY,
+  event_type TEXT NOT NULL,
+  user_id INTEGER NOT NULL DEFAULT 0,
+  actor_id INTEGER NOT NULL DEFAULT 0,
+  metadata TEXT NOT NULL DEFAULT '{}',
+  ip_address TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  prev_hash TEXT NOT NULL DEFAULT '',
+  hash TEXT NOT NULL
+);
+
+CREATE INDEX audit_events_user_id_idx ON audit_events (user_id);
+CREATE INDEX audit_events_event_type_idx ON audit_events (event_type);
+
+CREATE TRIGGER audit_events_no_update BEFORE UPDATE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TRIGGER audit_events_no_delete BEFORE DELETE ON audit_events
+BEGIN
+  SELECT RAISE(ABORT, 'audit_events is append-only');
+END;
+
+CREATE TABLE outbox_events (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  event_type TEXT NOT NULL,
+  aggregate_id INTEGER NOT NULL,
+  payload BLOB NOT NULL,
+  created_at TIMESTAMP NOT NULL,
+  attempts INTEGER NOT NULL DEFAULT 0,
+  claimed_until TIMESTAMP,
+  published_at TIMESTAMP,
+  last_error TEXT
+);
+
+CREATE INDEX outbox_events_unpublished_idx ON outbox_events (id) WHERE published_at IS NULL;
+
+CREATE TABLE webhook_subscriptions (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  url TEXT NOT NULL,
+  event_types TEXT NOT NULL DEFAULT '[]',
+  secret TEXT NOT NULL,
+  active BOOLEAN NOT NULL DEFAULT TRUE,
+  created_at TIMESTAMP NOT NULL
+);
+
+CREATE TABLE webhook_deliveries (
+  id INTEGER PRIMARY KEY AUTOINCREMENT,
+  subscription_id INTEGER NOT NULL REFERENCES webhook_subscriptions (id),
+  event_id INTEGER NOT NULL,
+  event_type TEXT NOT NULL,
+  payload BLOB NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending',
+  attempts INTEGER NOT NULL DEFAULT 0,
+  next_attempt_at TIMESTAMP NOT NULL,
+  last_status_code INTEGER NOT NULL DEFAULT 0,
+  last_error TEXT NOT NULL DEFAULT '',
+  created_at TIMESTAMP NOT NULL,
+  updated_at TIMESTAMP NOT NULL,
+  UNIQUE (subscription_id, event_id)
+);
+
+CREATE INDEX webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';
@@ -0,0 +1,16 @@
+-- Counterpart of the idempotency_keys table of database.sql.
+
+CREATE TABLE idempotency_keys (
+  caller TEXT NOT NULL,
+  idempotency_key TEXT NOT NULL,
+  fingerprint TEXT NOT NULL,
+  status_code INTEGER NOT NULL DEFAULT 0,
+  header TEXT NOT NULL DEFAULT '{}',
+  body BLOB,
+  created_at TIMESTAMP NOT NULL,
+  locked_until TIMESTAMP NOT NULL,
+  expires_at TIMESTAMP NOT NULL,
+  PRIMARY KEY (caller, idempotency_key)
+);
+
+CREATE INDEX idempotency_keys_expires_at_idx ON idempotency_keys (expires_at);
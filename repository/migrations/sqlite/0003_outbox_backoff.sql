@@ -0,0 +1,10 @@
+-- Failed outbox events are retried with a back-off until they are dead, see
+-- the outbox_events table of database.sql.
+
+ALTER TABLE outbox_events ADD COLUMN next_attempt_at TIMESTAMP;
+ALTER TABLE outbox_events ADD COLUMN dead_at TIMESTAMP;
+
+UPDATE outbox_events SET next_attempt_at = created_at;
+
+DROP INDEX outbox_events_unpublished_idx;
+CREATE INDEX outbox_events_unpublished_idx ON outbox_events (id) WHERE published_at IS NULL AND dead_at IS NULL;
@@ -0,0 +1,4 @@
+-- Incremented when the password changes, see the users table of
+-- database.sql.
+
+ALTER TABLE users ADD COLUMN token_version INTEGER NOT NULL DEFAULT 0;
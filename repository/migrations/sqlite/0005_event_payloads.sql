@@ -0,0 +1,8 @@
+-- The event payloads only carry the id of the user, the personal data copied
+-- by the previous versions is removed.
+
+UPDATE webhook_deliveries
+SET payload = (SELECT CAST(json_object('user_id', e.aggregate_id) AS BLOB) FROM outbox_events e WHERE e.id = webhook_deliveries.event_id)
+WHERE event_id IN (SELECT id FROM outbox_events);
+
+UPDATE outbox_events SET payload = CAST(json_object('user_id', aggregate_id) AS BLOB);
@@ -16,6 +16,184 @@ type RepositoryInterface struct {
 	mock.Mock
 }
 
+// ClaimOutboxEvents provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) ClaimOutboxEvents(ctx context.Context, in repository.ClaimOutboxEventsInput) ([]model.OutboxEvent, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []model.OutboxEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ClaimOutboxEventsInput) ([]model.OutboxEvent, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ClaimOutboxEventsInput) []model.OutboxEvent); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.OutboxEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.ClaimOutboxEventsInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// ClaimWebhookDeliveries provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) ClaimWebhookDeliveries(ctx context.Context, in repository.ClaimWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ClaimWebhookDeliveriesInput) ([]model.WebhookDelivery, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ClaimWebhookDeliveriesInput) []model.WebhookDelivery); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.ClaimWebhookDeliveriesInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// CompleteIdempotencyKey provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) CompleteIdempotencyKey(ctx context.Context, in repository.CompleteIdempotencyKeyInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.CompleteIdempotencyKeyInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeactivateWebhookSubscription provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) DeactivateWebhookSubscription(ctx context.Context, in repository.DeactivateWebhookSubscriptionInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeactivateWebhookSubscriptionInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// DeleteDeliveredWebhookDeliveries provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) DeleteDeliveredWebhookDeliveries(ctx context.Context, in repository.DeleteDeliveredWebhookDeliveriesInput) (int64, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeleteDeliveredWebhookDeliveriesInput) (int64, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeleteDeliveredWebhookDeliveriesInput) int64); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.DeleteDeliveredWebhookDeliveriesInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeleteExpiredIdempotencyKeys provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) DeleteExpiredIdempotencyKeys(ctx context.Context, in repository.DeleteExpiredIdempotencyKeysInput) (int64, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeleteExpiredIdempotencyKeysInput) (int64, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeleteExpiredIdempotencyKeysInput) int64); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.DeleteExpiredIdempotencyKeysInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// DeletePublishedOutboxEvents provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) DeletePublishedOutboxEvents(ctx context.Context, in repository.DeletePublishedOutboxEventsInput) (int64, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 int64
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeletePublishedOutboxEventsInput) (int64, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.DeletePublishedOutboxEventsInput) int64); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(int64)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.DeletePublishedOutboxEventsInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetAuditEvents provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) GetAuditEvents(ctx context.Context, in repository.GetAuditEventsInput) ([]model.AuditEvent, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []model.AuditEvent
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetAuditEventsInput) ([]model.AuditEvent, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetAuditEventsInput) []model.AuditEvent); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.AuditEvent)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.GetAuditEventsInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetLoginData provides a mock function with given fields: ctx, input
 func (_m *RepositoryInterface) GetLoginData(ctx context.Context, input repository.GetLoginDataInput) (repository.GetLoginDataOutput, error) {
 	ret := _m.Called(ctx, input)
@@ -40,6 +218,56 @@ func (_m *RepositoryInterface) GetLoginData(ctx context.Context, input repositor
 	return r0, r1
 }
 
+// GetPasswordHistory provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) GetPasswordHistory(ctx context.Context, in repository.GetPasswordHistoryInput) ([]string, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []string
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetPasswordHistoryInput) ([]string, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetPasswordHistoryInput) []string); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]string)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.GetPasswordHistoryInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetTokenVersion provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) GetTokenVersion(ctx context.Context, in repository.GetTokenVersionInput) (int32, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 int32
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetTokenVersionInput) (int32, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetTokenVersionInput) int32); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(int32)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.GetTokenVersionInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // GetUserDataByUserID provides a mock function with given fields: ctx, input
 func (_m *RepositoryInterface) GetUserDataByUserID(ctx context.Context, input repository.GetUserDataByUserIDInput) (model.User, error) {
 	ret := _m.Called(ctx, input)
@@ -64,6 +292,82 @@ func (_m *RepositoryInterface) GetUserDataByUserID(ctx context.Context, input re
 	return r0, r1
 }
 
+// GetWebhookDeliveries provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) GetWebhookDeliveries(ctx context.Context, in repository.GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []model.WebhookDelivery
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetWebhookDeliveriesInput) []model.WebhookDelivery); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookDelivery)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.GetWebhookDeliveriesInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// GetWebhookSubscriptions provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) GetWebhookSubscriptions(ctx context.Context, in repository.GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 []model.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.GetWebhookSubscriptionsInput) []model.WebhookSubscription); ok {
+		r0 = rf(ctx, in)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).([]model.WebhookSubscription)
+		}
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.GetWebhookSubscriptionsInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// InsertAuditEvent provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) InsertAuditEvent(ctx context.Context, in repository.InsertAuditEventInput) (repository.InsertAuditEventOutput, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 repository.InsertAuditEventOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.InsertAuditEventInput) (repository.InsertAuditEventOutput, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.InsertAuditEventInput) repository.InsertAuditEventOutput); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(repository.InsertAuditEventOutput)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.InsertAuditEventInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
 // InsertUser provides a mock function with given fields: ctx, in
 func (_m *RepositoryInterface) InsertUser(ctx context.Context, in repository.InsertUserInput) (repository.InsertUserOutput, error) {
 	ret := _m.Called(ctx, in)
@@ -88,6 +392,152 @@ func (_m *RepositoryInterface) InsertUser(ctx context.Context, in repository.Ins
 	return r0, r1
 }
 
+// InsertWebhookDeliveries provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) InsertWebhookDeliveries(ctx context.Context, in repository.InsertWebhookDeliveriesInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.InsertWebhookDeliveriesInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// InsertWebhookSubscription provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) InsertWebhookSubscription(ctx context.Context, in repository.InsertWebhookSubscriptionInput) (model.WebhookSubscription, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 model.WebhookSubscription
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.InsertWebhookSubscriptionInput) (model.WebhookSubscription, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.InsertWebhookSubscriptionInput) model.WebhookSubscription); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(model.WebhookSubscription)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.InsertWebhookSubscriptionInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// MarkOutboxEventFailed provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) MarkOutboxEventFailed(ctx context.Context, in repository.MarkOutboxEventFailedInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.MarkOutboxEventFailedInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// MarkOutboxEventPublished provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) MarkOutboxEventPublished(ctx context.Context, in repository.MarkOutboxEventPublishedInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.MarkOutboxEventPublishedInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RedeliverWebhookDelivery provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) RedeliverWebhookDelivery(ctx context.Context, in repository.RedeliverWebhookDeliveryInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.RedeliverWebhookDeliveryInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RehashPassword provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) RehashPassword(ctx context.Context, in repository.RehashPasswordInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.RehashPasswordInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReleaseIdempotencyKey provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) ReleaseIdempotencyKey(ctx context.Context, in repository.ReleaseIdempotencyKeyInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ReleaseIdempotencyKeyInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// ReserveIdempotencyKey provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) ReserveIdempotencyKey(ctx context.Context, in repository.ReserveIdempotencyKeyInput) (repository.ReserveIdempotencyKeyOutput, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 repository.ReserveIdempotencyKeyOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ReserveIdempotencyKeyInput) (repository.ReserveIdempotencyKeyOutput, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.ReserveIdempotencyKeyInput) repository.ReserveIdempotencyKeyOutput); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(repository.ReserveIdempotencyKeyOutput)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.ReserveIdempotencyKeyInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdatePassword provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) UpdatePassword(ctx context.Context, in repository.UpdatePasswordInput) error {
+	ret := _m.Called(ctx, in)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdatePasswordInput) error); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // UpdateSuccessfulLogin provides a mock function with given fields: ctx, in
 func (_m *RepositoryInterface) UpdateSuccessfulLogin(ctx context.Context, in repository.UpdateSuccessfulLoginInput) error {
 	ret := _m.Called(ctx, in)
@@ -103,11 +553,35 @@ func (_m *RepositoryInterface) UpdateSuccessfulLogin(ctx context.Context, in rep
 }
 
 // UpdateUserData provides a mock function with given fields: ctx, in
-func (_m *RepositoryInterface) UpdateUserData(ctx context.Context, in repository.UpdateUserDataInput) error {
+func (_m *RepositoryInterface) UpdateUserData(ctx context.Context, in repository.UpdateUserDataInput) (repository.UpdateUserDataOutput, error) {
+	ret := _m.Called(ctx, in)
+
+	var r0 repository.UpdateUserDataOutput
+	var r1 error
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateUserDataInput) (repository.UpdateUserDataOutput, error)); ok {
+		return rf(ctx, in)
+	}
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateUserDataInput) repository.UpdateUserDataOutput); ok {
+		r0 = rf(ctx, in)
+	} else {
+		r0 = ret.Get(0).(repository.UpdateUserDataOutput)
+	}
+
+	if rf, ok := ret.Get(1).(func(context.Context, repository.UpdateUserDataInput) error); ok {
+		r1 = rf(ctx, in)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// UpdateWebhookDelivery provides a mock function with given fields: ctx, in
+func (_m *RepositoryInterface) UpdateWebhookDelivery(ctx context.Context, in repository.UpdateWebhookDeliveryInput) error {
 	ret := _m.Called(ctx, in)
 
 	var r0 error
-	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateUserDataInput) error); ok {
+	if rf, ok := ret.Get(0).(func(context.Context, repository.UpdateWebhookDeliveryInput) error); ok {
 		r0 = rf(ctx, in)
 	} else {
 		r0 = ret.Error(0)
@@ -116,6 +590,20 @@ func (_m *RepositoryInterface) UpdateUserData(ctx context.Context, in repository
 	return r0
 }
 
+// WithTx provides a mock function with given fields: ctx, fn
+func (_m *RepositoryInterface) WithTx(ctx context.Context, fn func(repository.RepositoryInterface) error) error {
+	ret := _m.Called(ctx, fn)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context, func(repository.RepositoryInterface) error) error); ok {
+		r0 = rf(ctx, fn)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // NewRepositoryInterface creates a new instance of RepositoryInterface. It also registers a testing interface on the mock and a cleanup function to assert the mocks expectations.
 // The first argument is typically a *testing.T value.
 func NewRepositoryInterface(t interface {
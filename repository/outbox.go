@@ -0,0 +1,124 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+const defaultOutboxLease = time.Minute
+
+// insertOutboxEvent writes a domain event, it must be called in the transaction
+// of the change it describes.
+func (r *Repository) insertOutboxEvent(ctx context.Context, eventType string, aggregateID int32, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"INSERT INTO outbox_events (event_type, aggregate_id, payload) VALUES ($1, $2, $3)",
+		eventType,
+		aggregateID,
+		data,
+	)
+	return err
+}
+
+// ClaimOutboxEvents leases a batch of due unpublished events to the caller.
+// Events that are not marked published before the lease expires are handed
+// out again, so several relays can run side by side and a crashed relay does
+// not lose events.
+func (r *Repository) ClaimOutboxEvents(ctx context.Context, input ClaimOutboxEventsInput) (events []model.OutboxEvent, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Lease <= 0 {
+		input.Lease = defaultOutboxLease
+	}
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		`UPDATE outbox_events SET claimed_until = NOW() + $2 * INTERVAL '1 millisecond', attempts = attempts + 1
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= NOW()
+				AND (claimed_until IS NULL OR claimed_until < NOW())
+			ORDER BY id LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, event_type, aggregate_id, payload, created_at, attempts`,
+		input.Limit,
+		input.Lease.Milliseconds(),
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var event model.OutboxEvent
+
+		err = rows.Scan(&event.ID, &event.EventType, &event.AggregateID, &event.Payload, &event.CreatedAt, &event.Attempts)
+		if err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+func (r *Repository) MarkOutboxEventPublished(ctx context.Context, input MarkOutboxEventPublishedInput) (err error) {
+	defer translatePostgresError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE outbox_events SET published_at = NOW(), claimed_until = NULL, last_error = NULL WHERE id = $1",
+		input.ID,
+	)
+	return
+}
+
+// MarkOutboxEventFailed releases the lease on the event so it is retried by
+// the first claim after input.NextAttemptAt, or never again when input.Dead.
+func (r *Repository) MarkOutboxEventFailed(ctx context.Context, input MarkOutboxEventFailedInput) (err error) {
+	defer translatePostgresError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE outbox_events SET claimed_until = NULL, next_attempt_at = $3, dead_at = CASE WHEN $4 THEN NOW() END, last_error = $2 WHERE id = $1",
+		input.ID,
+		input.Error,
+		input.NextAttemptAt,
+		input.Dead,
+	)
+	return
+}
+
+// DeletePublishedOutboxEvents deletes up to input.Limit events published
+// before input.Before, 1000 when not positive. Dead events are kept for
+// investigation.
+func (r *Repository) DeletePublishedOutboxEvents(ctx context.Context, input DeletePublishedOutboxEventsInput) (deleted int64, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		`DELETE FROM outbox_events WHERE id IN (
+			SELECT id FROM outbox_events WHERE published_at < $1 LIMIT $2
+		)`,
+		input.Before,
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClaimOutboxEvents(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE outbox_events SET claimed_until = (.+) RETURNING id, event_type, aggregate_id, payload, created_at, attempts"
+	rows := sqlmock.NewRows([]string{"id", "event_type", "aggregate_id", "payload", "created_at", "attempts"}).
+		AddRow(1, model.EventUserRegistered, u.UserID, []byte(`{"user_id":1}`), time.Now(), 1)
+
+	// test 1 claim success
+	mock.ExpectQuery(query).WithArgs(10, defaultOutboxLease.Milliseconds()).WillReturnRows(rows)
+
+	events, err := repo.ClaimOutboxEvents(context.Background(), ClaimOutboxEventsInput{
+		Limit: 10,
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, model.EventUserRegistered, events[0].EventType)
+		assert.JSONEq(t, `{"user_id":1}`, string(events[0].Payload))
+	}
+
+	// test 2 claim error
+	mock.ExpectQuery(query).WillReturnError(sql.ErrConnDone)
+
+	events, err = repo.ClaimOutboxEvents(context.Background(), ClaimOutboxEventsInput{
+		Limit: 10,
+	})
+	assert.Empty(t, events)
+	assert.Error(t, err)
+}
+
+func TestMarkOutboxEvent(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	// test 1 mark published
+	mock.ExpectExec("UPDATE outbox_events SET published_at = NOW\\(\\), claimed_until = NULL, last_error = NULL WHERE id = \\$1").
+		WithArgs(int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.MarkOutboxEventPublished(context.Background(), MarkOutboxEventPublishedInput{ID: 1})
+	assert.NoError(t, err)
+
+	// test 2 mark failed
+	nextAttemptAt := time.Now().Add(time.Minute)
+	mock.ExpectExec("UPDATE outbox_events SET claimed_until = NULL, next_attempt_at = \\$3, dead_at = CASE WHEN \\$4 THEN NOW\\(\\) END, last_error = \\$2 WHERE id = \\$1").
+		WithArgs(int64(1), "timeout", nextAttemptAt, true).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err = repo.MarkOutboxEventFailed(context.Background(), MarkOutboxEventFailedInput{ID: 1, Error: "timeout", NextAttemptAt: nextAttemptAt, Dead: true})
+	assert.NoError(t, err)
+
+	// test 3 mark error
+	mock.ExpectExec("UPDATE outbox_events SET published_at").WillReturnError(sql.ErrConnDone)
+
+	err = repo.MarkOutboxEventPublished(context.Background(), MarkOutboxEventPublishedInput{ID: 1})
+	assert.Error(t, err)
+}
+
+func TestDeletePublishedOutboxEvents(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "DELETE FROM outbox_events WHERE id IN \\(\\s*SELECT id FROM outbox_events WHERE published_at < \\$1 LIMIT \\$2\\s*\\)"
+	before := time.Now().Add(-time.Hour)
+
+	// test 1 default limit
+	mock.ExpectExec(query).WithArgs(before, defaultPurgeLimit).WillReturnResult(sqlmock.NewResult(0, 3))
+
+	deleted, err := repo.DeletePublishedOutboxEvents(context.Background(), DeletePublishedOutboxEventsInput{Before: before})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(3), deleted)
+
+	// test 2 delete error
+	mock.ExpectExec(query).WillReturnError(sql.ErrConnDone)
+
+	_, err = repo.DeletePublishedOutboxEvents(context.Background(), DeletePublishedOutboxEventsInput{Before: before, Limit: 10})
+	assert.Error(t, err)
+}
@@ -0,0 +1,122 @@
+package repository
+
+import (
+	"context"
+)
+
+// GetPasswordHistory returns the password hashes of the user, the current one
+// first and then the previous ones, newest first.
+func (r *Repository) GetPasswordHistory(ctx context.Context, input GetPasswordHistoryInput) (hashes []string, err error) {
+	defer translatePostgresError(&err)
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		"SELECT password FROM ("+
+			"SELECT password, TRUE AS is_current, 0 AS history_id FROM users WHERE id = $1 AND password IS NOT NULL "+
+			"UNION ALL SELECT password, FALSE, id FROM password_history WHERE user_id = $1"+
+			") AS history ORDER BY is_current DESC, history_id DESC LIMIT $2",
+		input.UserID,
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// UpdatePassword replaces the password of the user and increments its token
+// version, revoking the access tokens issued so far. The replaced password is
+// moved to the history, of which only the newest KeepHistory entries are
+// kept. The user is locked first, so concurrent changes are applied one after
+// the other and only one of them can match ExpectedTokenVersion.
+func (r *Repository) UpdatePassword(ctx context.Context, input UpdatePasswordInput) (err error) {
+	defer translatePostgresError(&err)
+
+	query, args, err := newUpdateBuilder("users").
+		Set("password", input.Password).
+		SetExpr("token_version", "token_version + 1").
+		Where("id", input.UserID).
+		Build()
+	if err != nil {
+		return
+	}
+
+	err = r.inTx(ctx, func(txRepo *Repository) error {
+		var tokenVersion int32
+		err := txRepo.conn().QueryRowContext(
+			ctx,
+			"SELECT token_version FROM users WHERE id = $1 FOR UPDATE",
+			input.UserID,
+		).Scan(&tokenVersion)
+		if err != nil {
+			return err
+		}
+		if input.ExpectedTokenVersion != nil && *input.ExpectedTokenVersion != tokenVersion {
+			return ErrVersionConflict
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO password_history (user_id, password) SELECT id, password FROM users WHERE id = $1 AND password IS NOT NULL",
+			input.UserID,
+		)
+		if err != nil {
+			return err
+		}
+
+		res, err := txRepo.conn().ExecContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		if err = requireAffected(res); err != nil {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"DELETE FROM password_history WHERE user_id = $1 AND id NOT IN (SELECT id FROM password_history WHERE user_id = $1 ORDER BY id DESC LIMIT $2)",
+			input.UserID,
+			input.KeepHistory,
+		)
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	r.Replicas.recordWrite(userWriteKey(input.UserID))
+	return
+}
+
+// RehashPassword replaces the hash of the current password of the user by a
+// new hash of the same password, e.g. one created with stronger parameters.
+// The history is left as is. It returns ErrNotFound when the password has
+// been changed since PreviousPassword was read.
+func (r *Repository) RehashPassword(ctx context.Context, input RehashPasswordInput) (err error) {
+	defer translatePostgresError(&err)
+
+	query, args, err := newUpdateBuilder("users").
+		Set("password", input.Password).
+		Where("id", input.UserID).
+		Where("password", input.PreviousPassword).
+		Build()
+	if err != nil {
+		return
+	}
+
+	res, err := r.conn().ExecContext(ctx, query, args...)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
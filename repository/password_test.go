@@ -0,0 +1,118 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPasswordHistory(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "SELECT password FROM \\(SELECT password, TRUE AS is_current, 0 AS history_id FROM users WHERE id = \\$1 AND password IS NOT NULL UNION ALL SELECT password, FALSE, id FROM password_history WHERE user_id = \\$1\\) AS history ORDER BY is_current DESC, history_id DESC LIMIT \\$2"
+
+	// test 1 get success
+	rows := sqlmock.NewRows([]string{"password"}).
+		AddRow("current").
+		AddRow("previous")
+	mock.ExpectQuery(query).WithArgs(u.UserID, 3).WillReturnRows(rows)
+
+	hashes, err := repo.GetPasswordHistory(context.Background(), GetPasswordHistoryInput{UserID: u.UserID, Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"current", "previous"}, hashes)
+
+	// test 2 get error
+	mock.ExpectQuery(query).WithArgs(u.UserID, 3).WillReturnError(sql.ErrConnDone)
+
+	hashes, err = repo.GetPasswordHistory(context.Background(), GetPasswordHistoryInput{UserID: u.UserID, Limit: 3})
+	assert.Empty(t, hashes)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestUpdatePassword(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	lockQuery := "SELECT token_version FROM users WHERE id = \\$1 FOR UPDATE"
+	historyQuery := "INSERT INTO password_history \\(user_id, password\\) SELECT id, password FROM users WHERE id = \\$1 AND password IS NOT NULL"
+	updateQuery := "UPDATE users SET password = \\$1, token_version = token_version \\+ 1, updated_at = NOW\\(\\) WHERE id = \\$2"
+	pruneQuery := "DELETE FROM password_history WHERE user_id = \\$1 AND id NOT IN \\(SELECT id FROM password_history WHERE user_id = \\$1 ORDER BY id DESC LIMIT \\$2\\)"
+
+	tokenVersion := int32(3)
+	input := UpdatePasswordInput{UserID: u.UserID, Password: "new", KeepHistory: 2, ExpectedTokenVersion: &tokenVersion}
+	lockRows := func(tokenVersion int32) *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"token_version"}).AddRow(tokenVersion)
+	}
+
+	// test 1 update success
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(u.UserID).WillReturnRows(lockRows(3))
+	mock.ExpectExec(historyQuery).WithArgs(u.UserID).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectExec(updateQuery).WithArgs("new", u.UserID).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(pruneQuery).WithArgs(u.UserID, 2).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.UpdatePassword(context.Background(), input)
+	assert.NoError(t, err)
+
+	// test 2 missing user rolls back
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(u.UserID).WillReturnRows(sqlmock.NewRows([]string{"token_version"}))
+	mock.ExpectRollback()
+
+	err = repo.UpdatePassword(context.Background(), input)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// test 3 password changed since it was checked
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(u.UserID).WillReturnRows(lockRows(4))
+	mock.ExpectRollback()
+
+	err = repo.UpdatePassword(context.Background(), input)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+
+	// test 4 update error
+	mock.ExpectBegin()
+	mock.ExpectQuery(lockQuery).WithArgs(u.UserID).WillReturnRows(lockRows(3))
+	mock.ExpectExec(historyQuery).WithArgs(u.UserID).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	err = repo.UpdatePassword(context.Background(), input)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRehashPassword(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE users SET password = \\$1, updated_at = NOW\\(\\) WHERE id = \\$2 AND password = \\$3"
+	input := RehashPasswordInput{UserID: u.UserID, Password: "new", PreviousPassword: "old"}
+
+	// test 1 rehash success
+	mock.ExpectExec(query).WithArgs("new", u.UserID, "old").WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.RehashPassword(context.Background(), input)
+	assert.NoError(t, err)
+
+	// test 2 password changed meanwhile
+	mock.ExpectExec(query).WithArgs("new", u.UserID, "old").WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RehashPassword(context.Background(), input)
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// test 3 rehash error
+	mock.ExpectExec(query).WithArgs("new", u.UserID, "old").WillReturnError(sql.ErrConnDone)
+
+	err = repo.RehashPassword(context.Background(), input)
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
@@ -0,0 +1,228 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// FieldCipher protects the personal data columns of the users table, the
+// phone number and the full name, and the stored responses of the idempotency
+// keys, which may repeat them. The phone number is looked up by its blind
+// index, which must be deterministic.
+type FieldCipher interface {
+	// Encrypt binds the value to the associated data, see userFieldData,
+	// which Decrypt must be given again.
+	Encrypt(ctx context.Context, plaintext, associatedData string) (string, error)
+	Decrypt(ctx context.Context, value, associatedData string) (string, error)
+	// NeedsReencrypt reports whether a stored value must be encrypted again,
+	// e.g. because it was encrypted under a retired key.
+	NeedsReencrypt(value string) bool
+	BlindIndex(value string) string
+}
+
+// plaintextCipher stores the values as is, used when no cipher is configured.
+type plaintextCipher struct{}
+
+func (plaintextCipher) Encrypt(ctx context.Context, plaintext, associatedData string) (string, error) {
+	return plaintext, nil
+}
+
+func (plaintextCipher) Decrypt(ctx context.Context, value, associatedData string) (string, error) {
+	return value, nil
+}
+
+func (plaintextCipher) NeedsReencrypt(value string) bool {
+	return false
+}
+
+func (plaintextCipher) BlindIndex(value string) string {
+	return value
+}
+
+// cipher returns the configured cipher, or the plaintext one.
+func (r *Repository) cipher() FieldCipher {
+	return fieldCipher(r.Cipher)
+}
+
+func (r *Repository) encrypt(ctx context.Context, userID int32, phoneNumber, fullName *string) error {
+	return encryptUserFields(ctx, r.cipher(), userID, phoneNumber, fullName)
+}
+
+func (r *Repository) decrypt(ctx context.Context, userID int32, phoneNumber, fullName *string) error {
+	return decryptUserFields(ctx, r.cipher(), userID, phoneNumber, fullName)
+}
+
+// fieldCipher returns c, or the plaintext cipher when c is nil.
+func fieldCipher(c FieldCipher) FieldCipher {
+	if c == nil {
+		return plaintextCipher{}
+	}
+	return c
+}
+
+// userFieldData returns the associated data of a personal data column of the
+// user, so a value copied to another column or row fails to decrypt.
+func userFieldData(column string, userID int32) string {
+	return fmt.Sprintf("users.%s:%d", column, userID)
+}
+
+// idempotencyBodyData returns the associated data of the response stored for
+// the key of a caller.
+func idempotencyBodyData(caller, key string) string {
+	return fmt.Sprintf("idempotency_keys.body:%s:%s", caller, key)
+}
+
+// encryptIdempotencyBody returns the response to store for the key of a
+// caller, encrypted. An empty body is stored as is.
+func encryptIdempotencyBody(ctx context.Context, c FieldCipher, caller, key string, body []byte) ([]byte, error) {
+	if len(body) == 0 {
+		return body, nil
+	}
+
+	value, err := c.Encrypt(ctx, string(body), idempotencyBodyData(caller, key))
+	if err != nil {
+		return nil, err
+	}
+	return []byte(value), nil
+}
+
+// decryptIdempotencyBody replaces the stored response of record by its
+// plaintext.
+func decryptIdempotencyBody(ctx context.Context, c FieldCipher, record *model.IdempotencyRecord) error {
+	if len(record.Body) == 0 {
+		return nil
+	}
+
+	body, err := c.Decrypt(ctx, string(record.Body), idempotencyBodyData(record.Caller, record.Key))
+	if err != nil {
+		return err
+	}
+	record.Body = []byte(body)
+	return nil
+}
+
+// userFields pairs the phone number and full name of a user with their
+// column, skipping the nil ones.
+func userFields(phoneNumber, fullName *string) map[string]*string {
+	fields := make(map[string]*string, 2)
+	if phoneNumber != nil {
+		fields["phone_number"] = phoneNumber
+	}
+	if fullName != nil {
+		fields["full_name"] = fullName
+	}
+	return fields
+}
+
+// encryptUserFields replaces the phone number and full name of the user by
+// their encrypted form, skipping the nil ones.
+func encryptUserFields(ctx context.Context, c FieldCipher, userID int32, phoneNumber, fullName *string) (err error) {
+	for column, value := range userFields(phoneNumber, fullName) {
+		if *value, err = c.Encrypt(ctx, *value, userFieldData(column, userID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// decryptUserFields replaces the phone number and full name of the user by
+// their plaintext, skipping the nil ones.
+func decryptUserFields(ctx context.Context, c FieldCipher, userID int32, phoneNumber, fullName *string) (err error) {
+	for column, value := range userFields(phoneNumber, fullName) {
+		if *value, err = c.Decrypt(ctx, *value, userFieldData(column, userID)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// UserReencrypter is implemented by the repositories able to re-encrypt the
+// personal data of the users, see Repository.ReencryptUsers.
+type UserReencrypter interface {
+	ReencryptUsers(ctx context.Context, input ReencryptUsersInput) (ReencryptUsersOutput, error)
+}
+
+// reencryptUser encrypts the personal data of user again under the current
+// key and returns the blind index of its phone number. It reports false and
+// leaves user as is when the data is already encrypted under that key.
+func reencryptUser(ctx context.Context, c FieldCipher, user *model.User) (phoneNumberIndex string, ok bool, err error) {
+	if !c.NeedsReencrypt(user.PhoneNumber) && !c.NeedsReencrypt(user.FullName) {
+		return "", false, nil
+	}
+
+	if err = decryptUserFields(ctx, c, user.UserID, &user.PhoneNumber, &user.FullName); err != nil {
+		return "", false, fmt.Errorf("decrypt user %d: %w", user.UserID, err)
+	}
+	phoneNumberIndex = c.BlindIndex(user.PhoneNumber)
+	if err = encryptUserFields(ctx, c, user.UserID, &user.PhoneNumber, &user.FullName); err != nil {
+		return "", false, err
+	}
+	return phoneNumberIndex, true, nil
+}
+
+// ReencryptUsers encrypts again the personal data of the users after
+// AfterID, up to Limit of them, that is not encrypted under the current key,
+// and recomputes their blind index. It reports the last user read, zero when
+// there is none left.
+func (r *Repository) ReencryptUsers(ctx context.Context, input ReencryptUsersInput) (out ReencryptUsersOutput, err error) {
+	defer translatePostgresError(&err)
+
+	err = r.inTx(ctx, func(txRepo *Repository) error {
+		rows, err := txRepo.conn().QueryContext(
+			ctx,
+			"SELECT id, phone_number, full_name FROM users WHERE id > $1 ORDER BY id LIMIT $2 FOR UPDATE",
+			input.AfterID,
+			input.Limit,
+		)
+		if err != nil {
+			return err
+		}
+
+		var users []model.User
+		for rows.Next() {
+			var user model.User
+			if err := rows.Scan(&user.UserID, &user.PhoneNumber, &user.FullName); err != nil {
+				rows.Close()
+				return err
+			}
+			users = append(users, user)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		out = ReencryptUsersOutput{}
+		for _, user := range users {
+			out.LastID = user.UserID
+			phoneNumberIndex, ok, err := reencryptUser(ctx, txRepo.cipher(), &user)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			// updated_at is left as is, the user data did not change
+			_, err = txRepo.conn().ExecContext(
+				ctx,
+				"UPDATE users SET phone_number = $1, phone_number_index = $2, full_name = $3 WHERE id = $4",
+				user.PhoneNumber,
+				phoneNumberIndex,
+				user.FullName,
+				user.UserID,
+			)
+			if err != nil {
+				return err
+			}
+			out.Reencrypted++
+		}
+		return nil
+	})
+	if err != nil {
+		return ReencryptUsersOutput{}, err
+	}
+	return out, nil
+}
@@ -0,0 +1,143 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCipher marks the encrypted values with their associated data instead of
+// encrypting them, values marked with an old key need to be encrypted again.
+type testCipher struct{}
+
+func (testCipher) Encrypt(ctx context.Context, plaintext, associatedData string) (string, error) {
+	return sealed(plaintext, associatedData), nil
+}
+
+func (testCipher) Decrypt(ctx context.Context, value, associatedData string) (string, error) {
+	if marked, ok := strings.CutPrefix(value, "enc:"); ok {
+		plaintext, ok := strings.CutSuffix(marked, "@"+associatedData)
+		if !ok {
+			return "", errors.New("associated data mismatch")
+		}
+		return plaintext, nil
+	}
+	if plaintext, ok := strings.CutPrefix(value, "old:"); ok {
+		return plaintext, nil
+	}
+	return value, nil
+}
+
+func (testCipher) NeedsReencrypt(value string) bool {
+	return !strings.HasPrefix(value, "enc:")
+}
+
+func (testCipher) BlindIndex(value string) string {
+	return "idx:" + value
+}
+
+// sealed returns the value encrypted by testCipher.
+func sealed(plaintext, associatedData string) string {
+	return "enc:" + plaintext + "@" + associatedData
+}
+
+func TestEncryptedUser(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db, Cipher: testCipher{}}
+
+	phoneNumber := sealed(u.PhoneNumber, "users.phone_number:1")
+	fullName := sealed(u.FullName, "users.full_name:1")
+
+	// test 1 insert stores the values encrypted bound to the id, and the
+	// blind index
+	mock.ExpectBegin()
+	mock.ExpectQuery("SELECT nextval").WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(u.UserID))
+	mock.ExpectExec("INSERT INTO users").
+		WithArgs(u.UserID, phoneNumber, "idx:"+u.PhoneNumber, fullName, u.Password, "").
+		WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("INSERT INTO outbox_events").WithArgs(model.EventUserRegistered, u.UserID, sqlmock.AnyArg()).WillReturnResult(sqlmock.NewResult(1, 1))
+	mock.ExpectCommit()
+
+	_, err := repo.InsertUser(context.Background(), InsertUserInput{PhoneNumber: u.PhoneNumber, FullName: u.FullName, Password: u.Password})
+	assert.NoError(t, err)
+
+	// test 2 login data is looked up by blind index and decrypted
+	mock.ExpectQuery("SELECT id, full_name, password, preferred_language, token_version FROM users WHERE phone_number_index = \\$1").
+		WithArgs("idx:" + u.PhoneNumber).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "password", "preferred_language", "token_version"}).AddRow(u.UserID, fullName, u.Password, "", 0))
+
+	loginData, err := repo.GetLoginData(context.Background(), GetLoginDataInput{PhoneNumber: u.PhoneNumber})
+	assert.NoError(t, err)
+	assert.Equal(t, u.FullName, loginData.FullName)
+
+	// test 3 user data is decrypted
+	mock.ExpectQuery("SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = \\$1").
+		WithArgs(u.UserID).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login", "version", "preferred_language", "token_version"}).
+			AddRow(u.UserID, fullName, phoneNumber, 0, 1, "", 0))
+
+	user, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+	assert.NoError(t, err)
+	assert.Equal(t, u.FullName, user.FullName)
+	assert.Equal(t, u.PhoneNumber, user.PhoneNumber)
+
+	// test 4 values copied from another user are rejected
+	mock.ExpectQuery("SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = \\$1").
+		WithArgs(int32(2)).
+		WillReturnRows(sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login", "version", "preferred_language", "token_version"}).
+			AddRow(2, fullName, phoneNumber, 0, 1, "", 0))
+
+	_, err = repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: 2})
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestReencryptUsers(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db, Cipher: testCipher{}}
+
+	selectQuery := "SELECT id, phone_number, full_name FROM users WHERE id > \\$1 ORDER BY id LIMIT \\$2 FOR UPDATE"
+	updateQuery := "UPDATE users SET phone_number = \\$1, phone_number_index = \\$2, full_name = \\$3 WHERE id = \\$4"
+
+	// test 1 only the rows not encrypted under the current key are updated
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(int32(0), 3).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name"}).
+		AddRow(1, sealed("+628111111111", "users.phone_number:1"), sealed("Ana", "users.full_name:1")).
+		AddRow(2, "old:+628122222222", "old:Budi").
+		AddRow(3, "+628133333333", "Citra"))
+	mock.ExpectExec(updateQuery).WithArgs(sealed("+628122222222", "users.phone_number:2"), "idx:+628122222222", sealed("Budi", "users.full_name:2"), int32(2)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec(updateQuery).WithArgs(sealed("+628133333333", "users.phone_number:3"), "idx:+628133333333", sealed("Citra", "users.full_name:3"), int32(3)).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	out, err := repo.ReencryptUsers(context.Background(), ReencryptUsersInput{Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, ReencryptUsersOutput{LastID: 3, Reencrypted: 2}, out)
+
+	// test 2 no rows left
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(int32(3), 3).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name"}))
+	mock.ExpectCommit()
+
+	out, err = repo.ReencryptUsers(context.Background(), ReencryptUsersInput{AfterID: 3, Limit: 3})
+	assert.NoError(t, err)
+	assert.Equal(t, ReencryptUsersOutput{}, out)
+
+	// test 3 update error rolls back
+	mock.ExpectBegin()
+	mock.ExpectQuery(selectQuery).WithArgs(int32(0), 3).WillReturnRows(sqlmock.NewRows([]string{"id", "phone_number", "full_name"}).
+		AddRow(3, "+628133333333", "Citra"))
+	mock.ExpectExec(updateQuery).WillReturnError(sql.ErrConnDone)
+	mock.ExpectRollback()
+
+	_, err = repo.ReencryptUsers(context.Background(), ReencryptUsersInput{Limit: 3})
+	assert.Error(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
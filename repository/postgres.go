@@ -0,0 +1,157 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/stdlib"
+)
+
+// This file holds everything specific to the Postgres driver.
+
+const (
+	connectRetryBackoff    = 100 * time.Millisecond
+	connectMaxRetryBackoff = 5 * time.Second
+)
+
+// PoolOptions tunes the connections to Postgres. Zero values keep the
+// defaults of database/sql and pgx.
+type PoolOptions struct {
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// StatementCacheCapacity is the number of prepared statements cached by
+	// every connection, 512 when zero. A negative capacity disables the
+	// cache, e.g. behind a pooler in transaction mode, and statements are
+	// described on every run instead.
+	StatementCacheCapacity int
+}
+
+// openPostgres opens a pool of pgx connections to the database named by
+// opts.Dsn and checks it is reachable, retrying for up to opts.ConnectTimeout.
+func openPostgres(opts NewRepositoryOptions) (*sql.DB, error) {
+	db, err := openPostgresDB(opts.Dsn, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = pingWithRetry(context.Background(), db, opts.ConnectTimeout); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// openPostgresDB opens a pool of pgx connections to the database named by dsn,
+// configured by opts, without connecting yet.
+func openPostgresDB(dsn string, opts NewRepositoryOptions) (*sql.DB, error) {
+	config, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, fmt.Errorf("parse database dsn: %w", err)
+	}
+
+	switch capacity := opts.Pool.StatementCacheCapacity; {
+	case capacity > 0:
+		config.StatementCacheCapacity = capacity
+	case capacity < 0:
+		config.StatementCacheCapacity = 0
+		config.DefaultQueryExecMode = pgx.QueryExecModeDescribeExec
+	}
+
+	// The server cancels the statements running for longer, whatever the
+	// context of the caller
+	if opts.QueryTimeout > 0 {
+		config.RuntimeParams["statement_timeout"] = strconv.FormatInt(opts.QueryTimeout.Milliseconds(), 10)
+	}
+
+	db := stdlib.OpenDB(*config)
+	db.SetMaxOpenConns(opts.Pool.MaxOpenConns)
+	if opts.Pool.MaxIdleConns != 0 {
+		db.SetMaxIdleConns(opts.Pool.MaxIdleConns)
+	}
+	db.SetConnMaxLifetime(opts.Pool.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(opts.Pool.ConnMaxIdleTime)
+	return db, nil
+}
+
+// pingWithRetry pings db until it answers, backing off between attempts, and
+// gives up once timeout has elapsed or on errors that are not transient. It
+// tries only once when timeout is zero.
+func pingWithRetry(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	var (
+		deadline = time.Now().Add(timeout)
+		backoff  = connectRetryBackoff
+	)
+
+	for {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		err = fmt.Errorf("connect to database: %w", translateError(err, postgresDriverError))
+
+		// Errors such as a wrong password are not worth waiting for
+		remaining := time.Until(deadline)
+		if remaining <= 0 || !errors.Is(err, ErrTransient) {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(min(backoff, remaining)):
+		}
+		backoff = min(2*backoff, connectMaxRetryBackoff)
+	}
+}
+
+// translatePostgresError wraps *err in the domain error it stands for, see
+// translateError. It is deferred by the methods of Repository.
+func translatePostgresError(err *error) {
+	*err = translateError(*err, postgresDriverError)
+}
+
+func postgresDriverError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		var connectErr *pgconn.ConnectError
+		if errors.As(err, &connectErr) || pgconn.Timeout(err) {
+			return fmt.Errorf("%w: %w", ErrTransient, err)
+		}
+		return err
+	}
+
+	switch class := pgErr.Code[:2]; {
+	case pgErr.Code == "23505":
+		return uniqueViolation(pgErr.ConstraintName, err)
+	case pgErr.Code == "23503", pgErr.Code == "23P01":
+		// Foreign key and exclusion violations depend on the stored rows
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	case pgErr.Code == "40001", pgErr.Code == "40P01",
+		class == "08", class == "53", class == "57":
+		// Serialization failures and deadlocks, connection problems, exhausted
+		// resources and cancelled queries are worth retrying later
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	return err
+}
+
+// isRetryableTxError reports whether the transaction failed because of a
+// concurrent transaction and can be run again.
+func isRetryableTxError(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+
+	// serialization_failure and deadlock_detected
+	return pgErr.Code == "40001" || pgErr.Code == "40P01"
+}
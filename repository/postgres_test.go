@@ -0,0 +1,24 @@
+package repository
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewPostgresRepository(t *testing.T) {
+	// test 1 invalid DSN
+	_, err := NewPostgresRepository(NewRepositoryOptions{Dsn: "postgres://localhost:port/users"})
+	assert.ErrorContains(t, err, "parse database dsn")
+
+	// test 2 unreachable database is retried until the connect timeout
+	start := time.Now()
+	_, err = NewPostgresRepository(NewRepositoryOptions{
+		Dsn:            "postgres://postgres@127.0.0.1:1/users?sslmode=disable&connect_timeout=1",
+		ConnectTimeout: 300 * time.Millisecond,
+	})
+	assert.ErrorContains(t, err, "connect to database")
+	assert.ErrorIs(t, err, ErrTransient)
+	assert.GreaterOrEqual(t, time.Since(start), 300*time.Millisecond)
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultReadYourWritesWindow = 5 * time.Second
+
+	// replicaCooldown is how long a replica that failed is skipped before it
+	// is tried again.
+	replicaCooldown = 10 * time.Second
+)
+
+// ReplicaSet routes the hot reads of Repository to read replicas of the
+// primary, in turn, skipping the replicas that failed recently. Reads about a
+// user written through the repository less than the read-your-writes window
+// ago go to the primary, so replication lag cannot hide the write. A nil
+// ReplicaSet sends every read to the primary.
+type ReplicaSet struct {
+	replicas []*replica
+	next     atomic.Uint32
+	window   time.Duration
+
+	mu           sync.Mutex
+	recentWrites map[string]time.Time
+	lastSweep    time.Time
+}
+
+type replica struct {
+	db *sql.DB
+	// downUntil is the time in unix nanoseconds until which the replica is
+	// skipped.
+	downUntil atomic.Int64
+}
+
+// NewReplicaSet returns a ReplicaSet over the given databases. The
+// read-your-writes window defaults to 5s when not positive.
+func NewReplicaSet(dbs []*sql.DB, readYourWritesWindow time.Duration) *ReplicaSet {
+	if readYourWritesWindow <= 0 {
+		readYourWritesWindow = defaultReadYourWritesWindow
+	}
+
+	s := &ReplicaSet{
+		window:       readYourWritesWindow,
+		recentWrites: map[string]time.Time{},
+	}
+	for _, db := range dbs {
+		s.replicas = append(s.replicas, &replica{db: db})
+	}
+	return s
+}
+
+// openReplicas opens the replicas named by opts.ReplicaDsns. Unreachable
+// replicas do not fail the startup, they are skipped until they answer.
+func openReplicas(opts NewRepositoryOptions) (*ReplicaSet, error) {
+	if len(opts.ReplicaDsns) == 0 {
+		return nil, nil
+	}
+
+	var dbs []*sql.DB
+	for i, dsn := range opts.ReplicaDsns {
+		db, err := openPostgresDB(dsn, opts)
+		if err != nil {
+			for _, db := range dbs {
+				db.Close()
+			}
+			return nil, fmt.Errorf("replica %d: %w", i+1, err)
+		}
+		dbs = append(dbs, db)
+	}
+
+	s := NewReplicaSet(dbs, opts.ReadYourWritesWindow)
+	for _, replica := range s.replicas {
+		if err := replica.db.PingContext(context.Background()); err != nil {
+			s.markDown(replica)
+		}
+	}
+	return s, nil
+}
+
+// Close closes the connections to the replicas.
+func (s *ReplicaSet) Close() error {
+	if s == nil {
+		return nil
+	}
+
+	var errs []error
+	for _, replica := range s.replicas {
+		errs = append(errs, replica.db.Close())
+	}
+	return errors.Join(errs...)
+}
+
+// Healthy returns the number of replicas currently receiving reads, and the
+// number of replicas.
+func (s *ReplicaSet) Healthy() (healthy, total int) {
+	if s == nil {
+		return 0, 0
+	}
+
+	now := time.Now().UnixNano()
+	for _, replica := range s.replicas {
+		if replica.downUntil.Load() <= now {
+			healthy++
+		}
+	}
+	return healthy, len(s.replicas)
+}
+
+// pick returns the next replica not skipped, or nil when there is none.
+func (s *ReplicaSet) pick() *replica {
+	if s == nil || len(s.replicas) == 0 {
+		return nil
+	}
+
+	var (
+		now   = time.Now().UnixNano()
+		start = s.next.Add(1)
+	)
+	for i := range s.replicas {
+		replica := s.replicas[(int(start)+i)%len(s.replicas)]
+		if replica.downUntil.Load() <= now {
+			return replica
+		}
+	}
+	return nil
+}
+
+func (s *ReplicaSet) markDown(replica *replica) {
+	replica.downUntil.Store(time.Now().Add(replicaCooldown).UnixNano())
+}
+
+// recordWrite notes that the data identified by keys has just been written.
+func (s *ReplicaSet) recordWrite(keys ...string) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for _, key := range keys {
+		s.recentWrites[key] = now
+	}
+
+	// Forget the writes older than the window from time to time, so the map
+	// only holds about a window worth of writes
+	if now.Sub(s.lastSweep) > s.window {
+		for key, writtenAt := range s.recentWrites {
+			if now.Sub(writtenAt) > s.window {
+				delete(s.recentWrites, key)
+			}
+		}
+		s.lastSweep = now
+	}
+}
+
+// recentlyWritten reports whether any of keys was written within the window.
+func (s *ReplicaSet) recentlyWritten(keys ...string) bool {
+	if s == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, key := range keys {
+		if writtenAt, ok := s.recentWrites[key]; ok && time.Since(writtenAt) <= s.window {
+			return true
+		}
+	}
+	return false
+}
+
+func userWriteKey(userID int32) string {
+	return fmt.Sprintf("user:%d", userID)
+}
+
+// read runs query on a replica, unless the repository is bound to a
+// transaction or one of keys was written recently. It runs query again on the
+// primary when the replica fails.
+func (r *Repository) read(ctx context.Context, keys []string, query func(conn dbConn) error) error {
+	if r.tx != nil || r.Replicas.recentlyWritten(keys...) {
+		return query(r.conn())
+	}
+
+	replica := r.Replicas.pick()
+	if replica == nil {
+		return query(r.Db)
+	}
+
+	err := query(replica.db)
+	if !errors.Is(translateError(err, postgresDriverError), ErrTransient) || ctx.Err() != nil {
+		return err
+	}
+
+	r.Replicas.markDown(replica)
+	return query(r.Db)
+}
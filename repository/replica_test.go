@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"testing"
+	"time"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReplicaRouting(t *testing.T) {
+	primary, primaryMock := NewMock()
+	replica1, replica1Mock := NewMock()
+	replica2, replica2Mock := NewMock()
+	repo := &Repository{Db: primary, Replicas: NewReplicaSet([]*sql.DB{replica1, replica2}, time.Minute)}
+
+	query := "SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = \\$1"
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login", "version", "preferred_language", "token_version"}).
+			AddRow(u.UserID, u.FullName, u.PhoneNumber, u.SuccesfulLogin, 1, "", 0)
+	}
+	getUser := func() error {
+		_, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+		return err
+	}
+
+	// test 1 round-robin over the replicas
+	replica2Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	replica1Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	assert.NoError(t, getUser())
+	assert.NoError(t, getUser())
+
+	// test 2 a failed replica falls back to the primary and is skipped
+	replica2Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnError(&pgconn.PgError{Code: "57P01"})
+	primaryMock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	assert.NoError(t, getUser())
+
+	healthy, total := repo.Replicas.Healthy()
+	assert.Equal(t, 1, healthy)
+	assert.Equal(t, 2, total)
+
+	replica1Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	replica1Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	assert.NoError(t, getUser())
+	assert.NoError(t, getUser())
+
+	// test 3 missing rows are not a replica failure
+	replica1Mock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(sqlmock.NewRows([]string{"id"}))
+	assert.ErrorIs(t, getUser(), ErrNotFound)
+
+	// test 4 a user written recently is read from the primary
+	repo.Replicas.recordWrite(userWriteKey(u.UserID))
+	primaryMock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	assert.NoError(t, getUser())
+
+	// test 5 every replica down
+	repo.Replicas.markDown(repo.Replicas.replicas[0])
+	_, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: 2})
+	assert.Error(t, err, "the primary has no expectation left")
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replica1Mock.ExpectationsWereMet())
+	assert.NoError(t, replica2Mock.ExpectationsWereMet())
+}
+
+func TestReplicaReadYourWrites(t *testing.T) {
+	primary, primaryMock := NewMock()
+	replica, replicaMock := NewMock()
+	repo := &Repository{Db: primary, Replicas: NewReplicaSet([]*sql.DB{replica}, time.Minute)}
+
+	loginQuery := "SELECT id, full_name, password, preferred_language, token_version FROM users WHERE phone_number_index = \\$1"
+	query := "SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = \\$1"
+	rows := func() *sqlmock.Rows {
+		return sqlmock.NewRows([]string{"id", "full_name", "phone_number", "successful_login", "version", "preferred_language", "token_version"}).
+			AddRow(u.UserID, u.FullName, u.PhoneNumber, u.SuccesfulLogin, 1, "", 0)
+	}
+	getUser := func(userID int32) error {
+		_, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: userID})
+		return err
+	}
+
+	// test 1 login data is always read from the primary
+	primaryMock.ExpectQuery(loginQuery).WithArgs(u.PhoneNumber).WillReturnRows(
+		sqlmock.NewRows([]string{"id", "full_name", "password", "preferred_language", "token_version"}).
+			AddRow(u.UserID, u.FullName, u.Password, "id", 0),
+	)
+	_, err := repo.GetLoginData(context.Background(), GetLoginDataInput{PhoneNumber: u.PhoneNumber})
+	assert.NoError(t, err)
+
+	// test 2 the password of the user was changed, the profile is read from the primary
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT token_version FROM users").WithArgs(u.UserID).WillReturnRows(sqlmock.NewRows([]string{"token_version"}).AddRow(0))
+	primaryMock.ExpectExec("INSERT INTO password_history").WithArgs(u.UserID).WillReturnResult(sqlmock.NewResult(1, 1))
+	primaryMock.ExpectExec("UPDATE users SET password").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectExec("DELETE FROM password_history").WillReturnResult(sqlmock.NewResult(0, 0))
+	primaryMock.ExpectCommit()
+	assert.NoError(t, repo.UpdatePassword(context.Background(), UpdatePasswordInput{UserID: u.UserID, Password: "new", KeepHistory: 5}))
+
+	primaryMock.ExpectQuery(query).WithArgs(u.UserID).WillReturnRows(rows())
+	assert.NoError(t, getUser(u.UserID))
+
+	// test 3 a user registered recently is read from the primary
+	primaryMock.ExpectBegin()
+	primaryMock.ExpectQuery("SELECT nextval").WillReturnRows(sqlmock.NewRows([]string{"nextval"}).AddRow(2))
+	primaryMock.ExpectExec("INSERT INTO users").WillReturnResult(sqlmock.NewResult(0, 1))
+	primaryMock.ExpectExec("INSERT INTO outbox_events").WillReturnResult(sqlmock.NewResult(1, 1))
+	primaryMock.ExpectCommit()
+	_, err = repo.InsertUser(context.Background(), InsertUserInput{PhoneNumber: "+628123456780", FullName: "Other", Password: "hash"})
+	assert.NoError(t, err)
+
+	primaryMock.ExpectQuery(query).WithArgs(2).WillReturnRows(rows())
+	assert.NoError(t, getUser(2))
+
+	// test 4 the window expires
+	repo.Replicas.window = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	replicaMock.ExpectQuery(query).WithArgs(2).WillReturnRows(rows())
+	assert.NoError(t, getUser(2))
+
+	assert.NoError(t, primaryMock.ExpectationsWereMet())
+	assert.NoError(t, replicaMock.ExpectationsWereMet())
+}
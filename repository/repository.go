@@ -2,25 +2,101 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
-
-	_ "github.com/lib/pq"
+	"time"
 )
 
 type Repository struct {
 	Db *sql.DB
+
+	// TxIsolation and TxMaxRetries are the defaults used by WithTx.
+	TxIsolation  sql.IsolationLevel
+	TxMaxRetries int
+
+	// Cipher encrypts the personal data of the users. They are stored in
+	// plaintext when nil.
+	Cipher FieldCipher
+
+	// Replicas serve GetLoginData and GetUserDataByUserID when set.
+	Replicas *ReplicaSet
+
+	// tx is set on the copy of the repository handed to WithTx callbacks,
+	// txDepth counts the nested WithTx calls running in it.
+	tx      *sql.Tx
+	txDepth int
 }
 
 type NewRepositoryOptions struct {
-	Dsn string
+	Dsn          string
+	TxIsolation  sql.IsolationLevel
+	TxMaxRetries int
+	Cipher       FieldCipher
+
+	// Pool tunes the connections to Postgres.
+	Pool PoolOptions
+
+	// QueryTimeout bounds the time every statement may run on Postgres, on
+	// top of the deadline of its context. Zero leaves it unbounded.
+	QueryTimeout time.Duration
+
+	// ConnectTimeout is how long the connection to Postgres is retried at
+	// startup before giving up. Zero tries once.
+	ConnectTimeout time.Duration
+
+	// ReplicaDsns name the read replicas of the Postgres primary, see
+	// ReplicaSet. They share the settings of the primary.
+	ReplicaDsns []string
+
+	// ReadYourWritesWindow is how long the reads about a user go to the
+	// primary after it has been written. Defaults to 5s.
+	ReadYourWritesWindow time.Duration
 }
 
-func NewRepository(opts NewRepositoryOptions) *Repository {
-	db, err := sql.Open("postgres", opts.Dsn)
+// NewRepository returns the repository for the database named by opts.Dsn,
+// a SQLiteRepository for DSNs with the sqlite scheme and a Repository on
+// Postgres otherwise.
+func NewRepository(opts NewRepositoryOptions) (RepositoryInterface, error) {
+	if IsSQLiteDsn(opts.Dsn) {
+		return NewSQLiteRepository(opts)
+	}
+	return NewPostgresRepository(opts)
+}
+
+// NewPostgresRepository connects to the Postgres database named by opts.Dsn
+// through pgx, see openPostgres, and to its replicas.
+func NewPostgresRepository(opts NewRepositoryOptions) (*Repository, error) {
+	db, err := openPostgres(opts)
+	if err != nil {
+		return nil, err
+	}
+
+	replicas, err := openReplicas(opts)
 	if err != nil {
-		panic(err)
+		db.Close()
+		return nil, err
 	}
+
 	return &Repository{
-		Db: db,
+		Db:           db,
+		TxIsolation:  opts.TxIsolation,
+		TxMaxRetries: opts.TxMaxRetries,
+		Cipher:       opts.Cipher,
+		Replicas:     replicas,
+	}, nil
+}
+
+// dbConn is implemented by both *sql.DB and *sql.Tx.
+type dbConn interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row
+}
+
+// conn returns the transaction the repository is bound to, or the database.
+func (r *Repository) conn() dbConn {
+	if r.tx != nil {
+		return r.tx
 	}
+	return r.Db
 }
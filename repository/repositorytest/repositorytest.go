@@ -0,0 +1,1071 @@
+// Package repositorytest provides the conformance suite every implementation of
+// repository.RepositoryInterface must pass, so that handlers behave the same
+// whichever one they are given.
+package repositorytest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// concurrency is the number of goroutines racing in the concurrency tests.
+const concurrency = 20
+
+// Run runs the suite. newRepository is called once per test and must return
+// an empty repository.
+func Run(t *testing.T, newRepository func(t *testing.T) repository.RepositoryInterface) {
+	tests := []struct {
+		name string
+		test func(t *testing.T, repo repository.RepositoryInterface)
+	}{
+		{"Users", testUsers},
+		{"UniquePhoneNumber", testUniquePhoneNumber},
+		{"SuccessfulLogin", testSuccessfulLogin},
+		{"UpdateUserData", testUpdateUserData},
+		{"PasswordHistory", testPasswordHistory},
+		{"RehashPassword", testRehashPassword},
+		{"AuditEvents", testAuditEvents},
+		{"OutboxEvents", testOutboxEvents},
+		{"WebhookSubscriptions", testWebhookSubscriptions},
+		{"WebhookDeliveries", testWebhookDeliveries},
+		{"IdempotencyKeys", testIdempotencyKeys},
+		{"Transactions", testTransactions},
+		{"ConcurrentLogins", testConcurrentLogins},
+		{"ConcurrentInserts", testConcurrentInserts},
+		{"ConcurrentUpdates", testConcurrentUpdates},
+		{"ConcurrentPasswordChanges", testConcurrentPasswordChanges},
+		{"ConcurrentAuditEvents", testConcurrentAuditEvents},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.test(t, newRepository(t))
+		})
+	}
+}
+
+func insertUser(t *testing.T, repo repository.RepositoryInterface, phoneNumber string) int32 {
+	t.Helper()
+
+	out, err := repo.InsertUser(context.Background(), repository.InsertUserInput{
+		PhoneNumber:       phoneNumber,
+		FullName:          "Leonardo",
+		Password:          "hash",
+		PreferredLanguage: "id",
+	})
+	require.NoError(t, err)
+	return out.UserID
+}
+
+// claimAll claims every unpublished outbox event and marks them published.
+func claimAll(t *testing.T, repo repository.RepositoryInterface) []model.OutboxEvent {
+	t.Helper()
+	ctx := context.Background()
+
+	events, err := repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 100})
+	require.NoError(t, err)
+
+	for _, event := range events {
+		require.NoError(t, repo.MarkOutboxEventPublished(ctx, repository.MarkOutboxEventPublishedInput{ID: event.ID}))
+	}
+	return events
+}
+
+func testUsers(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	// test 1 insert
+	out, err := repo.InsertUser(ctx, repository.InsertUserInput{
+		PhoneNumber:       "+628123456789",
+		FullName:          "Leonardo",
+		Password:          "hash",
+		PreferredLanguage: "id",
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, out.UserID)
+
+	other := insertUser(t, repo, "+628123456780")
+	assert.NotEqual(t, out.UserID, other)
+
+	// test 2 login data by phone number
+	login, err := repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456789"})
+	require.NoError(t, err)
+	assert.Equal(t, repository.GetLoginDataOutput{
+		UserID:            out.UserID,
+		FullName:          "Leonardo",
+		HashedPassword:    "hash",
+		PreferredLanguage: "id",
+	}, login)
+
+	// test 3 user data by id
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: out.UserID})
+	require.NoError(t, err)
+	assert.Equal(t, model.User{
+		UserID:            out.UserID,
+		FullName:          "Leonardo",
+		PhoneNumber:       "+628123456789",
+		Version:           1,
+		PreferredLanguage: "id",
+	}, user)
+
+	// test 4 unknown users
+	_, err = repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628000000000"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: out.UserID + other})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// test 5 registration is published
+	events := claimAll(t, repo)
+	if assert.Len(t, events, 2) {
+		event := events[0]
+		if event.AggregateID != out.UserID {
+			event = events[1]
+		}
+		assert.Equal(t, model.EventUserRegistered, event.EventType)
+		assert.Equal(t, out.UserID, event.AggregateID)
+		assert.JSONEq(t, fmt.Sprintf(`{"user_id":%d}`, out.UserID), string(event.Payload))
+	}
+}
+
+func testUniquePhoneNumber(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	first := insertUser(t, repo, "+628123456789")
+	second := insertUser(t, repo, "+628123456780")
+	claimAll(t, repo)
+
+	// test 1 insert with a taken phone number
+	_, err := repo.InsertUser(ctx, repository.InsertUserInput{PhoneNumber: "+628123456789", FullName: "Other", Password: "hash"})
+	assert.ErrorIs(t, err, repository.ErrDuplicatePhone)
+
+	// test 2 update to a taken phone number
+	phoneNumber := "+628123456789"
+	_, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID: second,
+		Patch:  repository.UserPatch{PhoneNumber: &phoneNumber},
+	})
+	assert.ErrorIs(t, err, repository.ErrDuplicatePhone)
+
+	// test 3 nothing was written
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: second})
+	require.NoError(t, err)
+	assert.Equal(t, "+628123456780", user.PhoneNumber)
+	assert.Equal(t, int32(1), user.Version)
+	assert.Empty(t, claimAll(t, repo))
+
+	// test 4 a released phone number can be taken
+	phoneNumber = "+628123456781"
+	_, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID: first,
+		Patch:  repository.UserPatch{PhoneNumber: &phoneNumber},
+	})
+	require.NoError(t, err)
+
+	third := insertUser(t, repo, "+628123456789")
+	login, err := repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456789"})
+	require.NoError(t, err)
+	assert.Equal(t, third, login.UserID)
+}
+
+func testSuccessfulLogin(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+
+	// test 1 counter is incremented
+	for i := 0; i < 2; i++ {
+		err := repo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628123456789", UserID: userID})
+		require.NoError(t, err)
+	}
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), user.SuccesfulLogin)
+	assert.Equal(t, int32(1), user.Version)
+
+	// test 2 unknown phone number is ignored
+	err = repo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628000000000"})
+	assert.NoError(t, err)
+}
+
+func testUpdateUserData(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+	claimAll(t, repo)
+
+	fullName, phoneNumber, language := "Leo", "+628123456780", ""
+	version := int32(1)
+
+	// test 1 update every field
+	out, err := repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID:          userID,
+		Patch:           repository.UserPatch{FullName: &fullName, PhoneNumber: &phoneNumber, PreferredLanguage: &language},
+		ExpectedVersion: &version,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), out.Version)
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, model.User{UserID: userID, FullName: "Leo", PhoneNumber: "+628123456780", Version: 2}, user)
+
+	_, err = repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456789"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	login, err := repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456780"})
+	require.NoError(t, err)
+	assert.Equal(t, userID, login.UserID)
+
+	// test 2 changes are published
+	events := claimAll(t, repo)
+	payloads := map[string]string{}
+	for _, event := range events {
+		assert.Equal(t, userID, event.AggregateID)
+		payloads[event.EventType] = string(event.Payload)
+	}
+	assert.Len(t, payloads, 2)
+	assert.JSONEq(t, fmt.Sprintf(`{"user_id":%d}`, userID), payloads[model.EventFullNameChanged])
+	assert.JSONEq(t, fmt.Sprintf(`{"user_id":%d}`, userID), payloads[model.EventPhoneNumberChanged])
+
+	// test 3 unchanged values are not published
+	out, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID: userID,
+		Patch:  repository.UserPatch{FullName: &fullName, PhoneNumber: &phoneNumber},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), out.Version)
+	assert.Empty(t, claimAll(t, repo))
+
+	// test 4 stale version
+	_, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID:          userID,
+		Patch:           repository.UserPatch{FullName: &fullName},
+		ExpectedVersion: &version,
+	})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+
+	// test 5 unknown user
+	_, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+		UserID: userID + 1,
+		Patch:  repository.UserPatch{FullName: &fullName},
+	})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// test 6 empty patch
+	_, err = repo.UpdateUserData(ctx, repository.UpdateUserDataInput{UserID: userID})
+	assert.Error(t, err)
+
+	user, err = repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), user.Version)
+}
+
+func testPasswordHistory(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+	otherID := insertUser(t, repo, "+628123456780")
+
+	history := func(userID int32, limit int) []string {
+		hashes, err := repo.GetPasswordHistory(ctx, repository.GetPasswordHistoryInput{UserID: userID, Limit: limit})
+		require.NoError(t, err)
+		return hashes
+	}
+
+	// test 1 only the current password
+	assert.Equal(t, []string{"hash"}, history(userID, 5))
+
+	// test 2 replaced passwords are kept newest first, each change revokes the
+	// issued tokens
+	for _, password := range []string{"second", "third", "fourth"} {
+		err := repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: userID, Password: password, KeepHistory: 2})
+		require.NoError(t, err)
+	}
+	assert.Equal(t, []string{"fourth", "third", "second"}, history(userID, 5))
+	assert.Equal(t, []string{"fourth", "third"}, history(userID, 2))
+
+	login, err := repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456789"})
+	require.NoError(t, err)
+	assert.Equal(t, "fourth", login.HashedPassword)
+	assert.Equal(t, int32(3), login.TokenVersion)
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), user.TokenVersion)
+
+	tokenVersion, err := repo.GetTokenVersion(ctx, repository.GetTokenVersionInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(3), tokenVersion)
+
+	_, err = repo.GetTokenVersion(ctx, repository.GetTokenVersionInput{UserID: 999})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// test 3 history of other users is untouched
+	err = repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: otherID, Password: "other", KeepHistory: 0})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"other"}, history(otherID, 5))
+	assert.Equal(t, []string{"fourth", "third", "second"}, history(userID, 5))
+
+	// test 4 unknown user
+	err = repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: userID + otherID, Password: "new", KeepHistory: 2})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+	assert.Empty(t, history(userID+otherID, 5))
+
+	// test 5 the password was changed since the token version was read
+	stale := int32(2)
+	err = repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: userID, Password: "fifth", KeepHistory: 2, ExpectedTokenVersion: &stale})
+	assert.ErrorIs(t, err, repository.ErrVersionConflict)
+	assert.Equal(t, []string{"fourth", "third", "second"}, history(userID, 5))
+
+	current := int32(3)
+	err = repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: userID, Password: "fifth", KeepHistory: 2, ExpectedTokenVersion: &current})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"fifth", "fourth", "third"}, history(userID, 5))
+}
+
+func testRehashPassword(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+	require.NoError(t, repo.UpdatePassword(ctx, repository.UpdatePasswordInput{UserID: userID, Password: "current", KeepHistory: 5}))
+
+	// test 1 rehash the current password
+	err := repo.RehashPassword(ctx, repository.RehashPasswordInput{UserID: userID, Password: "rehashed", PreviousPassword: "current"})
+	require.NoError(t, err)
+
+	hashes, err := repo.GetPasswordHistory(ctx, repository.GetPasswordHistoryInput{UserID: userID, Limit: 5})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"rehashed", "hash"}, hashes)
+
+	// test 2 password changed meanwhile
+	err = repo.RehashPassword(ctx, repository.RehashPasswordInput{UserID: userID, Password: "other", PreviousPassword: "current"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// test 3 unknown user
+	err = repo.RehashPassword(ctx, repository.RehashPasswordInput{UserID: userID + 1, Password: "other", PreviousPassword: "hash"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testAuditEvents(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	// test 1 events are chained
+	inputs := []repository.InsertAuditEventInput{
+		{EventType: model.AuditEventLoginFailed, UserID: 1, Metadata: map[string]string{"reason": "invalid password"}, IPAddress: "10.0.0.1", RequestID: "req-1"},
+		{EventType: model.AuditEventLoginSucceeded, UserID: 1, ActorID: 1},
+		{EventType: model.AuditEventLoginSucceeded, UserID: 2, ActorID: 2},
+	}
+
+	var outputs []repository.InsertAuditEventOutput
+	for _, input := range inputs {
+		out, err := repo.InsertAuditEvent(ctx, input)
+		require.NoError(t, err)
+		outputs = append(outputs, out)
+	}
+
+	events, err := repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{})
+	require.NoError(t, err)
+	require.Len(t, events, 3)
+	assert.NoError(t, model.VerifyAuditChain(events, 0, ""))
+
+	for i, event := range events {
+		assert.Equal(t, outputs[i].ID, event.ID)
+		assert.Equal(t, outputs[i].Hash, event.Hash)
+		assert.Equal(t, inputs[i].EventType, event.EventType)
+	}
+	assert.Equal(t, map[string]string{"reason": "invalid password"}, events[0].Metadata)
+	assert.Equal(t, "10.0.0.1", events[0].IPAddress)
+	assert.Equal(t, "req-1", events[0].RequestID)
+	assert.Equal(t, map[string]string{}, events[1].Metadata)
+	assert.WithinDuration(t, time.Now(), events[0].CreatedAt, time.Minute)
+
+	// test 2 filters
+	events, err = repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{UserID: 1})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	events, err = repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{EventType: model.AuditEventLoginSucceeded})
+	require.NoError(t, err)
+	assert.Len(t, events, 2)
+
+	events, err = repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{UserID: 1, EventType: model.AuditEventLoginSucceeded})
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, outputs[1].ID, events[0].ID)
+	}
+
+	// test 3 pages
+	events, err = repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{AfterID: outputs[0].ID, Limit: 1})
+	require.NoError(t, err)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, outputs[1].ID, events[0].ID)
+		assert.NoError(t, model.VerifyAuditChain(events, outputs[0].ID, outputs[0].Hash))
+	}
+
+	events, err = repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{AfterID: outputs[2].ID})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+}
+
+func testOutboxEvents(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	first := insertUser(t, repo, "+628123456789")
+	second := insertUser(t, repo, "+628123456780")
+
+	// test 1 claim in order
+	events, err := repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 1})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, first, events[0].AggregateID)
+	assert.Equal(t, int32(1), events[0].Attempts)
+	firstEvent := events[0]
+
+	// test 2 claimed events are not handed out again
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10, Lease: 50 * time.Millisecond})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, second, events[0].AggregateID)
+	secondEvent := events[0]
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// test 3 failed events are retried once due
+	err = repo.MarkOutboxEventFailed(ctx, repository.MarkOutboxEventFailedInput{
+		ID:            firstEvent.ID,
+		Error:         "broker unavailable",
+		NextAttemptAt: time.Now().Add(time.Hour),
+	})
+	require.NoError(t, err)
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	err = repo.MarkOutboxEventFailed(ctx, repository.MarkOutboxEventFailedInput{
+		ID:            firstEvent.ID,
+		Error:         "broker unavailable",
+		NextAttemptAt: time.Now().Add(-time.Second),
+	})
+	require.NoError(t, err)
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, firstEvent.ID, events[0].ID)
+	assert.Equal(t, int32(2), events[0].Attempts)
+
+	// test 4 expired leases are handed out again
+	time.Sleep(100 * time.Millisecond)
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, secondEvent.ID, events[0].ID)
+	assert.Equal(t, int32(2), events[0].Attempts)
+
+	// test 5 published events are never handed out again
+	for _, id := range []int64{firstEvent.ID, secondEvent.ID} {
+		require.NoError(t, repo.MarkOutboxEventPublished(ctx, repository.MarkOutboxEventPublishedInput{ID: id}))
+	}
+	require.NoError(t, repo.MarkOutboxEventFailed(ctx, repository.MarkOutboxEventFailedInput{ID: firstEvent.ID, Error: "late"}))
+	require.NoError(t, repo.MarkOutboxEventPublished(ctx, repository.MarkOutboxEventPublishedInput{ID: firstEvent.ID}))
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// test 6 dead events are never handed out again
+	third := insertUser(t, repo, "+628123456781")
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, events, 1)
+	assert.Equal(t, third, events[0].AggregateID)
+
+	err = repo.MarkOutboxEventFailed(ctx, repository.MarkOutboxEventFailedInput{
+		ID:            events[0].ID,
+		Error:         "rejected",
+		NextAttemptAt: time.Now().Add(-time.Second),
+		Dead:          true,
+	})
+	require.NoError(t, err)
+
+	events, err = repo.ClaimOutboxEvents(ctx, repository.ClaimOutboxEventsInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, events)
+
+	// test 7 published events are purged once retained long enough, dead ones
+	// are kept
+	deleted, err := repo.DeletePublishedOutboxEvents(ctx, repository.DeletePublishedOutboxEventsInput{Before: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	deleted, err = repo.DeletePublishedOutboxEvents(ctx, repository.DeletePublishedOutboxEventsInput{Before: time.Now().Add(time.Second), Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = repo.DeletePublishedOutboxEvents(ctx, repository.DeletePublishedOutboxEventsInput{Before: time.Now().Add(time.Second)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+}
+
+func testWebhookSubscriptions(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	// test 1 insert
+	first, err := repo.InsertWebhookSubscription(ctx, repository.InsertWebhookSubscriptionInput{
+		URL:        "https://example.com/hooks",
+		EventTypes: []string{model.EventUserRegistered},
+		Secret:     "secret",
+	})
+	require.NoError(t, err)
+	assert.NotZero(t, first.ID)
+	assert.True(t, first.Active)
+	assert.WithinDuration(t, time.Now(), first.CreatedAt, time.Minute)
+
+	second, err := repo.InsertWebhookSubscription(ctx, repository.InsertWebhookSubscriptionInput{
+		URL:        "https://example.org/hooks",
+		EventTypes: []string{},
+		Secret:     "other",
+	})
+	require.NoError(t, err)
+
+	// test 2 list
+	subscriptions, err := repo.GetWebhookSubscriptions(ctx, repository.GetWebhookSubscriptionsInput{})
+	require.NoError(t, err)
+	if assert.Len(t, subscriptions, 2) {
+		assert.Equal(t, first.ID, subscriptions[0].ID)
+		assert.Equal(t, "https://example.com/hooks", subscriptions[0].URL)
+		assert.Equal(t, []string{model.EventUserRegistered}, subscriptions[0].EventTypes)
+		assert.Equal(t, "secret", subscriptions[0].Secret)
+		assert.Equal(t, second.ID, subscriptions[1].ID)
+	}
+
+	// test 3 deactivate
+	err = repo.DeactivateWebhookSubscription(ctx, repository.DeactivateWebhookSubscriptionInput{ID: first.ID})
+	require.NoError(t, err)
+
+	subscriptions, err = repo.GetWebhookSubscriptions(ctx, repository.GetWebhookSubscriptionsInput{ActiveOnly: true})
+	require.NoError(t, err)
+	if assert.Len(t, subscriptions, 1) {
+		assert.Equal(t, second.ID, subscriptions[0].ID)
+	}
+
+	subscriptions, err = repo.GetWebhookSubscriptions(ctx, repository.GetWebhookSubscriptionsInput{})
+	require.NoError(t, err)
+	if assert.Len(t, subscriptions, 2) {
+		assert.False(t, subscriptions[0].Active)
+	}
+
+	// test 4 unknown subscription
+	err = repo.DeactivateWebhookSubscription(ctx, repository.DeactivateWebhookSubscriptionInput{ID: first.ID + second.ID})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+}
+
+func testWebhookDeliveries(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	var subscriptionIDs []int32
+	for _, url := range []string{"https://example.com/hooks", "https://example.org/hooks"} {
+		subscription, err := repo.InsertWebhookSubscription(ctx, repository.InsertWebhookSubscriptionInput{URL: url, Secret: "secret"})
+		require.NoError(t, err)
+		subscriptionIDs = append(subscriptionIDs, subscription.ID)
+	}
+
+	event := model.OutboxEvent{ID: 7, EventType: model.EventUserRegistered, Payload: json.RawMessage(`{"user_id":1}`)}
+
+	// test 1 schedule once per subscription
+	for i := 0; i < 2; i++ {
+		err := repo.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{Event: event, SubscriptionIDs: subscriptionIDs})
+		require.NoError(t, err)
+	}
+	require.NoError(t, repo.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{Event: event}))
+
+	deliveries, err := repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0]})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	delivery := deliveries[0]
+	assert.Equal(t, event.ID, delivery.EventID)
+	assert.Equal(t, event.EventType, delivery.EventType)
+	assert.JSONEq(t, string(event.Payload), string(delivery.Payload))
+	assert.Equal(t, model.WebhookDeliveryPending, delivery.Status)
+
+	// test 2 unknown subscription
+	err = repo.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{
+		Event:           model.OutboxEvent{ID: 8, EventType: model.EventUserRegistered, Payload: event.Payload},
+		SubscriptionIDs: []int32{subscriptionIDs[0], subscriptionIDs[0] + subscriptionIDs[1]},
+	})
+	assert.Error(t, err)
+
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0]})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+
+	// test 3 claim due deliveries with their subscription
+	claimed, err := repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	require.Len(t, claimed, 2)
+	for _, delivery := range claimed {
+		assert.Equal(t, "secret", delivery.Secret)
+		assert.NotEmpty(t, delivery.URL)
+		assert.True(t, delivery.NextAttemptAt.After(time.Now()))
+	}
+
+	claimed, err = repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// test 4 record an attempt
+	nextAttemptAt := time.Now().Add(-time.Second).UTC().Truncate(time.Microsecond)
+	err = repo.UpdateWebhookDelivery(ctx, repository.UpdateWebhookDeliveryInput{
+		ID:             delivery.ID,
+		Status:         model.WebhookDeliveryPending,
+		Attempts:       1,
+		NextAttemptAt:  nextAttemptAt,
+		LastStatusCode: 500,
+		LastError:      "internal server error",
+	})
+	require.NoError(t, err)
+
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0], Status: model.WebhookDeliveryPending})
+	require.NoError(t, err)
+	if assert.Len(t, deliveries, 1) {
+		assert.Equal(t, int32(1), deliveries[0].Attempts)
+		assert.True(t, nextAttemptAt.Equal(deliveries[0].NextAttemptAt))
+		assert.Equal(t, int32(500), deliveries[0].LastStatusCode)
+		assert.Equal(t, "internal server error", deliveries[0].LastError)
+	}
+
+	claimed, err = repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, delivery.ID, claimed[0].ID)
+		assert.Equal(t, "https://example.com/hooks", claimed[0].URL)
+	}
+
+	// test 5 dead deliveries are not claimed
+	err = repo.UpdateWebhookDelivery(ctx, repository.UpdateWebhookDeliveryInput{
+		ID:            delivery.ID,
+		Status:        model.WebhookDeliveryDead,
+		Attempts:      2,
+		NextAttemptAt: nextAttemptAt,
+	})
+	require.NoError(t, err)
+
+	claimed, err = repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0], Status: model.WebhookDeliveryPending})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// test 6 redeliver
+	err = repo.RedeliverWebhookDelivery(ctx, repository.RedeliverWebhookDeliveryInput{ID: delivery.ID})
+	require.NoError(t, err)
+
+	claimed, err = repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	if assert.Len(t, claimed, 1) {
+		assert.Equal(t, delivery.ID, claimed[0].ID)
+		assert.Equal(t, int32(2), claimed[0].Attempts)
+	}
+
+	err = repo.RedeliverWebhookDelivery(ctx, repository.RedeliverWebhookDeliveryInput{ID: delivery.ID + 100})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	// test 7 pages
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0], AfterID: delivery.ID})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	// test 8 deliveries of deactivated subscriptions are not claimed
+	require.NoError(t, repo.RedeliverWebhookDelivery(ctx, repository.RedeliverWebhookDeliveryInput{ID: delivery.ID}))
+	require.NoError(t, repo.DeactivateWebhookSubscription(ctx, repository.DeactivateWebhookSubscriptionInput{ID: subscriptionIDs[0]}))
+
+	claimed, err = repo.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{Limit: 10})
+	require.NoError(t, err)
+	assert.Empty(t, claimed)
+
+	// test 9 delivered deliveries are purged once retained long enough
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[1]})
+	require.NoError(t, err)
+	require.Len(t, deliveries, 1)
+	err = repo.UpdateWebhookDelivery(ctx, repository.UpdateWebhookDeliveryInput{
+		ID:             deliveries[0].ID,
+		Status:         model.WebhookDeliveryDelivered,
+		Attempts:       1,
+		NextAttemptAt:  nextAttemptAt,
+		LastStatusCode: 204,
+	})
+	require.NoError(t, err)
+
+	deleted, err := repo.DeleteDeliveredWebhookDeliveries(ctx, repository.DeleteDeliveredWebhookDeliveriesInput{Before: time.Now().Add(-time.Hour)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(0), deleted)
+
+	deleted, err = repo.DeleteDeliveredWebhookDeliveries(ctx, repository.DeleteDeliveredWebhookDeliveriesInput{Before: time.Now().Add(time.Second)})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[1]})
+	require.NoError(t, err)
+	assert.Empty(t, deliveries)
+
+	deliveries, err = repo.GetWebhookDeliveries(ctx, repository.GetWebhookDeliveriesInput{SubscriptionID: subscriptionIDs[0]})
+	require.NoError(t, err)
+	assert.Len(t, deliveries, 1)
+}
+
+func testIdempotencyKeys(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	reserve := repository.ReserveIdempotencyKeyInput{Caller: "user:1", Key: "key-1", Fingerprint: "fingerprint-1"}
+
+	// test 1 reserve a new key
+	out, err := repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 2 a pending key is not reserved again
+	out, err = repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	assert.False(t, out.Reserved)
+	assert.Equal(t, reserve.Fingerprint, out.Record.Fingerprint)
+	assert.False(t, out.Record.Completed())
+
+	// test 3 keys are scoped by caller
+	out, err = repo.ReserveIdempotencyKey(ctx, repository.ReserveIdempotencyKeyInput{Caller: "user:2", Key: reserve.Key, Fingerprint: "fingerprint-2"})
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 4 the completed response is returned
+	err = repo.CompleteIdempotencyKey(ctx, repository.CompleteIdempotencyKeyInput{
+		Caller:     reserve.Caller,
+		Key:        reserve.Key,
+		StatusCode: 201,
+		Header:     map[string]string{"Content-Type": "application/json"},
+		Body:       []byte(`{"id":1}`),
+	})
+	require.NoError(t, err)
+
+	out, err = repo.ReserveIdempotencyKey(ctx, repository.ReserveIdempotencyKeyInput{Caller: reserve.Caller, Key: reserve.Key, Fingerprint: "other"})
+	require.NoError(t, err)
+	assert.False(t, out.Reserved)
+	assert.True(t, out.Record.Completed())
+	assert.Equal(t, reserve.Fingerprint, out.Record.Fingerprint)
+	assert.Equal(t, int32(201), out.Record.StatusCode)
+	assert.Equal(t, map[string]string{"Content-Type": "application/json"}, out.Record.Header)
+	assert.Equal(t, `{"id":1}`, string(out.Record.Body))
+	assert.True(t, out.Record.ExpiresAt.After(out.Record.CreatedAt))
+
+	// test 5 a completed key is neither completed nor released again
+	err = repo.CompleteIdempotencyKey(ctx, repository.CompleteIdempotencyKeyInput{Caller: reserve.Caller, Key: reserve.Key, StatusCode: 200})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, repository.ReleaseIdempotencyKeyInput{Caller: reserve.Caller, Key: reserve.Key}))
+	out, err = repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	assert.False(t, out.Reserved)
+
+	// test 6 a released key is reserved again
+	require.NoError(t, repo.ReleaseIdempotencyKey(ctx, repository.ReleaseIdempotencyKeyInput{Caller: "user:2", Key: reserve.Key}))
+	out, err = repo.ReserveIdempotencyKey(ctx, repository.ReserveIdempotencyKeyInput{Caller: "user:2", Key: reserve.Key, Fingerprint: "fingerprint-2"})
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 7 a pending key is taken over once its lease expired
+	leased := repository.ReserveIdempotencyKeyInput{Caller: "user:3", Key: "key-3", Fingerprint: "fingerprint-3", Lease: 10 * time.Millisecond}
+	out, err = repo.ReserveIdempotencyKey(ctx, leased)
+	require.NoError(t, err)
+	require.True(t, out.Reserved)
+
+	time.Sleep(50 * time.Millisecond)
+	out, err = repo.ReserveIdempotencyKey(ctx, leased)
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	// test 8 expired keys are reserved again and deleted
+	expiring := repository.ReserveIdempotencyKeyInput{Caller: "user:4", Key: "key-4", Fingerprint: "fingerprint-4", TTL: 10 * time.Millisecond}
+	for i := 0; i < 3; i++ {
+		expiring.Key = fmt.Sprintf("key-4-%d", i)
+		out, err = repo.ReserveIdempotencyKey(ctx, expiring)
+		require.NoError(t, err)
+		require.True(t, out.Reserved)
+		require.NoError(t, repo.CompleteIdempotencyKey(ctx, repository.CompleteIdempotencyKeyInput{Caller: expiring.Caller, Key: expiring.Key, StatusCode: 200}))
+	}
+
+	time.Sleep(50 * time.Millisecond)
+	expiring.TTL = 0
+	out, err = repo.ReserveIdempotencyKey(ctx, expiring)
+	require.NoError(t, err)
+	assert.True(t, out.Reserved)
+
+	deleted, err := repo.DeleteExpiredIdempotencyKeys(ctx, repository.DeleteExpiredIdempotencyKeysInput{Limit: 1})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	deleted, err = repo.DeleteExpiredIdempotencyKeys(ctx, repository.DeleteExpiredIdempotencyKeysInput{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), deleted)
+
+	out, err = repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	assert.False(t, out.Reserved, "unexpired keys are kept")
+}
+
+func testTransactions(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	errRollback := errors.New("rollback")
+
+	// test 1 commit
+	var userID int32
+	err := repo.WithTx(ctx, func(txRepo repository.RepositoryInterface) error {
+		userID = insertUser(t, txRepo, "+628123456789")
+		return txRepo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628123456789", UserID: userID})
+	})
+	require.NoError(t, err)
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), user.SuccesfulLogin)
+	assert.Len(t, claimAll(t, repo), 1)
+
+	// test 2 rollback undoes every change, including the outbox events
+	err = repo.WithTx(ctx, func(txRepo repository.RepositoryInterface) error {
+		insertUser(t, txRepo, "+628123456780")
+		if err := txRepo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628123456789", UserID: userID}); err != nil {
+			return err
+		}
+		return errRollback
+	})
+	assert.ErrorIs(t, err, errRollback)
+
+	_, err = repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456780"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	user, err = repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), user.SuccesfulLogin)
+	assert.Empty(t, claimAll(t, repo))
+
+	// test 3 a failed nested transaction only undoes its own changes
+	var nestedUserID int32
+	err = repo.WithTx(ctx, func(txRepo repository.RepositoryInterface) error {
+		if err := txRepo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628123456789", UserID: userID}); err != nil {
+			return err
+		}
+
+		err := txRepo.WithTx(ctx, func(nestedRepo repository.RepositoryInterface) error {
+			insertUser(t, nestedRepo, "+628123456780")
+			return errRollback
+		})
+		assert.ErrorIs(t, err, errRollback)
+
+		return txRepo.WithTx(ctx, func(nestedRepo repository.RepositoryInterface) error {
+			nestedUserID = insertUser(t, nestedRepo, "+628123456781")
+			return nil
+		})
+	})
+	require.NoError(t, err)
+
+	user, err = repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), user.SuccesfulLogin)
+
+	_, err = repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456780"})
+	assert.ErrorIs(t, err, repository.ErrNotFound)
+
+	_, err = repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456781"})
+	assert.NoError(t, err)
+
+	events := claimAll(t, repo)
+	if assert.Len(t, events, 1) {
+		assert.Equal(t, nestedUserID, events[0].AggregateID)
+	}
+}
+
+func testConcurrentLogins(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.WithTx(ctx, func(txRepo repository.RepositoryInterface) error {
+				return txRepo.UpdateSuccessfulLogin(ctx, repository.UpdateSuccessfulLoginInput{PhoneNumber: "+628123456789", UserID: userID})
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	wg.Wait()
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(concurrency), user.SuccesfulLogin)
+}
+
+func testConcurrentInserts(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		inserted  []int32
+		conflicts int
+	)
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			out, err := repo.InsertUser(ctx, repository.InsertUserInput{PhoneNumber: "+628123456789", FullName: "Leonardo", Password: "hash"})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				inserted = append(inserted, out.UserID)
+			case errors.Is(err, repository.ErrDuplicatePhone):
+				conflicts++
+			default:
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	require.Len(t, inserted, 1)
+	assert.Equal(t, concurrency-1, conflicts)
+
+	login, err := repo.GetLoginData(ctx, repository.GetLoginDataInput{PhoneNumber: "+628123456789"})
+	require.NoError(t, err)
+	assert.Equal(t, inserted[0], login.UserID)
+	assert.Len(t, claimAll(t, repo), 1)
+}
+
+func testConcurrentUpdates(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+	version := int32(1)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		updated   int
+		conflicts int
+	)
+	for i := 0; i < concurrency; i++ {
+		fullName := fmt.Sprintf("Leonardo %d", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, err := repo.UpdateUserData(ctx, repository.UpdateUserDataInput{
+				UserID:          userID,
+				Patch:           repository.UserPatch{FullName: &fullName},
+				ExpectedVersion: &version,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				updated++
+			case errors.Is(err, repository.ErrVersionConflict):
+				conflicts++
+			default:
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, updated)
+	assert.Equal(t, concurrency-1, conflicts)
+
+	user, err := repo.GetUserDataByUserID(ctx, repository.GetUserDataByUserIDInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(2), user.Version)
+}
+
+func testConcurrentPasswordChanges(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+	userID := insertUser(t, repo, "+628123456789")
+	tokenVersion := int32(0)
+
+	var (
+		wg        sync.WaitGroup
+		mu        sync.Mutex
+		changed   int
+		conflicts int
+	)
+	for i := 0; i < concurrency; i++ {
+		password := fmt.Sprintf("hash %d", i)
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := repo.UpdatePassword(ctx, repository.UpdatePasswordInput{
+				UserID:               userID,
+				Password:             password,
+				KeepHistory:          concurrency,
+				ExpectedTokenVersion: &tokenVersion,
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			switch {
+			case err == nil:
+				changed++
+			case errors.Is(err, repository.ErrVersionConflict):
+				conflicts++
+			default:
+				t.Errorf("unexpected error %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, 1, changed)
+	assert.Equal(t, concurrency-1, conflicts)
+
+	hashes, err := repo.GetPasswordHistory(ctx, repository.GetPasswordHistoryInput{UserID: userID, Limit: concurrency})
+	require.NoError(t, err)
+	assert.Len(t, hashes, 2)
+
+	tokenVersion, err = repo.GetTokenVersion(ctx, repository.GetTokenVersionInput{UserID: userID})
+	require.NoError(t, err)
+	assert.Equal(t, int32(1), tokenVersion)
+}
+
+func testConcurrentAuditEvents(t *testing.T, repo repository.RepositoryInterface) {
+	ctx := context.Background()
+
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := repo.InsertAuditEvent(ctx, repository.InsertAuditEventInput{
+				EventType: model.AuditEventLoginSucceeded,
+				UserID:    int32(i + 1),
+			})
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	events, err := repo.GetAuditEvents(ctx, repository.GetAuditEventsInput{})
+	require.NoError(t, err)
+	assert.Len(t, events, concurrency)
+	assert.NoError(t, model.VerifyAuditChain(events, 0, ""))
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+const (
+	defaultReadMaxRetries = 3
+	defaultReadRetryDelay = 50 * time.Millisecond
+	defaultReadMaxDelay   = time.Second
+)
+
+type RetryOptions struct {
+	// MaxRetries is the number of times a read is tried again after the
+	// first attempt. Defaults to 3.
+	MaxRetries int
+	// BaseDelay is the back-off before the first retry, doubled on every
+	// retry up to MaxDelay. Defaults to 50ms and 1s.
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+}
+
+// RetryingRepository decorates a repository, running its reads again when
+// they fail with ErrTransient, e.g. while the database fails over. The
+// back-off is jittered so the instances of the service do not retry in step.
+// Writes are not retried, neither are the statements run in WithTx, the
+// transactions are retried by the repositories themselves.
+type RetryingRepository struct {
+	RepositoryInterface
+	opts RetryOptions
+}
+
+// NewRetryingRepository returns repo with its reads retried.
+func NewRetryingRepository(repo RepositoryInterface, opts RetryOptions) *RetryingRepository {
+	if opts.MaxRetries <= 0 {
+		opts.MaxRetries = defaultReadMaxRetries
+	}
+	if opts.BaseDelay <= 0 {
+		opts.BaseDelay = defaultReadRetryDelay
+	}
+	if opts.MaxDelay <= 0 {
+		opts.MaxDelay = defaultReadMaxDelay
+	}
+	return &RetryingRepository{RepositoryInterface: repo, opts: opts}
+}
+
+func (r *RetryingRepository) GetLoginData(ctx context.Context, input GetLoginDataInput) (GetLoginDataOutput, error) {
+	return retryRead(ctx, r.opts, func() (GetLoginDataOutput, error) {
+		return r.RepositoryInterface.GetLoginData(ctx, input)
+	})
+}
+
+func (r *RetryingRepository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (model.User, error) {
+	return retryRead(ctx, r.opts, func() (model.User, error) {
+		return r.RepositoryInterface.GetUserDataByUserID(ctx, input)
+	})
+}
+
+func (r *RetryingRepository) GetTokenVersion(ctx context.Context, in GetTokenVersionInput) (int32, error) {
+	return retryRead(ctx, r.opts, func() (int32, error) {
+		return r.RepositoryInterface.GetTokenVersion(ctx, in)
+	})
+}
+
+func (r *RetryingRepository) GetPasswordHistory(ctx context.Context, in GetPasswordHistoryInput) ([]string, error) {
+	return retryRead(ctx, r.opts, func() ([]string, error) {
+		return r.RepositoryInterface.GetPasswordHistory(ctx, in)
+	})
+}
+
+func (r *RetryingRepository) GetAuditEvents(ctx context.Context, in GetAuditEventsInput) ([]model.AuditEvent, error) {
+	return retryRead(ctx, r.opts, func() ([]model.AuditEvent, error) {
+		return r.RepositoryInterface.GetAuditEvents(ctx, in)
+	})
+}
+
+func (r *RetryingRepository) GetWebhookSubscriptions(ctx context.Context, in GetWebhookSubscriptionsInput) ([]model.WebhookSubscription, error) {
+	return retryRead(ctx, r.opts, func() ([]model.WebhookSubscription, error) {
+		return r.RepositoryInterface.GetWebhookSubscriptions(ctx, in)
+	})
+}
+
+func (r *RetryingRepository) GetWebhookDeliveries(ctx context.Context, in GetWebhookDeliveriesInput) ([]model.WebhookDelivery, error) {
+	return retryRead(ctx, r.opts, func() ([]model.WebhookDelivery, error) {
+		return r.RepositoryInterface.GetWebhookDeliveries(ctx, in)
+	})
+}
+
+// retryRead runs read until it returns an error other than ErrTransient, at
+// most opts.MaxRetries more times, sleeping a random delay up to the
+// exponential back-off between attempts.
+func retryRead[T any](ctx context.Context, opts RetryOptions, read func() (T, error)) (T, error) {
+	backoff := opts.BaseDelay
+	for attempt := 0; ; attempt++ {
+		out, err := read()
+		if err == nil || !errors.Is(err, ErrTransient) || attempt == opts.MaxRetries {
+			return out, err
+		}
+
+		select {
+		case <-ctx.Done():
+			return out, err
+		case <-time.After(time.Duration(rand.Int63n(int64(backoff))) + 1):
+		}
+		backoff = min(2*backoff, opts.MaxDelay)
+	}
+}
@@ -0,0 +1,50 @@
+package repository
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/golang/mock/gomock"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryingRepository(t *testing.T) {
+	ctrl := gomock.NewController(t)
+	mock := NewMockRepositoryInterface(ctrl)
+	repo := NewRetryingRepository(mock, RetryOptions{MaxRetries: 2, BaseDelay: time.Millisecond})
+
+	// test 1 transient failures are retried
+	gomock.InOrder(
+		mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient),
+		mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{}, ErrTransient),
+		mock.EXPECT().GetUserDataByUserID(gomock.Any(), gomock.Any()).Return(model.User{UserID: u.UserID}, nil),
+	)
+	user, err := repo.GetUserDataByUserID(context.Background(), GetUserDataByUserIDInput{UserID: u.UserID})
+	assert.NoError(t, err)
+	assert.Equal(t, u.UserID, user.UserID)
+
+	// test 2 up to MaxRetries times
+	mock.EXPECT().GetLoginData(gomock.Any(), gomock.Any()).Return(GetLoginDataOutput{}, ErrTransient).Times(3)
+	_, err = repo.GetLoginData(context.Background(), GetLoginDataInput{PhoneNumber: u.PhoneNumber})
+	assert.ErrorIs(t, err, ErrTransient)
+
+	// test 3 other errors are not retried
+	mock.EXPECT().GetPasswordHistory(gomock.Any(), gomock.Any()).Return(nil, ErrNotFound)
+	_, err = repo.GetPasswordHistory(context.Background(), GetPasswordHistoryInput{UserID: u.UserID})
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	// test 4 writes are not retried
+	mock.EXPECT().UpdatePassword(gomock.Any(), gomock.Any()).Return(ErrTransient)
+	assert.ErrorIs(t, repo.UpdatePassword(context.Background(), UpdatePasswordInput{UserID: u.UserID}), ErrTransient)
+
+	// test 5 retries stop with the context
+	ctx, cancel := context.WithCancel(context.Background())
+	mock.EXPECT().GetAuditEvents(gomock.Any(), gomock.Any()).DoAndReturn(func(context.Context, GetAuditEventsInput) ([]model.AuditEvent, error) {
+		cancel()
+		return nil, ErrTransient
+	})
+	_, err = repo.GetAuditEvents(ctx, GetAuditEventsInput{})
+	assert.ErrorIs(t, err, ErrTransient)
+}
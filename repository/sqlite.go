@@ -0,0 +1,268 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// sqliteScheme prefixes the DSNs of SQLite databases, e.g.
+// sqlite:///var/lib/userservice/users.db or sqlite:users.db?_pragma=busy_timeout(10000).
+const sqliteScheme = "sqlite:"
+
+//go:embed migrations/sqlite/*.sql
+var sqliteMigrations embed.FS
+
+// SQLiteRepository implements RepositoryInterface on a SQLite database, for
+// single node deployments where running Postgres is not worth it. It
+// follows the contract of Repository, including the errors returned when a
+// unique constraint is violated.
+type SQLiteRepository struct {
+	Db *sql.DB
+
+	// TxMaxRetries is the number of times WithTx runs fn again when the
+	// database stays locked by other connections.
+	TxMaxRetries int
+
+	// Cipher encrypts the personal data of the users. They are stored in
+	// plaintext when nil.
+	Cipher FieldCipher
+
+	tx      *sql.Tx
+	txDepth int
+}
+
+// IsSQLiteDsn reports whether dsn names a SQLite database.
+func IsSQLiteDsn(dsn string) bool {
+	return strings.HasPrefix(dsn, sqliteScheme)
+}
+
+// NewSQLiteRepository opens the SQLite database named by opts.Dsn, creating
+// it when missing, and applies the pending migrations. SQLite transactions
+// are always serializable, opts.TxIsolation is ignored.
+func NewSQLiteRepository(opts NewRepositoryOptions) (*SQLiteRepository, error) {
+	dsn, inMemory, err := parseSQLiteDsn(opts.Dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open(sqliteDriverName, dsn)
+	if err != nil {
+		return nil, err
+	}
+
+	// Every connection to an in-memory database opens a database of its own
+	if inMemory {
+		db.SetMaxOpenConns(1)
+	}
+
+	if err = migrateSQLite(context.Background(), db); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("migrate sqlite database: %w", err)
+	}
+
+	return &SQLiteRepository{
+		Db:           db,
+		TxMaxRetries: opts.TxMaxRetries,
+		Cipher:       opts.Cipher,
+	}, nil
+}
+
+// parseSQLiteDsn converts a DSN with the sqlite scheme into the file URI
+// given to the driver, adding sqliteDsnDefaults.
+func parseSQLiteDsn(dsn string) (fileURI string, inMemory bool, err error) {
+	if !IsSQLiteDsn(dsn) {
+		return "", false, fmt.Errorf("dsn %q does not use the %s scheme", dsn, sqliteScheme)
+	}
+
+	name, rawQuery, _ := strings.Cut(strings.TrimPrefix(strings.TrimPrefix(dsn, sqliteScheme), "//"), "?")
+	if name == "" {
+		return "", false, fmt.Errorf("dsn %q does not name a database", dsn)
+	}
+
+	query, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return "", false, fmt.Errorf("parse dsn %q: %w", dsn, err)
+	}
+
+	for _, param := range sqliteDsnDefaults {
+		if !hasSQLiteDsnParam(query, param[0], param[1]) {
+			query.Add(param[0], param[1])
+		}
+	}
+
+	inMemory = name == ":memory:" || query.Get("mode") == "memory"
+	return "file:" + name + "?" + query.Encode(), inMemory, nil
+}
+
+// migrateSQLite applies the migrations not applied yet, in the order of their
+// version, the number their file name starts with.
+func migrateSQLite(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, "CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY, applied_at TIMESTAMP NOT NULL)")
+	if err != nil {
+		return err
+	}
+
+	names, err := fs.Glob(sqliteMigrations, "migrations/sqlite/*.sql")
+	if err != nil {
+		return err
+	}
+
+	migrations := map[int]string{}
+	versions := []int{}
+	for _, name := range names {
+		prefix, _, _ := strings.Cut(path.Base(name), "_")
+		version, err := strconv.Atoi(prefix)
+		if err != nil {
+			return fmt.Errorf("migration %s: version is not a number", name)
+		}
+		if _, ok := migrations[version]; ok {
+			return fmt.Errorf("migration %s: duplicate version %d", name, version)
+		}
+
+		migrations[version] = name
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	for _, version := range versions {
+		if err := applySQLiteMigration(ctx, db, version, migrations[version]); err != nil {
+			return fmt.Errorf("migration %s: %w", migrations[version], err)
+		}
+	}
+	return nil
+}
+
+func applySQLiteMigration(ctx context.Context, db *sql.DB, version int, name string) (err error) {
+	script, err := sqliteMigrations.ReadFile(name)
+	if err != nil {
+		return err
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var applied bool
+	err = tx.QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = ?)", version).Scan(&applied)
+	if err != nil {
+		return err
+	}
+	if applied {
+		return tx.Rollback()
+	}
+
+	if _, err = tx.ExecContext(ctx, string(script)); err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, applied_at) VALUES (?, ?)", version, sqliteNow())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// sqliteNow returns the current time as stored by the repository.
+func sqliteNow() time.Time {
+	return time.Now().UTC().Truncate(time.Microsecond)
+}
+
+// conn returns the transaction the repository is bound to, or the database.
+func (r *SQLiteRepository) conn() dbConn {
+	if r.tx != nil {
+		return r.tx
+	}
+	return r.Db
+}
+
+func (r *SQLiteRepository) cipher() FieldCipher {
+	return fieldCipher(r.Cipher)
+}
+
+// WithTx runs fn with a repository whose calls all go through one transaction,
+// like Repository.WithTx. fn runs again when the database stays locked by
+// other connections for longer than the busy timeout.
+func (r *SQLiteRepository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) (err error) {
+	defer translateSQLiteError(&err)
+
+	if r.tx != nil {
+		return r.withSavepoint(ctx, fn)
+	}
+
+	return retryTx(ctx, r.TxMaxRetries, isRetryableSQLiteError, func() error {
+		return r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+			return fn(txRepo)
+		})
+	})
+}
+
+func (r *SQLiteRepository) withSavepoint(ctx context.Context, fn func(RepositoryInterface) error) (err error) {
+	nested := *r
+	nested.txDepth++
+	savepoint := fmt.Sprintf("repository_tx_%d", nested.txDepth)
+
+	if _, err = r.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return
+	}
+
+	if err = fn(&nested); err != nil {
+		if _, rollbackErr := r.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return
+	}
+
+	_, err = r.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return
+}
+
+// inTx runs fn with a repository bound to a transaction which is committed when
+// fn succeeds and rolled back otherwise. When the repository is already bound
+// to a transaction fn joins it.
+func (r *SQLiteRepository) inTx(ctx context.Context, fn func(txRepo *SQLiteRepository) error) (err error) {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	tx, err := r.Db.BeginTx(ctx, nil)
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	txRepo := *r
+	txRepo.tx = tx
+
+	if err = fn(&txRepo); err != nil {
+		return
+	}
+
+	return tx.Commit()
+}
+
+// sqlitePlaceholders returns n comma separated placeholders.
+func sqlitePlaceholders(n int) string {
+	return strings.TrimSuffix(strings.Repeat("?, ", n), ", ")
+}
@@ -0,0 +1,115 @@
+package repository
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// This file holds everything specific to the SQLite driver, the pure Go
+// modernc.org/sqlite.
+
+const sqliteDriverName = "sqlite"
+
+// sqliteDsnDefaults are added to the SQLite DSNs that do not set them, the
+// pragmas by name. The busy timeout makes concurrent writers wait for each
+// other, and immediate transactions take the write lock upfront so two
+// transactions reading before writing cannot deadlock.
+var sqliteDsnDefaults = [][2]string{
+	{"_pragma", "busy_timeout(5000)"},
+	{"_pragma", "foreign_keys(1)"},
+	{"_pragma", "journal_mode(WAL)"},
+	{"_txlock", "immediate"},
+}
+
+// hasSQLiteDsnParam reports whether the query of a DSN sets the parameter of
+// sqliteDsnDefaults, or the same pragma for _pragma.
+func hasSQLiteDsnParam(query url.Values, name, value string) bool {
+	if name != "_pragma" {
+		return query.Has(name)
+	}
+
+	pragma := sqlitePragmaName(value)
+	for _, v := range query[name] {
+		if sqlitePragmaName(v) == pragma {
+			return true
+		}
+	}
+	return false
+}
+
+// sqlitePragmaName returns the name of a pragma set as name(value) or
+// name=value.
+func sqlitePragmaName(pragma string) string {
+	name, _, _ := strings.Cut(pragma, "(")
+	name, _, _ = strings.Cut(name, "=")
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+// translateSQLiteError wraps *err in the domain error it stands for, see
+// translateError. It is deferred by the methods of SQLiteRepository.
+func translateSQLiteError(err *error) {
+	*err = translateError(*err, sqliteDriverError)
+}
+
+func sqliteDriverError(err error) error {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return err
+	}
+
+	// The codes are the extended ones, enabled by the driver
+	switch sqliteErr.Code() {
+	case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+		return uniqueViolation(sqliteConstraintName(sqliteErr.Error()), err)
+	case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+		return fmt.Errorf("%w: %w", ErrConflict, err)
+	}
+
+	if isRetryableSQLiteError(err) {
+		return fmt.Errorf("%w: %w", ErrTransient, err)
+	}
+	return err
+}
+
+// sqliteConstraintName names the unique constraint reported in a message
+// such as "constraint failed: UNIQUE constraint failed:
+// users.phone_number_index (2067)" the way Postgres names it,
+// users_phone_number_index_key.
+func sqliteConstraintName(message string) string {
+	const prefix = "constraint failed: "
+
+	i := strings.LastIndex(message, prefix)
+	if i < 0 {
+		return ""
+	}
+	columns, _, _ := strings.Cut(message[i+len(prefix):], " (")
+
+	var (
+		table string
+		name  []string
+	)
+	for _, column := range strings.Split(columns, ", ") {
+		table, column, _ = strings.Cut(column, ".")
+		name = append(name, column)
+	}
+
+	return table + "_" + strings.Join(name, "_") + "_key"
+}
+
+// isRetryableSQLiteError reports whether the database was locked by another
+// connection for longer than the busy timeout.
+func isRetryableSQLiteError(err error) bool {
+	var sqliteErr *sqlite.Error
+	if !errors.As(err, &sqliteErr) {
+		return false
+	}
+
+	// The primary code is in the low byte of the extended one
+	code := sqliteErr.Code() & 0xff
+	return code == sqlite3.SQLITE_BUSY || code == sqlite3.SQLITE_LOCKED
+}
@@ -0,0 +1,587 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+// insertOutboxEvent writes a domain event, it must be called in the transaction
+// of the change it describes.
+func (r *SQLiteRepository) insertOutboxEvent(ctx context.Context, eventType string, aggregateID int32, payload interface{}) error {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	now := sqliteNow()
+	_, err = r.conn().ExecContext(
+		ctx,
+		"INSERT INTO outbox_events (event_type, aggregate_id, payload, created_at, next_attempt_at) VALUES (?, ?, ?, ?, ?)",
+		eventType,
+		aggregateID,
+		data,
+		now,
+		now,
+	)
+	return err
+}
+
+// InsertAuditEvent appends an event to the audit chain. The write lock taken by
+// the transaction serialises the inserts, like the advisory lock of
+// Repository.InsertAuditEvent.
+func (r *SQLiteRepository) InsertAuditEvent(ctx context.Context, input InsertAuditEventInput) (output InsertAuditEventOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	event := model.AuditEvent{
+		EventType: input.EventType,
+		UserID:    input.UserID,
+		ActorID:   input.ActorID,
+		Metadata:  input.Metadata,
+		IPAddress: input.IPAddress,
+		RequestID: input.RequestID,
+		CreatedAt: sqliteNow(),
+	}
+	if event.Metadata == nil {
+		event.Metadata = map[string]string{}
+	}
+
+	metadata, err := json.Marshal(event.Metadata)
+	if err != nil {
+		return
+	}
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		err := txRepo.conn().QueryRowContext(
+			ctx,
+			"SELECT id, hash FROM audit_events ORDER BY id DESC LIMIT 1",
+		).Scan(&event.ID, &event.PrevHash)
+		if err != nil && !errors.Is(err, sql.ErrNoRows) {
+			return err
+		}
+
+		event.ID++
+		event.Hash = event.ComputeHash()
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO audit_events (id, event_type, user_id, actor_id, metadata, ip_address, request_id, created_at, prev_hash, hash) VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)",
+			event.ID,
+			event.EventType,
+			event.UserID,
+			event.ActorID,
+			string(metadata),
+			event.IPAddress,
+			event.RequestID,
+			event.CreatedAt,
+			event.PrevHash,
+			event.Hash,
+		)
+		return err
+	})
+	if err != nil {
+		return
+	}
+
+	output.ID = event.ID
+	output.Hash = event.Hash
+	return
+}
+
+func (r *SQLiteRepository) GetAuditEvents(ctx context.Context, input GetAuditEventsInput) (events []model.AuditEvent, err error) {
+	defer translateSQLiteError(&err)
+
+	var (
+		conditions = []string{"id > ?"}
+		args       = []interface{}{input.AfterID}
+	)
+
+	if input.UserID != 0 {
+		conditions = append(conditions, "user_id = ?")
+		args = append(args, input.UserID)
+	}
+
+	if input.EventType != "" {
+		conditions = append(conditions, "event_type = ?")
+		args = append(args, input.EventType)
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultAuditEventsLimit
+	}
+	args = append(args, input.Limit)
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		"SELECT id, event_type, user_id, actor_id, metadata, ip_address, request_id, created_at, prev_hash, hash FROM audit_events WHERE "+
+			strings.Join(conditions, " AND ")+" ORDER BY id LIMIT ?",
+		args...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			event    model.AuditEvent
+			metadata []byte
+		)
+
+		err = rows.Scan(
+			&event.ID,
+			&event.EventType,
+			&event.UserID,
+			&event.ActorID,
+			&metadata,
+			&event.IPAddress,
+			&event.RequestID,
+			&event.CreatedAt,
+			&event.PrevHash,
+			&event.Hash,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(metadata, &event.Metadata); err != nil {
+			return nil, err
+		}
+
+		events = append(events, event)
+	}
+
+	return events, rows.Err()
+}
+
+// ClaimOutboxEvents leases a batch of unpublished events to the caller, see
+// Repository.ClaimOutboxEvents.
+func (r *SQLiteRepository) ClaimOutboxEvents(ctx context.Context, input ClaimOutboxEventsInput) (events []model.OutboxEvent, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Lease <= 0 {
+		input.Lease = defaultOutboxLease
+	}
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		now := sqliteNow()
+
+		ids, err := txRepo.queryIDs(
+			ctx,
+			"SELECT id FROM outbox_events WHERE published_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ? AND (claimed_until IS NULL OR claimed_until < ?) ORDER BY id LIMIT ?",
+			now,
+			now,
+			input.Limit,
+		)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"UPDATE outbox_events SET claimed_until = ?, attempts = attempts + 1 WHERE id IN ("+sqlitePlaceholders(len(ids))+")",
+			append([]interface{}{now.Add(input.Lease)}, ids...)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := txRepo.conn().QueryContext(
+			ctx,
+			"SELECT id, event_type, aggregate_id, payload, created_at, attempts FROM outbox_events WHERE id IN ("+sqlitePlaceholders(len(ids))+") ORDER BY id",
+			ids...,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var event model.OutboxEvent
+
+			err = rows.Scan(&event.ID, &event.EventType, &event.AggregateID, &event.Payload, &event.CreatedAt, &event.Attempts)
+			if err != nil {
+				return err
+			}
+
+			events = append(events, event)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) MarkOutboxEventPublished(ctx context.Context, input MarkOutboxEventPublishedInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE outbox_events SET published_at = ?, claimed_until = NULL, last_error = NULL WHERE id = ?",
+		sqliteNow(),
+		input.ID,
+	)
+	return
+}
+
+// MarkOutboxEventFailed releases the lease on the event, see
+// Repository.MarkOutboxEventFailed.
+func (r *SQLiteRepository) MarkOutboxEventFailed(ctx context.Context, input MarkOutboxEventFailedInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	var deadAt interface{}
+	if input.Dead {
+		deadAt = sqliteNow()
+	}
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE outbox_events SET claimed_until = NULL, next_attempt_at = ?, dead_at = ?, last_error = ? WHERE id = ?",
+		input.NextAttemptAt.UTC(),
+		deadAt,
+		input.Error,
+		input.ID,
+	)
+	return
+}
+
+func (r *SQLiteRepository) DeletePublishedOutboxEvents(ctx context.Context, input DeletePublishedOutboxEventsInput) (deleted int64, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"DELETE FROM outbox_events WHERE id IN (SELECT id FROM outbox_events WHERE published_at < ? LIMIT ?)",
+		input.Before.UTC(),
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
+
+func (r *SQLiteRepository) InsertWebhookSubscription(ctx context.Context, input InsertWebhookSubscriptionInput) (output model.WebhookSubscription, err error) {
+	defer translateSQLiteError(&err)
+
+	eventTypes, err := json.Marshal(input.EventTypes)
+	if err != nil {
+		return
+	}
+
+	output = model.WebhookSubscription{
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     input.Secret,
+		Active:     true,
+		CreatedAt:  sqliteNow(),
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"INSERT INTO webhook_subscriptions (url, event_types, secret, created_at) VALUES (?, ?, ?, ?)",
+		input.URL,
+		string(eventTypes),
+		input.Secret,
+		output.CreatedAt,
+	)
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	output.ID = int32(id)
+	return
+}
+
+func (r *SQLiteRepository) GetWebhookSubscriptions(ctx context.Context, input GetWebhookSubscriptionsInput) (subscriptions []model.WebhookSubscription, err error) {
+	defer translateSQLiteError(&err)
+
+	query := "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions"
+	if input.ActiveOnly {
+		query += " WHERE active"
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.conn().QueryContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			subscription model.WebhookSubscription
+			eventTypes   []byte
+		)
+
+		err = rows.Scan(&subscription.ID, &subscription.URL, &eventTypes, &subscription.Secret, &subscription.Active, &subscription.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(eventTypes, &subscription.EventTypes); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// DeactivateWebhookSubscription stops new deliveries to the subscription but keeps
+// its delivery log. It returns ErrNotFound when the subscription does not exist.
+func (r *SQLiteRepository) DeactivateWebhookSubscription(ctx context.Context, input DeactivateWebhookSubscriptionInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE webhook_subscriptions SET active = FALSE WHERE id = ?",
+		input.ID,
+	)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+// InsertWebhookDeliveries schedules the event for every given subscription.
+// Events relayed more than once are only scheduled once per subscription.
+func (r *SQLiteRepository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	if len(input.SubscriptionIDs) == 0 {
+		return nil
+	}
+
+	var (
+		now    = sqliteNow()
+		values = []string{}
+		args   = []interface{}{}
+	)
+
+	for _, subscriptionID := range input.SubscriptionIDs {
+		values = append(values, "(?, ?, ?, ?, ?, ?, ?)")
+		args = append(args, subscriptionID, input.Event.ID, input.Event.EventType, []byte(input.Event.Payload), now, now, now)
+	}
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload, next_attempt_at, created_at, updated_at) VALUES "+
+			strings.Join(values, ", ")+" ON CONFLICT (subscription_id, event_id) DO NOTHING",
+		args...,
+	)
+	return
+}
+
+// ClaimWebhookDeliveries leases a batch of due deliveries together with the URL
+// and secret of their subscription, see Repository.ClaimWebhookDeliveries.
+func (r *SQLiteRepository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Lease <= 0 {
+		input.Lease = defaultWebhookLease
+	}
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		now := sqliteNow()
+
+		ids, err := txRepo.queryIDs(
+			ctx,
+			"SELECT d.id FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id WHERE d.status = ? AND d.next_attempt_at <= ? AND s.active ORDER BY d.next_attempt_at LIMIT ?",
+			model.WebhookDeliveryPending,
+			now,
+			input.Limit,
+		)
+		if err != nil || len(ids) == 0 {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id IN ("+sqlitePlaceholders(len(ids))+")",
+			append([]interface{}{now.Add(input.Lease)}, ids...)...,
+		)
+		if err != nil {
+			return err
+		}
+
+		rows, err := txRepo.conn().QueryContext(
+			ctx,
+			"SELECT "+sqliteWebhookDeliveryColumns("d")+", s.url, s.secret FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id WHERE d.id IN ("+
+				sqlitePlaceholders(len(ids))+") ORDER BY d.id",
+			ids...,
+		)
+		if err != nil {
+			return err
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var delivery model.WebhookDelivery
+
+			err = rows.Scan(append(webhookDeliveryFields(&delivery), &delivery.URL, &delivery.Secret)...)
+			if err != nil {
+				return err
+			}
+
+			deliveries = append(deliveries, delivery)
+		}
+
+		return rows.Err()
+	})
+	if err != nil {
+		return nil, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) UpdateWebhookDelivery(ctx context.Context, input UpdateWebhookDeliveryInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE webhook_deliveries SET status = ?, attempts = ?, next_attempt_at = ?, last_status_code = ?, last_error = ?, updated_at = ? WHERE id = ?",
+		input.Status,
+		input.Attempts,
+		input.NextAttemptAt.UTC(),
+		input.LastStatusCode,
+		input.LastError,
+		sqliteNow(),
+		input.ID,
+	)
+	return
+}
+
+func (r *SQLiteRepository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	defer translateSQLiteError(&err)
+
+	var (
+		conditions = []string{"subscription_id = ?", "id > ?"}
+		args       = []interface{}{input.SubscriptionID, input.AfterID}
+	)
+
+	if input.Status != "" {
+		conditions = append(conditions, "status = ?")
+		args = append(args, input.Status)
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultWebhookDeliveriesLimit
+	}
+	args = append(args, input.Limit)
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		fmt.Sprintf(
+			"SELECT %s FROM webhook_deliveries WHERE %s ORDER BY id LIMIT ?",
+			webhookDeliveryColumns,
+			strings.Join(conditions, " AND "),
+		),
+		args...,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+
+		if err = rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RedeliverWebhookDelivery makes the delivery due immediately, including dead
+// ones. It returns ErrNotFound when the delivery does not exist.
+func (r *SQLiteRepository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	now := sqliteNow()
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE webhook_deliveries SET status = ?, next_attempt_at = ?, updated_at = ? WHERE id = ?",
+		model.WebhookDeliveryPending,
+		now,
+		now,
+		input.ID,
+	)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+func (r *SQLiteRepository) DeleteDeliveredWebhookDeliveries(ctx context.Context, input DeleteDeliveredWebhookDeliveriesInput) (deleted int64, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"DELETE FROM webhook_deliveries WHERE id IN (SELECT id FROM webhook_deliveries WHERE status = ? AND updated_at < ? LIMIT ?)",
+		model.WebhookDeliveryDelivered,
+		input.Before.UTC(),
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
+
+// sqliteWebhookDeliveryColumns returns webhookDeliveryColumns qualified by table.
+func sqliteWebhookDeliveryColumns(table string) string {
+	columns := strings.Split(webhookDeliveryColumns, ", ")
+	for i, column := range columns {
+		columns[i] = table + "." + column
+	}
+	return strings.Join(columns, ", ")
+}
+
+// queryIDs returns the first column of the rows selected by query.
+func (r *SQLiteRepository) queryIDs(ctx context.Context, query string, args ...interface{}) (ids []interface{}, err error) {
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		if err = rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, rows.Err()
+}
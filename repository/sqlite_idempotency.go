@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+)
+
+// ReserveIdempotencyKey hands the key to the caller unless a record holds it,
+// see Repository.ReserveIdempotencyKey.
+func (r *SQLiteRepository) ReserveIdempotencyKey(ctx context.Context, input ReserveIdempotencyKeyInput) (output ReserveIdempotencyKeyOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	input.applyDefaults()
+
+	for attempt := 0; attempt < idempotencyReserveMaxAttempts; attempt++ {
+		now := sqliteNow()
+
+		var res sql.Result
+		res, err = r.conn().ExecContext(
+			ctx,
+			`INSERT INTO idempotency_keys (caller, idempotency_key, fingerprint, created_at, locked_until, expires_at)
+			VALUES (?1, ?2, ?3, ?4, ?5, ?6)
+			ON CONFLICT (caller, idempotency_key) DO UPDATE SET
+				fingerprint = excluded.fingerprint, status_code = 0, header = '{}', body = NULL,
+				created_at = excluded.created_at, locked_until = excluded.locked_until, expires_at = excluded.expires_at
+			WHERE idempotency_keys.expires_at <= ?4
+				OR (idempotency_keys.status_code = 0 AND idempotency_keys.locked_until <= ?4)`,
+			input.Caller,
+			input.Key,
+			input.Fingerprint,
+			now,
+			now.Add(input.Lease),
+			now.Add(input.TTL),
+		)
+		if err != nil {
+			return output, err
+		}
+
+		if err = requireAffected(res); err == nil {
+			output.Reserved = true
+			return output, nil
+		}
+
+		output.Record, err = scanIdempotencyRecord(r.conn().QueryRowContext(
+			ctx,
+			"SELECT "+idempotencyRecordColumns+" FROM idempotency_keys WHERE caller = ? AND idempotency_key = ?",
+			input.Caller,
+			input.Key,
+		))
+		if err == nil {
+			err = decryptIdempotencyBody(ctx, r.cipher(), &output.Record)
+		}
+		if !errors.Is(translateError(err, sqliteDriverError), ErrNotFound) {
+			return output, err
+		}
+	}
+
+	return output, ErrConflict
+}
+
+// CompleteIdempotencyKey stores the response to the first request, see
+// Repository.CompleteIdempotencyKey.
+func (r *SQLiteRepository) CompleteIdempotencyKey(ctx context.Context, input CompleteIdempotencyKeyInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	header, err := json.Marshal(input.Header)
+	if err != nil {
+		return
+	}
+
+	// The response may hold the personal data of a user
+	body, err := encryptIdempotencyBody(ctx, r.cipher(), input.Caller, input.Key, input.Body)
+	if err != nil {
+		return
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE idempotency_keys SET status_code = ?, header = ?, body = ? WHERE caller = ? AND idempotency_key = ? AND status_code = 0",
+		input.StatusCode,
+		string(header),
+		body,
+		input.Caller,
+		input.Key,
+	)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+func (r *SQLiteRepository) ReleaseIdempotencyKey(ctx context.Context, input ReleaseIdempotencyKeyInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"DELETE FROM idempotency_keys WHERE caller = ? AND idempotency_key = ? AND status_code = 0",
+		input.Caller,
+		input.Key,
+	)
+	return
+}
+
+func (r *SQLiteRepository) DeleteExpiredIdempotencyKeys(ctx context.Context, input DeleteExpiredIdempotencyKeysInput) (deleted int64, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"DELETE FROM idempotency_keys WHERE rowid IN (SELECT rowid FROM idempotency_keys WHERE expires_at <= ? LIMIT ?)",
+		sqliteNow(),
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
@@ -0,0 +1,128 @@
+package repository
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/SawitProRecruitment/UserService/encryption"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSQLiteDsn(t *testing.T) {
+	// test 1 absolute path with the defaults
+	fileURI, inMemory, err := parseSQLiteDsn("sqlite:///var/lib/userservice/users.db")
+	assert.NoError(t, err)
+	assert.False(t, inMemory)
+	assert.Equal(t, "file:/var/lib/userservice/users.db?_pragma=busy_timeout%285000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_txlock=immediate", fileURI)
+
+	// test 2 relative path overriding defaults
+	fileURI, _, err = parseSQLiteDsn("sqlite:users.db?_pragma=BUSY_TIMEOUT(10000)&_txlock=deferred")
+	assert.NoError(t, err)
+	assert.Equal(t, "file:users.db?_pragma=BUSY_TIMEOUT%2810000%29&_pragma=foreign_keys%281%29&_pragma=journal_mode%28WAL%29&_txlock=deferred", fileURI)
+
+	// test 3 in-memory database
+	_, inMemory, err = parseSQLiteDsn("sqlite::memory:")
+	assert.NoError(t, err)
+	assert.True(t, inMemory)
+
+	_, inMemory, err = parseSQLiteDsn("sqlite:users?mode=memory")
+	assert.NoError(t, err)
+	assert.True(t, inMemory)
+
+	// test 4 invalid DSNs
+	_, _, err = parseSQLiteDsn("postgres://localhost/users")
+	assert.Error(t, err)
+
+	_, _, err = parseSQLiteDsn("sqlite://")
+	assert.Error(t, err)
+}
+
+func TestSQLiteConstraintName(t *testing.T) {
+	assert.Equal(t, "users_phone_number_index_key", sqliteConstraintName("constraint failed: UNIQUE constraint failed: users.phone_number_index (2067)"))
+	assert.Equal(t, "webhook_deliveries_subscription_id_event_id_key", sqliteConstraintName("constraint failed: UNIQUE constraint failed: webhook_deliveries.subscription_id, webhook_deliveries.event_id (2067)"))
+	assert.Equal(t, "", sqliteConstraintName("constraint failed"))
+}
+
+func TestNewSQLiteRepository(t *testing.T) {
+	dsn := "sqlite://" + t.TempDir() + "/users.db"
+
+	// test 1 the database is created and migrated
+	repo, err := NewSQLiteRepository(NewRepositoryOptions{Dsn: dsn})
+	require.NoError(t, err)
+
+	_, err = repo.InsertUser(context.Background(), InsertUserInput{
+		PhoneNumber: u.PhoneNumber,
+		FullName:    u.FullName,
+		Password:    u.Password,
+	})
+	assert.NoError(t, err)
+	require.NoError(t, repo.Db.Close())
+
+	// test 2 migrations are applied only once
+	repo, err = NewSQLiteRepository(NewRepositoryOptions{Dsn: dsn})
+	require.NoError(t, err)
+	defer repo.Db.Close()
+
+	var migrations int
+	require.NoError(t, repo.Db.QueryRow("SELECT COUNT(*) FROM schema_migrations").Scan(&migrations))
+	assert.Equal(t, 5, migrations)
+
+	// test 3 unique violations are translated
+	_, err = repo.InsertUser(context.Background(), InsertUserInput{
+		PhoneNumber: u.PhoneNumber,
+		FullName:    u.FullName,
+		Password:    u.Password,
+	})
+	assert.ErrorIs(t, err, ErrDuplicatePhone)
+
+	// test 4 NewRepository selects the implementation by scheme
+	memoryRepo, err := NewRepository(NewRepositoryOptions{Dsn: "sqlite::memory:"})
+	assert.NoError(t, err)
+	assert.IsType(t, &SQLiteRepository{}, memoryRepo)
+}
+
+func TestEncryptedSQLiteIdempotencyKey(t *testing.T) {
+	provider, err := encryption.ParseKeyFile(strings.NewReader("test MDEyMzQ1Njc4OWFiY2RlZjAxMjM0NTY3ODlhYmNkZWY=\n"))
+	require.NoError(t, err)
+	cipher, err := encryption.NewCipher(provider, []byte("0123456789abcdef0123456789abcdef"))
+	require.NoError(t, err)
+
+	repo, err := NewSQLiteRepository(NewRepositoryOptions{Dsn: "sqlite://" + t.TempDir() + "/users.db", Cipher: cipher})
+	require.NoError(t, err)
+	defer repo.Db.Close()
+
+	ctx := context.Background()
+	body := `{"id":1,"full_name":"` + u.FullName + `","phone_number":"` + u.PhoneNumber + `"}`
+	reserve := ReserveIdempotencyKeyInput{Caller: "anonymous", Key: "key", Fingerprint: "fingerprint"}
+
+	out, err := repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	require.True(t, out.Reserved)
+	require.NoError(t, repo.CompleteIdempotencyKey(ctx, CompleteIdempotencyKeyInput{
+		Caller:     reserve.Caller,
+		Key:        reserve.Key,
+		StatusCode: 201,
+		Body:       []byte(body),
+	}))
+
+	// test 1 the stored response holds no personal data
+	var stored []byte
+	require.NoError(t, repo.Db.QueryRow("SELECT body FROM idempotency_keys WHERE idempotency_key = ?", reserve.Key).Scan(&stored))
+	assert.NotContains(t, string(stored), u.FullName)
+	assert.NotContains(t, string(stored), u.PhoneNumber)
+
+	// test 2 the response is replayed decrypted
+	out, err = repo.ReserveIdempotencyKey(ctx, reserve)
+	require.NoError(t, err)
+	assert.False(t, out.Reserved)
+	assert.Equal(t, body, string(out.Record.Body))
+
+	// test 3 a response copied to another key fails to decrypt
+	_, err = repo.Db.Exec("UPDATE idempotency_keys SET idempotency_key = 'other' WHERE idempotency_key = ?", reserve.Key)
+	require.NoError(t, err)
+	reserve.Key = "other"
+	_, err = repo.ReserveIdempotencyKey(ctx, reserve)
+	assert.Error(t, err)
+}
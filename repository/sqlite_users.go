@@ -0,0 +1,360 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+func (r *SQLiteRepository) InsertUser(ctx context.Context, input InsertUserInput) (output InsertUserOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		// The personal data is encrypted bound to the id, it is only set once
+		// the row is inserted
+		res, err := txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO users (phone_number, phone_number_index, full_name, password, preferred_language, updated_at) VALUES ('', ?, '', ?, ?, ?)",
+			r.cipher().BlindIndex(input.PhoneNumber),
+			input.Password,
+			input.PreferredLanguage,
+			sqliteNow(),
+		)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+		output.UserID = int32(id)
+
+		phoneNumber, fullName := input.PhoneNumber, input.FullName
+		if err = encryptUserFields(ctx, txRepo.cipher(), output.UserID, &phoneNumber, &fullName); err != nil {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(ctx, "UPDATE users SET phone_number = ?, full_name = ? WHERE id = ?", phoneNumber, fullName, output.UserID)
+		if err != nil {
+			return err
+		}
+
+		return txRepo.insertOutboxEvent(ctx, model.EventUserRegistered, output.UserID, model.UserEventPayload{UserID: output.UserID})
+	})
+	if err != nil {
+		return InsertUserOutput{}, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) GetLoginData(ctx context.Context, input GetLoginDataInput) (output GetLoginDataOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	err = r.conn().QueryRowContext(
+		ctx,
+		"SELECT id, full_name, password, preferred_language, token_version FROM users WHERE phone_number_index = ?",
+		r.cipher().BlindIndex(input.PhoneNumber),
+	).Scan(&output.UserID, &output.FullName, &output.HashedPassword, &output.PreferredLanguage, &output.TokenVersion)
+	if err != nil {
+		return
+	}
+
+	err = decryptUserFields(ctx, r.cipher(), output.UserID, nil, &output.FullName)
+	return
+}
+
+func (r *SQLiteRepository) UpdateSuccessfulLogin(ctx context.Context, input UpdateSuccessfulLoginInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"UPDATE users SET successful_login = successful_login + 1, updated_at = ? WHERE phone_number_index = ?",
+		sqliteNow(),
+		r.cipher().BlindIndex(input.PhoneNumber),
+	)
+	return
+}
+
+func (r *SQLiteRepository) UpdateUserData(ctx context.Context, input UpdateUserDataInput) (out UpdateUserDataOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	if input.Patch.IsEmpty() {
+		err = fmt.Errorf("update user data is empty")
+		return
+	}
+
+	var (
+		sets []string
+		args []interface{}
+	)
+	if input.Patch.FullName != nil {
+		fullName := *input.Patch.FullName
+		if err = encryptUserFields(ctx, r.cipher(), input.UserID, nil, &fullName); err != nil {
+			return
+		}
+		sets = append(sets, "full_name = ?")
+		args = append(args, fullName)
+	}
+	if input.Patch.PhoneNumber != nil {
+		phoneNumber := *input.Patch.PhoneNumber
+		if err = encryptUserFields(ctx, r.cipher(), input.UserID, &phoneNumber, nil); err != nil {
+			return
+		}
+		sets = append(sets, "phone_number = ?", "phone_number_index = ?")
+		args = append(args, phoneNumber, r.cipher().BlindIndex(*input.Patch.PhoneNumber))
+	}
+	if input.Patch.PreferredLanguage != nil {
+		sets = append(sets, "preferred_language = ?")
+		args = append(args, *input.Patch.PreferredLanguage)
+	}
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		var old model.User
+		err := txRepo.conn().QueryRowContext(
+			ctx,
+			"SELECT full_name, phone_number, version FROM users WHERE id = ?",
+			input.UserID,
+		).Scan(&old.FullName, &old.PhoneNumber, &old.Version)
+		if err != nil {
+			return err
+		}
+		if err = decryptUserFields(ctx, txRepo.cipher(), input.UserID, &old.PhoneNumber, &old.FullName); err != nil {
+			return err
+		}
+
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != old.Version {
+			return ErrVersionConflict
+		}
+
+		// The version is checked again in case the transaction does not hold
+		// the write lock, e.g. when the DSN overrides _txlock
+		res, err := txRepo.conn().ExecContext(
+			ctx,
+			"UPDATE users SET "+strings.Join(sets, ", ")+", version = version + 1, updated_at = ? WHERE id = ? AND version = ?",
+			append(args, sqliteNow(), input.UserID, old.Version)...,
+		)
+		if err != nil {
+			return err
+		}
+		if err = requireAffected(res); errors.Is(err, ErrNotFound) {
+			return ErrVersionConflict
+		} else if err != nil {
+			return err
+		}
+		out.Version = old.Version + 1
+
+		if fullName := input.Patch.FullName; fullName != nil && *fullName != old.FullName {
+			err = txRepo.insertOutboxEvent(ctx, model.EventFullNameChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		if phoneNumber := input.Patch.PhoneNumber; phoneNumber != nil && *phoneNumber != old.PhoneNumber {
+			err = txRepo.insertOutboxEvent(ctx, model.EventPhoneNumberChanged, input.UserID, model.UserEventPayload{UserID: input.UserID})
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return UpdateUserDataOutput{}, err
+	}
+	return
+}
+
+func (r *SQLiteRepository) GetTokenVersion(ctx context.Context, input GetTokenVersionInput) (tokenVersion int32, err error) {
+	defer translateSQLiteError(&err)
+
+	err = r.conn().QueryRowContext(ctx, "SELECT token_version FROM users WHERE id = ?", input.UserID).Scan(&tokenVersion)
+	return
+}
+
+func (r *SQLiteRepository) GetUserDataByUserID(ctx context.Context, input GetUserDataByUserIDInput) (out model.User, err error) {
+	defer translateSQLiteError(&err)
+
+	err = r.conn().QueryRowContext(
+		ctx,
+		"SELECT id, full_name, phone_number, successful_login, version, preferred_language, token_version FROM users WHERE id = ?",
+		input.UserID,
+	).Scan(&out.UserID, &out.FullName, &out.PhoneNumber, &out.SuccesfulLogin, &out.Version, &out.PreferredLanguage, &out.TokenVersion)
+	if err != nil {
+		return
+	}
+
+	err = decryptUserFields(ctx, r.cipher(), out.UserID, &out.PhoneNumber, &out.FullName)
+	return
+}
+
+// GetPasswordHistory returns the password hashes of the user, the current one
+// first and then the previous ones, newest first.
+func (r *SQLiteRepository) GetPasswordHistory(ctx context.Context, input GetPasswordHistoryInput) (hashes []string, err error) {
+	defer translateSQLiteError(&err)
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		"SELECT password FROM ("+
+			"SELECT password, 1 AS is_current, 0 AS history_id FROM users WHERE id = ?1 AND password IS NOT NULL "+
+			"UNION ALL SELECT password, 0, id FROM password_history WHERE user_id = ?1"+
+			") ORDER BY is_current DESC, history_id DESC LIMIT ?2",
+		input.UserID,
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hash string
+		if err = rows.Scan(&hash); err != nil {
+			return nil, err
+		}
+		hashes = append(hashes, hash)
+	}
+
+	return hashes, rows.Err()
+}
+
+// UpdatePassword replaces the password of the user and increments its token
+// version, see Repository.UpdatePassword.
+func (r *SQLiteRepository) UpdatePassword(ctx context.Context, input UpdatePasswordInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	return r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		now := sqliteNow()
+
+		var tokenVersion int32
+		err := txRepo.conn().QueryRowContext(ctx, "SELECT token_version FROM users WHERE id = ?", input.UserID).Scan(&tokenVersion)
+		if err != nil {
+			return err
+		}
+		if input.ExpectedTokenVersion != nil && *input.ExpectedTokenVersion != tokenVersion {
+			return ErrVersionConflict
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"INSERT INTO password_history (user_id, password, created_at) SELECT id, password, ? FROM users WHERE id = ? AND password IS NOT NULL",
+			now,
+			input.UserID,
+		)
+		if err != nil {
+			return err
+		}
+
+		// The token version is checked again in case the transaction does not
+		// hold the write lock, e.g. when the DSN overrides _txlock
+		res, err := txRepo.conn().ExecContext(
+			ctx,
+			"UPDATE users SET password = ?, token_version = token_version + 1, updated_at = ? WHERE id = ? AND token_version = ?",
+			input.Password,
+			now,
+			input.UserID,
+			tokenVersion,
+		)
+		if err != nil {
+			return err
+		}
+		if err = requireAffected(res); errors.Is(err, ErrNotFound) {
+			return ErrVersionConflict
+		} else if err != nil {
+			return err
+		}
+
+		_, err = txRepo.conn().ExecContext(
+			ctx,
+			"DELETE FROM password_history WHERE user_id = ?1 AND id NOT IN (SELECT id FROM password_history WHERE user_id = ?1 ORDER BY id DESC LIMIT ?2)",
+			input.UserID,
+			input.KeepHistory,
+		)
+		return err
+	})
+}
+
+// RehashPassword replaces the hash of the current password of the user, see
+// Repository.RehashPassword.
+func (r *SQLiteRepository) RehashPassword(ctx context.Context, input RehashPasswordInput) (err error) {
+	defer translateSQLiteError(&err)
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE users SET password = ?, updated_at = ? WHERE id = ? AND password = ?",
+		input.Password,
+		sqliteNow(),
+		input.UserID,
+		input.PreviousPassword,
+	)
+	if err != nil {
+		return err
+	}
+	return requireAffected(res)
+}
+
+// ReencryptUsers encrypts again the personal data of the users after AfterID,
+// see Repository.ReencryptUsers.
+func (r *SQLiteRepository) ReencryptUsers(ctx context.Context, input ReencryptUsersInput) (out ReencryptUsersOutput, err error) {
+	defer translateSQLiteError(&err)
+
+	err = r.inTx(ctx, func(txRepo *SQLiteRepository) error {
+		rows, err := txRepo.conn().QueryContext(
+			ctx,
+			"SELECT id, phone_number, full_name FROM users WHERE id > ? ORDER BY id LIMIT ?",
+			input.AfterID,
+			input.Limit,
+		)
+		if err != nil {
+			return err
+		}
+
+		var users []model.User
+		for rows.Next() {
+			var user model.User
+			if err := rows.Scan(&user.UserID, &user.PhoneNumber, &user.FullName); err != nil {
+				rows.Close()
+				return err
+			}
+			users = append(users, user)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		out = ReencryptUsersOutput{}
+		for _, user := range users {
+			out.LastID = user.UserID
+			phoneNumberIndex, ok, err := reencryptUser(ctx, txRepo.cipher(), &user)
+			if err != nil {
+				return err
+			}
+			if !ok {
+				continue
+			}
+
+			_, err = txRepo.conn().ExecContext(
+				ctx,
+				"UPDATE users SET phone_number = ?, phone_number_index = ?, full_name = ? WHERE id = ?",
+				user.PhoneNumber,
+				phoneNumberIndex,
+				user.FullName,
+				user.UserID,
+			)
+			if err != nil {
+				return err
+			}
+			out.Reencrypted++
+		}
+		return nil
+	})
+	if err != nil {
+		return ReencryptUsersOutput{}, err
+	}
+	return out, nil
+}
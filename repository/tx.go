@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	defaultTxMaxRetries = 3
+	txRetryBackoff      = 20 * time.Millisecond
+)
+
+type txIsolationContextKey struct{}
+
+// WithTxIsolation overrides the isolation level of the transactions started by
+// WithTx with the returned context.
+func WithTxIsolation(ctx context.Context, level sql.IsolationLevel) context.Context {
+	return context.WithValue(ctx, txIsolationContextKey{}, level)
+}
+
+// ParseIsolationLevel converts names such as "serializable" or "repeatable read"
+// into a sql.IsolationLevel. An empty name selects the database default.
+func ParseIsolationLevel(name string) (sql.IsolationLevel, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "", "default":
+		return sql.LevelDefault, nil
+	case "read committed":
+		return sql.LevelReadCommitted, nil
+	case "repeatable read":
+		return sql.LevelRepeatableRead, nil
+	case "serializable":
+		return sql.LevelSerializable, nil
+	default:
+		return sql.LevelDefault, fmt.Errorf("unknown isolation level %q", name)
+	}
+}
+
+// WithTx runs fn with a repository whose calls all go through one transaction,
+// committed when fn returns nil and rolled back otherwise.
+//
+// Serialization failures and deadlocks roll the transaction back and run fn
+// again, up to TxMaxRetries times, so fn must not have side effects outside the
+// repository. Calling WithTx on the repository given to fn runs the nested fn
+// in a savepoint: its failure only undoes its own changes.
+func (r *Repository) WithTx(ctx context.Context, fn func(RepositoryInterface) error) (err error) {
+	defer translatePostgresError(&err)
+
+	if r.tx != nil {
+		return r.withSavepoint(ctx, fn)
+	}
+
+	return retryTx(ctx, r.TxMaxRetries, isRetryableTxError, func() error {
+		return r.inTx(ctx, func(txRepo *Repository) error {
+			return fn(txRepo)
+		})
+	})
+}
+
+// retryTx runs tx until it returns an error that is not retryable, at most
+// maxRetries more times after the first attempt, or defaultTxMaxRetries when
+// maxRetries is not positive.
+func retryTx(ctx context.Context, maxRetries int, retryable func(error) bool, tx func() error) error {
+	if maxRetries <= 0 {
+		maxRetries = defaultTxMaxRetries
+	}
+
+	var err error
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return err
+			case <-time.After(time.Duration(attempt) * txRetryBackoff):
+			}
+		}
+
+		err = tx()
+		if !retryable(err) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (r *Repository) withSavepoint(ctx context.Context, fn func(RepositoryInterface) error) (err error) {
+	nested := *r
+	nested.txDepth++
+	savepoint := fmt.Sprintf("repository_tx_%d", nested.txDepth)
+
+	if _, err = r.tx.ExecContext(ctx, "SAVEPOINT "+savepoint); err != nil {
+		return
+	}
+
+	if err = fn(&nested); err != nil {
+		if _, rollbackErr := r.tx.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+savepoint); rollbackErr != nil {
+			return errors.Join(err, rollbackErr)
+		}
+		return
+	}
+
+	_, err = r.tx.ExecContext(ctx, "RELEASE SAVEPOINT "+savepoint)
+	return
+}
+
+// inTx runs fn with a repository bound to a transaction which is committed when
+// fn succeeds and rolled back otherwise. When the repository is already bound
+// to a transaction fn joins it.
+func (r *Repository) inTx(ctx context.Context, fn func(txRepo *Repository) error) (err error) {
+	if r.tx != nil {
+		return fn(r)
+	}
+
+	isolation := r.TxIsolation
+	if level, ok := ctx.Value(txIsolationContextKey{}).(sql.IsolationLevel); ok {
+		isolation = level
+	}
+
+	tx, err := r.Db.BeginTx(ctx, &sql.TxOptions{Isolation: isolation})
+	if err != nil {
+		return
+	}
+
+	defer func() {
+		if err != nil {
+			tx.Rollback()
+		}
+	}()
+
+	txRepo := *r
+	txRepo.tx = tx
+
+	if err = fn(&txRepo); err != nil {
+		return
+	}
+
+	return tx.Commit()
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithTx(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE users SET successful_login = successful_login \\+ 1, updated_at = NOW\\(\\) WHERE phone_number_index = \\$1"
+	updateSuccessfulLogin := func(txRepo RepositoryInterface) error {
+		return txRepo.UpdateSuccessfulLogin(context.Background(), UpdateSuccessfulLoginInput{
+			PhoneNumber: u.PhoneNumber,
+		})
+	}
+
+	// test 1 commit
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err := repo.WithTx(context.Background(), updateSuccessfulLogin)
+	assert.NoError(t, err)
+
+	// test 2 rollback on error
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectRollback()
+
+	err = repo.WithTx(context.Background(), func(txRepo RepositoryInterface) error {
+		if err := updateSuccessfulLogin(txRepo); err != nil {
+			return err
+		}
+		return errors.New("error")
+	})
+	assert.Error(t, err)
+
+	// test 3 retry on serialization failure
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnError(&pgconn.PgError{Code: "40001"})
+	mock.ExpectRollback()
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectCommit()
+
+	err = repo.WithTx(context.Background(), updateSuccessfulLogin)
+	assert.NoError(t, err)
+
+	// test 4 give up after max retries
+	repo.TxMaxRetries = 1
+	for i := 0; i < 2; i++ {
+		mock.ExpectBegin()
+		mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnError(&pgconn.PgError{Code: "40P01"})
+		mock.ExpectRollback()
+	}
+
+	err = repo.WithTx(context.Background(), updateSuccessfulLogin)
+	assert.Error(t, err)
+
+	// test 5 isolation level from context
+	mock.ExpectBegin()
+	mock.ExpectCommit()
+
+	ctx := WithTxIsolation(context.Background(), sql.LevelSerializable)
+	err = repo.WithTx(ctx, func(txRepo RepositoryInterface) error { return nil })
+	assert.NoError(t, err)
+
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestWithTxNested(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE users SET successful_login = successful_login \\+ 1, updated_at = NOW\\(\\) WHERE phone_number_index = \\$1"
+
+	// nested failure is rolled back to its savepoint, the outer transaction commits
+	mock.ExpectBegin()
+	mock.ExpectExec(query).WithArgs(u.PhoneNumber).WillReturnResult(sqlmock.NewResult(0, 1))
+	mock.ExpectExec("SAVEPOINT repository_tx_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec(query).WithArgs("+620000000000").WillReturnError(sql.ErrConnDone)
+	mock.ExpectExec("ROLLBACK TO SAVEPOINT repository_tx_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("SAVEPOINT repository_tx_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectExec("RELEASE SAVEPOINT repository_tx_1").WillReturnResult(sqlmock.NewResult(0, 0))
+	mock.ExpectCommit()
+
+	err := repo.WithTx(context.Background(), func(txRepo RepositoryInterface) error {
+		err := txRepo.UpdateSuccessfulLogin(context.Background(), UpdateSuccessfulLoginInput{PhoneNumber: u.PhoneNumber})
+		if err != nil {
+			return err
+		}
+
+		nestedErr := txRepo.WithTx(context.Background(), func(nestedRepo RepositoryInterface) error {
+			return nestedRepo.UpdateSuccessfulLogin(context.Background(), UpdateSuccessfulLoginInput{PhoneNumber: "+620000000000"})
+		})
+		assert.Error(t, nestedErr)
+
+		return txRepo.WithTx(context.Background(), func(nestedRepo RepositoryInterface) error { return nil })
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
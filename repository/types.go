@@ -1,6 +1,12 @@
 // This file contains types that are used in the repository layer.
 package repository
 
+import (
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
 type GetTestByIdInput struct {
 	Id string
 }
@@ -10,9 +16,10 @@ type GetTestByIdOutput struct {
 }
 
 type InsertUserInput struct {
-	PhoneNumber string
-	FullName    string
-	Password    string
+	PhoneNumber       string
+	FullName          string
+	Password          string
+	PreferredLanguage string
 }
 
 type InsertUserOutput struct {
@@ -24,20 +31,230 @@ type GetLoginDataInput struct {
 }
 
 type GetLoginDataOutput struct {
-	UserID         int32
-	FullName       string
-	HashedPassword string
+	UserID            int32
+	FullName          string
+	HashedPassword    string
+	PreferredLanguage string
+	TokenVersion      int32
 }
 
 type UpdateSuccessfulLoginInput struct {
 	PhoneNumber string
+	// UserID is the user of the phone number, whose cached profile is
+	// invalidated.
+	UserID int32
+}
+
+// UserPatch is a partial update of a user. Nil fields are left untouched; an
+// empty PreferredLanguage clears the preference.
+type UserPatch struct {
+	FullName          *string
+	PhoneNumber       *string
+	PreferredLanguage *string
+}
+
+// IsEmpty reports whether the patch changes nothing.
+func (p UserPatch) IsEmpty() bool {
+	return p.FullName == nil && p.PhoneNumber == nil && p.PreferredLanguage == nil
+}
+
+// Fields returns the column names set by the patch in a stable order.
+func (p UserPatch) Fields() (fields []string) {
+	if p.FullName != nil {
+		fields = append(fields, "full_name")
+	}
+	if p.PhoneNumber != nil {
+		fields = append(fields, "phone_number")
+	}
+	if p.PreferredLanguage != nil {
+		fields = append(fields, "preferred_language")
+	}
+	return
 }
 
 type UpdateUserDataInput struct {
 	UserID int32
-	Data   map[string]string
+	Patch  UserPatch
+	// ExpectedVersion turns the update into a compare-and-swap: it only
+	// applies when the stored version still matches. Nil updates
+	// unconditionally.
+	ExpectedVersion *int32
+}
+
+type UpdateUserDataOutput struct {
+	Version int32
+}
+
+type GetPasswordHistoryInput struct {
+	UserID int32
+	Limit  int
+}
+
+type UpdatePasswordInput struct {
+	UserID int32
+	// Password is the hash of the new password.
+	Password    string
+	KeepHistory int
+	// ExpectedTokenVersion turns the update into a compare-and-swap: it only
+	// applies when the stored token version still matches, i.e. the password
+	// has not been changed since it was checked. Nil updates unconditionally.
+	ExpectedTokenVersion *int32
+}
+
+type RehashPasswordInput struct {
+	UserID int32
+	// Password is the new hash and PreviousPassword the hash it replaces.
+	Password         string
+	PreviousPassword string
 }
 
 type GetUserDataByUserIDInput struct {
 	UserID int32
 }
+
+type GetTokenVersionInput struct {
+	UserID int32
+}
+
+type InsertAuditEventInput struct {
+	EventType string
+	UserID    int32
+	ActorID   int32
+	Metadata  map[string]string
+	IPAddress string
+	RequestID string
+}
+
+type InsertAuditEventOutput struct {
+	ID   int64
+	Hash string
+}
+
+type GetAuditEventsInput struct {
+	UserID    int32
+	EventType string
+	AfterID   int64
+	Limit     int
+}
+
+type ClaimOutboxEventsInput struct {
+	Limit int
+	Lease time.Duration
+}
+
+type MarkOutboxEventPublishedInput struct {
+	ID int64
+}
+
+// DeletePublishedOutboxEventsInput selects the events published before
+// Before, up to Limit of them.
+type DeletePublishedOutboxEventsInput struct {
+	Before time.Time
+	Limit  int
+}
+
+type MarkOutboxEventFailedInput struct {
+	ID    int64
+	Error string
+	// NextAttemptAt is when the event is due again. Dead events are never
+	// claimed again.
+	NextAttemptAt time.Time
+	Dead          bool
+}
+
+type InsertWebhookSubscriptionInput struct {
+	URL        string
+	EventTypes []string
+	Secret     string
+}
+
+type GetWebhookSubscriptionsInput struct {
+	ActiveOnly bool
+}
+
+type DeactivateWebhookSubscriptionInput struct {
+	ID int32
+}
+
+type InsertWebhookDeliveriesInput struct {
+	Event           model.OutboxEvent
+	SubscriptionIDs []int32
+}
+
+type ClaimWebhookDeliveriesInput struct {
+	Limit int
+	Lease time.Duration
+}
+
+type UpdateWebhookDeliveryInput struct {
+	ID             int64
+	Status         string
+	Attempts       int32
+	NextAttemptAt  time.Time
+	LastStatusCode int32
+	LastError      string
+}
+
+type GetWebhookDeliveriesInput struct {
+	SubscriptionID int32
+	Status         string
+	AfterID        int64
+	Limit          int
+}
+
+type RedeliverWebhookDeliveryInput struct {
+	ID int64
+}
+
+// DeleteDeliveredWebhookDeliveriesInput selects the deliveries delivered
+// before Before, up to Limit of them.
+type DeleteDeliveredWebhookDeliveriesInput struct {
+	Before time.Time
+	Limit  int
+}
+
+type ReserveIdempotencyKeyInput struct {
+	Caller      string
+	Key         string
+	Fingerprint string
+	// TTL is how long the record is kept, 24h when zero. Lease is how long
+	// the first request holds the key before a retry may take it over, 1m
+	// when zero.
+	TTL   time.Duration
+	Lease time.Duration
+}
+
+type ReserveIdempotencyKeyOutput struct {
+	// Reserved is true when the caller holds the key and must handle the
+	// request. Record is the record holding the key otherwise.
+	Reserved bool
+	Record   model.IdempotencyRecord
+}
+
+type CompleteIdempotencyKeyInput struct {
+	Caller     string
+	Key        string
+	StatusCode int32
+	Header     map[string]string
+	Body       []byte
+}
+
+type ReleaseIdempotencyKeyInput struct {
+	Caller string
+	Key    string
+}
+
+type DeleteExpiredIdempotencyKeysInput struct {
+	Limit int
+}
+
+type ReencryptUsersInput struct {
+	AfterID int32
+	Limit   int
+}
+
+type ReencryptUsersOutput struct {
+	// LastID is the last user read, zero when there were none.
+	LastID      int32
+	Reencrypted int
+}
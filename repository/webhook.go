@@ -0,0 +1,312 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+)
+
+const (
+	defaultWebhookLease           = time.Minute
+	defaultWebhookDeliveriesLimit = 100
+)
+
+const webhookDeliveryColumns = "id, subscription_id, event_id, event_type, payload, status, attempts, next_attempt_at, last_status_code, last_error, created_at, updated_at"
+
+func (r *Repository) InsertWebhookSubscription(ctx context.Context, input InsertWebhookSubscriptionInput) (output model.WebhookSubscription, err error) {
+	defer translatePostgresError(&err)
+
+	eventTypes, err := json.Marshal(input.EventTypes)
+	if err != nil {
+		return
+	}
+
+	output = model.WebhookSubscription{
+		URL:        input.URL,
+		EventTypes: input.EventTypes,
+		Secret:     input.Secret,
+		Active:     true,
+	}
+
+	err = r.conn().QueryRowContext(
+		ctx,
+		"INSERT INTO webhook_subscriptions (url, event_types, secret) VALUES ($1, $2, $3) RETURNING id, created_at",
+		input.URL,
+		eventTypes,
+		input.Secret,
+	).Scan(&output.ID, &output.CreatedAt)
+	if err != nil {
+		return model.WebhookSubscription{}, err
+	}
+	return
+}
+
+func (r *Repository) GetWebhookSubscriptions(ctx context.Context, input GetWebhookSubscriptionsInput) (subscriptions []model.WebhookSubscription, err error) {
+	defer translatePostgresError(&err)
+
+	query := "SELECT id, url, event_types, secret, active, created_at FROM webhook_subscriptions"
+	if input.ActiveOnly {
+		query += " WHERE active"
+	}
+	query += " ORDER BY id"
+
+	rows, err := r.conn().QueryContext(ctx, query)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var (
+			subscription model.WebhookSubscription
+			eventTypes   []byte
+		)
+
+		err = rows.Scan(&subscription.ID, &subscription.URL, &eventTypes, &subscription.Secret, &subscription.Active, &subscription.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = json.Unmarshal(eventTypes, &subscription.EventTypes); err != nil {
+			return nil, err
+		}
+
+		subscriptions = append(subscriptions, subscription)
+	}
+
+	return subscriptions, rows.Err()
+}
+
+// DeactivateWebhookSubscription stops new deliveries to the subscription but keeps
+// its delivery log. It returns ErrNotFound when the subscription does not exist.
+func (r *Repository) DeactivateWebhookSubscription(ctx context.Context, input DeactivateWebhookSubscriptionInput) (err error) {
+	defer translatePostgresError(&err)
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		"UPDATE webhook_subscriptions SET active = FALSE WHERE id = $1",
+		input.ID,
+	)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+// InsertWebhookDeliveries schedules the event for every given subscription.
+// Events relayed more than once are only scheduled once per subscription.
+func (r *Repository) InsertWebhookDeliveries(ctx context.Context, input InsertWebhookDeliveriesInput) (err error) {
+	defer translatePostgresError(&err)
+
+	if len(input.SubscriptionIDs) == 0 {
+		return nil
+	}
+
+	var (
+		values = []string{}
+		args   = []interface{}{input.Event.ID, input.Event.EventType, []byte(input.Event.Payload)}
+	)
+
+	for _, subscriptionID := range input.SubscriptionIDs {
+		args = append(args, subscriptionID)
+		values = append(values, fmt.Sprintf("($%d, $1, $2, $3)", len(args)))
+	}
+
+	_, err = r.conn().ExecContext(
+		ctx,
+		"INSERT INTO webhook_deliveries (subscription_id, event_id, event_type, payload) VALUES "+strings.Join(values, ", ")+" ON CONFLICT (subscription_id, event_id) DO NOTHING",
+		args...,
+	)
+	return
+}
+
+// ClaimWebhookDeliveries leases a batch of due deliveries together with the URL
+// and secret of their subscription. A delivery which is not updated before the
+// lease expires becomes due again. The deliveries of deactivated
+// subscriptions are never claimed.
+func (r *Repository) ClaimWebhookDeliveries(ctx context.Context, input ClaimWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Lease <= 0 {
+		input.Lease = defaultWebhookLease
+	}
+
+	rows, err := r.conn().QueryContext(
+		ctx,
+		`WITH claimed AS (
+			UPDATE webhook_deliveries SET next_attempt_at = NOW() + $2 * INTERVAL '1 millisecond'
+			WHERE id IN (
+				SELECT d.id FROM webhook_deliveries d JOIN webhook_subscriptions s ON s.id = d.subscription_id
+				WHERE d.status = 'pending' AND d.next_attempt_at <= NOW() AND s.active
+				ORDER BY d.next_attempt_at LIMIT $1 FOR UPDATE OF d SKIP LOCKED
+			)
+			RETURNING `+webhookDeliveryColumns+`
+		)
+		SELECT claimed.*, s.url, s.secret FROM claimed JOIN webhook_subscriptions s ON s.id = claimed.subscription_id`,
+		input.Limit,
+		input.Lease.Milliseconds(),
+	)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+
+		err = rows.Scan(append(webhookDeliveryFields(&delivery), &delivery.URL, &delivery.Secret)...)
+		if err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *Repository) UpdateWebhookDelivery(ctx context.Context, input UpdateWebhookDeliveryInput) (err error) {
+	defer translatePostgresError(&err)
+
+	query, args, err := newUpdateBuilder("webhook_deliveries").
+		Set("status", input.Status).
+		Set("attempts", input.Attempts).
+		Set("next_attempt_at", input.NextAttemptAt).
+		Set("last_status_code", input.LastStatusCode).
+		Set("last_error", input.LastError).
+		Where("id", input.ID).
+		Build()
+	if err != nil {
+		return
+	}
+
+	_, err = r.conn().ExecContext(ctx, query, args...)
+	return
+}
+
+func (r *Repository) GetWebhookDeliveries(ctx context.Context, input GetWebhookDeliveriesInput) (deliveries []model.WebhookDelivery, err error) {
+	defer translatePostgresError(&err)
+
+	var (
+		conditions = []string{"subscription_id = $1", "id > $2"}
+		args       = []interface{}{input.SubscriptionID, input.AfterID}
+	)
+
+	if input.Status != "" {
+		args = append(args, input.Status)
+		conditions = append(conditions, fmt.Sprintf("status = $%d", len(args)))
+	}
+
+	if input.Limit <= 0 {
+		input.Limit = defaultWebhookDeliveriesLimit
+	}
+	args = append(args, input.Limit)
+
+	query := fmt.Sprintf(
+		"SELECT %s FROM webhook_deliveries WHERE %s ORDER BY id LIMIT $%d",
+		webhookDeliveryColumns,
+		strings.Join(conditions, " AND "),
+		len(args),
+	)
+
+	rows, err := r.conn().QueryContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var delivery model.WebhookDelivery
+
+		if err = rows.Scan(webhookDeliveryFields(&delivery)...); err != nil {
+			return nil, err
+		}
+
+		deliveries = append(deliveries, delivery)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RedeliverWebhookDelivery makes the delivery due immediately, including dead
+// ones. It returns ErrNotFound when the delivery does not exist.
+func (r *Repository) RedeliverWebhookDelivery(ctx context.Context, input RedeliverWebhookDeliveryInput) (err error) {
+	defer translatePostgresError(&err)
+
+	query, args, err := newUpdateBuilder("webhook_deliveries").
+		Set("status", model.WebhookDeliveryPending).
+		SetExpr("next_attempt_at", "NOW()").
+		Where("id", input.ID).
+		Build()
+	if err != nil {
+		return
+	}
+
+	res, err := r.conn().ExecContext(ctx, query, args...)
+	if err != nil {
+		return
+	}
+
+	return requireAffected(res)
+}
+
+// DeleteDeliveredWebhookDeliveries deletes up to input.Limit deliveries
+// delivered before input.Before, 1000 when not positive. Dead deliveries are
+// kept so they can be redelivered.
+func (r *Repository) DeleteDeliveredWebhookDeliveries(ctx context.Context, input DeleteDeliveredWebhookDeliveriesInput) (deleted int64, err error) {
+	defer translatePostgresError(&err)
+
+	if input.Limit <= 0 {
+		input.Limit = defaultPurgeLimit
+	}
+
+	res, err := r.conn().ExecContext(
+		ctx,
+		`DELETE FROM webhook_deliveries WHERE id IN (
+			SELECT id FROM webhook_deliveries WHERE status = $1 AND updated_at < $2 LIMIT $3
+		)`,
+		model.WebhookDeliveryDelivered,
+		input.Before,
+		input.Limit,
+	)
+	if err != nil {
+		return
+	}
+
+	return res.RowsAffected()
+}
+
+func webhookDeliveryFields(delivery *model.WebhookDelivery) []interface{} {
+	return []interface{}{
+		&delivery.ID,
+		&delivery.SubscriptionID,
+		&delivery.EventID,
+		&delivery.EventType,
+		&delivery.Payload,
+		&delivery.Status,
+		&delivery.Attempts,
+		&delivery.NextAttemptAt,
+		&delivery.LastStatusCode,
+		&delivery.LastError,
+		&delivery.CreatedAt,
+		&delivery.UpdatedAt,
+	}
+}
+
+func requireAffected(res sql.Result) error {
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if affected == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
@@ -0,0 +1,58 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/DATA-DOG/go-sqlmock"
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInsertWebhookDeliveries(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	event := model.OutboxEvent{
+		ID:        10,
+		EventType: model.EventUserRegistered,
+		Payload:   json.RawMessage(`{"user_id":1}`),
+	}
+
+	// test 1 one row per subscription
+	query := "INSERT INTO webhook_deliveries \\(subscription_id, event_id, event_type, payload\\) VALUES \\(\\$4, \\$1, \\$2, \\$3\\), \\(\\$5, \\$1, \\$2, \\$3\\) ON CONFLICT \\(subscription_id, event_id\\) DO NOTHING"
+	mock.ExpectExec(query).WithArgs(event.ID, event.EventType, []byte(event.Payload), int32(1), int32(2)).WillReturnResult(sqlmock.NewResult(0, 2))
+
+	err := repo.InsertWebhookDeliveries(context.Background(), InsertWebhookDeliveriesInput{
+		Event:           event,
+		SubscriptionIDs: []int32{1, 2},
+	})
+	assert.NoError(t, err)
+
+	// test 2 no subscription
+	err = repo.InsertWebhookDeliveries(context.Background(), InsertWebhookDeliveriesInput{
+		Event: event,
+	})
+	assert.NoError(t, err)
+	assert.NoError(t, mock.ExpectationsWereMet())
+}
+
+func TestRedeliverWebhookDelivery(t *testing.T) {
+	db, mock := NewMock()
+	repo := &Repository{Db: db}
+
+	query := "UPDATE webhook_deliveries SET status = \\$1, next_attempt_at = NOW\\(\\), updated_at = NOW\\(\\) WHERE id = \\$2"
+
+	// test 1 redeliver success
+	mock.ExpectExec(query).WithArgs(model.WebhookDeliveryPending, int64(1)).WillReturnResult(sqlmock.NewResult(0, 1))
+
+	err := repo.RedeliverWebhookDelivery(context.Background(), RedeliverWebhookDeliveryInput{ID: 1})
+	assert.NoError(t, err)
+
+	// test 2 delivery not found
+	mock.ExpectExec(query).WithArgs(model.WebhookDeliveryPending, int64(2)).WillReturnResult(sqlmock.NewResult(0, 0))
+
+	err = repo.RedeliverWebhookDelivery(context.Background(), RedeliverWebhookDeliveryInput{ID: 2})
+	assert.ErrorIs(t, err, ErrNotFound)
+}
@@ -0,0 +1,236 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+)
+
+const (
+	defaultBatchSize   = 50
+	defaultInterval    = 5 * time.Second
+	defaultTimeout     = 10 * time.Second
+	defaultMaxAttempts = 8
+	defaultBaseBackoff = 30 * time.Second
+	defaultMaxBackoff  = 6 * time.Hour
+
+	// maxErrorBodyLength bounds how much of a failed response is kept in the delivery log.
+	maxErrorBodyLength = 512
+)
+
+// Dispatcher schedules deliveries for new domain events and sends the due ones.
+type Dispatcher struct {
+	Repository  repository.RepositoryInterface
+	Client      *http.Client
+	Logger      *slog.Logger
+	BatchSize   int
+	Interval    time.Duration
+	MaxAttempts int32
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+	Now         func() time.Time
+}
+
+type NewDispatcherOptions struct {
+	Repository  repository.RepositoryInterface
+	Client      *http.Client
+	Logger      *slog.Logger
+	BatchSize   int
+	Interval    time.Duration
+	MaxAttempts int32
+	BaseBackoff time.Duration
+	MaxBackoff  time.Duration
+}
+
+func NewDispatcher(opts NewDispatcherOptions) *Dispatcher {
+	d := &Dispatcher{
+		Repository:  opts.Repository,
+		Client:      opts.Client,
+		Logger:      opts.Logger,
+		BatchSize:   opts.BatchSize,
+		Interval:    opts.Interval,
+		MaxAttempts: opts.MaxAttempts,
+		BaseBackoff: opts.BaseBackoff,
+		MaxBackoff:  opts.MaxBackoff,
+		Now:         time.Now,
+	}
+
+	if d.Client == nil {
+		d.Client = &http.Client{Timeout: defaultTimeout}
+	}
+	if d.Logger == nil {
+		d.Logger = slog.Default()
+	}
+	if d.BatchSize <= 0 {
+		d.BatchSize = defaultBatchSize
+	}
+	if d.Interval <= 0 {
+		d.Interval = defaultInterval
+	}
+	if d.MaxAttempts <= 0 {
+		d.MaxAttempts = defaultMaxAttempts
+	}
+	if d.BaseBackoff <= 0 {
+		d.BaseBackoff = defaultBaseBackoff
+	}
+	if d.MaxBackoff <= 0 {
+		d.MaxBackoff = defaultMaxBackoff
+	}
+
+	return d
+}
+
+// Enqueue schedules a delivery of the event to every active subscription
+// interested in it. Its signature matches outbox.Handler so it can be
+// subscribed to the in-process outbox publisher.
+func (d *Dispatcher) Enqueue(ctx context.Context, event model.OutboxEvent) error {
+	subscriptions, err := d.Repository.GetWebhookSubscriptions(ctx, repository.GetWebhookSubscriptionsInput{
+		ActiveOnly: true,
+	})
+	if err != nil {
+		return err
+	}
+
+	var subscriptionIDs []int32
+	for _, subscription := range subscriptions {
+		if subscription.Matches(event.EventType) {
+			subscriptionIDs = append(subscriptionIDs, subscription.ID)
+		}
+	}
+
+	return d.Repository.InsertWebhookDeliveries(ctx, repository.InsertWebhookDeliveriesInput{
+		Event:           event,
+		SubscriptionIDs: subscriptionIDs,
+	})
+}
+
+// Run sends due deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	for {
+		sent, err := d.DeliverOnce(ctx)
+		if err != nil {
+			d.Logger.ErrorContext(ctx, "deliver webhooks", "error", err)
+		}
+
+		if sent < d.BatchSize || err != nil {
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.Interval):
+			}
+		} else if ctx.Err() != nil {
+			return
+		}
+	}
+}
+
+// DeliverOnce claims one batch of due deliveries and sends them, returning the
+// number of deliveries attempted.
+func (d *Dispatcher) DeliverOnce(ctx context.Context) (int, error) {
+	deliveries, err := d.Repository.ClaimWebhookDeliveries(ctx, repository.ClaimWebhookDeliveriesInput{
+		Limit: d.BatchSize,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, delivery := range deliveries {
+		statusCode, sendErr := d.send(ctx, delivery)
+
+		update := repository.UpdateWebhookDeliveryInput{
+			ID:             delivery.ID,
+			Status:         model.WebhookDeliveryDelivered,
+			Attempts:       delivery.Attempts + 1,
+			NextAttemptAt:  d.Now(),
+			LastStatusCode: int32(statusCode),
+		}
+
+		if sendErr != nil {
+			update.LastError = sendErr.Error()
+			update.Status = model.WebhookDeliveryPending
+			update.NextAttemptAt = d.Now().Add(d.Backoff(update.Attempts))
+
+			if update.Attempts >= d.MaxAttempts {
+				update.Status = model.WebhookDeliveryDead
+			}
+
+			d.Logger.WarnContext(ctx, "webhook delivery failed",
+				"delivery_id", delivery.ID,
+				"subscription_id", delivery.SubscriptionID,
+				"attempts", update.Attempts,
+				"status", update.Status,
+				"error", sendErr,
+			)
+		}
+
+		if err := d.Repository.UpdateWebhookDelivery(ctx, update); err != nil {
+			return len(deliveries), err
+		}
+	}
+
+	return len(deliveries), nil
+}
+
+// Backoff returns the delay before the next attempt: BaseBackoff doubled after
+// every failed attempt, capped at MaxBackoff.
+func (d *Dispatcher) Backoff(attempts int32) time.Duration {
+	backoff := float64(d.BaseBackoff) * math.Pow(2, float64(attempts-1))
+	if backoff > float64(d.MaxBackoff) {
+		return d.MaxBackoff
+	}
+	return time.Duration(backoff)
+}
+
+type deliveryBody struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery model.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(deliveryBody{
+		ID:        delivery.EventID,
+		Type:      delivery.EventType,
+		CreatedAt: delivery.CreatedAt,
+		Data:      delivery.Payload,
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := d.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(HeaderDeliveryID, strconv.FormatInt(delivery.ID, 10))
+	req.Header.Set(HeaderEvent, delivery.EventType)
+	req.Header.Set(HeaderTimestamp, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(HeaderSignature, Sign(delivery.Secret, timestamp, body))
+
+	resp, err := d.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyLength))
+		return resp.StatusCode, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, respBody)
+	}
+
+	return resp.StatusCode, nil
+}
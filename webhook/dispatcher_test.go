@@ -0,0 +1,136 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/SawitProRecruitment/UserService/model"
+	"github.com/SawitProRecruitment/UserService/repository"
+	"github.com/SawitProRecruitment/UserService/repository/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func TestEnqueue(t *testing.T) {
+	repo := new(mocks.RepositoryInterface)
+	dispatcher := NewDispatcher(NewDispatcherOptions{Repository: repo})
+
+	event := model.OutboxEvent{ID: 10, EventType: model.EventPhoneNumberChanged}
+
+	repo.On("GetWebhookSubscriptions", mock.Anything, repository.GetWebhookSubscriptionsInput{ActiveOnly: true}).Return([]model.WebhookSubscription{
+		{ID: 1, EventTypes: []string{model.EventUserRegistered}},
+		{ID: 2, EventTypes: []string{model.EventPhoneNumberChanged}},
+		{ID: 3},
+	}, nil).Once()
+	repo.On("InsertWebhookDeliveries", mock.Anything, repository.InsertWebhookDeliveriesInput{
+		Event:           event,
+		SubscriptionIDs: []int32{2, 3},
+	}).Return(nil).Once()
+
+	assert.NoError(t, dispatcher.Enqueue(context.Background(), event))
+	repo.AssertExpectations(t)
+}
+
+func TestDeliverOnce(t *testing.T) {
+	now := time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC)
+	secret := "whsec_test"
+
+	var (
+		receivedBody   []byte
+		receivedHeader http.Header
+		statusCode     = http.StatusOK
+	)
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		receivedBody, _ = io.ReadAll(r.Body)
+		receivedHeader = r.Header.Clone()
+		w.WriteHeader(statusCode)
+	}))
+	defer receiver.Close()
+
+	repo := new(mocks.RepositoryInterface)
+	dispatcher := NewDispatcher(NewDispatcherOptions{
+		Repository:  repo,
+		MaxAttempts: 3,
+		BaseBackoff: time.Minute,
+	})
+	dispatcher.Now = func() time.Time { return now }
+
+	delivery := model.WebhookDelivery{
+		ID:        5,
+		EventID:   10,
+		EventType: model.EventUserRegistered,
+		Payload:   json.RawMessage(`{"user_id":1}`),
+		URL:       receiver.URL,
+		Secret:    secret,
+	}
+
+	// test 1 delivered with a valid signature
+	repo.On("ClaimWebhookDeliveries", mock.Anything, mock.Anything).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	repo.On("UpdateWebhookDelivery", mock.Anything, repository.UpdateWebhookDeliveryInput{
+		ID:             5,
+		Status:         model.WebhookDeliveryDelivered,
+		Attempts:       1,
+		NextAttemptAt:  now,
+		LastStatusCode: http.StatusOK,
+	}).Return(nil).Once()
+
+	sent, err := dispatcher.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, sent)
+	assert.Equal(t, "5", receivedHeader.Get(HeaderDeliveryID))
+	assert.Equal(t, model.EventUserRegistered, receivedHeader.Get(HeaderEvent))
+	assert.NoError(t, Verify(secret, receivedHeader.Get(HeaderSignature), receivedHeader.Get(HeaderTimestamp), receivedBody, time.Minute, now))
+	assert.ErrorIs(t, Verify("wrong", receivedHeader.Get(HeaderSignature), receivedHeader.Get(HeaderTimestamp), receivedBody, time.Minute, now), ErrInvalidSignature)
+	assert.JSONEq(t, `{"id":10,"type":"UserRegistered","created_at":"0001-01-01T00:00:00Z","data":{"user_id":1}}`, string(receivedBody))
+
+	// test 2 failure is retried with back-off
+	statusCode = http.StatusServiceUnavailable
+	delivery.Attempts = 1
+	repo.On("ClaimWebhookDeliveries", mock.Anything, mock.Anything).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	repo.On("UpdateWebhookDelivery", mock.Anything, mock.MatchedBy(func(in repository.UpdateWebhookDeliveryInput) bool {
+		return in.Status == model.WebhookDeliveryPending && in.Attempts == 2 && in.NextAttemptAt.Equal(now.Add(2*time.Minute)) && in.LastStatusCode == http.StatusServiceUnavailable
+	})).Return(nil).Once()
+
+	_, err = dispatcher.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+
+	// test 3 last attempt moves the delivery to dead
+	delivery.Attempts = 2
+	repo.On("ClaimWebhookDeliveries", mock.Anything, mock.Anything).Return([]model.WebhookDelivery{delivery}, nil).Once()
+	repo.On("UpdateWebhookDelivery", mock.Anything, mock.MatchedBy(func(in repository.UpdateWebhookDeliveryInput) bool {
+		return in.Status == model.WebhookDeliveryDead && in.Attempts == 3
+	})).Return(nil).Once()
+
+	_, err = dispatcher.DeliverOnce(context.Background())
+	assert.NoError(t, err)
+
+	repo.AssertExpectations(t)
+}
+
+func TestBackoff(t *testing.T) {
+	dispatcher := NewDispatcher(NewDispatcherOptions{
+		BaseBackoff: time.Second,
+		MaxBackoff:  time.Minute,
+	})
+
+	assert.Equal(t, time.Second, dispatcher.Backoff(1))
+	assert.Equal(t, 8*time.Second, dispatcher.Backoff(4))
+	assert.Equal(t, time.Minute, dispatcher.Backoff(10))
+}
+
+func TestVerifyExpiredTimestamp(t *testing.T) {
+	var (
+		now       = time.Now()
+		body      = []byte(`{}`)
+		timestamp = now.Add(-time.Hour).Unix()
+	)
+
+	err := Verify("secret", Sign("secret", timestamp, body), strconv.FormatInt(timestamp, 10), body, 5*time.Minute, now)
+	assert.ErrorIs(t, err, ErrExpiredTimestamp)
+}